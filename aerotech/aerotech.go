@@ -48,6 +48,28 @@ type Ensemble struct {
 	velocities map[string]float64
 
 	asyncMode *bool
+
+	// AxisNames is the list of axes on the controller, which cannot be
+	// queried over the ASCII interface and must be supplied by the user
+	AxisNames []string
+
+	// IOAxis is the axis whose drive the digital I/O is wired to.  Empty
+	// uses the first of AxisNames
+	IOAxis string
+
+	// DigitalInputs and DigitalOutputs are the number of digital lines of
+	// each direction.  Zero is DefaultDigitalLines
+	DigitalInputs, DigitalOutputs int
+
+	// BrakedAxes are the axes with brakes.  Enable releases their brake after
+	// enabling them, and Disable engages it before disabling them
+	BrakedAxes []string
+
+	// ProhibitedZones are regions moves may not enter or pass through
+	ProhibitedZones []ProhibitedZone
+
+	// debug receives the raw traffic, see SetDebug
+	debug io.Writer
 }
 
 // NewEnsemble returns a new Ensemble instance
@@ -62,6 +84,19 @@ func NewEnsemble(addr string, connectSerial bool) *Ensemble {
 		timeout:    300 * time.Second}
 }
 
+// SetDebug logs every message sent to and received from the controller to w,
+// with its terminator.  nil turns logging off
+func (e *Ensemble) SetDebug(w io.Writer) {
+	e.debug = w
+}
+
+// terminator wraps rw in the controller's terminators
+func (e *Ensemble) terminator(rw io.ReadWriter) comm.Terminator {
+	t := comm.NewTerminator(rw, Terminator, Terminator)
+	t.Debug = e.debug
+	return t
+}
+
 func (e *Ensemble) writeReadRaw(msg string) (response, error) {
 	/* this function works as follows:
 	Declare some outer scope error and trial counts,
@@ -93,7 +128,7 @@ func (e *Ensemble) writeReadRaw(msg string) (response, error) {
 			return resp, err
 		}
 		wrap, err = comm.NewTimeout(conn, e.timeout)
-		wrap = comm.NewTerminator(wrap, Terminator, Terminator)
+		wrap = e.terminator(wrap)
 		if err != nil {
 			// timeout unsupported, bail completely
 			return resp, err
@@ -155,7 +190,7 @@ func (e *Ensemble) writeReadRaw(msg string) (response, error) {
 					return resp, err
 				}
 				wrap, err = comm.NewTimeout(conn, e.timeout)
-				wrap = comm.NewTerminator(wrap, Terminator, Terminator)
+				wrap = e.terminator(wrap)
 				if err != nil {
 					// timeout unsupported, bail completely
 					return resp, err
@@ -205,11 +240,17 @@ func (e *Ensemble) gCodeWriteOnly(msg string, more ...string) error {
 
 // Enable commands the controller to enable an axis
 func (e *Ensemble) Enable(axis string) error {
+	if e.braked(axis) {
+		return e.enableBraked(axis)
+	}
 	return e.gCodeWriteOnly("ENABLE", axis)
 }
 
 // Disable commands the controller to disable an axis
 func (e *Ensemble) Disable(axis string) error {
+	if e.braked(axis) {
+		return e.disableBraked(axis)
+	}
 	return e.gCodeWriteOnly("DISABLE", axis)
 }
 
@@ -231,6 +272,29 @@ func (e *Ensemble) GetStatus(axis string) (Status, error) {
 	return Status(i64), nil
 }
 
+// GetAxisFault returns the AXISFAULT mask of an axis.  Zero indicates no fault
+func (e *Ensemble) GetAxisFault(axis string) (uint32, error) {
+	resp, err := e.writeRead(fmt.Sprintf("AXISFAULT(%s)", axis))
+	if err != nil {
+		return 0, err
+	}
+	i64, err := strconv.ParseInt(resp, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(i64), nil
+}
+
+// DecodeFault converts a fault mask into the named reasons for the fault
+func (e *Ensemble) DecodeFault(code uint32) []string {
+	return Fault(code).Reasons()
+}
+
+// ClearFault acknowledges and clears the fault on an axis
+func (e *Ensemble) ClearFault(axis string) error {
+	return e.gCodeWriteOnly("FAULTACK", axis)
+}
+
 // GetEnabled gets if the given axis is enabled or not
 func (e *Ensemble) GetEnabled(axis string) (bool, error) {
 	// get the status, it is a 32-bit int, which is really a bitfield
@@ -244,6 +308,25 @@ func (e *Ensemble) GetInPosition(axis string) (bool, error) {
 	return status.InPosition(), err
 }
 
+// MovingAxes returns the axes of AxisNames with a move active.  It is an error
+// for AxisNames to be empty, lest no axis ever appear to move
+func (e *Ensemble) MovingAxes() ([]string, error) {
+	if len(e.AxisNames) == 0 {
+		return nil, errors.New("aerotech: AxisNames must be set to find the moving axes")
+	}
+	var ret []string
+	for _, axis := range e.AxisNames {
+		status, err := e.GetStatus(axis)
+		if err != nil {
+			return nil, err
+		}
+		if status.MoveActive() {
+			ret = append(ret, axis)
+		}
+	}
+	return ret, nil
+}
+
 // SetSynchronous commands the controller to use synchronous motion mode
 //
 // The axis argument is ignored (Aerotech controllers are synchronous or not at
@@ -282,12 +365,18 @@ func (e *Ensemble) Home(axis string) error {
 
 // MoveAbs commands the controller to move an axis to an absolute position
 func (e *Ensemble) MoveAbs(axis string, pos float64) error {
+	if err := e.checkZones(axis, pos, false); err != nil {
+		return err
+	}
 	posS := strconv.FormatFloat(pos, 'G', -1, 64)
 	return e.gCodeWriteOnly("MOVEABS", axis, posS)
 }
 
 // MoveRel commands the controller to move an axis an incremental distance
 func (e *Ensemble) MoveRel(axis string, dist float64) error {
+	if err := e.checkZones(axis, dist, true); err != nil {
+		return err
+	}
 	posS := strconv.FormatFloat(dist, 'G', -1, 64)
 	return e.gCodeWriteOnly("MOVEINC", axis, posS)
 }
@@ -321,6 +410,47 @@ func (e *Ensemble) GetVelocity(axis string) (float64, error) {
 	return 0, errors.New("velocity not known for axis, use SetVelocity to make it known")
 }
 
+// Reconnect closes the idle connections to the Ensemble
+func (e *Ensemble) Reconnect() error {
+	e.pool.Drain()
+	return nil
+}
+
+// Shutdown closes the connections to the Ensemble
+func (e *Ensemble) Shutdown() error {
+	e.pool.Close()
+	return nil
+}
+
+// Stop aborts motion of an axis, which decelerates at its AbortDecelRate
+// parameter.  The Ensemble has no faster stop short of disabling the axis
+func (e *Ensemble) Stop(axis string) error {
+	return e.gCodeWriteOnly("ABORT", axis)
+}
+
+// StopAll aborts motion on every axis in AxisNames, which must be set
+func (e *Ensemble) StopAll() error {
+	if len(e.AxisNames) == 0 {
+		return errors.New("aerotech: AxisNames must be set to abort all axes")
+	}
+	return e.gCodeWriteOnly("ABORT", e.AxisNames...)
+}
+
+// Ping reads the status of the first of AxisNames.  The Ensemble has no
+// identification query
+func (e *Ensemble) Ping() error {
+	if len(e.AxisNames) == 0 {
+		return errors.New("aerotech: AxisNames must be set to ping the controller")
+	}
+	_, err := e.GetStatus(e.AxisNames[0])
+	return err
+}
+
+// Axes returns AxisNames, as the ASCII interface cannot list the axes
+func (e *Ensemble) Axes() ([]string, error) {
+	return append([]string{}, e.AxisNames...), nil
+}
+
 // Raw implements ascii.Rawer
 func (e *Ensemble) Raw(s string) (string, error) {
 	return e.writeRead(s)
@@ -0,0 +1,241 @@
+package aerotech
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// fakeEnsemble is an Ensemble ASCII server which answers each command with
+// reply, and records the commands it receives
+type fakeEnsemble struct {
+	ln    net.Listener
+	reply func(cmd string) string
+
+	mu   sync.Mutex
+	cmds []string
+}
+
+func newFakeEnsemble(t *testing.T, reply func(cmd string) string) (*fakeEnsemble, *Ensemble) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeEnsemble{ln: ln, reply: reply}
+	go f.serve()
+	t.Cleanup(func() { ln.Close() })
+	return f, NewEnsemble(ln.Addr().String(), false)
+}
+
+func (f *fakeEnsemble) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			br := bufio.NewReader(conn)
+			for {
+				line, err := br.ReadString(Terminator)
+				if err != nil {
+					return
+				}
+				cmd := strings.TrimSpace(line)
+				f.mu.Lock()
+				f.cmds = append(f.cmds, cmd)
+				f.mu.Unlock()
+				conn.Write([]byte(string(OKCode) + f.reply(cmd) + string(Terminator)))
+			}
+		}(conn)
+	}
+}
+
+func (f *fakeEnsemble) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.cmds...)
+}
+
+func TestFaultReasons(t *testing.T) {
+	cases := []struct {
+		code    Fault
+		reasons []string
+	}{
+		{0, []string{}},
+		{1, []string{"PositionError"}},
+		{1<<2 | 1<<3, []string{"CwEndOfTravelLimit", "CcwEndOfTravelLimit"}},
+		{1 << 11, []string{"EmergencyStopFault"}},
+		{1 << 13, []string{}}, // undocumented bit
+	}
+	for _, c := range cases {
+		got := c.code.Reasons()
+		if strings.Join(got, ",") != strings.Join(c.reasons, ",") {
+			t.Errorf("fault %#x: expected %v, got %v", uint32(c.code), c.reasons, got)
+		}
+		if c.code.Active() != (c.code != 0) {
+			t.Errorf("fault %#x: Active is %v", uint32(c.code), c.code.Active())
+		}
+	}
+}
+
+func TestGetAndClearAxisFault(t *testing.T) {
+	f, e := newFakeEnsemble(t, func(cmd string) string {
+		if cmd == "AXISFAULT(X)" {
+			return "2052" // 1<<11 | 1<<2
+		}
+		return ""
+	})
+	code, err := e.GetAxisFault("X")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 2052 {
+		t.Errorf("expected fault mask 2052, got %d", code)
+	}
+	reasons := e.DecodeFault(code)
+	if len(reasons) != 2 || reasons[0] != "CwEndOfTravelLimit" || reasons[1] != "EmergencyStopFault" {
+		t.Errorf("unexpected reasons %v", reasons)
+	}
+	if err = e.ClearFault("X"); err != nil {
+		t.Fatal(err)
+	}
+	cmds := f.sent()
+	if last := cmds[len(cmds)-1]; last != "FAULTACK X" {
+		t.Errorf("expected FAULTACK X to be sent, got %q", last)
+	}
+}
+
+func TestMovingAxes(t *testing.T) {
+	_, e := newFakeEnsemble(t, func(cmd string) string {
+		if cmd == "AXISSTATUS(Y)" {
+			return "8" // move active
+		}
+		return "0"
+	})
+	if _, err := e.MovingAxes(); err == nil {
+		t.Error("expected an error without AxisNames")
+	}
+	e.AxisNames = []string{"X", "Y"}
+	moving, err := e.MovingAxes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moving) != 1 || moving[0] != "Y" {
+		t.Errorf("expected [Y] to be moving, got %v", moving)
+	}
+}
+
+func TestProhibitedZoneContains(t *testing.T) {
+	z := ProhibitedZone{"X": {Min: 0, Max: 10}, "Y": {Min: -5, Max: 5}}
+	cases := []struct {
+		pos  map[string]float64
+		want bool
+	}{
+		{map[string]float64{"X": 5, "Y": 0}, true},
+		{map[string]float64{"X": 0, "Y": -5}, true}, // edges are inside
+		{map[string]float64{"X": 10, "Y": 5}, true},
+		{map[string]float64{"X": 10.001, "Y": 0}, false},
+		{map[string]float64{"X": 5, "Y": -5.001}, false},
+		{map[string]float64{"X": 5}, false}, // Y unknown
+		{map[string]float64{"X": 5, "Y": 0, "Z": 100}, true},
+	}
+	for _, c := range cases {
+		if got := z.Contains(c.pos); got != c.want {
+			t.Errorf("%v: expected %v, got %v", c.pos, c.want, got)
+		}
+	}
+	if (ProhibitedZone{}).Contains(map[string]float64{"X": 0}) {
+		t.Error("an empty zone contains nothing")
+	}
+}
+
+func TestCheckZones(t *testing.T) {
+	_, e := newFakeEnsemble(t, func(cmd string) string {
+		switch cmd {
+		case "PFBK X":
+			return "-10"
+		case "PFBK Y":
+			return "0"
+		case "PFBK Z":
+			return "20"
+		}
+		return ""
+	})
+	e.ProhibitedZones = []ProhibitedZone{{"X": {Min: 0, Max: 10}, "Y": {Min: -5, Max: 5}}}
+	cases := []struct {
+		axis     string
+		target   float64
+		relative bool
+		ok       bool
+	}{
+		{"X", -1, false, true},
+		{"X", 0, false, false},  // touches the edge
+		{"X", 5, false, false},  // ends inside
+		{"X", 20, false, false}, // passes through
+		{"X", 9, true, true},
+		{"X", 10, true, false},
+		{"Y", 100, false, true}, // X is outside the zone, so Y may go anywhere
+		{"Z", 0, false, true},   // Z is not part of the zone
+	}
+	for _, c := range cases {
+		err := e.checkZones(c.axis, c.target, c.relative)
+		if (err == nil) != c.ok {
+			t.Errorf("move %s to %g (relative %v): expected ok=%v, got %v", c.axis, c.target, c.relative, c.ok, err)
+		}
+	}
+
+	// from inside the zone, only moves that leave it are allowed
+	e.ProhibitedZones = []ProhibitedZone{{"X": {Min: -15, Max: 0}}}
+	if err := e.checkZones("X", 5, false); err != nil {
+		t.Errorf("expected a move out of the zone to be allowed, got %v", err)
+	}
+	err := e.checkZones("X", -12, false)
+	if err == nil {
+		t.Fatal("expected a move within the zone to be rejected")
+	}
+	w := httptest.NewRecorder()
+	generichttp.Error(w, err)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a move into a zone, got %d", w.Code)
+	}
+}
+
+func TestReleaseBrakeRequiresEnabled(t *testing.T) {
+	status := "0"
+	f, e := newFakeEnsemble(t, func(cmd string) string {
+		if cmd == "AXISSTATUS(X)" {
+			return status
+		}
+		return ""
+	})
+	err := e.ReleaseBrake("X")
+	if err == nil {
+		t.Fatal("expected releasing the brake of a disabled axis to fail")
+	}
+	w := httptest.NewRecorder()
+	generichttp.Error(w, err)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", w.Code)
+	}
+	for _, cmd := range f.sent() {
+		if strings.HasPrefix(cmd, "BRAKE") {
+			t.Fatalf("brake command %q sent to a disabled axis", cmd)
+		}
+	}
+
+	status = "1"
+	if err = e.ReleaseBrake("X"); err != nil {
+		t.Fatal(err)
+	}
+	cmds := f.sent()
+	if last := cmds[len(cmds)-1]; !strings.HasPrefix(last, "BRAKE ON") {
+		t.Errorf("expected the brake to be released, last command %q", last)
+	}
+}
@@ -0,0 +1,116 @@
+package aerotech
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// BrakeDelay is the time allowed for a brake to engage before the axis holding
+// the load is disabled
+var BrakeDelay = 100 * time.Millisecond
+
+// the brake output of the drive powers the brake coil; the brake is engaged
+// when the output is off.
+
+// ReleaseBrake releases the brake of an axis.  The axis must be enabled first
+// so the servo holds the load, and releasing the brake of a disabled axis is a
+// Conflict
+func (e *Ensemble) ReleaseBrake(axis string) error {
+	enabled, err := e.GetEnabled(axis)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return generichttp.Conflict(fmt.Errorf("aerotech: axis %s is disabled, enable it before releasing its brake", axis))
+	}
+	return e.gCodeWriteOnly("BRAKE ON", axis)
+}
+
+// EngageBrake engages the brake of an axis
+func (e *Ensemble) EngageBrake(axis string) error {
+	return e.gCodeWriteOnly("BRAKE OFF", axis)
+}
+
+// GetBrakeEngaged returns true if the brake of an axis is engaged
+func (e *Ensemble) GetBrakeEngaged(axis string) (bool, error) {
+	status, err := e.GetStatus(axis)
+	return !status.BrakeOutput(), err
+}
+
+// braked returns true if axis is one of BrakedAxes
+func (e *Ensemble) braked(axis string) bool {
+	for _, a := range e.BrakedAxes {
+		if a == axis {
+			return true
+		}
+	}
+	return false
+}
+
+// enableBraked enables an axis, then releases its brake
+func (e *Ensemble) enableBraked(axis string) error {
+	if err := e.gCodeWriteOnly("ENABLE", axis); err != nil {
+		return err
+	}
+	if err := e.ReleaseBrake(axis); err != nil {
+		return fmt.Errorf("aerotech: axis %s enabled but its brake was not released: %w", axis, err)
+	}
+	return nil
+}
+
+// disableBraked engages the brake of an axis, then disables it once the brake
+// has had BrakeDelay to grip.  The axis is left enabled if the brake cannot be
+// engaged, so a vertical load is not dropped
+func (e *Ensemble) disableBraked(axis string) error {
+	if err := e.EngageBrake(axis); err != nil {
+		return fmt.Errorf("aerotech: axis %s left enabled, its brake was not engaged: %w", axis, err)
+	}
+	time.Sleep(BrakeDelay)
+	return e.gCodeWriteOnly("DISABLE", axis)
+}
+
+// Braker is a type with axes that have brakes
+type Braker interface {
+	// ReleaseBrake releases the brake of an axis
+	ReleaseBrake(string) error
+
+	// EngageBrake engages the brake of an axis
+	EngageBrake(string) error
+
+	// GetBrakeEngaged returns true if the brake of an axis is engaged
+	GetBrakeEngaged(string) (bool, error)
+}
+
+// GetBrake returns an HTTP handler func that returns if the brake of the
+// axis in the URL is engaged
+func GetBrake(b Braker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		generichttp.GetBool(func() (bool, error) { return b.GetBrakeEngaged(axis) })(w, r)
+	}
+}
+
+// SetBrake returns an HTTP handler func that engages the brake of the axis in
+// the URL on {"bool": true} and releases it on {"bool": false}.  Releasing the
+// brake of a disabled axis is a 409; engaging it is always allowed
+func SetBrake(b Braker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		generichttp.SetBool(func(engage bool) error {
+			if engage {
+				return b.EngageBrake(axis)
+			}
+			return b.ReleaseBrake(axis)
+		})(w, r)
+	}
+}
+
+// HTTPBrake adds the GET and POST /axis/{axis}/brake routes to the table
+func HTTPBrake(b Braker, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/brake"}] = GetBrake(b)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/brake"}] = SetBrake(b)
+}
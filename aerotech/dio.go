@@ -0,0 +1,131 @@
+package aerotech
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// DefaultDigitalLines is the number of digital inputs and of digital outputs
+// on the standard I/O connector of an Ensemble drive
+const DefaultDigitalLines = 4
+
+// ioAxis returns the axis whose drive the digital I/O is on
+func (e *Ensemble) ioAxis() (string, error) {
+	if e.IOAxis != "" {
+		return e.IOAxis, nil
+	}
+	if len(e.AxisNames) > 0 {
+		return e.AxisNames[0], nil
+	}
+	return "", fmt.Errorf("aerotech: IOAxis or AxisNames must be set to use digital I/O")
+}
+
+// checkLine returns an error if bit is not one of n lines of the given
+// direction, naming the other direction if the line is one of those
+func checkLine(bit, n, other int, dir, otherDir string) error {
+	if n <= 0 {
+		n = DefaultDigitalLines
+	}
+	if other <= 0 {
+		other = DefaultDigitalLines
+	}
+	if bit >= 0 && bit < n {
+		return nil
+	}
+	if bit >= 0 && bit < other {
+		return fmt.Errorf("aerotech: digital line %d is not an %s, there are %d; it is an %s", bit, dir, n, otherDir)
+	}
+	return fmt.Errorf("aerotech: digital %s %d must be from 0 to %d", dir, bit, n-1)
+}
+
+// ReadDigitalInput returns the state of a digital input on the drive of
+// IOAxis
+func (e *Ensemble) ReadDigitalInput(bit int) (bool, error) {
+	if err := checkLine(bit, e.DigitalInputs, e.DigitalOutputs, "input", "output"); err != nil {
+		return false, err
+	}
+	axis, err := e.ioAxis()
+	if err != nil {
+		return false, err
+	}
+	resp, err := e.writeRead(fmt.Sprintf("DIN(%s,0,%d)", axis, bit))
+	if err != nil {
+		return false, fmt.Errorf("aerotech: reading digital input %d: %w", bit, err)
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(resp))
+	if err != nil {
+		return false, fmt.Errorf("aerotech: unable to parse digital input %q: %w", resp, err)
+	}
+	return i != 0, nil
+}
+
+// WriteDigitalOutput sets a digital output on the drive of IOAxis
+func (e *Ensemble) WriteDigitalOutput(bit int, on bool) error {
+	if err := checkLine(bit, e.DigitalOutputs, e.DigitalInputs, "output", "input"); err != nil {
+		return err
+	}
+	axis, err := e.ioAxis()
+	if err != nil {
+		return err
+	}
+	v := 0
+	if on {
+		v = 1
+	}
+	err = e.writeOnly(fmt.Sprintf("DOUT %s,0,%d:%d", axis, bit, v))
+	if err != nil {
+		return fmt.Errorf("aerotech: writing digital output %d: %w", bit, err)
+	}
+	return nil
+}
+
+// DigitalIO is a type with digital inputs and outputs
+type DigitalIO interface {
+	// ReadDigitalInput returns the state of an input
+	ReadDigitalInput(int) (bool, error)
+
+	// WriteDigitalOutput sets the state of an output
+	WriteDigitalOutput(int, bool) error
+}
+
+// bitParam returns the bit in the URL
+func bitParam(r *http.Request) (int, error) {
+	return strconv.Atoi(chi.URLParam(r, "bit"))
+}
+
+// GetDigitalInput returns an HTTP handler func that reads the input in the URL
+func GetDigitalInput(d DigitalIO) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bit, err := bitParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		generichttp.GetBool(func() (bool, error) { return d.ReadDigitalInput(bit) })(w, r)
+	}
+}
+
+// SetDigitalOutput returns an HTTP handler func that sets the output in the
+// URL from {"bool": state}
+func SetDigitalOutput(d DigitalIO) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bit, err := bitParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		generichttp.SetBool(func(on bool) error { return d.WriteDigitalOutput(bit, on) })(w, r)
+	}
+}
+
+// HTTPDigitalIO adds the GET /dio/in/{bit} and POST /dio/out/{bit} routes to
+// the table
+func HTTPDigitalIO(d DigitalIO, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/dio/in/{bit}"}] = GetDigitalInput(d)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/dio/out/{bit}"}] = SetDigitalOutput(d)
+}
@@ -0,0 +1,49 @@
+package aerotech
+
+// Fault is the Aerotech AXISFAULT bitfield
+type Fault uint32
+
+// faultBits maps bit indices of the AXISFAULT mask to human readable reasons
+var faultBits = []struct {
+	bit    uint
+	reason string
+}{
+	{0, "PositionError"},
+	{1, "OverCurrent"},
+	{2, "CwEndOfTravelLimit"},
+	{3, "CcwEndOfTravelLimit"},
+	{4, "CwSoftwareLimit"},
+	{5, "CcwSoftwareLimit"},
+	{6, "AmplifierFault"},
+	{7, "PositionFeedbackFault"},
+	{8, "VelocityFeedbackFault"},
+	{9, "HallSensorFault"},
+	{10, "MaxVelocityError"},
+	{11, "EmergencyStopFault"},
+	{12, "VelocityError"},
+	{15, "ExternalFault"},
+	{17, "MotorTemperature"},
+	{18, "AmplifierTemperature"},
+	{19, "EncoderFault"},
+	{20, "CommunicationLostFault"},
+	{23, "FeedbackScalingFault"},
+	{24, "MarkerSearchFault"},
+	{27, "VoltageClamp"},
+	{28, "PowerSupply"},
+	{30, "Internal"},
+}
+
+// Active is true if any fault bit is set
+func (f Fault) Active() bool { return f != 0 }
+
+// Reasons returns the named reason for each bit set in the fault mask.
+// Bits with no documented meaning are omitted
+func (f Fault) Reasons() []string {
+	out := []string{}
+	for _, b := range faultBits {
+		if (f>>b.bit)&1 == 1 {
+			out = append(out, b.reason)
+		}
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package aerotech
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+// ProhibitedZone is a region the axes may not be moved into or through, the box
+// where every named axis is within its range
+type ProhibitedZone map[string]util.Limiter
+
+// Contains returns true if pos, the positions of the axes, is inside the zone.
+// Axes of the zone missing from pos are not inside it
+func (z ProhibitedZone) Contains(pos map[string]float64) bool {
+	if len(z) == 0 {
+		return false
+	}
+	for axis, lim := range z {
+		p, ok := pos[axis]
+		if !ok || !lim.Contains(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the zone, e.g. "X in [0, 10] and Y in [-5, 5]"
+func (z ProhibitedZone) String() string {
+	axes := make([]string, 0, len(z))
+	for axis := range z {
+		axes = append(axes, axis)
+	}
+	sort.Strings(axes)
+	parts := make([]string, len(axes))
+	for i, axis := range axes {
+		parts[i] = fmt.Sprintf("%s in [%g, %g]", axis, z[axis].Min, z[axis].Max)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// Sweeps returns true if moving axis from its position in pos to target
+// passes through the zone, the other axes staying where pos puts them.  The
+// edges of the zone are inside it.  A move which starts inside the zone sweeps
+// it unless it ends outside, so an axis which is already inside can be backed
+// out
+func (z ProhibitedZone) Sweeps(pos map[string]float64, axis string, target float64) bool {
+	lim, ok := z[axis]
+	if !ok {
+		// the move cannot change whether the controller is inside the zone
+		return false
+	}
+	for a, l := range z {
+		if a == axis {
+			continue
+		}
+		if p, ok := pos[a]; !ok || !l.Contains(p) {
+			return false
+		}
+	}
+	from, ok := pos[axis]
+	if !ok {
+		return false
+	}
+	if lim.Contains(from) {
+		return lim.Contains(target)
+	}
+	lo, hi := math.Min(from, target), math.Max(from, target)
+	return lo <= lim.Max && hi >= lim.Min
+}
+
+// checkZones returns an error if moving axis to target, or by target if
+// relative, would take the controller into or through one of ProhibitedZones.
+// A rejected move is a bad request
+func (e *Ensemble) checkZones(axis string, target float64, relative bool) error {
+	pos := map[string]float64{}
+	for _, z := range e.ProhibitedZones {
+		if _, ok := z[axis]; !ok {
+			continue
+		}
+		for a := range z {
+			if _, ok := pos[a]; ok {
+				continue
+			}
+			p, err := e.GetPos(a)
+			if err != nil {
+				return fmt.Errorf("aerotech: unable to check prohibited zones, reading position of %s: %w", a, err)
+			}
+			pos[a] = p
+		}
+	}
+	if len(pos) == 0 {
+		return nil
+	}
+	if relative {
+		target += pos[axis]
+	}
+	for _, z := range e.ProhibitedZones {
+		if z.Sweeps(pos, axis, target) {
+			return generichttp.BadRequest(fmt.Errorf("aerotech: move of %s to %g rejected, it passes through the prohibited zone %s", axis, target, z))
+		}
+	}
+	return nil
+}
+
+// HTTPProhibitedZones adds the GET /zones route to the table, which returns
+// the prohibited zones as a JSON array
+func HTTPProhibitedZones(e *Ensemble, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/zones"}] = func(w http.ResponseWriter, r *http.Request) {
+		zones := e.ProhibitedZones
+		if zones == nil {
+			zones = []ProhibitedZone{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(zones)
+		if err != nil {
+			generichttp.Error(w, err)
+		}
+	}
+}
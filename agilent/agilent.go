@@ -33,13 +33,21 @@ type FunctionGenerator struct {
 // NewFunctionGenerator creates a new FunctionGenerator instance with
 // the communuication set up
 func NewFunctionGenerator(addr string, connectSerial bool) *FunctionGenerator {
+	return NewFunctionGeneratorPooled(addr, connectSerial, 1)
+}
+
+// NewFunctionGeneratorPooled is NewFunctionGenerator with a pool of up to
+// poolSize connections.  A serial port is always a pool of one
+func NewFunctionGeneratorPooled(addr string, connectSerial bool, poolSize int) *FunctionGenerator {
 	var maker comm.CreationFunc
 	if connectSerial {
+		// a serial port admits only one connection
+		poolSize = 1
 		maker = comm.SerialConnMaker(makeSerConf(addr))
 	} else {
 		maker = comm.BackingOffTCPConnMaker(addr, time.Second)
 	}
-	pool := comm.NewPool(1, time.Hour, maker)
+	pool := comm.NewPool(poolSize, time.Hour, maker)
 	return &FunctionGenerator{scpi.SCPI{Pool: pool, Handshaking: true}}
 }
 
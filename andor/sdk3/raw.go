@@ -271,6 +271,31 @@ func GetBool(handle int, feature string) (bool, error) {
 	return atToBool(b), enrich(Error(errCode), feature)
 }
 
+// IsImplemented returns true if the feature is implemented by the camera
+func IsImplemented(handle int, feature string) (bool, error) {
+	// this code is identical to GetBool except for the C call
+	cstr, err := cwch.FromGoString(feature)
+	if err != nil {
+		return false, err
+	}
+	str := (*C.AT_WC)(cstr.Pointer())
+	var b C.AT_BOOL
+	errCode := int(C.AT_IsImplemented(C.AT_H(handle), str, &b))
+	return atToBool(b), enrich(Error(errCode), feature)
+}
+
+// IsWritable returns true if the feature may be set in the camera's present state
+func IsWritable(handle int, feature string) (bool, error) {
+	cstr, err := cwch.FromGoString(feature)
+	if err != nil {
+		return false, err
+	}
+	str := (*C.AT_WC)(cstr.Pointer())
+	var b C.AT_BOOL
+	errCode := int(C.AT_IsWritable(C.AT_H(handle), str, &b))
+	return atToBool(b), enrich(Error(errCode), feature)
+}
+
 // SetString sets the value of a string
 func SetString(handle int, feature, value string) error {
 	cstr, err := cwch.FromGoString(feature)
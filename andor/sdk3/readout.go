@@ -0,0 +1,100 @@
+package sdk3
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nasa-jpl/golaborate/generichttp/camera"
+)
+
+/* ElectronicShutteringMode, PixelReadoutRate, and Overlap depend on each
+other.  The shuttering mode limits the readout rates, and whether overlap is
+available depends on both and on the trigger and cycle modes.  The SDK reports
+a feature which cannot be set in the present state as "not writable", which is
+confusing when the fix is to set another feature first.
+*/
+
+// GetReadoutMode returns the shuttering mode, readout rate, and overlap
+func (c *Camera) GetReadoutMode() (camera.ReadoutMode, error) {
+	var mode camera.ReadoutMode
+	var err error
+	mode.Shuttering, err = GetEnumString(c.Handle, "ElectronicShutteringMode")
+	if err != nil {
+		return mode, err
+	}
+	mode.ReadoutRate, err = GetEnumString(c.Handle, "PixelReadoutRate")
+	if err != nil {
+		return mode, err
+	}
+	overlap, err := GetBool(c.Handle, "Overlap")
+	if err != nil {
+		return mode, err
+	}
+	mode.Overlap = &overlap
+	return mode, nil
+}
+
+// SetReadoutMode sets the shuttering mode, then the readout rate, then
+// overlap, which is the order in which they depend on each other.  The
+// shuttering mode and readout rate are checked against the options the camera
+// offers before anything is changed, and overlap is checked to be writable
+// once the others are set.  Acquisition is stopped first
+func (c *Camera) SetReadoutMode(mode camera.ReadoutMode) error {
+	c.Lock()
+	defer c.Unlock()
+	check := func(feature, value string) error {
+		if value == "" {
+			return nil
+		}
+		opts, err := GetEnumStrings(c.Handle, feature)
+		if err != nil {
+			return err
+		}
+		for _, o := range opts {
+			if o == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("andor/sdk3: %s %q is not offered by this camera, options are %s",
+			feature, value, strings.Join(opts, ", "))
+	}
+	if err := check("ElectronicShutteringMode", mode.Shuttering); err != nil {
+		return err
+	}
+	if err := check("PixelReadoutRate", mode.ReadoutRate); err != nil {
+		return err
+	}
+
+	IssueCommand(c.Handle, "AcquisitionStop")
+	if mode.Shuttering != "" {
+		if err := SetEnumString(c.Handle, "ElectronicShutteringMode", mode.Shuttering); err != nil {
+			return err
+		}
+	}
+	if mode.ReadoutRate != "" {
+		// the list of rates may depend on the shuttering mode just set
+		if err := check("PixelReadoutRate", mode.ReadoutRate); err != nil {
+			return err
+		}
+		if err := SetEnumString(c.Handle, "PixelReadoutRate", mode.ReadoutRate); err != nil {
+			return err
+		}
+	}
+	if mode.Overlap != nil {
+		writable, err := IsWritable(c.Handle, "Overlap")
+		if err != nil {
+			return err
+		}
+		if !writable {
+			shutter, _ := GetEnumString(c.Handle, "ElectronicShutteringMode")
+			trigger, _ := GetEnumString(c.Handle, "TriggerMode")
+			cycle, _ := GetEnumString(c.Handle, "CycleMode")
+			return fmt.Errorf("andor/sdk3: Overlap cannot be set with ElectronicShutteringMode %s, TriggerMode %s, and CycleMode %s",
+				shutter, trigger, cycle)
+		}
+		if err := SetBool(c.Handle, "Overlap", *mode.Overlap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
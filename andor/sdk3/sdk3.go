@@ -23,6 +23,7 @@ import (
 	"github.com/theckman/yacspin"
 
 	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/generichttp/camera"
 	"github.com/nasa-jpl/golaborate/util"
 )
@@ -35,6 +36,8 @@ const (
 	// in non-realtime you could want an unlimited number, but
 	// the images are spooled outside the camera's capture loop
 	// (~= zero processing lag) so it is moot
+	//
+	// nbufs is the number of buffers unless AllocateN is used
 	nbufs = 3
 
 	// LengthOfUndefinedBuffers is how large a buffer to allocate for a Wchar
@@ -82,6 +85,7 @@ var (
 		"BufferOverflowEvent":     "int",
 		"DeviceCount":             "int",
 		"DeviceVideoIndex":        "int",
+		"EMCCDGain":               "int",
 		"EventsMissedEvent":       "int",
 		"ExposureStartEvent":      "int",
 		"ExposureEndEvent":        "int",
@@ -98,6 +102,7 @@ var (
 
 		// bools
 		"AlternatingReadoutDirection": "bool",
+		"BaselineClamp":               "bool",
 		"CameraAcquiring":             "bool",
 		"EventEnable":                 "bool",
 		"FastAOIFrameRateEnable":      "bool",
@@ -160,12 +165,32 @@ var (
 	}
 )
 
+// the SDK-backed camera shares its interfaces with camera.MockCamera, which
+// stands in for it in tests
+var (
+	_ camera.PictureTaker         = (*Camera)(nil)
+	_ camera.AOIManipulator       = (*Camera)(nil)
+	_ camera.CenteredAOISetter    = (*Camera)(nil)
+	_ camera.CapabilityReporter   = (*Camera)(nil)
+	_ camera.EMGainController     = (*Camera)(nil)
+	_ camera.BaselineClampManager = (*Camera)(nil)
+	_ camera.ThermalManager       = (*Camera)(nil)
+	_ camera.FeatureManager       = (*Camera)(nil)
+	_ generichttp.Identifier      = (*Camera)(nil)
+	_ camera.Resetter             = (*Camera)(nil)
+	_ camera.ReadoutModeSetter    = (*Camera)(nil)
+	_ camera.FullFrameSetter      = (*Camera)(nil)
+	_ camera.FrameRateReporter    = (*Camera)(nil)
+	_ camera.FrameRateLimiter     = (*Camera)(nil)
+	_ camera.SoftwareTriggerer    = (*Camera)(nil)
+)
+
 // Camera represents a camera from SDK3
 type Camera struct {
 	sync.Mutex
 
-	// bufs is the queue of buffers to send TO andor
-	bufs [nbufs]buffer
+	// bufs is the ring of buffers to send TO andor
+	bufs []buffer
 
 	// nextbuf indicates which element of bufs to send next
 	nextbuf int
@@ -176,9 +201,15 @@ type Camera struct {
 	// Handle holds the int that points to a specific camera
 	Handle int
 
+	// index is the index the camera was opened at, used to reopen it
+	index int
+
 	// UseSpinner indicates whether to run a spinner in the command line when
 	// taking video
 	UseSpinner bool
+
+	// meter measures the rate frames of a burst are received at
+	meter camera.FrameRateMeter
 }
 
 // Open opens a connection to the camera.  Typically, a real camera
@@ -188,6 +219,7 @@ func Open(camIdx int) (*Camera, error) {
 	var hndle C.AT_H
 	err := enrich(Error(int(C.AT_Open(C.int(camIdx), &hndle))), "AT_OPEN")
 	c.Handle = int(hndle)
+	c.index = camIdx
 	if err == nil {
 		c.Allocate()
 	}
@@ -195,27 +227,120 @@ func Open(camIdx int) (*Camera, error) {
 	return &c, err
 }
 
-// Close closes a connection to the camera
+// Close closes a connection to the camera and frees its buffers
 func (c *Camera) Close() error {
-	return enrich(Error(int(C.AT_Close(C.AT_H(c.Handle)))), "AT_Close")
+	err := enrich(Error(int(C.AT_Close(C.AT_H(c.Handle)))), "AT_Close")
+	c.freeBuffers()
+	return err
+}
+
+// freeBuffers frees every allocated buffer.  The SDK must not hold any of
+// them, i.e. acquisition is stopped and the queue flushed or the handle closed
+func (c *Camera) freeBuffers() {
+	for i := range c.bufs {
+		if c.bufs[i].allocated {
+			c.bufs[i].Free()
+		}
+	}
+	c.nextbuf = 0
+	c.recvdbuf = nil
 }
 
-// Allocate creates the buffer that will be populated by the SDK
+// resetFeatures are the settings carried across a Reset, in the order they
+// are restored.  Binning precedes the AOI size, which precedes its position,
+// and the readout mode precedes the exposure time and frame rate it limits
+var resetFeatures = []string{
+	"PixelEncoding", "SimplePreAmpGainControl", "ElectronicShutteringMode",
+	"PixelReadoutRate", "CycleMode", "TriggerMode", "Overlap",
+	"AOIHBin", "AOIVBin", "AOIWidth", "AOIHeight", "AOILeft", "AOITop",
+	"ExposureTime", "FrameRate", "SensorCooling", "FanSpeed", "TemperatureControl",
+}
+
+// Reset recovers a camera which has become stuck by stopping acquisition,
+// freeing the buffers, and closing and reopening the handle.  The settings
+// in resetFeatures which could be read beforehand are applied again.  The
+// values of those settings after the reset are returned, along with any
+// errors restoring them.  Reset waits for any acquisition in progress
+func (c *Camera) Reset() (map[string]interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+	// a wedged camera may not answer, so the snapshot is best effort
+	prev := map[string]interface{}{}
+	for _, f := range resetFeatures {
+		if v, err := c.GetFeature(f); err == nil {
+			prev[f] = v
+		}
+	}
+	IssueCommand(c.Handle, "AcquisitionStop")
+	c.Flush()
+	n := len(c.bufs)
+	if n == 0 {
+		n = nbufs
+	}
+	c.Close() // the handle is replaced whether or not this succeeds
+
+	var hndle C.AT_H
+	err := enrich(Error(int(C.AT_Open(C.int(c.index), &hndle))), "AT_OPEN")
+	if err != nil {
+		return nil, fmt.Errorf("andor/sdk3: reopening camera %d during reset: %w", c.index, err)
+	}
+	c.Handle = int(hndle)
+
+	var errs []error
+	for _, f := range resetFeatures {
+		v, ok := prev[f]
+		if !ok {
+			continue
+		}
+		if err := c.SetFeature(f, v); err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %w", f, err))
+		}
+	}
+	if err := c.AllocateN(n); err != nil {
+		errs = append(errs, err)
+	}
+	state := map[string]interface{}{}
+	for _, f := range resetFeatures {
+		if v, err := c.GetFeature(f); err == nil {
+			state[f] = v
+		}
+	}
+	return state, util.MergeErrors(errs)
+}
+
+// Allocate creates the buffers that will be populated by the SDK
 // it should be called at init, and whenever the AOI or encoding changes
-// AT_Flush is called to ensure stale buffers are not held by the SDK
+// AT_Flush is called to ensure stale buffers are not held by the SDK.
+// The number of buffers is kept from the last AllocateN, or nbufs
 func (c *Camera) Allocate() error {
+	n := len(c.bufs)
+	if n == 0 {
+		n = nbufs
+	}
+	return c.AllocateN(n)
+}
+
+// AllocateN replaces the buffers with a ring of n, which are kept queued
+// during a burst so frames are not dropped while one is being copied out
+func (c *Camera) AllocateN(n int) error {
+	if n < 1 {
+		return fmt.Errorf("andor/sdk3: at least one buffer is needed, not %d", n)
+	}
 	sze, err := c.ImageSizeBytes()
 	if err != nil {
 		return err
 	}
-
-	for i := 0; i < nbufs; i++ {
-		if c.bufs[i].allocated {
-			c.bufs[i].Free()
-		}
+	// the SDK must let go of the old buffers before they are freed
+	err = c.Flush()
+	if err != nil {
+		return err
+	}
+	c.freeBuffers()
+	c.bufs = make([]buffer, n)
+	for i := range c.bufs {
 		c.bufs[i].Alloc(sze)
 	}
-	return c.Flush()
+	return nil
 }
 
 // ImageSizeBytes is the size of the image buffer in bytes.  This function
@@ -266,11 +391,28 @@ func (c *Camera) GetAOITop() (int, error) {
 	return GetInt(c.Handle, "AOITop")
 }
 
-// SetAOI updates the AOI and re-allocates the buffer.  Width and height are
-// calculated from the difference of the sensor dimensions and top-left if they
-// are zero
+// SetAOI updates the AOI and re-allocates the buffer.
+//
+// The AOI is checked against the sensor size, present binning, and the
+// constraints of the camera before anything is sent to the SDK, so that an
+// invalid AOI produces an error naming the nearest valid value instead of
+// AT_ERR_OUTOFRANGE
 func (c *Camera) SetAOI(aoi camera.AOI) error {
-	var err error
+	err := c.validateAOI(aoi)
+	if err != nil {
+		return err
+	}
+
+	// the valid range of width and height depends on left and top, so move
+	// to the corner first to make any width and height that fit allowed
+	err = SetInt(c.Handle, "AOILeft", 1)
+	if err != nil {
+		return err
+	}
+	err = SetInt(c.Handle, "AOITop", 1)
+	if err != nil {
+		return err
+	}
 
 	err = SetInt(c.Handle, "AOIWidth", int64(aoi.Width))
 	if err != nil {
@@ -295,6 +437,119 @@ func (c *Camera) SetAOI(aoi camera.AOI) error {
 	return err
 }
 
+// validateAOI checks aoi against the sensor size, present binning, and the
+// constraints of the camera
+func (c *Camera) validateAOI(aoi camera.AOI) error {
+	sw, err := c.GetSensorWidth()
+	if err != nil {
+		return err
+	}
+	sh, err := c.GetSensorHeight()
+	if err != nil {
+		return err
+	}
+	b, err := c.GetBinning()
+	if err != nil {
+		return err
+	}
+	cons, err := c.GetAOIConstraints()
+	if err != nil {
+		return err
+	}
+	return cons.Validate(aoi, sw, sh, b)
+}
+
+// GetAOIConstraints returns the limits the camera places on its AOI.  The
+// minimum size is reported by the SDK; the SDK reports no increments, and
+// instead rounds the size it is given, so the steps are left at one.  Binned
+// AOIs are aligned to the binning grid
+func (c *Camera) GetAOIConstraints() (camera.AOIConstraints, error) {
+	wmin, err := GetIntMin(c.Handle, "AOIWidth")
+	if err != nil {
+		return camera.AOIConstraints{}, err
+	}
+	hmin, err := GetIntMin(c.Handle, "AOIHeight")
+	if err != nil {
+		return camera.AOIConstraints{}, err
+	}
+	return camera.AOIConstraints{MinWidth: wmin, MinHeight: hmin, AlignToBinning: true}, nil
+}
+
+// SetCenteredAOI sets an AOI of width x height (binned) pixels centered on
+// the sensor.  The size is rounded to the constraints of the camera and set
+// first; the AOI is then centered on the size the SDK kept, and its left and
+// top clamped to the range the SDK reports for them.  The AOI is read back
+// after it is set, and that is returned, so any adjustment made by the SDK is
+// reflected
+func (c *Camera) SetCenteredAOI(width, height int) (camera.AOI, error) {
+	sw, err := c.GetSensorWidth()
+	if err != nil {
+		return camera.AOI{}, err
+	}
+	sh, err := c.GetSensorHeight()
+	if err != nil {
+		return camera.AOI{}, err
+	}
+	b, err := c.GetBinning()
+	if err != nil {
+		return camera.AOI{}, err
+	}
+	cons, err := c.GetAOIConstraints()
+	if err != nil {
+		return camera.AOI{}, err
+	}
+	aoi := cons.Center(sw, sh, width, height, b)
+
+	// from the corner, any size which fits is allowed
+	for _, f := range []struct {
+		name string
+		v    int
+	}{{"AOILeft", 1}, {"AOITop", 1}, {"AOIWidth", aoi.Width}, {"AOIHeight", aoi.Height}} {
+		if err = SetInt(c.Handle, f.name, int64(f.v)); err != nil {
+			return camera.AOI{}, err
+		}
+	}
+	kept := camera.AOI{}
+	if kept.Width, err = c.GetAOIWidth(); err != nil {
+		return camera.AOI{}, err
+	}
+	if kept.Height, err = c.GetAOIHeight(); err != nil {
+		return camera.AOI{}, err
+	}
+	aoi = cons.Center(sw, sh, kept.Width, kept.Height, b)
+	for _, f := range []struct {
+		name string
+		v    int
+	}{{"AOILeft", aoi.Left}, {"AOITop", aoi.Top}} {
+		min, err := GetIntMin(c.Handle, f.name)
+		if err != nil {
+			return camera.AOI{}, err
+		}
+		max, err := GetIntMax(c.Handle, f.name)
+		if err != nil {
+			return camera.AOI{}, err
+		}
+		if err = SetInt(c.Handle, f.name, int64(clampInt(f.v, min, max))); err != nil {
+			return camera.AOI{}, err
+		}
+	}
+	if err = c.Allocate(); err != nil {
+		return camera.AOI{}, err
+	}
+	return c.GetAOI()
+}
+
+// clampInt clamps v to [min, max]
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
 // GetAOI gets the AOI
 func (c *Camera) GetAOI() (camera.AOI, error) {
 	// no point bailing early since these will all throw the same error if
@@ -333,6 +588,59 @@ func (c *Camera) SetBinning(b camera.Binning) error {
 	return c.Allocate()
 }
 
+// capabilities maps generichttp/camera capabilities to the features which
+// must all be implemented for the camera to have them
+var capabilities = map[string][]string{
+	camera.CapEMGain:        {"EMCCDGain"},
+	camera.CapBaselineClamp: {"BaselineClamp"},
+}
+
+// Capable returns true if the connected camera implements every feature
+// needed for the capability.  Errors from the SDK are treated as not capable
+func (c *Camera) Capable(capability string) bool {
+	features, ok := capabilities[capability]
+	if !ok {
+		return false
+	}
+	for _, f := range features {
+		impl, err := IsImplemented(c.Handle, f)
+		if err != nil || !impl {
+			return false
+		}
+	}
+	return true
+}
+
+// GetEMGain returns the EM gain.  Only EMCCD models implement this
+func (c *Camera) GetEMGain() (int, error) {
+	return GetInt(c.Handle, "EMCCDGain")
+}
+
+// SetEMGain sets the EM gain.  Only EMCCD models implement this
+func (c *Camera) SetEMGain(gain int) error {
+	return SetInt(c.Handle, "EMCCDGain", int64(gain))
+}
+
+// GetEMGainRange returns the minimum and maximum EM gain
+func (c *Camera) GetEMGainRange() (int, int, error) {
+	min, err := GetIntMin(c.Handle, "EMCCDGain")
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err := GetIntMax(c.Handle, "EMCCDGain")
+	return min, max, err
+}
+
+// GetBaselineClamp returns true if the baseline clamp is enabled
+func (c *Camera) GetBaselineClamp() (bool, error) {
+	return GetBool(c.Handle, "BaselineClamp")
+}
+
+// SetBaselineClamp enables or disables the baseline clamp
+func (c *Camera) SetBaselineClamp(b bool) error {
+	return SetBool(c.Handle, "BaselineClamp", b)
+}
+
 // GetFirmwareVersion gets the firmware version of the camera
 func (c *Camera) GetFirmwareVersion() (string, error) {
 	return GetString(c.Handle, "FirmwareVersion")
@@ -353,10 +661,28 @@ func (c *Camera) GetSerialNumber() (string, error) {
 	return GetString(c.Handle, "SerialNumber")
 }
 
-// QueueBuffer puts the Camera's internal buffer into the write queue for the SDK
-// only one buffer is supported in this wrapper, though the SDK supports
-// multiple buffers
+// Identify returns the model, serial, and firmware version of the camera
+func (c *Camera) Identify() (generichttp.DeviceInfo, error) {
+	ret := generichttp.DeviceInfo{Vendor: "Andor"}
+	var err error
+	ret.Model, err = c.GetModel()
+	if err != nil {
+		return ret, err
+	}
+	ret.Serial, err = c.GetSerialNumber()
+	if err != nil {
+		return ret, err
+	}
+	ret.Version, err = c.GetFirmwareVersion()
+	return ret, err
+}
+
+// QueueBuffer puts the next of the Camera's buffers into the write queue for
+// the SDK.  The buffers are queued in turn, so the SDK fills them in order
 func (c *Camera) QueueBuffer() error {
+	if len(c.bufs) == 0 {
+		return fmt.Errorf("image buffer not allocated")
+	}
 	buf := c.bufs[c.nextbuf]
 	if !buf.allocated {
 		return fmt.Errorf("image buffer not allocated")
@@ -365,7 +691,7 @@ func (c *Camera) QueueBuffer() error {
 	err = enrich(err, "AT_QueueBuffer")
 	if err == nil {
 		// advance the buffer index and wrap if needed
-		c.nextbuf = (c.nextbuf + 1) % nbufs
+		c.nextbuf = (c.nextbuf + 1) % len(c.bufs)
 	}
 	return err
 }
@@ -381,7 +707,7 @@ func (c *Camera) WaitBuffer(timeout time.Duration) error {
 	err := Error(int(C.AT_WaitBuffer(C.AT_H(c.Handle), &ptr, &size, tout)))
 	err = enrich(err, "AT_WaitBuffer")
 	if err == nil {
-		for i := 0; i < nbufs; i++ {
+		for i := range c.bufs {
 			if c.bufs[i].cptr == ptr {
 				c.recvdbuf = &c.bufs[i]
 				return nil
@@ -392,6 +718,47 @@ func (c *Camera) WaitBuffer(timeout time.Duration) error {
 	return err
 }
 
+// GetFrameRateReport returns the FrameRate setting and the rate the last
+// camera.FrameRateWindow frames of a burst were received at.  Single frames
+// from GetFrame are not measured, as they are not taken at the frame rate
+func (c *Camera) GetFrameRateReport() (camera.FrameRateReport, error) {
+	var rep camera.FrameRateReport
+	var err error
+	rep.Requested, err = GetFloat(c.Handle, "FrameRate")
+	if err != nil {
+		return rep, err
+	}
+	rep.Measured, rep.Frames = c.meter.Rate()
+	return rep, nil
+}
+
+// GetFrameRateRange returns the lowest and highest FrameRate the camera can
+// achieve with its present AOI, readout, and exposure time
+func (c *Camera) GetFrameRateRange() (float64, float64, error) {
+	min, err := GetFloatMin(c.Handle, "FrameRate")
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err := GetFloatMax(c.Handle, "FrameRate")
+	return min, max, err
+}
+
+// setFloat sets a float feature.  FrameRate is checked against
+// GetFrameRateRange first, since the SDK's out of range error does not say
+// what the range is
+func (c *Camera) setFloat(feature string, f float64) error {
+	if feature == "FrameRate" {
+		min, max, err := c.GetFrameRateRange()
+		if err != nil {
+			return err
+		}
+		if err = camera.CheckFrameRate(f, min, max); err != nil {
+			return err
+		}
+	}
+	return SetFloat(c.Handle, feature, f)
+}
+
 // Flush removes any pending buffers from the andor SDK's internal queue
 func (c *Camera) Flush() error {
 	err := enrich(Error(int(C.AT_Flush(C.AT_H(c.Handle)))), "AT_Flush")
@@ -462,6 +829,13 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 	defer c.Unlock()
 	spinning := c.UseSpinner
 	defer close(ch)
+	min, max, err := c.GetFrameRateRange()
+	if err != nil {
+		return err
+	}
+	if err = camera.CheckFrameRate(fps, min, max); err != nil {
+		return err
+	}
 	imgS, err := c.ImageSizeBytes()
 	if err != nil {
 		return err
@@ -520,6 +894,7 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 	c.Allocate()
 	defer func() {
 		IssueCommand(c.Handle, "AcquisitionStop")
+		c.Flush()
 		SetFloat(c.Handle, "FrameRate", prevFps)
 		SetEnumString(c.Handle, "CycleMode", prevCycle)
 	}()
@@ -540,22 +915,41 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 		spinner.Start()
 	}
 
+	// keep every buffer queued, so the SDK always has one to fill while a
+	// frame is copied out of another
+	queued := len(c.bufs)
+	if queued > frames {
+		queued = frames
+	}
+	for i := 0; i < queued; i++ {
+		err = c.QueueBuffer()
+		if err != nil {
+			return err
+		}
+	}
+
+	// measure the burst alone
+	c.meter.Reset()
 	err = IssueCommand(c.Handle, "AcquisitionStart")
 	if err != nil {
 		return err
 	}
 
 	for idx := 0; idx < frames; idx++ {
-		err = c.QueueBuffer()
-		if err != nil {
-			return err
-		}
 		err := c.WaitBuffer(waitT)
 		if err != nil {
 			return err
 		}
+		c.meter.Mark(time.Now())
 		buf := c.Buffer()
 		buf = UnpadBuffer(buf, stride, aoi.Width, aoi.Height)
+		// the buffer just copied is the next in the ring, recycle it
+		if idx+queued < frames {
+			err = c.QueueBuffer()
+			if err != nil {
+				return err
+			}
+		}
 		ch <- &image.Gray16{Pix: buf, Stride: aoi.Width * 2, Rect: image.Rect(0, 0, aoi.Width, aoi.Height)}
 		if spinning {
 			spinner.Message(fmt.Sprintf("frame %d/%d", idx, frames))
@@ -596,6 +990,20 @@ func (c *Camera) SetExposureTime(d time.Duration) error {
 	return SetFloat(c.Handle, "ExposureTime", ts)
 }
 
+// GetExposureTimeRange returns the minimum and maximum exposure time
+// permitted by the camera in its current configuration
+func (c *Camera) GetExposureTimeRange() (time.Duration, time.Duration, error) {
+	min, err := GetFloatMin(c.Handle, "ExposureTime")
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err := GetFloatMax(c.Handle, "ExposureTime")
+	if err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(min * 1e9), time.Duration(max * 1e9), nil
+}
+
 // GetCooling gets if temperature control is currently active or not
 func (c *Camera) GetCooling() (bool, error) {
 	return GetBool(c.Handle, "SensorCooling")
@@ -678,6 +1086,20 @@ func (c *Camera) Command(cmd string) error {
 	return IssueCommand(c.Handle, cmd)
 }
 
+// SoftwareTrigger fires the SoftwareTrigger command, which takes one frame
+// when TriggerMode is Software and acquisition is running.  It does not take
+// the camera's lock, so that it may be fired during a burst
+func (c *Camera) SoftwareTrigger() error {
+	mode, err := GetEnumString(c.Handle, "TriggerMode")
+	if err != nil {
+		return err
+	}
+	if mode != "Software" {
+		return camera.TriggerModeError{Mode: mode}
+	}
+	return IssueCommand(c.Handle, "SoftwareTrigger")
+}
+
 // GetFrameSize returns the AOI W, H
 func (c *Camera) GetFrameSize() (int, int, error) {
 	aoi, err := c.GetAOI()
@@ -953,29 +1375,29 @@ func (c *Camera) SetFeature(feature string, v interface{}) error {
 	case "float":
 		switch vv := v.(type) {
 		case int:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case int8:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case int16:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case int32:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case int64:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case uint:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case uint8:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case uint16:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case uint32:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case uint64:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case float32:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		case float64:
-			return SetFloat(c.Handle, feature, float64(vv))
+			return c.setFloat(feature, float64(vv))
 		default:
 			return fmt.Errorf("andor/sdk3: feature %s set with type %T, expected %s", feature, v, t)
 		}
@@ -1001,7 +1423,15 @@ func (c *Camera) Features() (map[string]string, error) {
 	return Features, nil
 }
 
-// UnpadBuffer strips padding bytes from a buffer
+// UnpadBuffer strips padding bytes from a buffer.
+//
+// buf is a row-major 16-bit image as delivered by the SDK: aoiheight rows,
+// each beginning aoistride bytes after the previous one, of which the first
+// aoiwidth*2 bytes are pixels and the remainder is padding.  aoiwidth is the
+// number of pixels per row (the fast axis, AOIWidth) and aoiheight the number
+// of rows (the slow axis, AOIHeight); swapping them will silently produce a
+// garbled image whenever the stride contains padding.  The output has
+// aoiwidth*2 bytes per row and no padding.  PadBuffer is the inverse
 func UnpadBuffer(buf []byte, aoistride, aoiwidth, aoiheight int) []byte {
 	// TODO: this allocates something bigger than needed
 	// can improve performance a little bit by changing this
@@ -1022,6 +1452,19 @@ func UnpadBuffer(buf []byte, aoistride, aoiwidth, aoiheight int) []byte {
 	return out
 }
 
+// PadBuffer is the inverse of UnpadBuffer; it spreads the rows of a tightly
+// packed row-major 16-bit image of aoiwidth by aoiheight pixels out to
+// aoistride bytes each, filling the padding with zeros
+func PadBuffer(buf []byte, aoistride, aoiwidth, aoiheight int) []byte {
+	out := make([]byte, aoistride*aoiheight)
+	bpp := 2
+	rowWidthBytes := bpp * aoiwidth
+	for row := 0; row < aoiheight; row++ {
+		copy(out[row*aoistride:], buf[row*rowWidthBytes:(row+1)*rowWidthBytes])
+	}
+	return out
+}
+
 func bytesToUint(b []byte) []uint16 {
 	var ary []uint16
 	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&ary))
@@ -0,0 +1,58 @@
+package sdk3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnpadPadRoundTrip(t *testing.T) {
+	tests := []struct {
+		name                  string
+		stride, width, height int
+	}{
+		{"no padding", 8, 4, 3},
+		{"padded", 12, 4, 3},
+		{"wide and short", 24, 10, 2},
+		{"tall and narrow", 8, 2, 7},
+		{"single row", 16, 5, 1},
+		{"single pixel", 4, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packed := make([]byte, tt.width*tt.height*2)
+			for i := range packed {
+				packed[i] = byte(i + 1)
+			}
+			padded := PadBuffer(packed, tt.stride, tt.width, tt.height)
+			if len(padded) != tt.stride*tt.height {
+				t.Fatalf("expected %d padded bytes, got %d", tt.stride*tt.height, len(padded))
+			}
+			for row := 0; row < tt.height; row++ {
+				for _, b := range padded[row*tt.stride+tt.width*2 : (row+1)*tt.stride] {
+					if b != 0 {
+						t.Fatalf("expected zero padding in row %d", row)
+					}
+				}
+			}
+			got := UnpadBuffer(padded, tt.stride, tt.width, tt.height)
+			if !bytes.Equal(got, packed) {
+				t.Errorf("round trip mismatch\nwant %v\ngot  %v", packed, got)
+			}
+		})
+	}
+}
+
+func TestUnpadBufferOrientation(t *testing.T) {
+	// a 3 wide, 2 tall image with 2 bytes of padding per row; pixel values
+	// are 10*row + col so rows and columns are distinguishable
+	stride, width, height := 8, 3, 2
+	buf := []byte{
+		0, 0, 1, 0, 2, 0, 0xff, 0xff,
+		10, 0, 11, 0, 12, 0, 0xff, 0xff,
+	}
+	got := UnpadBuffer(buf, stride, width, height)
+	want := []byte{0, 0, 1, 0, 2, 0, 10, 0, 11, 0, 12, 0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected rows of width pixels, got %v", got)
+	}
+}
@@ -36,6 +36,14 @@ type recorder struct {
 
 	// Prefix is the filename prefix to use
 	Prefix string `yaml:"Prefix"`
+
+	// MaxFiles is the number of files in a folder after which the recorder
+	// rolls over to a new one.  Zero is no limit
+	MaxFiles int `yaml:"MaxFiles"`
+
+	// MaxBytes is the size of a folder after which the recorder rolls over
+	// to a new one.  Zero is no limit
+	MaxBytes int64 `yaml:"MaxBytes"`
 }
 type config struct {
 	Addr         string                 `yaml:"Addr"`
@@ -135,7 +143,7 @@ func printconf() {
 }
 
 func pversion() {
-	fmt.Printf("andor-http version %v\n", Version)
+	fmt.Printf("andor-http version %s\n", generichttp.GetBuildInfo())
 }
 
 func run() {
@@ -244,15 +252,17 @@ func run() {
 	}
 
 	args := cfg.Recorder
-	r := &imgrec.Recorder{Root: args.Root, Prefix: args.Prefix}
+	r := &imgrec.Recorder{Root: args.Root, Prefix: args.Prefix, MaxFiles: args.MaxFiles, MaxBytes: args.MaxBytes}
 	w := camera.NewHTTPCamera(c, r)
 
 	// clean up the submux string
 	hndlrS := cfg.Root
 	hndlrS = generichttp.SubMuxSanitize(hndlrS)
 	root := chi.NewRouter()
+	root.Get("/version", generichttp.VersionHTTP)
 	mux := chi.NewRouter()
 	root.Mount(hndlrS, mux)
+	mux.Use(w.SnapshotGuard)
 	w.RT().Bind(mux)
 	addr := cfg.Addr + cfg.Root
 	log.Println("now listening for requests at ", addr)
@@ -260,6 +270,7 @@ func run() {
 }
 
 func main() {
+	generichttp.DefaultVersion(Version)
 	var cmd string
 	args := os.Args
 	if len(args) == 1 {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/generichttp/camera"
 	"github.com/nasa-jpl/golaborate/imgrec"
+	"github.com/nasa-jpl/golaborate/server/middleware/bodylimit"
 
 	"github.com/go-chi/chi"
 	"github.com/knadh/koanf"
@@ -36,6 +38,14 @@ type recorder struct {
 
 	// Prefix is the filename prefix to use
 	Prefix string `yaml:"Prefix"`
+
+	// MaxFiles is the number of files in a folder after which the recorder
+	// rolls over to a new one.  Zero is no limit
+	MaxFiles int `yaml:"MaxFiles"`
+
+	// MaxBytes is the size of a folder after which the recorder rolls over
+	// to a new one.  Zero is no limit
+	MaxBytes int64 `yaml:"MaxBytes"`
 }
 type config struct {
 	Addr         string                 `yaml:"Addr"`
@@ -43,6 +53,22 @@ type config struct {
 	SerialNumber string                 `yaml:"SerialNumber"`
 	Recorder     recorder               `yaml:"Recorder"`
 	BootupArgs   map[string]interface{} `yaml:"BootupArgs"`
+
+	// Buffers is the number of image buffers given to the SDK.  More keep
+	// fast bursts from dropping frames, at the cost of memory
+	Buffers int `yaml:"Buffers"`
+
+	// MaxBodyBytes is the largest request body accepted, in bytes.  Zero is
+	// bodylimit.DefaultMax, and a negative value disables the limit
+	MaxBodyBytes int64 `yaml:"MaxBodyBytes"`
+
+	// Profiles are named feature maps which may be applied at runtime with
+	// a POST to /configure/profile
+	Profiles map[string]camera.Profile `yaml:"Profiles"`
+
+	// StartupProfile is the path to a file of features, e.g. the AOI and
+	// exposure time, applied after BootupArgs when the camera is opened
+	StartupProfile string `yaml:"StartupProfile"`
 }
 
 func setupconfig() {
@@ -51,6 +77,7 @@ func setupconfig() {
 		Root:         "/",
 		SerialNumber: "auto",
 		Recorder:     recorder{},
+		Buffers:      3,
 		BootupArgs: map[string]interface{}{
 			"ElectronicShutteringMode": "Rolling",
 			"SimplePreAmpGainControl":  "16-bit (low noise & high well capacity)",
@@ -61,7 +88,18 @@ func setupconfig() {
 			"MetadataEnable":           false,
 			"SensorCooling":            true,
 			"SpuriousNoiseFilter":      false,
-			"StaticBlemishCorrection":  false}}, "koanf"), nil)
+			"StaticBlemishCorrection":  false},
+		Profiles: map[string]camera.Profile{
+			"lownoise": {
+				"ElectronicShutteringMode": "Rolling",
+				"SimplePreAmpGainControl":  "16-bit (low noise & high well capacity)",
+				"PixelReadoutRate":         "100 MHz",
+				"PixelEncoding":            "Mono16"},
+			"highspeed": {
+				"ElectronicShutteringMode": "Rolling",
+				"SimplePreAmpGainControl":  "12-bit (low noise)",
+				"PixelReadoutRate":         "280 MHz",
+				"PixelEncoding":            "Mono12"}}}, "koanf"), nil)
 	if err := k.Load(file.Provider(ConfigFileName), yaml.Parser()); err != nil {
 		errtxt := err.Error()
 		if !strings.Contains(errtxt, "no such") { // file missing, who cares
@@ -78,6 +116,7 @@ instead of custom socket logic.
 
 Usage:
 	andor-http <command>
+	andor-http run [-sn serial]
 
 Commands:
 	run
@@ -100,8 +139,92 @@ a config file.
 If for some reason there is an error during server bootup, it may be that a feature is not supported by the camera.
 Modify the BootupArgs portion of the config to remove the offending parameters.
 
+Profiles are named sets of features, like BootupArgs, which can be applied while
+the server is running by a POST to /configure/profile with a body of
+{"name": "lownoise"}, or {"features": {...}} for a one-off profile.  Each feature
+which could not be set is reported in the response, without aborting the others.
+
+StartupProfile, or run -startup path, names a YAML or JSON file of features, e.g.
+{"AOIWidth": 512, "AOIHeight": 512, "ExposureTime": 0.01}, applied after BootupArgs
+when the camera is opened, so each experiment can keep its own defaults.  If the file
+cannot be read, or any feature in it cannot be set, the problem is logged, the features
+it did set are put back as they were, and BootupArgs are applied again; the server still
+starts.  Features which depend on one another, such as AOILeft and AOIWidth, may be given
+in any order.  GET /configure/startup reports the file, what was applied, what was put
+back, and whether the fallback was used.
+
+GET /version, at the top level regardless of Root, returns the version, git commit,
+and build date the server was built with, as does the version command.
+
+Buffers is the number of image buffers handed to the SDK, 3 by default.  During a
+burst every buffer is kept queued, so raise it if fast bursts drop frames.
+
+MaxBodyBytes bounds the size of request bodies, 1 MiB when zero or absent.  Larger
+requests, such as an outsized defect map, are refused with 413 Request Entity Too
+Large.  A negative value removes the limit.
+
 serialNumber 'auto' causes the server to scan the available cameras and pick the first one
-which is not a software simulation camera.
+which is not a software simulation camera (those with SFT in the serial number).  A
+simulation camera is only used if no hardware camera is connected.  The serial number
+may also be given on the command line, which takes precedence over the config file:
+
+	andor-http run -sn VSC-01234
+
+Recorder.MaxFiles and Recorder.MaxBytes make the recorder start a new folder, named
+for the time, inside the day's folder once the present one holds that many files or
+bytes.  Each rollover is logged.  GET /autowrite/target shows where the next file goes.
+POST {"bool": true} to /record-on-acquire records every frame of each following burst
+as it is acquired, one file per frame, even frames the spool drops.  /burst/status
+reports how many were recorded.
+
+Exposure times are in seconds, unless ?unit=ms or ?unit=us is given to
+/exposure-time or /feature/ExposureTime, for both setting and reading.  Setting the
+exposure time responds with the time the camera applied, in the same unit.  Frame
+rates are always in Hz.
+
+If the camera becomes stuck, a POST to /admin/reset stops acquisition, reopens the
+camera, and restores its readout, AOI, exposure, and cooling settings.  The settings
+afterwards are returned, with any which could not be restored.
+
+The shuttering mode, readout rate, and overlap depend on each other.  POST
+{"shuttering": "Rolling", "readoutRate": "280 MHz", "overlap": true} to /readout-mode
+to set them together in the right order; omitted fields are left alone.  GET
+/readout-mode returns the present combination.
+
+A POST to /aoi/full returns the camera to 1x1 binning and an AOI of the whole sensor.
+
+GET /framerate/actual returns the FrameRate setting as "requested" and, as
+"measured", the rate the last 64 frames of a burst or stream arrived at.  A burst starts
+the measurement over, and single frames from /image are not counted.
+
+GET /framerate/range returns {"min": ..., "max": ...}, the FrameRate achievable with the
+present AOI, readout, and exposure time.  Setting FrameRate, or starting a burst, outside
+that range is refused with 400 and a message naming the limit.
+
+POST /trigger fires a software trigger, taking one frame of a running acquisition.  It
+requires TriggerMode to be Software (POST /feature/TriggerMode {"value": "Software"})
+and is refused with 409 otherwise.
+
+GET /image/averaged?n=16 takes 16 frames and returns their mean as a 32-bit float FITS
+file, or rounded to 16 bits with &bits=16.  If a frame fails partway, the frames
+already taken are averaged; NFRAMES in the header is how many, and ERR the failure.
+
+GET /array returns a frame as raw little endian uint16 pixels, row major, with no image
+encoding.  The X-Array-Width, X-Array-Height, X-Array-Bitdepth, and X-Array-Dtype
+(numpy's "<u2") headers describe it, so in Python
+np.frombuffer(r.content, r.headers['X-Array-Dtype']).reshape(height, width) is the frame.
+
+Header cards may be added to FITS files from /image and /snapshot with query
+parameters such as ?hdr.OBJECT=M31&hdr.FILTER=R, or a JSON body of keyword to
+value.  Keywords are at most 8 characters, and the structural keywords (SIMPLE,
+BITPIX, NAXIS, BZERO, ...) may not be given.
+
+The ranges and options of features, from /feature/{feature}/options, are cached for
+ten seconds, and the cache is emptied whenever a feature is set through /feature.
+A POST to /feature/cache/clear empties it at once.
+POST {"features": ["PixelEncoding", "ExposureTime"]} to /features/info/batch returns
+{"info": {feature: options}, "errors": {feature: message}} for all of them at once, or
+for every feature if the list is empty.
 
 If the files and folders created do not have the permissions you want on linux,
 your umask is likely to blame  andor-http makes them with permission 666, but your
@@ -140,12 +263,65 @@ func printconf() {
 }
 
 func pversion() {
-	fmt.Printf("andor-http version %v\n", Version)
+	fmt.Printf("andor-http version %s\n", generichttp.GetBuildInfo())
+}
+
+// isSimulator returns true if the serial number belongs to one of the
+// software simulation cameras the SDK provides, which have SFT in the serial
+func isSimulator(sn string) bool {
+	return strings.Contains(sn, "SFT")
 }
 
-func run() {
+// openCamera opens the camera with serial number sn among the first ncam
+// devices.  If sn is "auto", the first real camera is opened; a simulation
+// camera is used only if there is no hardware camera connected
+func openCamera(ncam int, sn string) (*sdk3.Camera, string, error) {
+	sim := -1
+	for idx := 0; idx < ncam; idx++ {
+		c, err := sdk3.Open(idx)
+		if err != nil {
+			return nil, "", err
+		}
+		snCam, err := c.GetSerialNumber()
+		if err != nil {
+			c.Close()
+			return nil, "", err
+		}
+		if sn == "auto" {
+			if !isSimulator(snCam) {
+				return c, snCam, nil
+			}
+			if sim == -1 {
+				sim = idx
+			}
+		} else if sn == snCam {
+			return c, snCam, nil
+		}
+		c.Close()
+	}
+	if sn == "auto" && sim != -1 {
+		log.Println("no hardware camera found, falling back to a simulation camera")
+		c, err := sdk3.Open(sim)
+		if err != nil {
+			return nil, "", err
+		}
+		snCam, err := c.GetSerialNumber()
+		if err != nil {
+			c.Close()
+			return nil, "", err
+		}
+		return c, snCam, nil
+	}
+	return nil, "", fmt.Errorf("no camera with serial number %s among %d connected", sn, ncam)
+}
+
+func run(args []string) {
 	cfg := config{}
 	k.Unmarshal("", &cfg)
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	sn := fs.String("sn", cfg.SerialNumber, "serial number of the camera to connect to, or auto")
+	startup := fs.String("startup", cfg.StartupProfile, "path to a profile of features applied after BootupArgs")
+	fs.Parse(args)
 	// load the library and see how many cameras are connected
 	err := sdk3.InitializeLibrary()
 	if err != nil {
@@ -163,36 +339,9 @@ func run() {
 	}
 	log.Printf("SDK version is %s\n", swver)
 
-	// now scan for the right serial number
-	// c escapes into the outer scope
-	sn := cfg.SerialNumber
-	var (
-		c     *sdk3.Camera
-		snCam string
-	)
-	for idx := 0; idx < ncam; idx++ {
-		c, err = sdk3.Open(idx)
-		if err != nil {
-			log.Fatal(err)
-		}
-		snCam, err = c.GetSerialNumber()
-		if err != nil {
-			c.Close()
-			log.Fatal(err)
-		}
-		if sn == "auto" {
-			if !strings.Contains(sn, "SFT") {
-				break
-			} else {
-				c.Close()
-			}
-		} else {
-			if sn == snCam {
-				break
-			} else {
-				c.Close()
-			}
-		}
+	c, snCam, err := openCamera(ncam, *sn)
+	if err != nil {
+		log.Fatal(err)
 	}
 	defer c.Close()
 	defer sdk3.FinalizeLibrary()
@@ -206,18 +355,47 @@ func run() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	c.Allocate()
+	var startupRep camera.StartupReport
+	if *startup != "" {
+		startupRep = camera.ApplyStartupProfile(c, *startup, cfg.BootupArgs)
+		for k, v := range startupRep.Result.Errors {
+			log.Printf("startup profile: %s: %s\n", k, v)
+		}
+		if startupRep.Error != "" {
+			log.Printf("%s; BootupArgs applied in its place\n", startupRep.Error)
+			if startupRep.Restored != nil {
+				for k, v := range startupRep.Restored.Errors {
+					log.Printf("restoring %s: %s\n", k, v)
+				}
+			}
+			for k, v := range startupRep.Fallback.Errors {
+				log.Printf("fallback: %s: %s\n", k, v)
+			}
+		} else {
+			log.Printf("applied startup profile %s: %v\n", *startup, startupRep.Result.Applied)
+		}
+	}
+	err = c.AllocateN(cfg.Buffers)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer c.Close()
-	args := cfg.Recorder
-	r := &imgrec.Recorder{Root: args.Root, Prefix: args.Prefix}
+	rec := cfg.Recorder
+	r := &imgrec.Recorder{Root: rec.Root, Prefix: rec.Prefix, MaxFiles: rec.MaxFiles, MaxBytes: rec.MaxBytes}
 	w := camera.NewHTTPCamera(c, r)
+	pm := camera.NewProfileManager(c, cfg.Profiles)
+	pm.SetStartup(startupRep)
+	pm.Inject(w.RouteTable)
 
 	// clean up the submux string
 	hndlrS := cfg.Root
 	hndlrS = generichttp.SubMuxSanitize(hndlrS)
 	root := chi.NewRouter()
+	root.Use(bodylimit.New(cfg.MaxBodyBytes).Check)
+	root.Get("/version", generichttp.VersionHTTP)
 	mux := chi.NewRouter()
 	root.Mount(hndlrS, mux)
+	mux.Use(w.SnapshotGuard)
 	w.RT().Bind(mux)
 	addr := cfg.Addr + cfg.Root
 	log.Println("now listening for requests at ", addr)
@@ -225,6 +403,7 @@ func run() {
 }
 
 func main() {
+	generichttp.DefaultVersion(Version)
 	var cmd string
 	args := os.Args
 	if len(args) == 1 {
@@ -245,7 +424,7 @@ func main() {
 		printconf()
 		return
 	case "run":
-		run()
+		run(args[2:])
 		return
 	case "version":
 		pversion()
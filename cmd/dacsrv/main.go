@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/nasa-jpl/golaborate/acromag"
+	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/generichttp/daq"
 	"github.com/nasa-jpl/golaborate/server/middleware/locker"
 )
@@ -143,6 +144,7 @@ func LoadWaveform(dac *acromag.AP235, name string, period time.Duration) error {
 func main() {
 	root := chi.NewRouter()
 	root.Use(middleware.Logger)
+	root.Get("/version", generichttp.VersionHTTP)
 	log.Println("connecting to AP235 (waveform DAC).  If the program is hanging, the driver has glitched;\n reboot the computer")
 	ap235, err := SetupAP235()
 	if err != nil {
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
+)
+
+// nodeHealth is the result of probing one node
+type nodeHealth struct {
+	// OK is true if the node answered
+	OK bool `json:"ok"`
+
+	// Probed is false for nodes which have no liveness query
+	Probed bool `json:"probed"`
+
+	// Error is the last error, if the node did not answer
+	Error string `json:"error,omitempty"`
+
+	// Time is when the node was last probed
+	Time *time.Time `json:"time,omitempty"`
+}
+
+// healthBoard holds the health of every node.  Nodes are probed with a cheap
+// query of their own if they have one, or by asking them to identify
+// themselves; those which can do neither are listed as not probed
+type healthBoard struct {
+	mu     sync.Mutex
+	probes map[string]func() error
+	status map[string]nodeHealth
+
+	// Retries is the number of further attempts made after a failed probe
+	Retries int
+
+	// Backoff is the time between attempts
+	Backoff time.Duration
+}
+
+func newHealthBoard() *healthBoard {
+	return &healthBoard{
+		probes:  map[string]func() error{},
+		status:  map[string]nodeHealth{},
+		Backoff: time.Second}
+}
+
+// probeFor returns the liveness query of device, its Ping if it is a
+// generichttp.Pinger or else its Identify, or nil if it has neither
+func probeFor(device interface{}) func() error {
+	if p, ok := device.(generichttp.Pinger); ok {
+		return p.Ping
+	}
+	if id, ok := device.(generichttp.Identifier); ok {
+		return func() error {
+			_, err := id.Identify()
+			return err
+		}
+	}
+	return nil
+}
+
+// add registers a node.  probe may be nil if the node has no liveness query
+func (h *healthBoard) add(endpoint string, probe func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if probe != nil {
+		h.probes[endpoint] = probe
+	}
+	h.status[endpoint] = nodeHealth{}
+}
+
+// probeOne probes a single node with retries and records the outcome
+func (h *healthBoard) probeOne(endpoint string, probe func() error) {
+	var err error
+	for attempt := 0; attempt <= h.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(h.Backoff)
+		}
+		err = probe()
+		if err == nil {
+			break
+		}
+	}
+	now := time.Now()
+	nh := nodeHealth{OK: err == nil, Probed: true, Time: &now}
+	if err != nil {
+		nh.Error = err.Error()
+	}
+	h.mu.Lock()
+	h.status[endpoint] = nh
+	h.mu.Unlock()
+}
+
+// probe probes every node concurrently and waits for them all
+func (h *healthBoard) probe() {
+	h.mu.Lock()
+	probes := make(map[string]func() error, len(h.probes))
+	for k, v := range h.probes {
+		probes[k] = v
+	}
+	h.mu.Unlock()
+	var wg sync.WaitGroup
+	for endpoint, probe := range probes {
+		wg.Add(1)
+		go func(endpoint string, probe func() error) {
+			defer wg.Done()
+			h.probeOne(endpoint, probe)
+		}(endpoint, probe)
+	}
+	wg.Wait()
+}
+
+// snapshot returns a copy of the status of every node
+func (h *healthBoard) snapshot() map[string]nodeHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ret := make(map[string]nodeHealth, len(h.status))
+	for k, v := range h.status {
+		ret[k] = v
+	}
+	return ret
+}
+
+// logTable logs one line per node, e.g. "/omc/nkt: OK"
+func (h *healthBoard) logTable() {
+	status := h.snapshot()
+	keys := make([]string, 0, len(status))
+	for k := range status {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	log.Println("startup probe of nodes:")
+	for _, k := range keys {
+		s := status[k]
+		var txt string
+		switch {
+		case !s.Probed:
+			txt = "not probed (no liveness query)"
+		case s.OK:
+			txt = "OK"
+		default:
+			txt = fmt.Sprintf("unreachable (%s)", s.Error)
+		}
+		log.Printf("\t%s: %s\n", k, txt)
+	}
+}
+
+// ServeHTTP responds with the health of every node as JSON.  With
+// ?probe=true, every node is probed again first
+func (h *healthBoard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	probe, err := server.QueryBool(r, "probe", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if probe {
+		h.probe()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(h.snapshot())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
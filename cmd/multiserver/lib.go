@@ -2,16 +2,28 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nasa-jpl/golaborate/agilent"
+	"github.com/nasa-jpl/golaborate/comm"
 	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/keysight"
 	"github.com/nasa-jpl/golaborate/pi"
+	"github.com/nasa-jpl/golaborate/server"
+	"github.com/nasa-jpl/golaborate/server/middleware/accesslog"
+	"github.com/nasa-jpl/golaborate/server/middleware/bodylimit"
+	"github.com/nasa-jpl/golaborate/server/middleware/compress"
+	"github.com/nasa-jpl/golaborate/server/middleware/cors"
 	"github.com/nasa-jpl/golaborate/server/middleware/locker"
+	"github.com/nasa-jpl/golaborate/server/middleware/timeout"
 	"github.com/nasa-jpl/golaborate/util"
 
 	"github.com/nasa-jpl/golaborate/aerotech"
@@ -28,21 +40,20 @@ import (
 	"github.com/nasa-jpl/golaborate/generichttp/tmc"
 
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
-	"github.com/go-yaml/yaml"
+	"github.com/knadh/koanf"
 )
 
 // Minmax holds a min and max value
 type Minmax struct {
-	Min float64 `yaml:"Min"`
-	Max float64 `yaml:"Max"`
+	Min float64 `json:"Min" yaml:"Min"`
+	Max float64 `json:"Max" yaml:"Max"`
 }
 
 // Daisy holds a controller ID, endpoint, and limit
 type Daisy struct {
-	ControllerID int               `yaml:"ControllerID"`
-	Endpoint     string            `yaml:"Endpoint"`
-	Limits       map[string]Minmax `yaml:"Limits"`
+	ControllerID int               `json:"ControllerID" yaml:"ControllerID"`
+	Endpoint     string            `json:"Endpoint" yaml:"Endpoint"`
+	Limits       map[string]Minmax `json:"Limits" yaml:"Limits"`
 }
 
 // ObjSetup holds the typical triplet of args for a New<device> call.
@@ -51,48 +62,427 @@ type Daisy struct {
 type ObjSetup struct {
 	// Addr holds the network or filesystem address of the remote device,
 	// e.g. 192.168.100.123:2006 for a device connected to port 6
-	// on a digi portserver, or /dev/ttyS4 for an RS232 device on a serial cable
-	Addr string `yaml:"Addr"`
+	// on a digi portserver, or /dev/ttyS4 for an RS232 device on a serial cable,
+	// or rfc2217://192.168.100.123:4001 with Serial for a port on a terminal
+	// server which speaks RFC 2217
+	Addr string `json:"Addr" yaml:"Addr"`
 
 	// URL is the full path the routes from this device will be served on
 	// ex. URL="/omc/nkt" will produce routes of /omc/nkt/power, etc.
-	Endpoint string `yaml:"Endpoint"`
+	Endpoint string `json:"Endpoint" yaml:"Endpoint"`
 
 	// Endpt is the final "directory" to put object functionality under, it will be
 	// prepended to routes
 	// Serial determines if the connection is serial/RS232 (True) or TCP (False)
-	Serial bool `yaml:"Serial"`
+	Serial bool `json:"Serial" yaml:"Serial"`
 
 	// Typ is the "type" of the object, e.g. ESP301
-	Type string `yaml:"Type"`
+	Type string `json:"Type" yaml:"Type"`
 
 	// Args holds any arguments to pass into the constructor for the object
-	Args map[string]interface{} `yaml:"Args"`
+	Args map[string]interface{} `json:"Args" yaml:"Args"`
 
-	DaisyChain []Daisy `yaml:"DaisyChain"`
+	DaisyChain []Daisy `json:"DaisyChain" yaml:"DaisyChain"`
+}
+
+// newLock returns a Locker, or an AxisLocker if axis is true, configured from
+// a node's Args.  Args["LockTTL"] is a duration after which locks expire,
+// e.g. "10m".  Args["LockQueue"] is the longest a request waits for a held
+// lock instead of being rejected, e.g. "30s".  admin is the token which may
+// force the release of any lock
+func newLock(axis bool, args map[string]interface{}, admin string) locker.ManipulableLock {
+	ttl := durationArg(args, "LockTTL")
+	wait := durationArg(args, "LockQueue")
+	if axis {
+		al := locker.NewAL()
+		al.TTL = ttl
+		al.QueueWait = wait
+		al.AdminToken = admin
+		return al
+	}
+	l := locker.New()
+	l.TTL = ttl
+	l.QueueWait = wait
+	l.AdminToken = admin
+	return l
+}
+
+// stringsArg returns args[key] as a list of strings, or nil if it is absent
+func stringsArg(args map[string]interface{}, key string) []string {
+	raw, ok := args[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	ret := make([]string, 0, len(raw))
+	for _, v := range raw {
+		ret = append(ret, fmt.Sprint(v))
+	}
+	return ret
+}
+
+// poolSizeArg returns Args["PoolSize"], the number of connections to open to
+// a device concurrently.  It is one if absent, and always one for serial
+// devices
+func poolSizeArg(node ObjSetup) int {
+	if node.Serial {
+		return 1
+	}
+	n := numberArg(node.Args, "PoolSize")
+	if n < 1 {
+		return 1
+	}
+	return int(n)
+}
+
+// numberArg returns args[key], which is an int from YAML or a float64 from
+// JSON, as a float64.  It is zero if absent
+func numberArg(args map[string]interface{}, key string) float64 {
+	f, _ := number(args[key])
+	return f
+}
+
+// number returns v as a float64 if it is an int, as YAML gives for whole
+// numbers, a float64, as JSON gives, or a string holding a number, as from an
+// environment variable
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// boolArg returns args[key] if it is a bool, or a string holding one, as from
+// an environment variable.  It is false if absent
+func boolArg(args map[string]interface{}, key string) bool {
+	switch v := args[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// rawHistoryArg returns Args["RawHistory"], the number of raw commands to
+// remember for /raw/history.  Zero, the default, remembers none
+func rawHistoryArg(args map[string]interface{}) int {
+	return int(numberArg(args, "RawHistory"))
+}
+
+// debugLog is an io.Writer which logs each line written to it, prefixed by
+// the endpoint of the device it came from
+type debugLog string
+
+func (d debugLog) Write(b []byte) (int, error) {
+	log.Printf("%s %s", string(d), strings.TrimSuffix(string(b), "\n"))
+	return len(b), nil
+}
+
+// debugArg logs the raw traffic of dev if Args["Debug"] is true
+func debugArg(dev interface{}, args map[string]interface{}, endpoint string) {
+	if !boolArg(args, "Debug") {
+		return
+	}
+	d, ok := dev.(comm.Debugger)
+	if !ok {
+		log.Printf("%s: Debug is not supported by %T\n", endpoint, dev)
+		return
+	}
+	d.SetDebug(debugLog(endpoint))
+}
+
+// piOptions sets the Epsilon, PCAddress, Aliases, line endings, and query
+// retries of a PI controller from Args["Epsilon"], Args["PCAddress"],
+// Args["AxisAliases"], Args["Terminator"], and Args["Retries"], if they are present
+func piOptions(ctl pi.PIController, args map[string]interface{}) {
+	pc, ok := ctl.(*pi.Controller)
+	if !ok {
+		return
+	}
+	if v, ok := number(args["Epsilon"]); ok {
+		pc.Epsilon = v
+	}
+	if v, ok := number(args["PCAddress"]); ok {
+		pc.PCAddress = int(v)
+	}
+	if aliases, ok := args["AxisAliases"].(map[string]interface{}); ok {
+		pc.Aliases = map[string]string{}
+		for k, v := range aliases {
+			pc.Aliases[k] = fmt.Sprint(v)
+		}
+	}
+	if term, ok := args["Terminator"].(string); ok && term != "" {
+		pc.RxTerm, pc.TxTerm = term, term
+	}
+	if v, ok := number(args["Retries"]); ok {
+		pc.Retries = int(v)
+	}
+}
+
+// gantryAxis returns the controller ID and axis name of one axis of
+// Args["Gantry"], e.g. {Controller: 2, Axis: "1"}
+func gantryAxis(gantry map[string]interface{}, key string) (int, string) {
+	ax, ok := gantry[key].(map[string]interface{})
+	if !ok {
+		log.Fatalf("Gantry.%s must be a map of Controller and Axis", key)
+	}
+	id, ok := number(ax["Controller"])
+	if !ok {
+		log.Fatalf("Gantry.%s.Controller must be a controller ID", key)
+	}
+	return int(id), fmt.Sprint(ax["Axis"])
+}
+
+// newGantry builds a raster scanning gantry from Args["Gantry"] of a PI
+// daisy chain and the endpoint to serve it on, with the IDs of its X and Y
+// controllers.  The axes are under the keys XAxis and YAxis, since YAML reads
+// a bare Y as true.  The limits of each controller, keyed by ID, are applied
+// to scans.  nil is returned if there is no Gantry arg
+func newGantry(args map[string]interface{}, ctls map[int]pi.PIController, limits map[int]map[string]util.Limiter) (g *pi.Gantry, endpoint string, xID, yID int) {
+	gantry, ok := args["Gantry"].(map[string]interface{})
+	if !ok {
+		return nil, "", 0, 0
+	}
+	xID, xAxis := gantryAxis(gantry, "XAxis")
+	yID, yAxis := gantryAxis(gantry, "YAxis")
+	x, ok := ctls[xID]
+	if !ok {
+		log.Fatalf("Gantry.XAxis refers to controller %d, which is not in the daisy chain", xID)
+	}
+	y, ok := ctls[yID]
+	if !ok {
+		log.Fatalf("Gantry.YAxis refers to controller %d, which is not in the daisy chain", yID)
+	}
+	g = &pi.Gantry{X: x, Y: y, XAxis: xAxis, YAxis: yAxis}
+	if l, ok := limits[xID][xAxis]; ok {
+		g.XLimit = &l
+	}
+	if l, ok := limits[yID][yAxis]; ok {
+		g.YLimit = &l
+	}
+	return g, fmt.Sprint(gantry["Endpoint"]), xID, yID
+}
+
+// daisyLimits returns the limits of one controller of a daisy chain, those of
+// the node overridden by the controller's own
+func daisyLimits(node map[string]util.Limiter, daisy map[string]Minmax) map[string]util.Limiter {
+	ret := make(map[string]util.Limiter, len(node)+len(daisy))
+	for k, v := range node {
+		ret[k] = v
+	}
+	for k, v := range daisy {
+		ret[k] = util.Limiter{Min: v.Min, Max: v.Max}
+	}
+	return ret
+}
+
+// axisLock returns the middleware of lock for requests which move axis, which
+// is the lock of that axis alone if lock is an AxisLocker
+func axisLock(lock locker.ManipulableLock, axis string) func(http.Handler) http.Handler {
+	if al, ok := lock.(*locker.AxisLocker); ok {
+		return al.Axis(axis).Check
+	}
+	return lock.Check
+}
+
+// durationArg parses args[key] as a duration, returning zero if it is absent
+func durationArg(args map[string]interface{}, key string) time.Duration {
+	s, ok := args[key].(string)
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", key, s, err)
+	}
+	return d
+}
+
+// zonesArg parses Args["ProhibitedZones"], a list of {axis: {Min, Max}} boxes
+func zonesArg(args map[string]interface{}) []aerotech.ProhibitedZone {
+	raw, ok := args["ProhibitedZones"].([]interface{})
+	if !ok {
+		return nil
+	}
+	zones := make([]aerotech.ProhibitedZone, 0, len(raw))
+	for i, v := range raw {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			log.Fatalf("prohibited zone %d is not a map of axis to {Min, Max}", i)
+		}
+		z := aerotech.ProhibitedZone{}
+		for axis, rng := range m {
+			bounds, _ := rng.(map[string]interface{})
+			min, ok1 := number(bounds["Min"])
+			max, ok2 := number(bounds["Max"])
+			if !ok1 || !ok2 {
+				log.Fatalf("prohibited zone %d axis %s must have a numeric Min and Max", i, axis)
+			}
+			z[axis] = util.Limiter{Min: min, Max: max}
+		}
+		zones = append(zones, z)
+	}
+	return zones
 }
 
 // Config is a struct that holds the initialization parameters for various
 // HTTP adapted devices.  It is to be populated by a json/unmarshal call.
 type Config struct {
 	// Addr is the address to listen at
-	Addr string `yaml:"Addr"`
+	Addr string `json:"Addr" yaml:"Addr"`
+
+	Mock bool `json:"Mock" yaml:"Mock"`
+
+	// RequestTimeout is the longest any request may take, e.g. "5m".  Empty
+	// uses DefaultRequestTimeout, and "0" disables the timeout
+	RequestTimeout string `json:"RequestTimeout" yaml:"RequestTimeout"`
+
+	// Compress gzips responses to clients which accept it, except images
+	// that are already compressed and streams
+	Compress bool `json:"Compress" yaml:"Compress"`
+
+	// CORSOrigins are the origins of browser pages allowed to use the
+	// server, e.g. http://dash.lab:8080, or "*" for any.  Empty, the default,
+	// allows none
+	CORSOrigins []string `json:"CORSOrigins" yaml:"CORSOrigins"`
+
+	// MaxBodyBytes is the largest request body accepted, in bytes.  Zero uses
+	// bodylimit.DefaultMax, and a negative value disables the limit
+	MaxBodyBytes int64 `json:"MaxBodyBytes" yaml:"MaxBodyBytes"`
 
-	Mock bool `yaml:"Mock"`
+	// AccessLog is the file each request is appended to as a line of JSON.
+	// Empty logs to stderr, and "off" disables the access log
+	AccessLog string `json:"AccessLog" yaml:"AccessLog"`
+
+	// AccessLogBodies are path fragments, e.g. /factory-reset, whose write
+	// requests have their bodies in the access log
+	AccessLogBodies []string `json:"AccessLogBodies" yaml:"AccessLogBodies"`
+
+	// LockAdminToken lets a request carrying it in the X-Admin-Token header
+	// force the release of any lock.  Empty means only the holder of a lock
+	// may force its release
+	LockAdminToken string `json:"LockAdminToken" yaml:"LockAdminToken"`
+
+	// PayloadShape is the JSON shape of single values, "typed" ({"f64": 1.5})
+	// or "value" ({"value": 1.5}).  Empty keeps the shape built in
+	PayloadShape string `json:"PayloadShape" yaml:"PayloadShape"`
+
+	// Probe checks that every node answers when the server starts, logging a
+	// table of the results.  Nodes which do not answer are still mounted, and
+	// are flagged in /health
+	Probe bool `json:"Probe" yaml:"Probe"`
+
+	// ProbeRetries is the number of further attempts made to reach a node
+	// which does not answer the first probe
+	ProbeRetries int `json:"ProbeRetries" yaml:"ProbeRetries"`
 
 	// Nodes is the list of nodes to set up
-	Nodes []ObjSetup `yaml:"Nodes"`
+	Nodes []ObjSetup `json:"Nodes" yaml:"Nodes"`
+}
+
+// accessLog returns the access logging middleware described by c
+func accessLog(c Config) func(http.Handler) http.Handler {
+	switch c.AccessLog {
+	case "off":
+		return func(next http.Handler) http.Handler { return next }
+	case "":
+		return accesslog.New(os.Stderr, c.AccessLogBodies...).Check
+	}
+	f, err := os.OpenFile(c.AccessLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("unable to open AccessLog: %v", err)
+	}
+	return accesslog.New(f, c.AccessLogBodies...).Check
 }
 
-// LoadYaml converts a (path to a) yaml file into a Config struct
+// DefaultRequestTimeout is the request timeout used when the config does not
+// give one.  It is long enough for homing and long moves
+const DefaultRequestTimeout = 5 * time.Minute
+
+// envRef matches ${VAR} and ${VAR:-default}
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces ${VAR} in the string values held in v, a config parsed
+// from yaml or json, with the value of the environment variable VAR.
+// ${VAR:-default} uses default when VAR is unset or empty.  Only values are
+// expanded, not keys, and what a variable holds is never parsed as yaml, so it
+// cannot add to or break the structure of the config.  Expanded values stay
+// strings, even if the variable holds a number, so that a token such as 00123
+// is kept as written.  A reference to a variable which is unset and has no
+// default is an error.
+//
+// Maps and slices in v are expanded in place
+func ExpandEnv(v interface{}) (interface{}, error) {
+	var missing []string
+	v = expandEnv(v, &missing)
+	if len(missing) > 0 {
+		return v, fmt.Errorf("config references unset environment variable(s) with no default: %s",
+			strings.Join(missing, ", "))
+	}
+	return v, nil
+}
+
+// expandEnv is ExpandEnv, noting variables with no value in missing
+func expandEnv(v interface{}, missing *[]string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = expandEnv(e, missing)
+		}
+	case map[interface{}]interface{}:
+		for k, e := range t {
+			t[k] = expandEnv(e, missing)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = expandEnv(e, missing)
+		}
+	case string:
+		return expandString(t, missing)
+	}
+	return v
+}
+
+// expandString expands the references in one string value.  The result is
+// always a string, which typed fields and args parse for themselves
+func expandString(s string, missing *[]string) string {
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envRef.FindStringSubmatch(ref)
+		name := m[1]
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		if strings.Contains(ref, ":-") {
+			return m[3]
+		}
+		if _, ok := os.LookupEnv(name); !ok {
+			*missing = append(*missing, name)
+		}
+		return ""
+	})
+}
+
+// LoadYaml converts a (path to a) yaml file into a Config struct, as the
+// server loads its config.  Environment variables in its values are
+// expanded, see ExpandEnv.  A file ending in .json is read as json
 func LoadYaml(path string) (Config, error) {
 	cfg := Config{}
-	f, err := os.Open(path)
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return cfg, err
 	}
-
-	err = yaml.NewDecoder(f).Decode(&cfg)
+	k := koanf.New(".")
+	if err = loadConfig(k, b, path); err != nil {
+		return cfg, err
+	}
+	err = k.Unmarshal("", &cfg)
 	return cfg, err
 }
 
@@ -101,16 +491,55 @@ func LoadYaml(path string) (Config, error) {
 // The mux serves a special route, route-list, which returns an
 // array of strings containing all routes as JSON.
 func BuildMux(c Config) chi.Router {
+	mux, _ := buildMux(c)
+	return mux
+}
+
+// buildMux is BuildMux, and also returns the devices which must be shut down
+// when the server exits
+func buildMux(c Config) (chi.Router, []generichttp.Shutdowner) {
 	// make the root handler
 	root := chi.NewRouter()
-	root.Use(middleware.Logger)
+	root.Use(accessLog(c))
+	if len(c.CORSOrigins) > 0 {
+		// before the others, so preflights are answered before routing
+		root.Use(cors.New(c.CORSOrigins).Check)
+	}
+	reqTimeout := DefaultRequestTimeout
+	if c.RequestTimeout != "" {
+		d, err := time.ParseDuration(c.RequestTimeout)
+		if err != nil {
+			log.Fatalf("invalid RequestTimeout %q: %v", c.RequestTimeout, err)
+		}
+		reqTimeout = d
+	}
+	if c.Compress {
+		root.Use(compress.New().Check)
+	}
+	root.Use(timeout.New(reqTimeout).Check)
+	root.Use(bodylimit.New(c.MaxBodyBytes).Check)
+	switch c.PayloadShape {
+	case "":
+	case generichttp.ShapeTyped, generichttp.ShapeValue:
+		generichttp.PayloadShape = c.PayloadShape
+	default:
+		log.Fatalf("invalid PayloadShape %q, must be %q or %q", c.PayloadShape, generichttp.ShapeTyped, generichttp.ShapeValue)
+	}
 	supergraph := map[string][]string{}
+	// tables holds the route table of every endpoint, for /openapi.json
+	tables := map[string]generichttp.RouteTable{}
+	schemas := map[string]generichttp.Schemas{}
+	identities := map[string]generichttp.Identifier{}
+	var shutdowners []generichttp.Shutdowner
+	health := newHealthBoard()
+	health.Retries = c.ProbeRetries
 
 OuterLoop:
 	// for every node specified, build a submux
 	for _, node := range c.Nodes {
 		var (
 			httper     generichttp.HTTPer
+			device     interface{}
 			middleware []func(http.Handler) http.Handler
 		)
 		axislocker := false
@@ -133,6 +562,8 @@ OuterLoop:
 			Limits -> map[string]interface
 			limit key -> map[string]float64
 			*/
+			// ClampToLimits: true clamps moves to the limits instead of rejecting them
+			clamp := boolArg(node.Args, "ClampToLimits")
 			limiters := map[string]util.Limiter{}
 			if node.Args != nil {
 				if node.Args["Limits"] != nil {
@@ -155,19 +586,58 @@ OuterLoop:
 					log.Fatal("Aerotech mock interface is not yet implemented")
 				}
 				ensemble := aerotech.NewEnsemble(node.Addr, node.Serial)
-				limiter := motion.LimitMiddleware{Limits: limiters, Mov: ensemble}
+				ensemble.AxisNames = stringsArg(node.Args, "Axes")
+				if len(ensemble.AxisNames) == 0 {
+					// emergency stop and wait-idle address every axis, which
+					// the Ensemble cannot list
+					log.Fatalf("Aerotech %s must list its axes in Args: Axes", node.Endpoint)
+				}
+				ensemble.IOAxis, _ = node.Args["IOAxis"].(string)
+				ensemble.DigitalInputs = int(numberArg(node.Args, "DigitalInputs"))
+				ensemble.DigitalOutputs = int(numberArg(node.Args, "DigitalOutputs"))
+				ensemble.BrakedAxes = stringsArg(node.Args, "BrakedAxes")
+				ensemble.ProhibitedZones = zonesArg(node.Args)
+				device = ensemble
+				limiter := motion.LimitMiddleware{Limits: limiters, Mov: ensemble, Clamp: clamp}
 				httper = motion.NewHTTPMotionController(ensemble)
 				middleware = append(middleware, limiter.Check)
 				limiter.Inject(httper)
+				aerotech.HTTPDigitalIO(ensemble, httper.RT())
+				aerotech.HTTPBrake(ensemble, httper.RT())
+				aerotech.HTTPProhibitedZones(ensemble, httper.RT())
 			case "esp", "esp300", "esp301":
 				if c.Mock {
 					log.Fatal("newport esp mock interface is not yet implemented")
 				}
 				esp := newport.NewESP301(node.Addr, node.Serial)
-				limiter := motion.LimitMiddleware{Limits: limiters, Mov: esp}
+				if d := durationArg(node.Args, "HomeTimeout"); d > 0 {
+					esp.HomeTimeout = d
+				}
+				device = esp
+				if node.Args != nil {
+					if boolArg(node.Args, "LimitsFromController") {
+						// limits in the config file take precedence over the
+						// ones stored on the controller
+						for axis := 1; axis <= 3; axis++ {
+							key := strconv.Itoa(axis)
+							if _, exists := limiters[key]; exists {
+								continue
+							}
+							cfg, err := esp.GetStageConfig(axis)
+							if err != nil {
+								log.Printf("unable to read stage configuration of axis %d on %s: %v\n", axis, node.Addr, err)
+								continue
+							}
+							limiters[key] = cfg.Limiter()
+						}
+					}
+				}
+				limiter := motion.LimitMiddleware{Limits: limiters, Mov: esp, Clamp: clamp}
 				httper = motion.NewHTTPMotionController(esp)
 				middleware = append(middleware, limiter.Check)
 				limiter.Inject(httper)
+				newport.HTTPStageConfig(esp, httper.RT())
+				newport.HTTPHomeMode(esp, httper.RT())
 			case "xps":
 				var xps motion.Controller
 				if c.Mock {
@@ -175,56 +645,143 @@ OuterLoop:
 				} else {
 					xps = newport.NewXPS(node.Addr)
 				}
-				limiter := motion.LimitMiddleware{Limits: limiters, Mov: xps}
+				device = xps
+				limiter := motion.LimitMiddleware{Limits: limiters, Mov: xps, Clamp: clamp}
 				httper = motion.NewHTTPMotionController(xps)
 				middleware = append(middleware, limiter.Check)
 				limiter.Inject(httper)
+				if gs, ok := xps.(newport.GroupStatusQueryer); ok {
+					newport.HTTPGroupStatus(gs, httper.RT())
+				}
+				if g, ok := xps.(newport.Gatherer); ok {
+					newport.HTTPGathering(g, httper.RT())
+				}
 			case "pi-daisy-chain":
 				// daisy chain is special in that a single pool is used for multiple controllers
-				network := pi.NewNetwork(node.Addr, node.Serial)
+				network := pi.NewNetworkPooled(node.Addr, node.Serial, poolSizeArg(node))
+				ctls := map[int]pi.PIController{}
+				ctlLimits := map[int]map[string]util.Limiter{}
+				ctlLimiters := map[int]motion.LimitMiddleware{}
+				locks := map[int]locker.ManipulableLock{}
 				for i := range node.DaisyChain {
 					daisy := node.DaisyChain[i]
 					ctl := network.Add(daisy.ControllerID, true, c.Mock) // true => handshaking//error checking
-					limiter := motion.LimitMiddleware{Limits: limiters, Mov: ctl}
+					ctls[daisy.ControllerID] = ctl
+					piOptions(ctl, node.Args)
+					debugArg(ctl, node.Args, daisy.Endpoint)
+					// each controller has its own limits and limiter, as axis
+					// names repeat from one controller to the next
+					ctlLimits[daisy.ControllerID] = daisyLimits(limiters, daisy.Limits)
+					limiter := motion.LimitMiddleware{Limits: ctlLimits[daisy.ControllerID], Mov: ctl, Clamp: clamp}
+					ctlLimiters[daisy.ControllerID] = limiter
 					httper = motion.NewHTTPMotionController(ctl)
 					ascii.InjectRawComm(httper.RT(), ctl)
+					if n := rawHistoryArg(node.Args); n > 0 {
+						ascii.InjectRawHistory(httper.RT(), ctl, n)
+					}
+					if m, ok := ctl.(pi.MacroRecorder); ok {
+						pi.HTTPMacro(m, httper.RT())
+					}
+					if wg, ok := ctl.(pi.WaveGenerator); ok {
+						pi.HTTPWave(wg, httper.RT())
+					}
+					if dr, ok := ctl.(pi.DataRecorder); ok {
+						pi.HTTPDataRecorder(dr, httper.RT())
+					}
+					if sn, ok := ctl.(pi.Snapshotter); ok {
+						pi.HTTPSnapshot(sn, httper.RT())
+					}
 					limiter.Inject(httper)
-					middleware = append(middleware, limiter.Check)
+					ctlMiddleware := append(middleware[:len(middleware):len(middleware)], limiter.Check)
+					if axes := stringsArg(node.Args, "Axes"); axes != nil {
+						motion.HTTPAxes(motion.StaticAxes(axes), httper.RT())
+					}
 					// prepare the URL, "omc/nkt" => "/omc/nkt/*"
 					hndlS := generichttp.SubMuxSanitize(daisy.Endpoint)
+					if id, ok := ctl.(generichttp.Identifier); ok {
+						generichttp.HTTPIdentify(id, httper.RT())
+						identities[hndlS] = id
+					}
+					health.add(hndlS, probeFor(ctl))
+					if rc, ok := ctl.(generichttp.Reconnector); ok {
+						generichttp.HTTPReconnect(rc, httper.RT())
+					}
+					if sd, ok := ctl.(generichttp.Shutdowner); ok {
+						shutdowners = append(shutdowners, sd)
+					}
 
 					// add a lock interface for this node
-					var lock locker.ManipulableLock
-					if !axislocker {
-						lock = locker.New()
-					} else {
-						lock = locker.NewAL()
-					}
+					lock := newLock(axislocker, node.Args, c.LockAdminToken)
+					locks[daisy.ControllerID] = lock
 					// add the lock middleware
 					locker.Inject(httper, lock)
 					r := chi.NewRouter()
-					r.Use(middleware...)
+					r.Use(ctlMiddleware...)
 					r.Use(lock.Check)
 					httper.RT().Bind(r)
 					root.Mount(hndlS, r)
+					tables[hndlS] = httper.RT()
+					if d, ok := httper.(generichttp.Describer); ok {
+						schemas[hndlS] = d.Schemas()
+					}
+				}
+				// Gantry: {Endpoint: omc/gantry, XAxis: {Controller: 1, Axis: "1"}, YAxis: {...}}
+				// serves raster scans over two controllers of the chain, behind
+				// the locks of both axes
+				if g, endpt, xID, yID := newGantry(node.Args, ctls, ctlLimits); g != nil {
+					rt := generichttp.RouteTable{}
+					pi.HTTPRasterScan(g, rt)
+					hndlS := generichttp.SubMuxSanitize(endpt)
+					supergraph[hndlS] = rt.Endpoints()
+					tables[hndlS] = rt
+					xLimiter, yLimiter := ctlLimiters[xID], ctlLimiters[yID]
+					r := chi.NewRouter()
+					r.Use(middleware...)
+					r.Use(xLimiter.Check, yLimiter.Check)
+					r.Use(axisLock(locks[xID], g.XAxis))
+					if xID != yID || g.XAxis != g.YAxis {
+						r.Use(axisLock(locks[yID], g.YAxis))
+					}
+					rt.Bind(r)
+					root.Mount(hndlS, r)
 				}
 				continue OuterLoop
 			case "pi":
-				network := pi.NewNetwork(node.Addr, node.Serial)
+				network := pi.NewNetworkPooled(node.Addr, node.Serial, poolSizeArg(node))
 				ctl := network.Add(1, true, c.Mock)
-				limiter := motion.LimitMiddleware{Limits: limiters, Mov: ctl}
+				piOptions(ctl, node.Args)
+				device = ctl
+				limiter := motion.LimitMiddleware{Limits: limiters, Mov: ctl, Clamp: clamp}
 				httper = motion.NewHTTPMotionController(ctl)
 				ascii.InjectRawComm(httper.RT(), ctl)
 				limiter.Inject(httper)
 				middleware = append(middleware, limiter.Check)
+				if m, ok := ctl.(pi.MacroRecorder); ok {
+					pi.HTTPMacro(m, httper.RT())
+				}
+				if wg, ok := ctl.(pi.WaveGenerator); ok {
+					pi.HTTPWave(wg, httper.RT())
+				}
+				if dr, ok := ctl.(pi.DataRecorder); ok {
+					pi.HTTPDataRecorder(dr, httper.RT())
+				}
+				if sn, ok := ctl.(pi.Snapshotter); ok {
+					pi.HTTPSnapshot(sn, httper.RT())
+				}
 
 			}
+			// Axes: [X, Y] overrides the axes the controller reports, or
+			// supplies them for controllers which cannot enumerate their own
+			if axes := stringsArg(node.Args, "Axes"); axes != nil {
+				motion.HTTPAxes(motion.StaticAxes(axes), httper.RT())
+			}
 
 		case "cryocon":
 			if c.Mock {
 				log.Fatal("cryocon mock interface is not yet implemented")
 			}
-			cryo := cryocon.NewTemperatureMonitor(node.Addr)
+			cryo := cryocon.NewTemperatureMonitorPooled(node.Addr, poolSizeArg(node))
+			device = cryo
 			httper = cryocon.NewHTTPWrapper(*cryo)
 
 		case "fluke", "dewk":
@@ -232,27 +789,38 @@ OuterLoop:
 				log.Fatal("fluke dewk mock interface is not yet implemented")
 			}
 			dewK := fluke.NewDewK(node.Addr)
+			device = dewK
+			// the wrapper holds a copy, so these must come first
+			debugArg(dewK, node.Args, node.Endpoint)
+			interval := fluke.DefaultSampleInterval
+			if _, ok := node.Args["SampleInterval"]; ok {
+				interval = durationArg(node.Args, "SampleInterval")
+			}
+			dewK.Sample(interval)
 			httper = fluke.NewHTTPWrapper(*dewK)
 
 		case "keysight-scope":
 			if c.Mock {
 				log.Fatal("keysight scope mock interface is not yet implemented")
 			}
-			scope := keysight.NewScope(node.Addr)
+			scope := keysight.NewScopePooled(node.Addr, poolSizeArg(node))
+			device = scope
 			httper = tmc.NewHTTPOscilloscope(scope)
 
 		case "agilent-function-generator":
 			if c.Mock {
 				log.Fatal("agilent function generator mock interface is not yet implemented")
 			}
-			gen := agilent.NewFunctionGenerator(node.Addr, node.Serial)
+			gen := agilent.NewFunctionGeneratorPooled(node.Addr, node.Serial, poolSizeArg(node))
+			device = gen
 			httper = tmc.NewHTTPFunctionGenerator(gen)
 
 		case "keysight-daq":
 			if c.Mock {
 				log.Fatal("keysight daq xps mock interface is not yet implemented")
 			}
-			daq := keysight.NewDAQ(node.Addr)
+			daq := keysight.NewDAQPooled(node.Addr, poolSizeArg(node))
+			device = daq
 			httper = tmc.NewHTTPDAQ(daq)
 
 		case "nkt", "superk":
@@ -261,8 +829,13 @@ OuterLoop:
 			if c.Mock {
 				sk = nkt.NewMockSuperK(node.Addr, node.Serial)
 			} else {
-				sk = nkt.NewSuperK(node.Addr, node.Serial)
+				superk := nkt.NewSuperK(node.Addr, node.Serial)
+				superk.MaxPower = numberArg(node.Args, "MaxPower")
+				superk.RampStep = numberArg(node.Args, "RampStep")
+				superk.RampInterval = durationArg(node.Args, "RampInterval")
+				sk = superk
 			}
+			device = sk
 			httper = nkt.NewHTTPWrapper(sk)
 
 		default:
@@ -272,16 +845,43 @@ OuterLoop:
 		// prepare the URL, "omc/nkt" => "/omc/nkt/*"
 		hndlS := generichttp.SubMuxSanitize(node.Endpoint)
 
+		// Debug: true logs the raw bytes sent to and read from the device
+		if typ != "fluke" && typ != "dewk" {
+			debugArg(device, node.Args, node.Endpoint)
+		}
+
+		// mount /whoami for devices that can describe themselves, and list
+		// them in /endpoints?identify=true
+		if id, ok := device.(generichttp.Identifier); ok {
+			generichttp.HTTPIdentify(id, httper.RT())
+			identities[hndlS] = id
+		}
+		health.add(hndlS, probeFor(device))
+
+		// RawHistory: n records the last n commands to /raw at /raw/history
+		if n := rawHistoryArg(node.Args); n > 0 {
+			if rawer, ok := device.(ascii.RawCommunicator); ok {
+				ascii.InjectRawHistory(httper.RT(), rawer, n)
+			}
+		}
+
+		// mount /admin/reconnect for devices that hold connections
+		if rc, ok := device.(generichttp.Reconnector); ok {
+			generichttp.HTTPReconnect(rc, httper.RT())
+		}
+		if sd, ok := device.(generichttp.Shutdowner); ok {
+			shutdowners = append(shutdowners, sd)
+		}
+
 		// add the endpoints to the graph
 		supergraph[hndlS] = httper.RT().Endpoints()
+		tables[hndlS] = httper.RT()
+		if d, ok := httper.(generichttp.Describer); ok {
+			schemas[hndlS] = d.Schemas()
+		}
 
 		// add a lock interface for this node
-		var lock locker.ManipulableLock
-		if !axislocker {
-			lock = locker.New()
-		} else {
-			lock = locker.NewAL()
-		}
+		lock := newLock(axislocker, node.Args, c.LockAdminToken)
 
 		// add the lock middleware
 		locker.Inject(httper, lock)
@@ -293,13 +893,55 @@ OuterLoop:
 		httper.RT().Bind(r)
 		root.Mount(hndlS, r)
 	}
-	root.Get("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+	root.Get("/version", generichttp.VersionHTTP)
+	root.Get("/openapi.json", generichttp.OpenAPIHTTP("multiserver", generichttp.Version, tables, schemas))
+	root.Get("/endpoints", endpointsHandler(supergraph, identities))
+	if c.Probe {
+		health.probe()
+		health.logTable()
+	}
+	root.Get("/health", health.ServeHTTP)
+	return root, shutdowners
+}
+
+// endpointInventory is the response to /endpoints?identify=true
+type endpointInventory struct {
+	// Endpoints maps each node to its routes, as /endpoints returns
+	Endpoints map[string][]string `json:"endpoints"`
+
+	// Devices maps each node which can identify itself to what it is
+	Devices map[string]generichttp.DeviceInfo `json:"devices"`
+}
+
+// endpointsHandler serves /endpoints, the routes of every node.  With
+// ?identify=true it also asks every node which can to identify itself, for
+// taking inventory of the hardware behind the server.  A device which fails to
+// identify is reported with an empty DeviceInfo instead of failing the whole
+// inventory
+func endpointsHandler(graph map[string][]string, ids map[string]generichttp.Identifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identify, err := server.QueryBool(r, "identify", false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ret interface{} = graph
+		if identify {
+			infos := make(map[string]generichttp.DeviceInfo, len(ids))
+			for k, id := range ids {
+				info, err := id.Identify()
+				if err != nil {
+					log.Printf("error identifying %s: %v\n", k, err)
+				}
+				infos[k] = info
+			}
+			ret = endpointInventory{Endpoints: graph, Devices: infos}
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		err := json.NewEncoder(w).Encode(supergraph)
+		err = json.NewEncoder(w).Encode(ret)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
-	})
-	return root
+	}
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/pi"
+	"github.com/nasa-jpl/golaborate/util"
+
+	"github.com/knadh/koanf"
+)
+
+// parseConfig loads src, a multiserver.yml, as the server does
+func parseConfig(t *testing.T, src string) Config {
+	t.Helper()
+	k := koanf.New(".")
+	if err := loadConfig(k, []byte(src), "multiserver.yml"); err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	if err := k.Unmarshal("", &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type fakeIdentifier struct {
+	info generichttp.DeviceInfo
+	err  error
+}
+
+func (f fakeIdentifier) Identify() (generichttp.DeviceInfo, error) { return f.info, f.err }
+
+func TestEndpointsIdentify(t *testing.T) {
+	graph := map[string][]string{"/pi": {"GET /whoami"}, "/nkt": {"GET /power"}}
+	ids := map[string]generichttp.Identifier{
+		"/pi":  fakeIdentifier{info: generichttp.DeviceInfo{Vendor: "PI", Model: "C-884"}},
+		"/bad": fakeIdentifier{err: errors.New("no answer")},
+	}
+	h := endpointsHandler(graph, ids)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/endpoints", nil))
+	var plain map[string][]string
+	if err := json.NewDecoder(w.Body).Decode(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != 2 || plain["/nkt"][0] != "GET /power" {
+		t.Errorf("expected the plain route graph, got %v", plain)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/endpoints?identify=true", nil))
+	var inv endpointInventory
+	if err := json.NewDecoder(w.Body).Decode(&inv); err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Endpoints) != 2 {
+		t.Errorf("expected the route graph in the inventory, got %v", inv.Endpoints)
+	}
+	if inv.Devices["/pi"].Model != "C-884" {
+		t.Errorf("expected /pi to identify as a C-884, got %+v", inv.Devices["/pi"])
+	}
+	if bad, ok := inv.Devices["/bad"]; !ok || bad != (generichttp.DeviceInfo{}) {
+		t.Errorf("expected a failed identify to be listed empty, got %+v, %v", bad, ok)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/endpoints?identify=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed identify, got %d", w.Code)
+	}
+}
+
+func TestGantryLimitsArePerController(t *testing.T) {
+	c := parseConfig(t, `Nodes:
+  - Type: pi-daisy-chain
+    Args:
+      Gantry:
+        Endpoint: omc/gantry
+        XAxis: {Controller: 1, Axis: "1"}
+        YAxis: {Controller: 2, Axis: "1"}
+`)
+	node := map[string]util.Limiter{"1": {Min: -100, Max: 100}}
+	limits := map[int]map[string]util.Limiter{
+		1: daisyLimits(node, nil),
+		2: daisyLimits(node, map[string]Minmax{"1": {Min: 0, Max: 5}}),
+	}
+	g, endpt, xID, yID := newGantry(c.Nodes[0].Args, map[int]pi.PIController{1: nil, 2: nil}, limits)
+	if g == nil || endpt != "omc/gantry" || xID != 1 || yID != 2 {
+		t.Fatalf("unexpected gantry %+v at %q on %d, %d", g, endpt, xID, yID)
+	}
+	if g.XLimit == nil || g.XLimit.Max != 100 {
+		t.Errorf("expected the node's limit on X, got %+v", g.XLimit)
+	}
+	if g.YLimit == nil || g.YLimit.Max != 5 {
+		t.Errorf("expected controller 2's own limit on Y, got %+v", g.YLimit)
+	}
+}
+
+type fakePinger struct{ err error }
+
+func (f fakePinger) Ping() error { return f.err }
+
+func TestHealthProbesPingersAndIdentifiers(t *testing.T) {
+	h := newHealthBoard()
+	h.add("/esp", probeFor(fakePinger{}))
+	h.add("/fluke", probeFor(fakePinger{err: errors.New("no answer")}))
+	h.add("/pi", probeFor(fakeIdentifier{}))
+	h.add("/camera", probeFor(struct{}{}))
+	h.probe()
+	status := h.snapshot()
+	if s := status["/esp"]; !s.Probed || !s.OK {
+		t.Errorf("expected /esp to be probed and OK, got %+v", s)
+	}
+	if s := status["/fluke"]; !s.Probed || s.OK || s.Error != "no answer" {
+		t.Errorf("expected /fluke to be probed and down, got %+v", s)
+	}
+	if s := status["/pi"]; !s.Probed || !s.OK {
+		t.Errorf("expected /pi to be probed by identifying it, got %+v", s)
+	}
+	if s := status["/camera"]; s.Probed {
+		t.Errorf("expected /camera not to be probed, got %+v", s)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health?probe=1", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 for probe=1, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health?probe=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed probe, got %d", w.Code)
+	}
+}
+
+func TestExpandEnvOnlyExpandsValues(t *testing.T) {
+	vars := map[string]string{
+		"MS_TEST_ADDR":    "10.0.0.5:2116 # not a comment",
+		"MS_TEST_NODES":   "\n  - Type: nkt",
+		"MS_TEST_POOL":    "3",
+		"MS_TEST_TOKEN":   "00123",
+		"MS_TEST_RETRIES": "2",
+	}
+	for k, v := range vars {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	c := parseConfig(t, `Addr: ${MS_TEST_ADDR}
+LockAdminToken: ${MS_TEST_TOKEN}
+ProbeRetries: ${MS_TEST_RETRIES}
+Nodes:
+  - Type: pi
+    Endpoint: /pi${MS_TEST_NODES}
+    Args:
+      PoolSize: ${MS_TEST_POOL}
+      Debug: ${MS_TEST_DEBUG:-true}
+`)
+	if c.Addr != "10.0.0.5:2116 # not a comment" {
+		t.Errorf("expected the address verbatim, got %q", c.Addr)
+	}
+	if c.LockAdminToken != "00123" {
+		t.Errorf("expected the token as written, got %q", c.LockAdminToken)
+	}
+	if c.ProbeRetries != 2 {
+		t.Errorf("expected ProbeRetries to parse as 2, got %d", c.ProbeRetries)
+	}
+	if len(c.Nodes) != 1 || c.Nodes[0].Endpoint != "/pi\n  - Type: nkt" {
+		t.Fatalf("expected one node with the variable in its endpoint, got %+v", c.Nodes)
+	}
+	args := c.Nodes[0].Args
+	if args["PoolSize"] != "3" || poolSizeArg(c.Nodes[0]) != 3 || !boolArg(args, "Debug") {
+		t.Errorf("expected PoolSize \"3\" read as 3 and Debug read as true, got %v", args)
+	}
+
+	_, err := ExpandEnv(map[string]interface{}{"Addr": "${MS_TEST_UNSET}"})
+	if err == nil || !strings.Contains(err.Error(), "MS_TEST_UNSET") {
+		t.Errorf("expected an error naming the unset variable, got %v", err)
+	}
+}
+
+func TestZonesTakeWholeNumberBounds(t *testing.T) {
+	c := parseConfig(t, `Nodes:
+  - Type: ensemble
+    Args:
+      ProhibitedZones: [{X: {Min: 0, Max: 10}, "Y": {Min: 1, Max: 2}, Z: {Min: -5.5, Max: 0}}]
+`)
+	zones := zonesArg(c.Nodes[0].Args)
+	if len(zones) != 1 {
+		t.Fatalf("expected one zone, got %v", zones)
+	}
+	x, y, z := zones[0]["X"], zones[0]["Y"], zones[0]["Z"]
+	if x.Min != 0 || x.Max != 10 || y.Min != 1 || y.Max != 2 || z.Min != -5.5 || z.Max != 0 {
+		t.Errorf("expected X in [0, 10], Y in [1, 2], and Z in [-5.5, 0], got %+v", zones[0])
+	}
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/json"
 	"github.com/knadh/koanf/parsers/yaml"
-	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/knadh/koanf/providers/structs"
+	"github.com/nasa-jpl/golaborate/generichttp"
 
 	yml "gopkg.in/yaml.v2"
 )
@@ -21,18 +29,56 @@ var (
 
 	// ConfigFileName is what it sounds like
 	ConfigFileName = "multiserver.yml"
-	k              = koanf.New(".")
+
+	// JSONConfigFileName is read instead of ConfigFileName if only it exists
+	JSONConfigFileName = "multiserver.json"
+	k                  = koanf.New(".")
 )
 
+// envParser is a koanf.Parser which expands environment variables in the
+// values it parses, see ExpandEnv
+type envParser struct {
+	koanf.Parser
+}
+
+func (p envParser) Unmarshal(b []byte) (map[string]interface{}, error) {
+	m, err := p.Parser.Unmarshal(b)
+	if err != nil {
+		return m, err
+	}
+	_, err = ExpandEnv(m)
+	return m, err
+}
+
+// loadConfig loads b, the contents of the config file name, into k.  The
+// file is JSON if name ends in .json, and YAML otherwise
+func loadConfig(k *koanf.Koanf, b []byte, name string) error {
+	var parser koanf.Parser = yaml.Parser()
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		parser = json.Parser()
+	}
+	return k.Load(rawbytes.Provider(b), envParser{parser})
+}
+
 func setupconfig() {
 	k.Load(structs.Provider(Config{
 		Addr:  ":8000",
 		Nodes: []ObjSetup{}}, "koanf"), nil)
-	if err := k.Load(file.Provider(ConfigFileName), yaml.Parser()); err != nil {
+	if _, err := os.Stat(ConfigFileName); os.IsNotExist(err) {
+		if _, err := os.Stat(JSONConfigFileName); err == nil {
+			ConfigFileName = JSONConfigFileName
+		}
+	}
+	b, err := ioutil.ReadFile(ConfigFileName)
+	if err != nil {
 		errtxt := err.Error()
 		if !strings.Contains(errtxt, "no such") { // file missing, who cares
 			log.Fatalf("error loading config: %v", err)
 		}
+		return
+	}
+	if err := loadConfig(k, b, ConfigFileName); err != nil {
+		log.Fatalf("error loading config: %v", err)
 	}
 }
 
@@ -62,6 +108,224 @@ that there are no endpoints.
 
 No two endpoints can have the same URL.
 
+Motion controllers accept Args: Limits (per-axis Min and Max) to reject moves outside
+them.  Args: ClampToLimits: true clamps those moves to the limit instead, for jogging;
+the position actually commanded is returned in the X-Motion-Clamped header.
+<endpoint>/axes lists the axes of a motion controller.  Args: Axes: [X, Y, Z] supplies
+the list for controllers which cannot enumerate their own, or overrides it.  It is
+required for Aerotech.
+A POST to <endpoint>/emergency-stop halts every axis of a motion controller (PI STP,
+Aerotech ABORT, XPS KillAll).  It ignores locks, and every use is logged.
+
+RequestTimeout: "5m" (the default) bounds how long any request may take; after it the
+client receives 503 and the request is cancelled.  "0" disables it.  Event streams
+are exempt.
+
+Compress: true gzips responses for clients that send Accept-Encoding: gzip, which
+greatly shrinks raw camera frames.  JPEG and PNG images and streams are not compressed.
+
+Browser pages served from another origin may only use the server if their origin is
+listed, e.g. CORSOrigins: [http://dash.lab:8080], or CORSOrigins: ["*"] for any.  No
+origins are allowed by default.
+
+Request bodies larger than MaxBodyBytes: 1048576 (1 MiB, the default) are refused with
+413 Request Entity Too Large.  A negative value removes the limit.
+
+Every request is logged to stderr as a line of JSON with its time, method, path, query,
+remote address (and X-Forwarded-For), X-Client-ID, status, response size, and duration
+in ms.  AccessLog: /var/log/multiserver.log appends the lines to that file instead, and
+AccessLog: off turns the log off.  Bodies are not logged unless the path contains one of
+AccessLogBodies, e.g. [/factory-reset, /emission, /limits], and then only for writes
+and only the first 4096 bytes, so image uploads stay out of the log.
+
+Single values are returned named by their type, {"f64": 1.5}, {"int": 2}, {"str": "a"},
+{"bool": true}.  PayloadShape: value returns every type as {"value": ...} instead, so
+clients need not know the type.  Requests to set a single value may use either shape.
+
+A serial device behind a terminal server which speaks RFC 2217 is given Serial: true and
+Addr: rfc2217://portserver:4001; the baud, parity, and so on are then set over the
+network just as for a local port.  Raw TCP (Serial: false) and local ports are unchanged.
+
+The configuration may also be written as JSON in multiserver.json, with the same
+keys; it is used when multiserver.yml does not exist.
+
+Any value in the file may reference an environment variable as ${VAR}, or
+${VAR:-default} to fall back on default when VAR is unset, e.g.
+Addr: ${NKT_ADDR:-192.168.100.40:2116}.  Only values are expanded, after the file is
+parsed, so a variable cannot change the structure of the file.  The value stays text,
+so a token of 00123 is kept as written, and numeric or true/false settings such as
+PoolSize: ${PI_POOL:-1} parse it themselves.  A variable which is unset and has no
+default stops the server with an error.
+
+Probe: true sends every node a cheap query at startup (its firmware version, a status
+register, or a reading, else an identification) and logs which answered;
+ProbeRetries: 2 tries unresponsive nodes that many more times, a second apart.  Nodes
+which do not answer are still served.  /health reports the result for every node, and
+/health?probe=true probes them all again.
+
+GET /endpoints lists the routes of every node.  GET /endpoints?identify=true also asks
+each node which can (PI, Cryocon) to identify itself, and returns
+{"endpoints": {...}, "devices": {"/omc/pi": {"vendor", "model", "serial", "version"}}}
+for taking inventory of a rack.  Each of those nodes also serves <endpoint>/whoami.
+
+GET /openapi.json describes every route of every node as OpenAPI 3, tagged by endpoint,
+for generating clients.  Routes which take or return a single typed value, {"f64": 1.5}
+and the like, have their bodies described (in the PayloadShape in use) where the driver
+registers them, as the lasers do; the bodies of other routes are not, only their paths
+and parameters.
+
+GET /version returns {"version", "commit", "buildDate", "goVersion"} of the build, as
+does the version command.  The first three are set when building with
+-ldflags "-X github.com/nasa-jpl/golaborate/generichttp.Version=v1.4.0
+-X github.com/nasa-jpl/golaborate/generichttp.Commit=$(git rev-parse --short HEAD)
+-X github.com/nasa-jpl/golaborate/generichttp.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+and are otherwise the version of multiserver (or -X main.Version) and "unknown".
+
+Motion nodes stream the position of an axis over a websocket at <endpoint>/axis/X/ws,
+as JSON {"axis", "pos", "moving", "time"} messages.  While the axis moves its position
+is sent ?rate=20 times a second (at most 100); while it is still, only changes are sent.
+
+POST <endpoint>/axis/X/stop brings one axis to a controlled stop: PI HLT at the axis
+deceleration, XPS GroupMoveAbort at the profile deceleration, Aerotech ABORT at the
+AbortDecelRate parameter.  POST <endpoint>/axis/X/halt stops it faster where the
+controller can (XPS GroupMoveAbortFast, 5x the deceleration).  PI and Aerotech have no
+abrupt stop of a single axis; /emergency-stop stops all of them.
+
+GET <endpoint>/wait-idle on a motion node responds once no axis is moving (PI: every axis
+on target, XPS: no group moving or homing, Aerotech: no move active), or with 504 naming
+the axes still moving after ?timeout=30s.
+
+Aerotech nodes expose the digital I/O of the drive of the first axis, or of Args:
+{IOAxis: "Y"}.  GET <endpoint>/dio/in/2 reads input 2, and POST {"bool": true} to
+<endpoint>/dio/out/2 sets output 2.  There are 4 of each unless Args: {DigitalInputs:
+8, DigitalOutputs: 8} say otherwise; using a line as the wrong direction is an error.
+
+Aerotech axes listed in Args: {BrakedAxes: [Z]} have their brake released after they
+are enabled and engaged before they are disabled.  <endpoint>/axis/Z/brake reads or,
+with POST {"bool": true}, engages the brake by hand; false releases it, which is a 409
+while the axis is disabled.  Args: {ProhibitedZones: [{X: {Min: 0, Max: 10}, Z: {Min: -5,
+Max: 0}}]} rejects any move that would end in or pass through the box where every
+listed axis is in its range, before it is sent to the controller.  An axis already in a
+zone may only be moved out of it.  GET <endpoint>/zones lists them.  Quote an axis
+named Y or N, as in "Y": {Min: 0, Max: 1}, since YAML reads those bare as booleans.
+
+A move of a PI axis to within Args: {Epsilon: 1e-6} of where it already is returns
+without moving, as some firmware never reports such a move complete.  0 disables this.
+PI axes answer to their position as well as their name, 1..N for controllers which
+name them A..Z and vice versa.  Args: {AxisAliases: {X: "1", Y: "2"}} adds more names.
+<endpoint>/axes/aliases lists every alias.
+
+Args: {PCAddress: 5} is for PI networks where the PC is not address 0; responses
+addressed elsewhere are rejected.
+
+PI lines end with a newline.  For firmware which uses another line ending, set
+Args: {Terminator: "\r\n"} (double quoted, so the escapes are understood).  A PI query
+whose connection is dropped or reset is tried twice more on a fresh connection; Args:
+{Retries: n} changes how many times.  Timeouts and controller errors are not retried.
+
+Args: {RawHistory: 50} keeps the last 50 commands sent to <endpoint>/raw, with their
+responses, errors, and times, and GET <endpoint>/raw/history returns them oldest
+first.  Nothing is recorded unless it is set.
+
+Args: {Debug: true} logs every message sent to and read from a PI, ESP, Aerotech,
+Fluke, or SCPI device, terminators included, in hex and as quoted text, e.g.
+  omc/pi tx 31 20 50 4f 53 3f 0a "1 POS?\n"
+A read which never finds its terminator is logged with what did arrive and the
+error, which shows what line ending the device really uses.
+
+PI nodes can store macros on the controller.  POST {"str": "name"} to
+<endpoint>/macro/record, send the commands to <endpoint>/raw, then POST to
+<endpoint>/macro/end.  POST {"str": "name"} to <endpoint>/macro/run to run it.  While
+recording, queries and absolute moves, which wait for the axis to arrive, are refused
+with 409 Conflict.  An emergency stop ends the recording, so the stop is run rather than
+stored.
+
+PI nodes also expose the wave generator, for smooth scans of piezo stages.  POST
+{"type": "sine", "points": 1000, "amplitude": 10, "offset": 0, "rate": 1} to
+<endpoint>/wave/1/table to define the table of generator 1 ("linear" for a ramp),
+{"int": cycles} to <endpoint>/wave/1/start to play it (0 repeats until stopped),
+and POST to <endpoint>/wave/1/stop to stop it.  Generator n drives axis n.
+
+GET <endpoint>/recorder/1 on a PI node returns the points in record table 1 of the
+controller's data recorder as a JSON array, sampled at the servo rate.
+
+ESP nodes home by the home and index signals unless POST {"str": "negative-limit"} to
+<endpoint>/axis/1/home/mode picks another of those listed at <endpoint>/home-modes.
+POST <endpoint>/axis/1/home/wait homes and responds once the search is done, with any
+error the controller raised, or stops it after Args: {HomeTimeout: 2m}.
+
+XPS nodes gather data at a divisor of the servo rate.  POST {"types":
+["Group1.Pos.CurrentPosition", "Group1.Pos.CurrentVelocity"]} to <endpoint>/gathering/config,
+then {"points": 10000, "divisor": 1} to <endpoint>/gathering/start before the move.
+GET <endpoint>/gathering/status reports how many were taken, and <endpoint>/gathering/data
+returns {"types", "data"} with one array per type.  POST <endpoint>/gathering/stop ends it early.
+
+GET <endpoint>/snapshot on a PI node returns the position, servo state, referencing,
+and on-target state of every axis.  With ?errors=true it adds the controller's error
+code from ERR?, which clears the error, so that no other client will see it.
+
+Two controllers of a pi-daisy-chain can form an XY gantry for raster scans with
+Args: {Gantry: {Endpoint: omc/gantry, XAxis: {Controller: 1, Axis: "1"}, YAxis:
+{Controller: 2, Axis: "1"}}}.  POST {"x": {"start": 0, "step": 1, "count": 10}, "y": {...},
+"serpentine": true, "dwell": 0.1} to <gantry endpoint>/raster to scan the grid; each
+point is streamed as an event once both axes are on target, then "done" or "error".
+The limits of each controller are applied to the whole scan before it starts, and
+the scan waits on the locks of both axes.  Limits in a DaisyChain entry override
+those of the node for that controller alone.
+
+SuperK nodes refuse to turn emission on while the interlock is open, saying which
+interlock condition is at fault; GET <endpoint>/interlock is true when it is closed.
+POST {"f64": 60} to <endpoint>/power/ramp to change the power level 5% at a time,
+five steps a second, instead of all at once.  The ramp stops if the interlock opens.
+
+POST {"center": 650, "width": 50} to <endpoint>/band on a SuperK with a Varia to set
+both filter edges at once.  The band must lie within 400-850 nm and be 10-100 nm wide,
+and the edges are moved in the order which keeps them from crossing.
+
+GET <endpoint>/input/A/config on a cryocon node returns the sensor selected for input
+A, as its index in the monitor's sensor table with its name and type.  POST {"sensor":
+3} to the same URL to select another; {"sensor": 9, "type": "PTC100"} also sets the
+type of a user curve.  GET <endpoint>/sensor-types lists the valid types.
+
+The relays of a cryocon are configured at <endpoint>/relay/0/config with {"source": "A",
+"high": 300, "low": 77, "enableHigh": true, "enableLow": false, "deadband": 1}, the
+setpoints in the units of the source input.  The monitor switches them itself, so they
+work with the server down.  GET <endpoint>/relay/0/state is true while it is asserted.
+
+GET <endpoint>/environment on a DewK node returns the temperature, humidity, and
+dewpoint from a single reading, with its time and the units of each.
+
+GET <endpoint>/minmax on a DewK node returns the least and greatest temperature and
+humidity since the last POST to <endpoint>/minmax/reset.  The DewK does not report
+its own, so they are taken over the readings the server makes; "source" says so.  The
+server reads the DewK every 10 seconds for this, or as often as Args: {SampleInterval:
+1m}; SampleInterval: 0s leaves only the readings clients ask for.
+
+By default one connection is made to each device, so requests to it are served one
+at a time.  For TCP devices which accept several connections, Args: {PoolSize: 4}
+allows up to that many, so status queries need not wait on a long move.  This is
+honored by pi, pi-daisy-chain, cryocon, keysight-scope, keysight-daq, and
+agilent-function-generator nodes.  The XPS always allows many connections.
+
+A POST to <endpoint>/admin/reconnect closes the idle connections to that device, so a
+connection which has gone stale is replaced without restarting the server.  On SIGINT
+or SIGTERM the server finishes the requests in flight and closes the connections to
+every device, freeing serial ports for the next run.
+
+Every endpoint has a lock, manipulated at <endpoint>/lock (or /axis/{axis}/lock for
+motion controllers).  <endpoint>/lock/status reports who holds it and since when, and
+a POST to <endpoint>/lock/force-release frees a lock abandoned by a crashed client.
+Force-release requires the lock's token, or the LockAdminToken of the config in the
+X-Admin-Token header; without either it fails with 403.
+Acquiring a lock ({"bool": true}) returns a token, which must be sent in the
+X-Lock-Token header of every request to the locked endpoint and to release it.
+<endpoint>/lock/transfer with {"holder": "name"} hands the lock to another client
+and returns the token for them.
+Args: LockTTL: "10m" (any Go duration) makes locks on that endpoint expire on their own.
+Args: LockQueue: "30s" makes requests to a locked endpoint wait up to that long for
+the lock to free, in order of arrival, instead of failing with 423; if it does not free
+in time they fail with 503 and a Retry-After header.
+
 URLs may look like any variation between "omc/nkt" or "/omc/nkt/*", the leading
 and trailing slashes, as well as the *, are added by the server if missing.
 
@@ -82,9 +346,12 @@ Hardware and matching "type" fields, case insensitive, alphabetical by vendor:
 	> KJC pressure sensor, "kjc", "lesker"
 - Newport
 	> ESP300 / ESP301 "esp", "esp300", "esp301"
+	  (Args: LimitsFromController: true populates missing limits from the controller)
 	> XPS "xps"
 - NKT
 	> SuperK Extreme / SuperK Varia "nkt", "superk"
+	  (Args: MaxPower: 80 caps ramped power in percent; RampStep: 5 and RampInterval: "200ms"
+	  set the size of and time between the steps of a ramp)
 - Thermocube
 	> 200, 300, 400 series "cube" fluid temperature controllers, "thermocube"
 - Thorlabs
@@ -124,21 +391,52 @@ func printconf() {
 }
 
 func pversion() {
-	fmt.Printf("multiserver version %v\n", Version)
+	fmt.Printf("multiserver version %s\n", generichttp.GetBuildInfo())
 }
 
+// shutdownTimeout is how long requests in flight are given to finish when the
+// server is stopped
+const shutdownTimeout = 10 * time.Second
+
 func run() {
 	c := Config{}
 	err := k.Unmarshal("", &c)
 	if err != nil {
 		log.Fatal(err)
 	}
-	mux := BuildMux(c)
+	mux, shutdowners := buildMux(c)
+	srv := &http.Server{Addr: c.Addr, Handler: mux}
+
+	// on SIGINT or SIGTERM, finish the requests in flight and then release
+	// the devices, so that serial ports are free when the server restarts
+	done := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		log.Println("shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Println("error shutting down HTTP server: ", err)
+		}
+		for _, sd := range shutdowners {
+			if err := sd.Shutdown(); err != nil {
+				log.Println("error shutting down device: ", err)
+			}
+		}
+		close(done)
+	}()
 	log.Println("now listening for requests at ", c.Addr)
-	log.Fatal(http.ListenAndServe(c.Addr, mux))
+	err = srv.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
 }
 
 func main() {
+	generichttp.DefaultVersion(Version)
 	var cmd string
 	args := os.Args
 	if len(args) == 1 {
@@ -3,10 +3,14 @@ package comm
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -21,6 +25,11 @@ const (
 var (
 	// ErrTimeout is generated by timeout on a reader or writer
 	ErrTimeout = errors.New("io: timeout")
+
+	// ErrPortDisconnected is generated when a serial port disappears, e.g.
+	// because the USB-serial adapter was unplugged.  The connection it
+	// was generated on is no good and should be destroyed
+	ErrPortDisconnected = errors.New("comm: serial port disconnected")
 )
 
 // CreationFunc is a function which returns a new "connection" to something
@@ -38,18 +47,23 @@ type Pool struct {
 	interrupt   chan struct{}           // interrupt is used to stop the background closer
 	sem         chan struct{}           // sem is the semaphore used to ensure acquisitions from the pool are atomic
 	maker       func() (io.ReadWriteCloser, error)
+	closeOnce   sync.Once // Close stops the background closer only once
 }
 
 // NewPool creates a new pool.  At each interval of timeout, a connection
 // may be freed if it is available.  Calling Close terminates the background
 // goroutine which closes idle connections and drains the pool as it is immediately
-// if idleTimeout is zero, the pool never frees connections.
+// if idleTimeout is zero, the pool never frees connections.  A maxSize
+// below one is treated as one.
 func NewPool(maxSize int, idleTimeout time.Duration, maker CreationFunc) *Pool {
+	if maxSize < 1 {
+		maxSize = 1
+	}
 	p := &Pool{
 		maxSize:     maxSize,
 		idleTimeout: idleTimeout,
 		conns:       make(chan io.ReadWriteCloser, maxSize),
-		interrupt:   make(chan struct{}),
+		interrupt:   make(chan struct{}, 1), // buffered so Close need not wait out the idle sleep
 		sem:         make(chan struct{}, 1),
 		maker:       maker,
 	}
@@ -70,6 +84,14 @@ func NewPool(maxSize int, idleTimeout time.Duration, maker CreationFunc) *Pool {
 //
 // If the error from Get is not nil, you must not return it to the pool.
 func (p *Pool) Get() (io.ReadWriter, error) {
+	return p.GetContext(context.Background())
+}
+
+// GetContext is Get, but gives up waiting for a connection when ctx is done
+func (p *Pool) GetContext(ctx context.Context) (io.ReadWriter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	select {
 	case rw := <-p.conns:
 		p.onLease++
@@ -83,9 +105,13 @@ func (p *Pool) Get() (io.ReadWriter, error) {
 			// the user.  May cause a deadlock by not returning, but that
 			// is an error in their program, not the pool.
 			// eventually, they must return one.
-			rw := <-p.conns
-			p.onLease++
-			return rw, nil
+			select {
+			case rw := <-p.conns:
+				p.onLease++
+				return rw, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 
 		// due to a subtle race, we need to select again
@@ -138,6 +164,22 @@ func (p *Pool) ReturnWithError(rw io.ReadWriter, err error) {
 
 }
 
+// Drain closes every idle connection in the pool, so that the next Get makes
+// a fresh one.  This recovers from a connection which has gone stale without
+// erroring.  Connections in use are unaffected and are returned as usual
+func (p *Pool) Drain() {
+	<-p.sem
+	defer func() { p.sem <- struct{}{} }()
+	for {
+		select {
+		case rw := <-p.conns:
+			rw.Close()
+		default:
+			return
+		}
+	}
+}
+
 // Size returns the number of connections in the pool, or given out from it
 func (p *Pool) Size() int {
 	return len(p.conns) + p.onLease
@@ -165,28 +207,58 @@ func (p *Pool) destroyTrash() {
 
 // Close interrupts the background collection of idle connections
 // if it is not called, the garbage collector can never free the pool and the
-// background worker will never stop
+// background worker will never stop.  It is safe to call Close more than once,
+// as when several devices share a pool
 func (p *Pool) Close() {
-	p.interrupt <- struct{}{} // stop the background "garbage collection"
-	<-p.sem                   // acquire the semaphore and prevent new connections
-	for len(p.conns) > 0 {    // not a range so that the loop will skip if the pool is empty
+	p.closeOnce.Do(func() {
+		if p.idleTimeout != 0 {
+			p.interrupt <- struct{}{} // stop the background "garbage collection"
+		}
+	})
+	<-p.sem                // acquire the semaphore and prevent new connections
+	for len(p.conns) > 0 { // not a range so that the loop will skip if the pool is empty
 		c := <-p.conns
 		c.Close()
 	}
 	p.sem <- struct{}{}
 }
 
+// Debugger is a device which can log the raw bytes it sends and receives,
+// e.g. to find out what line endings a new device uses
+type Debugger interface {
+	// SetDebug sets the writer traffic is logged to.  nil turns logging off
+	SetDebug(w io.Writer)
+}
+
+// logTraffic writes one line to w describing b, as hex and as quoted text so
+// that the line endings are visible.  dir is "tx" or "rx"
+func logTraffic(w io.Writer, dir string, b []byte, err error) {
+	if err != nil {
+		fmt.Fprintf(w, "%s % x %q (%v)\n", dir, b, b, err)
+		return
+	}
+	fmt.Fprintf(w, "%s % x %q\n", dir, b, b)
+}
+
 // Terminator is a struct holding termination sequences and read/writers
 type Terminator struct {
 	Wterm byte
 	Rterm byte
 	w     io.Writer
 	r     io.Reader
+
+	// Debug, if not nil, receives a line for every message written or read,
+	// with the terminators
+	Debug io.Writer
 }
 
 func (t Terminator) Write(b []byte) (int, error) {
 	b = append(b, t.Wterm)
-	return t.w.Write(b)
+	n, err := t.w.Write(b)
+	if t.Debug != nil {
+		logTraffic(t.Debug, "tx", b, err)
+	}
+	return n, err
 }
 
 // Read implements io.Reader.  The input is scanned up to the first encounter
@@ -194,6 +266,9 @@ func (t Terminator) Write(b []byte) (int, error) {
 // buf is double buffered for this purpose.
 func (t Terminator) Read(buf []byte) (int, error) {
 	b, err := bufio.NewReader(t.r).ReadBytes(t.Rterm)
+	if t.Debug != nil {
+		logTraffic(t.Debug, "rx", b, err)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -210,6 +285,76 @@ func NewTerminator(rw io.ReadWriter, Rx, Tx byte) Terminator {
 	return Terminator{w: rw, r: rw, Wterm: Tx, Rterm: Rx}
 }
 
+// LineTerminator is like Terminator, but for line endings of more than one
+// byte, such as "\r\n"
+type LineTerminator struct {
+	Wterm string
+	Rterm string
+	w     io.Writer
+	r     io.Reader
+
+	// Debug is as in Terminator
+	Debug io.Writer
+}
+
+func (t LineTerminator) Write(b []byte) (int, error) {
+	b = append(b, t.Wterm...)
+	n, err := t.w.Write(b)
+	if t.Debug != nil {
+		logTraffic(t.Debug, "tx", b, err)
+	}
+	return n, err
+}
+
+// byteReader is a reader which is already buffered, such as a bufio.Reader
+type byteReader interface {
+	ReadBytes(delim byte) ([]byte, error)
+}
+
+// Read implements io.Reader.  The input is scanned up to the first encounter
+// of Rterm, which is stripped from the message and the remainder returned.
+// If the underlying reader is buffered (has ReadBytes), it is read directly,
+// so that successive reads of a multi-line reply lose nothing
+func (t LineTerminator) Read(buf []byte) (int, error) {
+	if t.Rterm == "" {
+		n, err := t.r.Read(buf)
+		if t.Debug != nil {
+			logTraffic(t.Debug, "rx", buf[:n], err)
+		}
+		return n, err
+	}
+	br, ok := t.r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(t.r)
+	}
+	last := t.Rterm[len(t.Rterm)-1]
+	var b []byte
+	for {
+		chunk, err := br.ReadBytes(last)
+		b = append(b, chunk...)
+		if err != nil {
+			if t.Debug != nil {
+				logTraffic(t.Debug, "rx", b, err)
+			}
+			return 0, err
+		}
+		if bytes.HasSuffix(b, []byte(t.Rterm)) {
+			break
+		}
+	}
+	if t.Debug != nil {
+		logTraffic(t.Debug, "rx", b, nil)
+	}
+	b = b[:len(b)-len(t.Rterm)]
+	return copy(buf, b), nil
+}
+
+// NewLineTerminator returns a wrapper around a Read/Writer that appends and
+// strips termination sequences
+func NewLineTerminator(rw io.ReadWriter, Rx, Tx string) LineTerminator {
+	return LineTerminator{w: rw, r: rw, Wterm: Tx, Rterm: Rx}
+}
+
 type deadlineWriter interface {
 	io.Writer
 	SetWriteDeadline(t time.Time) error
@@ -302,9 +447,46 @@ func BackingOffTCPConnMaker(address string, timeout time.Duration) CreationFunc
 }
 
 // SerialConnMaker creates the closure for a new serial connection based on a
-// config
+// config.  Errors caused by the port disappearing are returned as
+// ErrPortDisconnected.  If cfg.Name begins with RFC2217Scheme, the port is
+// reached through a terminal server instead
 func SerialConnMaker(cfg *serial.Config) CreationFunc {
+	if addr, ok := isRFC2217(cfg.Name); ok {
+		return rfc2217ConnMaker(addr, cfg)
+	}
 	return func() (io.ReadWriteCloser, error) {
-		return serial.OpenPort(cfg)
+		port, err := serial.OpenPort(cfg)
+		if err != nil {
+			return nil, portErr(err)
+		}
+		return serialConn{port}, nil
+	}
+}
+
+// serialConn wraps a serial port and translates port-gone errors
+type serialConn struct {
+	io.ReadWriteCloser
+}
+
+func (s serialConn) Read(b []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Read(b)
+	return n, portErr(err)
+}
+
+func (s serialConn) Write(b []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Write(b)
+	return n, portErr(err)
+}
+
+// portErr converts errors that indicate the device backing a serial port
+// is gone into ErrPortDisconnected, and passes all others through unchanged.
+// os.ErrClosed is not among them; it means the port was closed on purpose
+func portErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if os.IsNotExist(err) || errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ENXIO) || errors.Is(err, syscall.ENODEV) {
+		return fmt.Errorf("%w: %v", ErrPortDisconnected, err)
 	}
+	return err
 }
@@ -1,6 +1,9 @@
 package comm_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -8,6 +11,7 @@ import (
 	"time"
 
 	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/tarm/serial"
 )
 
 func tcpEchoServer(addr string) {
@@ -125,3 +129,195 @@ func TestLoggingDebugWithEchoReleasesExpires(t *testing.T) {
 func TestLoggingDebugWithEchoMaintainsSize(t *testing.T) {
 	LoggingDebugDeadlocksIfTryToTakeTooMany(3)
 }
+
+func TestSerialConnMakerMissingPortIsDisconnected(t *testing.T) {
+	maker := comm.SerialConnMaker(&serial.Config{Name: "/dev/golab-no-such-port", Baud: 9600})
+	_, err := maker()
+	if !errors.Is(err, comm.ErrPortDisconnected) {
+		t.Errorf("expected ErrPortDisconnected, got %v", err)
+	}
+}
+
+type countingConn struct {
+	closed *int
+}
+
+func (c countingConn) Read(b []byte) (int, error)  { return 0, nil }
+func (c countingConn) Write(b []byte) (int, error) { return len(b), nil }
+func (c countingConn) Close() error                { *c.closed++; return nil }
+
+func TestPoolDrainMakesFreshConnections(t *testing.T) {
+	made, closed := 0, 0
+	p := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		made++
+		return countingConn{closed: &closed}, nil
+	})
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(conn)
+	p.Drain()
+	if closed != 1 {
+		t.Errorf("expected the idle connection to be closed, %d were", closed)
+	}
+	conn, err = p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(conn)
+	if made != 2 {
+		t.Errorf("expected a new connection after draining, %d were made", made)
+	}
+}
+
+func TestPoolCloseTwice(t *testing.T) {
+	closed := 0
+	p := comm.NewPool(1, time.Hour, func() (io.ReadWriteCloser, error) {
+		return countingConn{closed: &closed}, nil
+	})
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(conn)
+	done := make(chan struct{})
+	go func() {
+		p.Close()
+		p.Close() // as by a second device sharing the pool
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("closing a pool twice blocked")
+	}
+	if closed != 1 {
+		t.Errorf("expected the connection to be closed once, it was closed %d times", closed)
+	}
+}
+
+func TestPoolGetContextGivesUpWhenDone(t *testing.T) {
+	closed := 0
+	p := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return countingConn{closed: &closed}, nil
+	})
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Put(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.GetContext(ctx) // the only connection is on lease
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestLineTerminatorCRLF(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := comm.NewLineTerminator(buf, "\r\n", "\r\n")
+	if _, err := io.WriteString(term, "*IDN?"); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "*IDN?\r\n" {
+		t.Errorf("expected the command to end with CRLF, got %q", got)
+	}
+	buf.Reset()
+	buf.WriteString("a\rb\r\n")
+	b := make([]byte, 16)
+	n, err := term.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b[:n]); got != "a\rb" {
+		t.Errorf("expected a lone CR to be kept and the CRLF stripped, got %q", got)
+	}
+}
+
+func TestLineTerminatorDebug(t *testing.T) {
+	buf := &bytes.Buffer{}
+	dbg := &bytes.Buffer{}
+	term := comm.NewLineTerminator(buf, "\r\n", "\n")
+	term.Debug = dbg
+	if _, err := io.WriteString(term, "POS?"); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	buf.WriteString("1=0.5\n")
+	if _, err := term.Read(make([]byte, 16)); err != io.EOF {
+		t.Fatalf("expected EOF reading with the wrong terminator, got %v", err)
+	}
+	want := "tx 50 4f 53 3f 0a \"POS?\\n\"\nrx 31 3d 30 2e 35 0a \"1=0.5\\n\" (EOF)\n"
+	if got := dbg.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestRFC2217SetsPortAndStripsTelnet(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// ack the baud rate, ask for terminal type, then send data with an
+		// escaped 0xFF in it
+		conn.Write([]byte{255, 250, 44, 101, 0, 0, 37, 128, 255, 240, 255, 253, 24, 'o', 'k', 255, 255, '\n'})
+		buf := make([]byte, 256)
+		var all []byte
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for !bytes.Contains(all, []byte{255, 252, 24}) || !bytes.Contains(all, []byte("x\xff\xffy")) {
+			n, err := conn.Read(buf)
+			all = append(all, buf[:n]...)
+			if err != nil {
+				break
+			}
+		}
+		got <- all
+	}()
+
+	cfg := &serial.Config{Name: comm.RFC2217Scheme + ln.Addr().String(), Baud: 9600, Size: 7, Parity: serial.ParityEven, StopBits: serial.Stop2}
+	rwc, err := comm.SerialConnMaker(cfg)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+	buf := make([]byte, 16)
+	var data []byte
+	for !bytes.HasSuffix(data, []byte{'\n'}) {
+		n, err := rwc.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, buf[:n]...)
+	}
+	if !bytes.Equal(data, []byte{'o', 'k', 255, '\n'}) {
+		t.Errorf("expected telnet commands stripped and 0xFF unescaped, got %v", data)
+	}
+	if _, err = rwc.Write([]byte("x\xffy")); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := <-got
+	for _, want := range [][]byte{
+		{255, 251, 44}, // WILL COM-PORT-OPTION
+		{255, 250, 44, 1, 0, 0, 37, 128, 255, 240}, // SET-BAUDRATE 9600
+		{255, 250, 44, 2, 7, 255, 240},             // SET-DATASIZE 7
+		{255, 250, 44, 3, 3, 255, 240},             // SET-PARITY EVEN
+		{255, 250, 44, 4, 2, 255, 240},             // SET-STOPSIZE 2
+		{255, 252, 24},                             // WONT TERMINAL-TYPE
+		[]byte("x\xff\xffy"),                       // escaped data
+	} {
+		if !bytes.Contains(sent, want) {
+			t.Errorf("expected %v to be sent, got %v", want, sent)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package comm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// RFC2217Scheme prefixes the address of a serial port which is reached through
+// a terminal server speaking the telnet com port option of RFC 2217, e.g.
+// rfc2217://portserver:4001.  SerialConnMaker dials such addresses over TCP
+// and sets the baud, size, parity, and stop bits of the remote port from the
+// serial.Config
+const RFC2217Scheme = "rfc2217://"
+
+// RFC2217DialTimeout is the timeout for each attempt to reach a terminal server
+var RFC2217DialTimeout = 3 * time.Second
+
+// telnet commands and options, RFC 854, 856, 858, and 2217
+const (
+	telIAC  = 255
+	telDONT = 254
+	telDO   = 253
+	telWONT = 252
+	telWILL = 251
+	telSB   = 250
+	telSE   = 240
+
+	optBinary  = 0
+	optSGA     = 3
+	optComPort = 44
+
+	cpcSetBaud     = 1
+	cpcSetDataSize = 2
+	cpcSetParity   = 3
+	cpcSetStopSize = 4
+	cpcSetControl  = 5
+
+	cpcNoFlowControl = 1
+)
+
+// isRFC2217 returns the host:port of an RFC2217 address, and if it is one
+func isRFC2217(addr string) (string, bool) {
+	if strings.HasPrefix(addr, RFC2217Scheme) {
+		return strings.TrimPrefix(addr, RFC2217Scheme), true
+	}
+	return addr, false
+}
+
+// comPortSettings returns the subnegotiations which configure a remote port
+// like cfg
+func comPortSettings(cfg *serial.Config) ([][]byte, error) {
+	size := cfg.Size
+	if size == 0 {
+		size = serial.DefaultSize
+	}
+	var parity byte
+	switch cfg.Parity {
+	case serial.ParityNone, 0:
+		parity = 1
+	case serial.ParityOdd:
+		parity = 2
+	case serial.ParityEven:
+		parity = 3
+	case serial.ParityMark:
+		parity = 4
+	case serial.ParitySpace:
+		parity = 5
+	default:
+		return nil, fmt.Errorf("comm: unknown parity %c for RFC2217", cfg.Parity)
+	}
+	var stop byte
+	switch cfg.StopBits {
+	case serial.Stop1, 0:
+		stop = 1
+	case serial.Stop2:
+		stop = 2
+	case serial.Stop1Half:
+		stop = 3
+	default:
+		return nil, fmt.Errorf("comm: unknown stop bits %d for RFC2217", cfg.StopBits)
+	}
+	baud := make([]byte, 4)
+	binary.BigEndian.PutUint32(baud, uint32(cfg.Baud))
+	return [][]byte{
+		append([]byte{cpcSetBaud}, baud...),
+		{cpcSetDataSize, size},
+		{cpcSetParity, parity},
+		{cpcSetStopSize, stop},
+		{cpcSetControl, cpcNoFlowControl},
+	}, nil
+}
+
+// escapeIAC doubles every IAC byte in b, as telnet requires of data
+func escapeIAC(b []byte) []byte {
+	n := 0
+	for _, c := range b {
+		if c == telIAC {
+			n++
+		}
+	}
+	if n == 0 {
+		return b
+	}
+	out := make([]byte, 0, len(b)+n)
+	for _, c := range b {
+		out = append(out, c)
+		if c == telIAC {
+			out = append(out, telIAC)
+		}
+	}
+	return out
+}
+
+// rfc2217Conn is a telnet connection to a terminal server which carries the
+// data of a serial port.  Telnet commands from the server are answered or
+// discarded, so that only the data of the port reaches the reader
+type rfc2217Conn struct {
+	net.Conn
+	br *bufio.Reader
+
+	// readTimeout mimics the ReadTimeout of a local serial port
+	readTimeout time.Duration
+
+	wmu sync.Mutex
+}
+
+// newRFC2217Conn negotiates binary transmission and the com port option on
+// conn and configures the remote port like cfg
+func newRFC2217Conn(conn net.Conn, cfg *serial.Config) (*rfc2217Conn, error) {
+	settings, err := comPortSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	buf := []byte{
+		telIAC, telWILL, optComPort,
+		telIAC, telWILL, optBinary,
+		telIAC, telDO, optBinary,
+		telIAC, telWILL, optSGA,
+		telIAC, telDO, optSGA,
+	}
+	for _, s := range settings {
+		buf = append(buf, telIAC, telSB, optComPort)
+		buf = append(buf, escapeIAC(s)...)
+		buf = append(buf, telIAC, telSE)
+	}
+	if _, err = conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return &rfc2217Conn{Conn: conn, br: bufio.NewReader(conn), readTimeout: cfg.ReadTimeout}, nil
+}
+
+// command writes a telnet command to the server
+func (c *rfc2217Conn) command(verb, opt byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Conn.Write([]byte{telIAC, verb, opt})
+	return err
+}
+
+// handleIAC consumes the remainder of a telnet command.  It returns the data
+// byte and true for an escaped IAC
+func (c *rfc2217Conn) handleIAC() (byte, bool, error) {
+	verb, err := c.br.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch verb {
+	case telIAC:
+		return telIAC, true, nil
+	case telDO, telDONT, telWILL, telWONT:
+		opt, err := c.br.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		if opt == optBinary || opt == optSGA || opt == optComPort {
+			// asked for by us, or agreed to
+			return 0, false, nil
+		}
+		switch verb {
+		case telDO:
+			err = c.command(telWONT, opt)
+		case telWILL:
+			err = c.command(telDONT, opt)
+		}
+		return 0, false, err
+	case telSB:
+		// the server's replies to the com port settings, and any other
+		// subnegotiation, are of no interest; skip to IAC SE
+		var prev byte
+		for {
+			b, err := c.br.ReadByte()
+			if err != nil {
+				return 0, false, err
+			}
+			if prev == telIAC {
+				if b == telSE {
+					return 0, false, nil
+				}
+				b = 0 // an escaped IAC does not start IAC SE
+			}
+			prev = b
+		}
+	default:
+		// NOP, GA, and friends carry no option
+		return 0, false, nil
+	}
+}
+
+// Read reads the data of the serial port, with telnet commands removed
+func (c *rfc2217Conn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if c.readTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	n := 0
+	for n < len(b) {
+		if n > 0 && c.br.Buffered() == 0 {
+			break
+		}
+		ch, err := c.br.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if ch == telIAC {
+			var data bool
+			ch, data, err = c.handleIAC()
+			if err != nil {
+				return n, err
+			}
+			if !data {
+				continue
+			}
+		}
+		b[n] = ch
+		n++
+	}
+	return n, nil
+}
+
+// Write writes b to the serial port, escaping any IAC bytes
+func (c *rfc2217Conn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Conn.Write(escapeIAC(b))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// rfc2217ConnMaker dials the terminal server at address with backoff and
+// configures its port like cfg
+func rfc2217ConnMaker(address string, cfg *serial.Config) CreationFunc {
+	dial := BackingOffTCPConnMaker(address, RFC2217DialTimeout)
+	return func() (io.ReadWriteCloser, error) {
+		rwc, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		conn := rwc.(net.Conn)
+		tc, err := newRFC2217Conn(conn, cfg)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+}
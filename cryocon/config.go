@@ -0,0 +1,130 @@
+package cryocon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// SensorTypes are the kinds of sensor a cryocon input can read
+var SensorTypes = []string{"NONE", "DIODE", "ACR", "PTC100", "PTC1K", "NTC10UA", "TC70"}
+
+// InputConfig is the sensor configuration of one input channel
+type InputConfig struct {
+	// Sensor is the index of the sensor, or calibration curve, in the sensor
+	// table of the monitor.  The factory curves come first, then the user
+	// curves
+	Sensor int `json:"sensor"`
+
+	// Name is the name of the sensor, e.g. "PT100 385"
+	Name string `json:"name"`
+
+	// Type is the kind of sensor the curve is for, one of SensorTypes.  Only
+	// the type of a user curve may be changed
+	Type string `json:"type"`
+}
+
+// checkChannel returns an error if ch is not an input letter
+func checkChannel(ch string) error {
+	if len(ch) != 1 || ch[0] < 'A' || ch[0] > 'H' {
+		return generichttp.BadRequest(fmt.Errorf("cryocon: input %q must be a letter from A to H", ch))
+	}
+	return nil
+}
+
+// checkSensorType normalizes typ and returns an error if it is not one of
+// SensorTypes
+func checkSensorType(typ string) (string, error) {
+	up := strings.ToUpper(strings.TrimSpace(typ))
+	for _, t := range SensorTypes {
+		if up == t {
+			return up, nil
+		}
+	}
+	return "", generichttp.BadRequest(fmt.Errorf("cryocon: sensor type %q is not one of %s", typ, strings.Join(SensorTypes, ", ")))
+}
+
+// query sends a query and returns the response, or an error if the monitor
+// did not understand it
+func (tm *TemperatureMonitor) query(cmd string) (string, error) {
+	resp, err := tm.s.ReadString(cmd)
+	if err != nil {
+		return "", err
+	}
+	resp = strings.TrimSpace(resp)
+	if resp == "NAK" {
+		return "", fmt.Errorf("cryocon: the monitor rejected %q", cmd)
+	}
+	return resp, nil
+}
+
+// GetInputConfig returns the sensor configuration of input ch, e.g. "A"
+func (tm *TemperatureMonitor) GetInputConfig(ch string) (InputConfig, error) {
+	var cfg InputConfig
+	ch = strings.ToUpper(ch)
+	if err := checkChannel(ch); err != nil {
+		return cfg, err
+	}
+	resp, err := tm.query(fmt.Sprintf("INPUT %s:SENSOR?", ch))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Sensor, err = strconv.Atoi(resp)
+	if err != nil {
+		return cfg, fmt.Errorf("cryocon: unable to parse sensor index %q: %w", resp, err)
+	}
+	cfg.Name, err = tm.query(fmt.Sprintf("SENSOR %d:NAME?", cfg.Sensor))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Name = strings.Trim(cfg.Name, `"`)
+	cfg.Type, err = tm.query(fmt.Sprintf("SENSOR %d:TYPE?", cfg.Sensor))
+	return cfg, err
+}
+
+// SetInputConfig selects the sensor of input ch.  If cfg.Type is not empty,
+// the type of the sensor is set first, which is only possible for user
+// curves.  The name is not changed.  The configuration is read back, and an
+// error returned if the monitor did not take it
+func (tm *TemperatureMonitor) SetInputConfig(ch string, cfg InputConfig) error {
+	ch = strings.ToUpper(ch)
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	if cfg.Sensor < 0 {
+		return generichttp.BadRequest(fmt.Errorf("cryocon: sensor index %d must not be negative", cfg.Sensor))
+	}
+	if cfg.Type != "" {
+		typ, err := checkSensorType(cfg.Type)
+		if err != nil {
+			return err
+		}
+		cur, err := tm.query(fmt.Sprintf("SENSOR %d:TYPE?", cfg.Sensor))
+		if err != nil {
+			return err
+		}
+		if cur != typ {
+			if err = tm.s.Write(fmt.Sprintf("SENSOR %d:TYPE %s", cfg.Sensor, typ)); err != nil {
+				return err
+			}
+		}
+		cfg.Type = typ
+	}
+	if err := tm.s.Write(fmt.Sprintf("INPUT %s:SENSOR %d", ch, cfg.Sensor)); err != nil {
+		return err
+	}
+	got, err := tm.GetInputConfig(ch)
+	if err != nil {
+		return err
+	}
+	if got.Sensor != cfg.Sensor {
+		return fmt.Errorf("cryocon: input %s did not take sensor %d, it has sensor %d", ch, cfg.Sensor, got.Sensor)
+	}
+	if cfg.Type != "" && got.Type != cfg.Type {
+		return fmt.Errorf("cryocon: sensor %d (%s) is of type %s and could not be changed to %s; only user curves may be",
+			cfg.Sensor, got.Name, got.Type, cfg.Type)
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/scpi"
 	"github.com/nasa-jpl/golaborate/temperature"
 )
@@ -49,8 +50,14 @@ type TemperatureMonitor struct {
 
 // NewTemperatureMonitor creates a new temperature monitor instance
 func NewTemperatureMonitor(addr string) *TemperatureMonitor {
+	return NewTemperatureMonitorPooled(addr, 1)
+}
+
+// NewTemperatureMonitorPooled is NewTemperatureMonitor with a pool of up to
+// poolSize connections
+func NewTemperatureMonitorPooled(addr string, poolSize int) *TemperatureMonitor {
 	maker := comm.BackingOffTCPConnMaker(addr, time.Second)
-	pool := comm.NewPool(1, 10*time.Second, maker)
+	pool := comm.NewPool(poolSize, 10*time.Second, maker)
 	return &TemperatureMonitor{scpi.SCPI{Pool: pool}}
 }
 
@@ -62,6 +69,25 @@ func (tm *TemperatureMonitor) Identification() (string, error) {
 	return tm.s.ReadString("*IDN?")
 }
 
+// Identify parses the *IDN? response into a DeviceInfo
+func (tm *TemperatureMonitor) Identify() (generichttp.DeviceInfo, error) {
+	ret := generichttp.DeviceInfo{Vendor: "Cryocon"}
+	id, err := tm.Identification()
+	if err != nil {
+		return ret, err
+	}
+	// Cryocon Model 12/14 Rev <firmware rev code><hardware rev code>
+	ret.Model = id
+	if idx := strings.Index(id, "Model"); idx != -1 {
+		ret.Model = id[idx:]
+	}
+	if idx := strings.Index(ret.Model, " Rev "); idx != -1 {
+		ret.Version = ret.Model[idx+5:]
+		ret.Model = ret.Model[:idx]
+	}
+	return ret, nil
+}
+
 // ReadChannelLetter reads the temperature on a given channel in C, where the
 // channel is something like "A"
 func (tm *TemperatureMonitor) ReadChannelLetter(ch string) (float64, error) {
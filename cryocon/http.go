@@ -2,10 +2,13 @@ package cryocon
 
 import (
 	"encoding/json"
+	"fmt"
 	"go/types"
 	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi"
 	"github.com/nasa-jpl/golaborate/generichttp"
 	"goji.io/pat"
 )
@@ -27,6 +30,14 @@ func NewHTTPWrapper(m TemperatureMonitor) HTTPWrapper {
 		generichttp.MethodPath{Method: http.MethodGet, Path: "/read"}:     w.ReadAll,
 		generichttp.MethodPath{Method: http.MethodGet, Path: "/read/:ch"}: w.ReadChan,
 		generichttp.MethodPath{Method: http.MethodGet, Path: "/version"}:  w.Version,
+
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/input/{ch}/config"}:  w.GetInputConfig,
+		generichttp.MethodPath{Method: http.MethodPost, Path: "/input/{ch}/config"}: w.SetInputConfig,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/sensor-types"}:       w.SensorTypes,
+
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/relay/{n}/config"}:  w.GetRelayConfig,
+		generichttp.MethodPath{Method: http.MethodPost, Path: "/relay/{n}/config"}: w.SetRelayConfig,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/relay/{n}/state"}:   w.GetRelayState,
 	}
 	w.RouteTable = rt
 	return w
@@ -83,3 +94,116 @@ func (h *HTTPWrapper) Version(w http.ResponseWriter, r *http.Request) {
 	hp.EncodeAndRespond(w, r)
 	return
 }
+
+// GetInputConfig returns the sensor configuration of the input in the URL as
+// JSON
+func (h *HTTPWrapper) GetInputConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, err := h.TemperatureMonitor.GetInputConfig(chi.URLParam(r, "ch"))
+	if err != nil {
+		generichttp.Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SetInputConfig sets the sensor configuration of the input in the URL from
+// JSON {"sensor": index, "type": "DIODE"}.  type may be omitted
+func (h *HTTPWrapper) SetInputConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := InputConfig{}
+	err := json.NewDecoder(r.Body).Decode(&cfg)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = h.TemperatureMonitor.SetInputConfig(chi.URLParam(r, "ch"), cfg)
+	if err != nil {
+		generichttp.Error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// SensorTypes returns the list of sensor types as JSON
+func (h *HTTPWrapper) SensorTypes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(SensorTypes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// relayParam returns the relay number from the URL
+func relayParam(r *http.Request) (int, error) {
+	n, err := strconv.Atoi(chi.URLParam(r, "n"))
+	if err != nil {
+		return 0, fmt.Errorf("cryocon: relay %q is not a number", chi.URLParam(r, "n"))
+	}
+	return n, nil
+}
+
+// GetRelayConfig returns the configuration of the relay in the URL as JSON
+func (h *HTTPWrapper) GetRelayConfig(w http.ResponseWriter, r *http.Request) {
+	n, err := relayParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg, err := h.TemperatureMonitor.GetRelayConfig(n)
+	if err != nil {
+		generichttp.Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SetRelayConfig sets the configuration of the relay in the URL from a JSON
+// RelayConfig
+func (h *HTTPWrapper) SetRelayConfig(w http.ResponseWriter, r *http.Request) {
+	n, err := relayParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg := RelayConfig{}
+	err = json.NewDecoder(r.Body).Decode(&cfg)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = cfg.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = h.TemperatureMonitor.SetRelayConfig(n, cfg)
+	if err != nil {
+		generichttp.Error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetRelayState returns whether the relay in the URL is asserted as JSON
+func (h *HTTPWrapper) GetRelayState(w http.ResponseWriter, r *http.Request) {
+	n, err := relayParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	on, err := h.TemperatureMonitor.GetRelayState(n)
+	if err != nil {
+		generichttp.Error(w, err)
+		return
+	}
+	hp := generichttp.HumanPayload{T: types.Bool, Bool: on}
+	hp.EncodeAndRespond(w, r)
+}
@@ -0,0 +1,57 @@
+package cryocon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestBadInputIs400(t *testing.T) {
+	// the channel is checked before the monitor is contacted
+	h := NewHTTPWrapper(TemperatureMonitor{})
+	r := chi.NewRouter()
+	r.Get("/input/{ch}/config", h.GetInputConfig)
+	r.Post("/input/{ch}/config", h.SetInputConfig)
+	cases := []struct {
+		method, url, body string
+	}{
+		{http.MethodGet, "/input/Z/config", ""},
+		{http.MethodPost, "/input/Z/config", `{"sensor": 1}`},
+		{http.MethodPost, "/input/A/config", `{"sensor": -1}`},
+		{http.MethodPost, "/input/A/config", `{"sensor": 1, "type": "THERMISTOR"}`},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(c.method, c.url, strings.NewReader(c.body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s %s: expected 400, got %d", c.method, c.url, c.body, w.Code)
+		}
+	}
+}
+
+func TestBadRelayIs400(t *testing.T) {
+	h := NewHTTPWrapper(TemperatureMonitor{})
+	r := chi.NewRouter()
+	r.Get("/relay/{n}/config", h.GetRelayConfig)
+	r.Post("/relay/{n}/config", h.SetRelayConfig)
+	r.Get("/relay/{n}/state", h.GetRelayState)
+	cases := []struct {
+		method, url, body string
+	}{
+		{http.MethodGet, "/relay/x/config", ""},
+		{http.MethodGet, "/relay/2/config", ""},
+		{http.MethodGet, "/relay/-1/state", ""},
+		{http.MethodPost, "/relay/5/config", `{"source": "A", "high": 300}`},
+		{http.MethodPost, "/relay/0/config", `{"source": "Z", "high": 300}`},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(c.method, c.url, strings.NewReader(c.body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s %s: expected 400, got %d", c.method, c.url, c.body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package cryocon
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// Relays is the number of relay outputs of the monitor
+const Relays = 2
+
+// RelayConfig is the configuration of a relay output, which the monitor
+// switches by itself when the temperature of its source input crosses the
+// setpoints.  Setpoints are in the units the source input displays
+type RelayConfig struct {
+	// Source is the input the relay follows, e.g. "A"
+	Source string `json:"source"`
+
+	// High is the setpoint above which the relay is asserted, if EnableHigh
+	High float64 `json:"high"`
+
+	// Low is the setpoint below which the relay is asserted, if EnableLow
+	Low float64 `json:"low"`
+
+	// EnableHigh and EnableLow arm the high and low setpoints
+	EnableHigh bool `json:"enableHigh"`
+	EnableLow  bool `json:"enableLow"`
+
+	// Deadband is the hysteresis the temperature must move back through the
+	// setpoint before the relay is released
+	Deadband float64 `json:"deadband"`
+}
+
+// Check returns an error if the configuration is invalid
+func (c RelayConfig) Check() error {
+	if err := checkChannel(strings.ToUpper(c.Source)); err != nil {
+		return err
+	}
+	for _, v := range []float64{c.High, c.Low, c.Deadband} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("cryocon: relay setpoints must be finite, got %g", v)
+		}
+	}
+	if c.Deadband < 0 {
+		return fmt.Errorf("cryocon: relay deadband %g must not be negative", c.Deadband)
+	}
+	if c.EnableHigh && c.EnableLow && c.Low+c.Deadband >= c.High {
+		return fmt.Errorf("cryocon: relay low setpoint %g plus deadband %g must be below the high setpoint %g",
+			c.Low, c.Deadband, c.High)
+	}
+	return nil
+}
+
+// checkRelay returns an error if n is not a relay of the monitor
+func checkRelay(n int) error {
+	if n < 0 || n >= Relays {
+		return generichttp.BadRequest(fmt.Errorf("cryocon: relay %d must be from 0 to %d", n, Relays-1))
+	}
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
+
+// GetRelayConfig returns the configuration of relay n
+func (tm *TemperatureMonitor) GetRelayConfig(n int) (RelayConfig, error) {
+	var cfg RelayConfig
+	if err := checkRelay(n); err != nil {
+		return cfg, err
+	}
+	q := func(field string) (string, error) {
+		return tm.query(fmt.Sprintf("RELAYS %d:%s?", n, field))
+	}
+	f := func(field string) (float64, error) {
+		resp, err := q(field)
+		if err != nil {
+			return 0, err
+		}
+		// the unit follows the number, as in 77.000K
+		return strconv.ParseFloat(strings.TrimRight(resp, "KCFSkcfs"), 64)
+	}
+	var err error
+	if cfg.Source, err = q("SOURCE"); err != nil {
+		return cfg, err
+	}
+	if cfg.High, err = f("HIGHEST"); err != nil {
+		return cfg, err
+	}
+	if cfg.Low, err = f("LOWEST"); err != nil {
+		return cfg, err
+	}
+	if cfg.Deadband, err = f("DEADBAND"); err != nil {
+		return cfg, err
+	}
+	resp, err := q("ENAHIGH")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.EnableHigh = resp == "YES"
+	resp, err = q("ENALOW")
+	cfg.EnableLow = resp == "YES"
+	return cfg, err
+}
+
+// SetRelayConfig checks and sets the configuration of relay n
+func (tm *TemperatureMonitor) SetRelayConfig(n int, cfg RelayConfig) error {
+	if err := checkRelay(n); err != nil {
+		return err
+	}
+	if err := cfg.Check(); err != nil {
+		return err
+	}
+	cmds := []string{
+		fmt.Sprintf("RELAYS %d:SOURCE %s", n, strings.ToUpper(cfg.Source)),
+		fmt.Sprintf("RELAYS %d:HIGHEST %g", n, cfg.High),
+		fmt.Sprintf("RELAYS %d:LOWEST %g", n, cfg.Low),
+		fmt.Sprintf("RELAYS %d:DEADBAND %g", n, cfg.Deadband),
+		fmt.Sprintf("RELAYS %d:ENAHIGH %s", n, yesNo(cfg.EnableHigh)),
+		fmt.Sprintf("RELAYS %d:ENALOW %s", n, yesNo(cfg.EnableLow)),
+	}
+	return tm.s.Write(strings.Join(cmds, ";"))
+}
+
+// GetRelayState returns true if relay n is asserted
+func (tm *TemperatureMonitor) GetRelayState(n int) (bool, error) {
+	if err := checkRelay(n); err != nil {
+		return false, err
+	}
+	resp, err := tm.query(fmt.Sprintf("RELAYS %d:STATUS?", n))
+	return resp == "ON", err
+}
@@ -0,0 +1,21 @@
+package cryocon
+
+import "testing"
+
+func TestRelayConfigCheck(t *testing.T) {
+	cases := []struct {
+		cfg RelayConfig
+		ok  bool
+	}{
+		{RelayConfig{Source: "a", High: 300, Low: 77, EnableHigh: true, EnableLow: true, Deadband: 1}, true},
+		{RelayConfig{Source: "A", High: 77, Low: 300, EnableHigh: true, EnableLow: true}, false},
+		{RelayConfig{Source: "A", High: 77, Low: 300, EnableHigh: true}, true},
+		{RelayConfig{Source: "Z", High: 300}, false},
+		{RelayConfig{Source: "B", High: 300, Deadband: -1}, false},
+	}
+	for i, c := range cases {
+		if err := c.cfg.Check(); (err == nil) != c.ok {
+			t.Errorf("case %d: expected ok=%v, got %v", i, c.ok, err)
+		}
+	}
+}
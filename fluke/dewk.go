@@ -12,6 +12,18 @@ import (
 // and serves data HTTP routes and meta HTTP routes (route list)
 type DewK struct {
 	pool *comm.Pool
+
+	// RxTerm and TxTerm end the lines read from and written to the DewK
+	RxTerm, TxTerm string
+
+	// debug receives the raw traffic, see SetDebug
+	debug io.Writer
+
+	// minmax tracks the extremes of every reading
+	minmax *minMaxTracker
+
+	// sampling stops the background readings, see Sample
+	sampling *sampler
 }
 
 // NewDewK creates a new DewK instance
@@ -21,7 +33,34 @@ func NewDewK(addr string) *DewK {
 	}
 	maker := comm.BackingOffTCPConnMaker(addr, time.Second)
 	pool := comm.NewPool(1, time.Minute, maker)
-	return &DewK{pool: pool}
+	return &DewK{pool: pool, RxTerm: "\n", TxTerm: "\n", minmax: newMinMaxTracker()}
+}
+
+// SetDebug logs every line sent to and received from the DewK to w, with its
+// terminator.  nil turns logging off
+func (dk *DewK) SetDebug(w io.Writer) {
+	dk.debug = w
+}
+
+// Reconnect closes the idle socket to port 10001 of the DewK
+func (dk *DewK) Reconnect() error {
+	dk.pool.Drain()
+	return nil
+}
+
+// Shutdown stops the background readings and closes the socket to the DewK
+func (dk *DewK) Shutdown() error {
+	if dk.sampling != nil {
+		dk.sampling.close()
+	}
+	dk.pool.Close()
+	return nil
+}
+
+// Ping takes a reading, the only query the DewK has
+func (dk *DewK) Ping() error {
+	_, err := dk.Read()
+	return err
 }
 
 // Read polls the DewK for the current temperature and humidity, opening and closing a connection along the way
@@ -32,7 +71,8 @@ func (dk *DewK) Read() (TempHumid, error) {
 		return ret, err
 	}
 	defer func() { dk.pool.ReturnWithError(conn, err) }()
-	wrap := comm.NewTerminator(conn, '\n', '\n')
+	wrap := comm.NewLineTerminator(conn, dk.RxTerm, dk.TxTerm)
+	wrap.Debug = dk.debug
 	_, err = io.WriteString(wrap, "read?")
 	if err != nil {
 		return ret, err
@@ -42,5 +82,9 @@ func (dk *DewK) Read() (TempHumid, error) {
 	if err != nil {
 		return ret, err
 	}
-	return ParseTHFromBuffer(buf[:n])
+	ret, perr := ParseTHFromBuffer(buf[:n])
+	if perr == nil && dk.minmax != nil {
+		dk.minmax.add(ret)
+	}
+	return ret, perr
 }
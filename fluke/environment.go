@@ -0,0 +1,75 @@
+package fluke
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Magnus coefficients for dewpoint over water, valid from -45 to 60 C
+const (
+	magnusA = 17.62
+	magnusB = 243.12
+)
+
+// Dewpoint returns the dewpoint in C of air at t C and rh % relative humidity,
+// by the Magnus formula.  It is NaN if rh is not positive
+func Dewpoint(t, rh float64) float64 {
+	if rh <= 0 {
+		return math.NaN()
+	}
+	gamma := math.Log(rh/100) + magnusA*t/(magnusB+t)
+	return magnusB * gamma / (magnusA - gamma)
+}
+
+// EnvironmentUnits are the units of the fields of Environment
+var EnvironmentUnits = map[string]string{
+	"temp":     "C",
+	"humidity": "%RH",
+	"dewpoint": "C",
+}
+
+// Environment is the temperature, humidity, and dewpoint from one reading
+type Environment struct {
+	Temp     float64 `json:"temp"`
+	Humidity float64 `json:"humidity"`
+
+	// Dewpoint is computed from Temp and Humidity, and null when the
+	// humidity is zero
+	Dewpoint *float64 `json:"dewpoint"`
+
+	// Timestamp is when the reading was taken
+	Timestamp time.Time `json:"timestamp"`
+
+	// Units maps each field to its unit
+	Units map[string]string `json:"units"`
+}
+
+// Environment reads the temperature and humidity once and computes the
+// dewpoint from them
+func (dk *DewK) Environment() (Environment, error) {
+	th, err := dk.Read()
+	if err != nil {
+		return Environment{}, err
+	}
+	env := Environment{Temp: th.T, Humidity: th.H, Timestamp: time.Now(), Units: EnvironmentUnits}
+	if dp := Dewpoint(th.T, th.H); !math.IsNaN(dp) {
+		env.Dewpoint = &dp
+	}
+	return env, nil
+}
+
+// GetEnvironment reads the environment from the DewK and sends it as JSON
+func (h HTTPWrapper) GetEnvironment(w http.ResponseWriter, r *http.Request) {
+	env, err := h.DewK.Environment()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(env)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,25 @@
+package fluke
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDewpoint(t *testing.T) {
+	cases := []struct {
+		t, rh, want float64
+	}{
+		{20, 50, 9.26},
+		{25, 100, 25},
+		{0, 80, -3.04},
+		{30, 10, -4.96},
+	}
+	for _, c := range cases {
+		if got := Dewpoint(c.t, c.rh); math.Abs(got-c.want) > 0.01 {
+			t.Errorf("Dewpoint(%g, %g) = %.3f, expected %g", c.t, c.rh, got, c.want)
+		}
+	}
+	if !math.IsNaN(Dewpoint(20, 0)) {
+		t.Error("expected the dewpoint of dry air to be NaN")
+	}
+}
@@ -20,7 +20,10 @@ type HTTPWrapper struct {
 func NewHTTPWrapper(dk DewK) HTTPWrapper {
 	w := HTTPWrapper{DewK: dk}
 	rt := generichttp.RouteTable{
-		generichttp.MethodPath{Method: http.MethodGet, Path: "/read"}: w.Read,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/read"}:          w.Read,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/minmax"}:        w.GetMinMax,
+		generichttp.MethodPath{Method: http.MethodPost, Path: "/minmax/reset"}: w.ResetMinMax,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/environment"}:   w.GetEnvironment,
 	}
 	w.RouteTable = rt
 	return w
@@ -0,0 +1,148 @@
+package fluke
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MinMaxSource is the Source of a MinMax computed by the server.  The DewK
+// does not report its own extremes over the network, so they are taken over
+// every reading the server makes, for /read and /environment and by the
+// sampler started by Sample.  Excursions between readings are not seen
+const MinMaxSource = "server"
+
+// DefaultSampleInterval is the time between the readings the server makes in
+// the background to track the extremes, if it is not configured
+const DefaultSampleInterval = 10 * time.Second
+
+// Extremes is the least and greatest value of a quantity
+type Extremes struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func (e *Extremes) add(v float64) {
+	e.Min = math.Min(e.Min, v)
+	e.Max = math.Max(e.Max, v)
+}
+
+// MinMax holds the extremes of temperature (C) and humidity (% RH) since
+// they were last reset
+type MinMax struct {
+	Temp Extremes `json:"temp"`
+	RH   Extremes `json:"rh"`
+
+	// Samples is the number of readings the extremes are taken over
+	Samples int `json:"samples"`
+
+	// Since is when the extremes were last reset
+	Since time.Time `json:"since"`
+
+	// Source says where the extremes come from, see MinMaxSource
+	Source string `json:"source"`
+}
+
+// minMaxTracker accumulates a MinMax
+type minMaxTracker struct {
+	mu sync.Mutex
+	mm MinMax
+}
+
+func newMinMaxTracker() *minMaxTracker {
+	t := &minMaxTracker{}
+	t.reset()
+	return t
+}
+
+func (t *minMaxTracker) add(th TempHumid) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mm.Samples == 0 {
+		t.mm.Temp = Extremes{Min: th.T, Max: th.T}
+		t.mm.RH = Extremes{Min: th.H, Max: th.H}
+	} else {
+		t.mm.Temp.add(th.T)
+		t.mm.RH.add(th.H)
+	}
+	t.mm.Samples++
+}
+
+func (t *minMaxTracker) get() MinMax {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.mm
+}
+
+func (t *minMaxTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mm = MinMax{Since: time.Now(), Source: MinMaxSource}
+}
+
+// sampler stops the background readings of Sample
+type sampler struct {
+	once sync.Once
+	stop chan struct{}
+}
+
+func (s *sampler) close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// Sample reads the DewK every interval in the background until Shutdown, so
+// that the extremes are tracked while no client is polling.  Failed readings
+// are skipped.  It is to be called once, before the DewK is copied into an
+// HTTPWrapper.  A non-positive interval does nothing
+func (dk *DewK) Sample(interval time.Duration) {
+	if interval <= 0 || dk.sampling != nil {
+		return
+	}
+	s := &sampler{stop: make(chan struct{})}
+	dk.sampling = s
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				dk.Read()
+			}
+		}
+	}()
+}
+
+// MinMax returns the extremes of temperature and humidity since the last
+// reset.  With no samples, the extremes are zero
+func (dk *DewK) MinMax() MinMax {
+	if dk.minmax == nil {
+		return MinMax{Source: MinMaxSource}
+	}
+	return dk.minmax.get()
+}
+
+// ResetMinMax forgets the extremes and starts tracking them anew
+func (dk *DewK) ResetMinMax() {
+	if dk.minmax != nil {
+		dk.minmax.reset()
+	}
+}
+
+// GetMinMax returns the extremes as JSON
+func (h HTTPWrapper) GetMinMax(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(h.DewK.MinMax())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ResetMinMax resets the extremes
+func (h HTTPWrapper) ResetMinMax(w http.ResponseWriter, r *http.Request) {
+	h.DewK.ResetMinMax()
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,83 @@
+package fluke
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/comm"
+)
+
+func TestMinMaxTracker(t *testing.T) {
+	tr := newMinMaxTracker()
+	for _, th := range []TempHumid{{T: 21, H: 40}, {T: 19.5, H: 45}, {T: 22, H: 38}} {
+		tr.add(th)
+	}
+	mm := tr.get()
+	if mm.Samples != 3 || mm.Temp != (Extremes{19.5, 22}) || mm.RH != (Extremes{38, 45}) {
+		t.Errorf("wrong extremes %+v", mm)
+	}
+	tr.reset()
+	tr.add(TempHumid{T: 25, H: 50})
+	if mm = tr.get(); mm.Samples != 1 || mm.Temp.Min != 25 || mm.Source != MinMaxSource {
+		t.Errorf("expected the reset to forget the old extremes, got %+v", mm)
+	}
+}
+
+// fakeDewK answers every read? with 21.5 C and 40 % RH, counting the reads
+type fakeDewK struct {
+	mu    sync.Mutex
+	reads int
+	out   bytes.Buffer
+}
+
+func (f *fakeDewK) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+	f.out.WriteString("21.5,40,0,\n")
+	return len(b), nil
+}
+
+func (f *fakeDewK) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.out.Read(b)
+}
+
+func (f *fakeDewK) Close() error { return nil }
+
+func (f *fakeDewK) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads
+}
+
+func TestSampleTracksExtremesUntilShutdown(t *testing.T) {
+	f := &fakeDewK{}
+	dk := &DewK{
+		pool:   comm.NewPool(1, time.Minute, func() (io.ReadWriteCloser, error) { return f, nil }),
+		RxTerm: "\n", TxTerm: "\n",
+		minmax: newMinMaxTracker(),
+	}
+	dk.Sample(time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for dk.MinMax().Samples < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sampler to read the DewK")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if mm := dk.MinMax(); mm.Temp != (Extremes{21.5, 21.5}) || mm.RH != (Extremes{40, 40}) {
+		t.Errorf("wrong extremes %+v", mm)
+	}
+	dk.Shutdown()
+	time.Sleep(5 * time.Millisecond)
+	n := f.count()
+	time.Sleep(20 * time.Millisecond)
+	if f.count() != n {
+		t.Error("expected the sampler to stop at Shutdown")
+	}
+}
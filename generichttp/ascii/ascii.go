@@ -2,9 +2,12 @@
 package ascii
 
 import (
+	"context"
 	"encoding/json"
 	"go/types"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/nasa-jpl/golaborate/generichttp"
 )
@@ -14,9 +17,18 @@ type RawCommunicator interface {
 	Raw(string) (string, error)
 }
 
+// RawContextCommunicator is a RawCommunicator which can give up when the
+// request it serves is cancelled or times out
+type RawContextCommunicator interface {
+	RawContext(context.Context, string) (string, error)
+}
+
 // RawWrapper is a wrapper around a raw communicator
 type RawWrapper struct {
 	Comm RawCommunicator
+
+	// History, if not nil, records each command sent through HTTPRaw
+	History *History
 }
 
 // HTTPRaw provides access to the raw function over http
@@ -25,12 +37,20 @@ func (rw *RawWrapper) HTTPRaw(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&str)
 	defer r.Body.Close()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		generichttp.Error(w, err)
 		return
 	}
-	resp, err := rw.Comm.Raw(str.Str)
+	var resp string
+	if rc, ok := rw.Comm.(RawContextCommunicator); ok {
+		resp, err = rc.RawContext(r.Context(), str.Str)
+	} else {
+		resp, err = rw.Comm.Raw(str.Str)
+	}
+	if rw.History != nil {
+		rw.History.Add(str.Str, resp, err)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		generichttp.Error(w, err)
 		return
 	}
 	hp := generichttp.HumanPayload{T: types.String, String: resp}
@@ -43,3 +63,72 @@ func InjectRawComm(rt generichttp.RouteTable, raw RawCommunicator) {
 	wrap := RawWrapper{Comm: raw}
 	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/raw"}] = wrap.HTTPRaw
 }
+
+// HistoryEntry is one command sent to a device and what came back
+type HistoryEntry struct {
+	Command  string    `json:"command"`
+	Response string    `json:"response"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// History is a ring buffer of the most recent raw commands
+type History struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+// NewHistory returns a History which keeps the last n commands
+func NewHistory(n int) *History {
+	if n < 1 {
+		n = 1
+	}
+	return &History{entries: make([]HistoryEntry, n)}
+}
+
+// Add records a command, its response, and its error, if any
+func (h *History) Add(cmd, resp string, err error) {
+	e := HistoryEntry{Command: cmd, Response: resp, Time: time.Now()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Entries returns the recorded commands, oldest first
+func (h *History) Entries() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]HistoryEntry{}, h.entries[:h.next]...)
+	}
+	ret := make([]HistoryEntry, 0, len(h.entries))
+	ret = append(ret, h.entries[h.next:]...)
+	return append(ret, h.entries[:h.next]...)
+}
+
+// ServeHTTP responds with the entries as a JSON array
+func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(h.Entries())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// InjectRawHistory injects a /raw POST route which records the last n commands
+// and a /raw/history GET route which returns them, replacing any /raw route
+// from InjectRawComm
+func InjectRawHistory(rt generichttp.RouteTable, raw RawCommunicator, n int) {
+	wrap := RawWrapper{Comm: raw, History: NewHistory(n)}
+	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/raw"}] = wrap.HTTPRaw
+	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/raw/history"}] = wrap.History.ServeHTTP
+}
@@ -0,0 +1,26 @@
+package ascii
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHistoryKeepsLastN(t *testing.T) {
+	h := NewHistory(3)
+	for _, cmd := range []string{"a", "b", "c", "d"} {
+		h.Add(cmd, cmd+"!", nil)
+	}
+	h.Add("e", "", errors.New("timeout"))
+	got := h.Entries()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(got))
+	}
+	for i, want := range []string{"c", "d", "e"} {
+		if got[i].Command != want {
+			t.Errorf("entry %d: expected %q, got %q", i, want, got[i].Command)
+		}
+	}
+	if got[2].Error != "timeout" {
+		t.Errorf("expected the error to be recorded, got %q", got[2].Error)
+	}
+}
@@ -0,0 +1,101 @@
+package camera
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+/* /array serves a frame as bare pixels, for numeric clients which would
+otherwise decode an image format only to get back the array.  The body is
+width*height 16-bit unsigned integers, little endian, row major with no
+padding between rows.  The shape and type are in the response headers:
+
+X-Array-Width:    pixels per row
+X-Array-Height:   number of rows
+X-Array-Bitdepth: 16
+X-Array-Dtype:    <u2, as understood by numpy
+
+so that, in Python,
+
+	np.frombuffer(resp.content, dtype=resp.headers['X-Array-Dtype']).reshape(
+		int(resp.headers['X-Array-Height']), int(resp.headers['X-Array-Width']))
+
+gives the frame.
+*/
+
+// ArrayDtype is the numpy dtype of the body of /array
+const ArrayDtype = "<u2"
+
+// encodeArray returns the pixels of a 16-bit frame as little endian bytes.
+// The rows are read by the stride of the frame, so sub-images work too
+func encodeArray(img image.Image) ([]byte, error) {
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return nil, fmt.Errorf("generichttp/camera: can only serve 16-bit frames as an array, got %T", img)
+	}
+	b := g16.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := make([]byte, 2*w*h)
+	if len(out) == 0 {
+		return out, nil
+	}
+	if need := (h-1)*g16.Stride + 2*w; len(g16.Pix) < need {
+		return nil, fmt.Errorf("generichttp/camera: frame of %dx%d holds %d bytes, expected at least %d", w, h, len(g16.Pix), need)
+	}
+	for y := 0; y < h; y++ {
+		off := y * g16.Stride
+		// frames hold native endian pixels, as from the cameras
+		row := bytesToUint(g16.Pix[off : off+2*w])
+		for x, u := range row {
+			binary.LittleEndian.PutUint16(out[2*(y*w+x):], u)
+		}
+	}
+	return out, nil
+}
+
+// GetArray returns an HTTP handler func which takes a frame and responds with
+// its pixels as raw little endian bytes, described by the X-Array headers.
+//
+// procs are applied to the frame as in GetFrame
+func GetArray(p Camera, procs ...FrameProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		img, err := p.GetFrame()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		// there is no header to put the cards from procs in
+		for _, proc := range procs {
+			img, _, err = proc.ProcessFrame(w, r, img)
+			if err != nil {
+				generichttp.Error(w, err)
+				return
+			}
+		}
+		buf, err := encodeArray(img)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		b := img.Bounds()
+		hdr := w.Header()
+		hdr.Set("Content-Type", "application/octet-stream")
+		hdr.Set("Content-Length", strconv.Itoa(len(buf)))
+		hdr.Set("X-Array-Width", strconv.Itoa(b.Dx()))
+		hdr.Set("X-Array-Height", strconv.Itoa(b.Dy()))
+		hdr.Set("X-Array-Bitdepth", "16")
+		hdr.Set("X-Array-Dtype", ArrayDtype)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf)
+	}
+}
+
+// HTTPArray adds the GET /array route to the table
+func HTTPArray(p Camera, table generichttp.RouteTable, procs ...FrameProcessor) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/array"}] = GetArray(p, procs...)
+}
@@ -0,0 +1,37 @@
+package camera
+
+import (
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+func TestEncodeArrayHonorsStride(t *testing.T) {
+	full := image.NewGray16(image.Rect(0, 0, 4, 3))
+	px := bytesToUint(full.Pix)
+	for i := range px {
+		px[i] = uint16(i)
+	}
+	// the middle 2x2 of the bottom two rows, with a stride of 4 pixels
+	sub := full.SubImage(image.Rect(1, 1, 3, 3))
+	buf, err := encodeArray(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint16{5, 6, 9, 10}
+	if len(buf) != 2*len(want) {
+		t.Fatalf("expected %d bytes, got %d", 2*len(want), len(buf))
+	}
+	for i, w := range want {
+		if got := binary.LittleEndian.Uint16(buf[2*i:]); got != w {
+			t.Errorf("pixel %d: expected %d, got %d", i, w, got)
+		}
+	}
+}
+
+func TestEncodeArrayOfEmptyFrame(t *testing.T) {
+	buf, err := encodeArray(image.NewGray16(image.Rect(0, 0, 0, 0)))
+	if err != nil || len(buf) != 0 {
+		t.Errorf("expected no bytes and no error, got %d, %v", len(buf), err)
+	}
+}
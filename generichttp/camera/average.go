@@ -0,0 +1,184 @@
+package camera
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
+)
+
+// MaxAveragedFrames is the most frames /image/averaged will stack
+const MaxAveragedFrames = 1024
+
+// stack accumulates the pixel-wise sum of 16-bit frames
+type stack struct {
+	sum    []float64
+	bounds image.Rectangle
+	n      int
+}
+
+// add adds a frame to the stack.  Every frame must be the same size
+func (s *stack) add(img image.Image) error {
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return fmt.Errorf("generichttp/camera: can only average 16-bit frames, got %T", img)
+	}
+	b := g16.Bounds()
+	if s.n == 0 {
+		s.bounds = b
+		s.sum = make([]float64, b.Dx()*b.Dy())
+	} else if b.Dx() != s.bounds.Dx() || b.Dy() != s.bounds.Dy() {
+		return fmt.Errorf("generichttp/camera: frame %d is %dx%d, the stack is %dx%d",
+			s.n+1, b.Dx(), b.Dy(), s.bounds.Dx(), s.bounds.Dy())
+	}
+	// frames hold native endian pixels, as from the cameras
+	for i, u := range bytesToUint(g16.Pix)[:len(s.sum)] {
+		s.sum[i] += float64(u)
+	}
+	s.n++
+	return nil
+}
+
+// mean32 returns the mean of the stack as float32
+func (s *stack) mean32() []float32 {
+	ret := make([]float32, len(s.sum))
+	for i, v := range s.sum {
+		ret[i] = float32(v / float64(s.n))
+	}
+	return ret
+}
+
+// mean16 returns the mean of the stack rounded to 16 bits
+func (s *stack) mean16() *image.Gray16 {
+	w, h := s.bounds.Dx(), s.bounds.Dy()
+	img := image.NewGray16(image.Rect(0, 0, w, h))
+	px := bytesToUint(img.Pix)
+	for i, v := range s.sum {
+		px[i] = uint16(math.Round(v / float64(s.n)))
+	}
+	return img
+}
+
+// WriteFitsFloat streams a single 32-bit floating point frame of width x
+// height as a fits file to w
+func WriteFitsFloat(w io.Writer, metadata []fitsio.Card, data []float32, width, height int) error {
+	fits, err := fitsio.Create(w)
+	if err != nil {
+		return err
+	}
+	defer fits.Close()
+	im := fitsio.NewImage(-32, []int{width, height})
+	defer im.Close()
+	err = im.Header().Append(metadata...)
+	if err != nil {
+		return err
+	}
+	err = im.Write(data)
+	if err != nil {
+		return err
+	}
+	return fits.Write(im)
+}
+
+// Averaged returns an HTTP handler func which takes n frames, from the n query
+// parameter, and responds with their pixel-wise mean as FITS.  The mean is
+// 32-bit floating point, or rounded to 16 bits if bits=16.  If a frame cannot
+// be taken partway through, the frames already taken are averaged, and the
+// NFRAMES card holds how many there were, with the error in the ERR card.
+//
+// procs are applied to each frame as in GetFrame, and FITS header cards may
+// be attached as described by UserCards
+func Averaged(p Camera, procs ...FrameProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		n, err := server.QueryInt(r, "n", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if n < 1 || n > MaxAveragedFrames {
+			http.Error(w, fmt.Sprintf("n must be an integer from 1 to %d", MaxAveragedFrames), http.StatusBadRequest)
+			return
+		}
+		bits := q.Get("bits")
+		if bits != "" && bits != "16" && bits != "32" {
+			http.Error(w, fmt.Sprintf("bits %q must be 16 or 32", bits), http.StatusBadRequest)
+			return
+		}
+		userCards, err := UserCards(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var (
+			s        stack
+			procCard []fitsio.Card
+			stackErr error
+		)
+	Frames:
+		for i := 0; i < n; i++ {
+			img, err := p.GetFrame()
+			if err != nil {
+				stackErr = err
+				break
+			}
+			for _, proc := range procs {
+				var cards []fitsio.Card
+				img, cards, err = proc.ProcessFrame(w, r, img)
+				if err != nil {
+					stackErr = err
+					break Frames
+				}
+				if i == 0 {
+					procCard = append(procCard, cards...)
+				}
+			}
+			if err = s.add(img); err != nil {
+				stackErr = err
+				break
+			}
+		}
+		if s.n == 0 {
+			generichttp.Error(w, stackErr)
+			return
+		}
+
+		var cards []fitsio.Card
+		if carder, ok := p.(MetadataMaker); ok {
+			cards = carder.CollectHeaderMetadata()
+		}
+		cards = append(cards, procCard...)
+		cards = append(cards,
+			fitsio.Card{Name: "NFRAMES", Value: s.n, Comment: "number of frames averaged"},
+			fitsio.Card{Name: "NREQUEST", Value: n, Comment: "number of frames requested"})
+		if stackErr != nil {
+			cards = append(cards, fitsio.Card{Name: "ERR", Value: stackErr.Error(), Comment: "error which ended the stack early"})
+		}
+		cards, err = appendUserCards(cards, userCards)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		hdr := w.Header()
+		hdr.Set("Content-Type", "image/fits")
+		hdr.Set("Content-Disposition", "attachment; filename=averaged.fits")
+		w.WriteHeader(http.StatusOK)
+		if bits == "16" {
+			WriteFits(w, cards, []image.Image{s.mean16()})
+			return
+		}
+		WriteFitsFloat(w, cards, s.mean32(), s.bounds.Dx(), s.bounds.Dy())
+	}
+}
+
+// HTTPAveraged adds the GET /image/averaged route to the table
+func HTTPAveraged(p Camera, table generichttp.RouteTable, procs ...FrameProcessor) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/image/averaged"}] = Averaged(p, procs...)
+}
@@ -0,0 +1,142 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// ExposureLimiter is a camera which can report the range of exposure times
+// it supports
+type ExposureLimiter interface {
+	// GetExposureTimeRange returns the minimum and maximum exposure time
+	GetExposureTimeRange() (time.Duration, time.Duration, error)
+}
+
+// bracketRequest is the body of a POST to /bracket
+type bracketRequest struct {
+	// Exposures is the list of exposure times, in seconds
+	Exposures []float64 `json:"exposures"`
+
+	// Clamp causes out of range exposures to be clamped to the camera's
+	// limits instead of skipped
+	Clamp bool `json:"clamp"`
+}
+
+// Bracket returns an HTTP handler func which captures one frame at each of
+// several exposure times and returns them as a FITS cube.  The exposure time
+// of slice N is written to the EXPTnnn header card.  The original exposure
+// time is restored when done.
+//
+// If p is an ExposureLimiter, out of range exposures are skipped (or clamped,
+// if the request sets clamp=true); each is noted in a COMMENT card and the
+// X-Bracket-Warnings response header
+func Bracket(p PictureTaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := bracketRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Exposures) == 0 {
+			http.Error(w, "no exposures requested", http.StatusBadRequest)
+			return
+		}
+		if len(req.Exposures) > 999 {
+			http.Error(w, "at most 999 exposures may be bracketed", http.StatusBadRequest)
+			return
+		}
+		var (
+			min, max time.Duration
+			limited  bool
+		)
+		if lim, ok := p.(ExposureLimiter); ok {
+			min, max, err = lim.GetExposureTimeRange()
+			if err != nil {
+				generichttp.Error(w, err)
+				return
+			}
+			limited = true
+		}
+		orig, err := p.GetExposureTime()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		defer p.SetExposureTime(orig)
+
+		var (
+			imgs     []image.Image
+			cards    []fitsio.Card
+			warnings []string
+		)
+		for _, f := range req.Exposures {
+			d := time.Duration(f * 1e9)
+			if limited && (d < min || d > max) {
+				if !req.Clamp {
+					warnings = append(warnings, fmt.Sprintf("exposure %gs outside [%gs, %gs], skipped", f, min.Seconds(), max.Seconds()))
+					continue
+				}
+				clamped := d
+				if clamped < min {
+					clamped = min
+				} else {
+					clamped = max
+				}
+				warnings = append(warnings, fmt.Sprintf("exposure %gs outside [%gs, %gs], clamped to %gs", f, min.Seconds(), max.Seconds(), clamped.Seconds()))
+				d = clamped
+			}
+			err = p.SetExposureTime(d)
+			if err != nil {
+				generichttp.Error(w, err)
+				return
+			}
+			img, err := p.GetFrame()
+			if err != nil {
+				generichttp.Error(w, err)
+				return
+			}
+			imgs = append(imgs, img)
+			cards = append(cards, fitsio.Card{
+				Name:    fmt.Sprintf("EXPT%03d", len(imgs)),
+				Value:   d.Seconds(),
+				Comment: fmt.Sprintf("exposure time of slice %d, seconds", len(imgs))})
+		}
+		if len(imgs) == 0 {
+			http.Error(w, "all exposures were out of range: "+strings.Join(warnings, "; "), http.StatusBadRequest)
+			return
+		}
+		// warnings only contain ASCII, as FITS headers must
+		for _, warn := range warnings {
+			cards = append(cards, fitsio.Card{Name: "COMMENT", Comment: warn})
+		}
+		if carder, ok := interface{}(p).(MetadataMaker); ok {
+			cards = append(cards, carder.CollectHeaderMetadata()...)
+		}
+		hdr := w.Header()
+		if len(warnings) > 0 {
+			hdr.Set("X-Bracket-Warnings", strings.Join(warnings, "; "))
+		}
+		hdr.Set("Content-Type", "image/fits")
+		hdr.Set("Content-Disposition", "attachment; filename=bracket.fits")
+		w.WriteHeader(http.StatusOK)
+		err = WriteFits(w, cards, imgs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// HTTPBracket adds the exposure bracketing route to the table
+func HTTPBracket(p PictureTaker, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/bracket"}] = Bracket(p)
+}
@@ -0,0 +1,92 @@
+package camera
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/imgrec"
+)
+
+func TestForwardDropOldestKeepsNewest(t *testing.T) {
+	in := make(chan image.Image)
+	out := make(chan image.Image, 2)
+	var dropped uint64
+	go forwardDropOldest(in, out, &dropped)
+	for i := 1; i <= 5; i++ {
+		in <- image.NewGray16(image.Rect(0, 0, i, 1))
+	}
+	close(in)
+	var widths []int
+	for img := range out {
+		widths = append(widths, img.Bounds().Dx())
+	}
+	if len(widths) != 2 || widths[0] != 4 || widths[1] != 5 {
+		t.Errorf("expected the two newest frames (4, 5) to be kept, got %v", widths)
+	}
+	if dropped != 3 {
+		t.Errorf("expected 3 dropped frames, got %d", dropped)
+	}
+}
+
+func TestRecordFramesWritesEachFrameOnce(t *testing.T) {
+	root, err := ioutil.TempDir("", "recordframes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	rec := &imgrec.Recorder{Root: root, Prefix: "burst"}
+	in := make(chan image.Image)
+	out := make(chan image.Image, 3)
+	var recorded uint64
+	go func() {
+		for i := 0; i < 3; i++ {
+			in <- image.NewGray16(image.Rect(0, 0, 4, 4))
+		}
+		close(in)
+	}()
+	if err = recordFrames(in, out, rec, nil, &recorded); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range out {
+		n++
+	}
+	if n != 3 || recorded != 3 {
+		t.Errorf("expected 3 frames forwarded and recorded, got %d and %d", n, recorded)
+	}
+	if recs := rec.Recordings(); len(recs) != 3 {
+		t.Errorf("expected 3 files, got %v", recs)
+	}
+}
+
+func TestRecordFramesForwardsWhenWritesFail(t *testing.T) {
+	f, err := ioutil.TempFile("", "notadir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	// a root which is a file cannot hold recordings
+	rec := &imgrec.Recorder{Root: f.Name(), Prefix: "burst"}
+	in := make(chan image.Image)
+	out := make(chan image.Image, 3)
+	var recorded uint64
+	go func() {
+		for i := 0; i < 3; i++ {
+			in <- image.NewGray16(image.Rect(0, 0, 4, 4))
+		}
+		close(in)
+	}()
+	if err = recordFrames(in, out, rec, nil, &recorded); err == nil {
+		t.Error("expected the write error to be returned")
+	}
+	n := 0
+	for range out {
+		n++
+	}
+	if n != 3 || recorded != 0 {
+		t.Errorf("expected 3 frames forwarded and none recorded, got %d and %d", n, recorded)
+	}
+}
@@ -3,15 +3,20 @@ package camera
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/types"
 	"image"
 	"image/jpeg"
 	"image/png"
 	"io"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/astrogo/fitsio"
@@ -60,6 +65,163 @@ func (b Binning) HxV() string {
 	return fmt.Sprintf("%dx%d", b.H, b.V)
 }
 
+// CenterAOI returns an AOI of width x height (binned) pixels centered on a
+// sensor of sensorW x sensorH (unbinned) pixels.  If the window does not
+// divide evenly, it is placed one pixel up and to the left of center
+func CenterAOI(sensorW, sensorH, width, height int, b Binning) AOI {
+	if b.H < 1 {
+		b.H = 1
+	}
+	if b.V < 1 {
+		b.V = 1
+	}
+	return AOI{
+		Left:   (sensorW-width*b.H)/2 + 1,
+		Top:    (sensorH-height*b.V)/2 + 1,
+		Width:  width,
+		Height: height}
+}
+
+// AOIConstraints are the limits a camera places on its AOI beyond fitting on
+// the sensor.  Sizes are in binned pixels
+type AOIConstraints struct {
+	// MinWidth and MinHeight are the smallest AOI
+	MinWidth, MinHeight int
+
+	// WidthStep and HeightStep are the increments of the width and height.
+	// Zero or one allows any size
+	WidthStep, HeightStep int
+
+	// AlignToBinning requires the AOI to start on the binning grid, so that
+	// Left-1 is a multiple of the horizontal binning and Top-1 of the vertical
+	AlignToBinning bool
+}
+
+// roundSize rounds v down to a multiple of step within [min, max].  A min
+// which is not a multiple of step is rounded up to one
+func roundSize(v, min, step, max int) int {
+	if step < 1 {
+		step = 1
+	}
+	if min < 1 {
+		min = 1
+	}
+	if r := min % step; r != 0 {
+		min += step - r
+	}
+	v -= v % step
+	if v > max {
+		v = max - max%step
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
+
+// Center returns the AOI nearest width x height centered on a sensor of
+// sensorW x sensorH unbinned pixels which meets the constraints.  The size is
+// rounded down to the steps, but not below the minimum, and the AOI is moved
+// up and left onto the binning grid if it must be aligned to it
+func (c AOIConstraints) Center(sensorW, sensorH, width, height int, b Binning) AOI {
+	if b.H < 1 {
+		b.H = 1
+	}
+	if b.V < 1 {
+		b.V = 1
+	}
+	width = roundSize(width, c.MinWidth, c.WidthStep, sensorW/b.H)
+	height = roundSize(height, c.MinHeight, c.HeightStep, sensorH/b.V)
+	aoi := CenterAOI(sensorW, sensorH, width, height, b)
+	if c.AlignToBinning {
+		aoi.Left = (aoi.Left-1)/b.H*b.H + 1
+		aoi.Top = (aoi.Top-1)/b.V*b.V + 1
+	}
+	return aoi
+}
+
+// Validate checks that aoi, in binned pixels, fits on a sensor of
+// sensorW x sensorH unbinned pixels with binning b, as ValidateAOI does, and
+// meets the constraints.  The error wraps ErrAOIOutOfBounds and names the
+// nearest valid value of the first offending field
+func (c AOIConstraints) Validate(aoi AOI, sensorW, sensorH int, b Binning) error {
+	err := ValidateAOI(aoi, sensorW, sensorH, c.MinWidth, c.MinHeight, b)
+	if err != nil {
+		return err
+	}
+	if b.H < 1 {
+		b.H = 1
+	}
+	if b.V < 1 {
+		b.V = 1
+	}
+	if c.WidthStep > 1 && aoi.Width%c.WidthStep != 0 {
+		return fmt.Errorf("%w: width %d is not a multiple of %d; nearest valid width is %d",
+			ErrAOIOutOfBounds, aoi.Width, c.WidthStep, roundSize(aoi.Width, c.MinWidth, c.WidthStep, sensorW/b.H))
+	}
+	if c.HeightStep > 1 && aoi.Height%c.HeightStep != 0 {
+		return fmt.Errorf("%w: height %d is not a multiple of %d; nearest valid height is %d",
+			ErrAOIOutOfBounds, aoi.Height, c.HeightStep, roundSize(aoi.Height, c.MinHeight, c.HeightStep, sensorH/b.V))
+	}
+	if c.AlignToBinning {
+		if (aoi.Left-1)%b.H != 0 {
+			return fmt.Errorf("%w: left %d is not on the %s binning grid; nearest valid left is %d",
+				ErrAOIOutOfBounds, aoi.Left, b.HxV(), (aoi.Left-1)/b.H*b.H+1)
+		}
+		if (aoi.Top-1)%b.V != 0 {
+			return fmt.Errorf("%w: top %d is not on the %s binning grid; nearest valid top is %d",
+				ErrAOIOutOfBounds, aoi.Top, b.HxV(), (aoi.Top-1)/b.V*b.V+1)
+		}
+	}
+	return nil
+}
+
+// ValidateAOI checks that aoi, in binned pixels, fits on a sensor of
+// sensorW x sensorH unbinned pixels with binning b and is at least
+// minW x minH.  The error wraps ErrAOIOutOfBounds and names the nearest
+// valid value of the first offending field
+func ValidateAOI(aoi AOI, sensorW, sensorH, minW, minH int, b Binning) error {
+	if b.H < 1 {
+		b.H = 1
+	}
+	if b.V < 1 {
+		b.V = 1
+	}
+	if minW < 1 {
+		minW = 1
+	}
+	if minH < 1 {
+		minH = 1
+	}
+	switch {
+	case aoi.Left < 1:
+		return fmt.Errorf("%w: left %d is less than 1", ErrAOIOutOfBounds, aoi.Left)
+	case aoi.Top < 1:
+		return fmt.Errorf("%w: top %d is less than 1", ErrAOIOutOfBounds, aoi.Top)
+	case aoi.Width < minW:
+		return fmt.Errorf("%w: width %d is less than the minimum of %d", ErrAOIOutOfBounds, aoi.Width, minW)
+	case aoi.Height < minH:
+		return fmt.Errorf("%w: height %d is less than the minimum of %d", ErrAOIOutOfBounds, aoi.Height, minH)
+	}
+	if maxW := (sensorW - aoi.Left + 1) / b.H; aoi.Width > maxW {
+		if maxW < minW {
+			return fmt.Errorf("%w: left %d leaves no room for a %d px wide AOI with %s binning on a %d px wide sensor; nearest valid left is %d",
+				ErrAOIOutOfBounds, aoi.Left, aoi.Width, b.HxV(), sensorW, sensorW-minW*b.H+1)
+		}
+		return fmt.Errorf("%w: width %d with %s binning extends past the %d px wide sensor from left %d; nearest valid width is %d",
+			ErrAOIOutOfBounds, aoi.Width, b.HxV(), sensorW, aoi.Left, maxW)
+	}
+	if maxH := (sensorH - aoi.Top + 1) / b.V; aoi.Height > maxH {
+		if maxH < minH {
+			return fmt.Errorf("%w: top %d leaves no room for a %d px tall AOI with %s binning on a %d px tall sensor; nearest valid top is %d",
+				ErrAOIOutOfBounds, aoi.Top, aoi.Height, b.HxV(), sensorH, sensorH-minH*b.V+1)
+		}
+		return fmt.Errorf("%w: height %d with %s binning extends past the %d px tall sensor from top %d; nearest valid height is %d",
+			ErrAOIOutOfBounds, aoi.Height, b.HxV(), sensorH, aoi.Top, maxH)
+	}
+	return nil
+}
+
 // HxVToBin converts a string like "3x3" => Binning{3,3}
 func HxVToBin(hxv string) Binning {
 	b := Binning{}
@@ -194,6 +356,27 @@ type Burster interface {
 // BurstWrapper is a type that holds the internal buffer for a burst of camera
 // frames
 type BurstWrapper struct {
+	// dropped is the number of frames discarded in the present burst.  It is
+	// first in the struct to keep it 64-bit aligned for atomic access
+	dropped uint64
+
+	// recorded is the number of frames of the present burst written to Rec,
+	// also accessed atomically
+	recorded uint64
+
+	// recordOnAcquire is 1 if bursts are recorded as they are acquired
+	recordOnAcquire uint32
+
+	// mu guards policy and recErr, which are set by /burst/setup and the
+	// recorder and read by /burst/status from other requests
+	mu sync.Mutex
+
+	// recErr is the first error recording the present burst
+	recErr error
+
+	// policy is the backpressure policy of the present burst
+	policy string
+
 	// ch is the channel of images streamed from the camera
 	ch chan image.Image
 
@@ -205,6 +388,10 @@ type BurstWrapper struct {
 
 	// frames is the number of frames in the burst
 	frames int
+
+	// Rec, if not nil, records bursts as they are acquired when turned on
+	// with /record-on-acquire
+	Rec *imgrec.Recorder
 }
 
 // SetupBurst returns a function which triggers the burst on the camera
@@ -213,6 +400,7 @@ func (b *BurstWrapper) SetupBurst(w http.ResponseWriter, r *http.Request) {
 		FPS    float64 `json:"fps"`
 		Frames int     `json:"frames"`
 		Spool  int     `json:"spool"`
+		Drop   string  `json:"drop"`
 	}{}
 	err := json.NewDecoder(r.Body).Decode(&t)
 	defer r.Body.Close()
@@ -223,14 +411,105 @@ func (b *BurstWrapper) SetupBurst(w http.ResponseWriter, r *http.Request) {
 	if t.Spool == 0 {
 		t.Spool = int(float64(t.Frames) * t.FPS)
 	}
+	if t.Drop == "" {
+		t.Drop = DropBlock
+	}
+	if t.Drop != DropBlock && t.Drop != DropOldest {
+		http.Error(w, fmt.Sprintf("unknown drop policy %q, must be %s or %s", t.Drop, DropBlock, DropOldest), http.StatusBadRequest)
+		return
+	}
+	if lim, ok := b.B.(FrameRateLimiter); ok {
+		min, max, err := lim.GetFrameRateRange()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		if err = CheckFrameRate(t.FPS, min, max); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	atomic.StoreUint64(&b.dropped, 0)
+	b.mu.Lock()
+	b.policy = t.Drop
+	b.recErr = nil
+	b.mu.Unlock()
 	b.ch = make(chan image.Image, t.Spool)
-	go func() {
-		b.err = b.B.Burst(t.Frames, t.FPS, b.ch)
-	}()
+	if t.Drop == DropBlock {
+		target := b.recordTarget(b.ch)
+		go func() {
+			b.err = b.B.Burst(t.Frames, t.FPS, target)
+		}()
+	} else {
+		// the camera writes to an unbuffered channel which is drained as fast
+		// as the camera produces, so it is never held up by the reader
+		in := make(chan image.Image)
+		go forwardDropOldest(in, b.ch, &b.dropped)
+		target := b.recordTarget(in)
+		go func() {
+			b.err = b.B.Burst(t.Frames, t.FPS, target)
+		}()
+	}
 	w.WriteHeader(http.StatusOK)
 	return
 }
 
+const (
+	// DropBlock is the backpressure policy of a burst in which the camera
+	// waits for the reader when the spool is full
+	DropBlock = "block"
+
+	// DropOldest is the backpressure policy of a burst in which the oldest
+	// spooled frame is discarded to make room when the spool is full, so that
+	// acquisition is never held up by the reader
+	DropOldest = "oldest"
+)
+
+// forwardDropOldest copies each image from in to out, discarding the oldest
+// image in out if it is full and incrementing dropped for each discard.  out
+// is closed when in is
+func forwardDropOldest(in <-chan image.Image, out chan image.Image, dropped *uint64) {
+	defer close(out)
+	for img := range in {
+		for sent := false; !sent; {
+			select {
+			case out <- img:
+				sent = true
+			default:
+				select {
+				case <-out:
+					atomic.AddUint64(dropped, 1)
+				default:
+					// the reader took one in the meantime
+				}
+			}
+		}
+	}
+}
+
+// Status responds with the backpressure policy and the number of frames
+// dropped in the present burst as {"policy": "oldest", "dropped": 0}, with the
+// number of frames recorded on acquisition and any error recording them
+func (b *BurstWrapper) Status(w http.ResponseWriter, r *http.Request) {
+	s := struct {
+		Policy   string `json:"policy"`
+		Dropped  uint64 `json:"dropped"`
+		Recorded uint64 `json:"recorded"`
+		RecErr   string `json:"recordError,omitempty"`
+	}{Dropped: atomic.LoadUint64(&b.dropped), Recorded: atomic.LoadUint64(&b.recorded)}
+	b.mu.Lock()
+	s.Policy = b.policy
+	if b.recErr != nil {
+		s.RecErr = b.recErr.Error()
+	}
+	b.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // ReadFrame returns one frame from the buffer, as FITS, over HTTP
 func (b *BurstWrapper) ReadFrame(w http.ResponseWriter, r *http.Request) {
 	select {
@@ -294,6 +573,11 @@ func (b *BurstWrapper) Inject(table generichttp.RouteTable) {
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/burst/setup"}] = b.SetupBurst
 	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/burst/frame"}] = b.ReadFrame
 	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/burst/all-frames"}] = b.ReadAllFrames
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/burst/status"}] = b.Status
+	if b.Rec != nil {
+		table[generichttp.MethodPath{Method: http.MethodGet, Path: "/record-on-acquire"}] = b.GetRecordOnAcquire
+		table[generichttp.MethodPath{Method: http.MethodPost, Path: "/record-on-acquire"}] = b.SetRecordOnAcquire
+	}
 }
 
 // MetadataMaker can produce an array of FITS cards
@@ -302,11 +586,12 @@ type MetadataMaker interface {
 	CollectHeaderMetadata() []fitsio.Card
 }
 
-// HTTPPicture injects HTTP methods into a route table for a picture taker
-func HTTPPicture(p PictureTaker, table generichttp.RouteTable, rec *imgrec.Recorder) {
+// HTTPPicture injects HTTP methods into a route table for a picture taker.
+// procs are applied to each frame served from /image, in order
+func HTTPPicture(p PictureTaker, table generichttp.RouteTable, rec *imgrec.Recorder, procs ...FrameProcessor) {
 	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/exposure-time"}] = GetExposureTime(p)
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/exposure-time"}] = SetExposureTime(p)
-	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/image"}] = GetFrame(p, rec)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/image"}] = GetFrame(p, rec, procs...)
 
 	if rec != nil {
 		rW := imgrec.NewHTTPWrapper(rec)
@@ -314,20 +599,41 @@ func HTTPPicture(p PictureTaker, table generichttp.RouteTable, rec *imgrec.Recor
 	}
 }
 
+// timeUnit returns the duration of one of the unit given in the unit query
+// parameter, "s" (the default), "ms", or "us"
+func timeUnit(r *http.Request) (time.Duration, error) {
+	switch u := r.URL.Query().Get("unit"); u {
+	case "", "s":
+		return time.Second, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "us", "µs":
+		return time.Microsecond, nil
+	default:
+		return 0, fmt.Errorf("generichttp/camera: unknown time unit %q, must be s, ms, or us", u)
+	}
+}
+
 // SetExposureTime sets the exposure time on a POST request.
 // it can be provided either as a query parameter exposureTime, formatted in a
 // way that is parsable by golang/time.ParseDuration, or a json payload with
-// key f64, holding the exposure time in seconds.
+// key f64, holding the exposure time in seconds, or in the unit of the unit
+// query parameter (s, ms, or us).  The exposure time applied by the camera is
+// returned, in the same unit.
 func SetExposureTime(p PictureTaker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		unit, err := timeUnit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		q := r.URL.Query()
 		texp := q.Get("exposureTime")
 		var d time.Duration
-		var err error
 		if texp == "" {
 			f := generichttp.FloatT{}
 			err = json.NewDecoder(r.Body).Decode(&f)
-			d = time.Duration(int(f.F64*1e9)) * time.Nanosecond // 1e9 s => ns
+			d = time.Duration(math.Round(f.F64 * float64(unit))) // unit => ns
 		} else {
 			d, err = time.ParseDuration(texp)
 		}
@@ -340,20 +646,32 @@ func SetExposureTime(p PictureTaker) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		w.WriteHeader(http.StatusOK)
+		d, err = p.GetExposureTime()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hp := generichttp.HumanPayload{T: types.Float64, Float: float64(d) / float64(unit)}
+		hp.EncodeAndRespond(w, r)
 		return
 	}
 }
 
-// GetExposureTime gets the exposure time on a GET request
+// GetExposureTime gets the exposure time on a GET request, in seconds or the
+// unit of the unit query parameter
 func GetExposureTime(p PictureTaker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		unit, err := timeUnit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		f, err := p.GetExposureTime()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		hp := generichttp.HumanPayload{T: types.Float64, Float: f.Seconds()}
+		hp := generichttp.HumanPayload{T: types.Float64, Float: float64(f) / float64(unit)}
 		hp.EncodeAndRespond(w, r)
 		return
 	}
@@ -370,9 +688,18 @@ func GetExposureTime(p PictureTaker) http.HandlerFunc {
 // if no unit is appended, an s (seconds) is added.
 //
 // if no exposure time is provided, it is not updated and the existing value is used.
-func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
+//
+// each of procs is applied to the frame before it is encoded.
+//
+// FITS header cards may be attached as described by UserCards.
+func GetFrame(p Camera, rec *imgrec.Recorder, procs ...FrameProcessor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
+		userCards, err := UserCards(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if pictureTaker, ok := interface{}(p).(PictureTaker); ok {
 			texp := q.Get("exposureTime")
 			if texp != "" {
@@ -396,6 +723,16 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		var procCards []fitsio.Card
+		for _, proc := range procs {
+			var cards []fitsio.Card
+			img, cards, err = proc.ProcessFrame(w, r, img)
+			if err != nil {
+				generichttp.Error(w, err)
+				return
+			}
+			procCards = append(procCards, cards...)
+		}
 
 		format := q.Get("fmt")
 		if format == "" {
@@ -406,32 +743,12 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 		case "jpg":
 			w.Header().Set("Content-Type", "image/jpeg")
 			w.WriteHeader(http.StatusOK)
-			if g16, ok := (img).(*image.Gray16); ok {
-				uints := bytesToUint(g16.Pix)
-				b := make([]byte, len(uints))
-				l := len(uints)
-				for i := 0; i < l; i++ {
-					b[i] = byte(uints[i] / 255)
-				}
-				bound := g16.Bounds()
-				out := &image.Gray{Pix: b, Stride: bound.Dx(), Rect: bound}
-				img = out
-			}
+			img = gray16To8(img)
 			jpeg.Encode(w, img, nil)
 		case "png":
 			w.Header().Set("Content-Type", "image/png")
 			w.WriteHeader(http.StatusOK)
-			if g16, ok := (img).(*image.Gray16); ok {
-				uints := bytesToUint(g16.Pix)
-				b := make([]byte, len(uints))
-				l := len(uints)
-				for i := 0; i < l; i++ {
-					b[i] = byte(uints[i] / 255)
-				}
-				bound := g16.Bounds()
-				out := &image.Gray{Pix: b, Stride: bound.Dx(), Rect: bound}
-				img = out
-			}
+			img = gray16To8(img)
 			png.Encode(w, img)
 		case "fits":
 			// ^\- for picture taker::
@@ -447,7 +764,8 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 			// that is also introspected through a metadata interface
 			// declare a writer to use to stream the file to
 			var w2 io.Writer
-			if rec != nil && rec.Enabled && rec.Root != "" {
+			recording := rec != nil && rec.Enabled && rec.Root != ""
+			if recording {
 				// if it is "", the recorder is not to be used
 				w2 = io.MultiWriter(w, rec)
 				defer rec.Incr()
@@ -458,6 +776,12 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 			if carder, ok := interface{}(p).(MetadataMaker); ok {
 				cards = carder.CollectHeaderMetadata()
 			}
+			cards = append(cards, procCards...)
+			cards, err = appendUserCards(cards, userCards)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 
 			hdr := w.Header()
 			hdr.Set("Content-Type", "image/fits")
@@ -468,11 +792,23 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			if recording {
+				rec.Annotate(cardsToMap(cards))
+			}
 			return
 		}
 	}
 }
 
+// cardsToMap converts FITS cards to strings by name, for recording metadata
+func cardsToMap(cards []fitsio.Card) map[string]string {
+	m := make(map[string]string, len(cards))
+	for _, c := range cards {
+		m[c.Name] = fmt.Sprint(c.Value)
+	}
+	return m
+}
+
 // AOIManipulator is an interface to a camera's AOI manipulating functions
 type AOIManipulator interface {
 	// SetAOI allows the AOI to be set
@@ -497,6 +833,98 @@ func HTTPAOIManipulator(a AOIManipulator, table generichttp.RouteTable) {
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/binning"}] = SetBinning(a)
 }
 
+// CenteredAOISetter is a camera which can center an AOI on its sensor
+type CenteredAOISetter interface {
+	// SetCenteredAOI sets an AOI of the given size centered on the sensor
+	// and returns the AOI which was actually applied
+	SetCenteredAOI(width, height int) (AOI, error)
+}
+
+// SetCenteredAOI returns an HTTP handler func that centers an AOI of the
+// width and height in the JSON body on the sensor, and responds with the AOI
+// which was applied
+func SetCenteredAOI(c CenteredAOISetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		aoi, err := c.SetCenteredAOI(req.Width, req.Height)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(aoi)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPCenteredAOISetter adds the AOI centering route to the table
+func HTTPCenteredAOISetter(c CenteredAOISetter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/aoi/centered"}] = SetCenteredAOI(c)
+}
+
+// SensorSizer is a camera which knows the size of its sensor
+type SensorSizer interface {
+	// GetSensorWidth returns the width of the sensor in pixels
+	GetSensorWidth() (int, error)
+
+	// GetSensorHeight returns the height of the sensor in pixels
+	GetSensorHeight() (int, error)
+}
+
+// FullFrameSetter is a camera which can return to a full frame AOI
+type FullFrameSetter interface {
+	AOIManipulator
+	SensorSizer
+}
+
+// SetFullAOI sets 1x1 binning and an AOI covering the whole sensor, and
+// returns the AOI
+func SetFullAOI(c FullFrameSetter) (AOI, error) {
+	w, err := c.GetSensorWidth()
+	if err != nil {
+		return AOI{}, err
+	}
+	h, err := c.GetSensorHeight()
+	if err != nil {
+		return AOI{}, err
+	}
+	// binning first, so that the full sensor fits in the AOI
+	err = c.SetBinning(Binning{H: 1, V: 1})
+	if err != nil {
+		return AOI{}, err
+	}
+	aoi := AOI{Left: 1, Top: 1, Width: w, Height: h}
+	return aoi, c.SetAOI(aoi)
+}
+
+// HTTPFullAOI adds the POST /aoi/full route to the table, which returns the
+// camera to a full frame AOI with 1x1 binning and responds with the AOI
+func HTTPFullAOI(c FullFrameSetter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/aoi/full"}] = func(w http.ResponseWriter, r *http.Request) {
+		aoi, err := SetFullAOI(c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(aoi)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // SetAOI returns an HTTP handler func that sets the AOI of the camera
 func SetAOI(a AOIManipulator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -572,14 +1000,35 @@ func GetBinning(a AOIManipulator) http.HandlerFunc {
 	}
 }
 
-// EMGainManager describes an interface that can manage its electron multiplying gain
-type EMGainManager interface {
-	// GetEMGainMode returns how the EM gain is applied in the camera
-	GetEMGainMode() (string, error)
+// Capability names, for use with CapabilityReporter
+const (
+	// CapEMGain is the capability of EM gain control
+	CapEMGain = "em-gain"
 
-	// SetEMGainMode changes how the EM gain is applied in the camera
-	SetEMGainMode(string) error
+	// CapBaselineClamp is the capability of baseline clamp control
+	CapBaselineClamp = "baseline-clamp"
+)
 
+// CapabilityReporter is a camera whose support for some interfaces depends
+// on the model connected, not only its type.  Routes for an interface gated
+// by a capability are only added if the camera reports it as supported
+type CapabilityReporter interface {
+	// Capable returns true if the camera supports the named capability
+	Capable(capability string) bool
+}
+
+// capable returns true if p is not a CapabilityReporter, or if it reports
+// the capability as supported
+func capable(p interface{}, capability string) bool {
+	if cr, ok := p.(CapabilityReporter); ok {
+		return cr.Capable(capability)
+	}
+	return true
+}
+
+// EMGainController describes an interface that can set its electron
+// multiplying gain
+type EMGainController interface {
 	// GetEMGainRange returns the lower, upper limits on EM gain
 	GetEMGainRange() (int, int, error)
 
@@ -590,6 +1039,17 @@ type EMGainManager interface {
 	SetEMGain(int) error
 }
 
+// EMGainManager describes an interface that can manage its electron multiplying gain
+type EMGainManager interface {
+	EMGainController
+
+	// GetEMGainMode returns how the EM gain is applied in the camera
+	GetEMGainMode() (string, error)
+
+	// SetEMGainMode changes how the EM gain is applied in the camera
+	SetEMGainMode(string) error
+}
+
 // GetEMGainMode returns the EM gain mode over HTTP as JSON
 func GetEMGainMode(e EMGainManager) http.HandlerFunc {
 	return generichttp.GetString(e.GetEMGainMode)
@@ -601,7 +1061,7 @@ func SetEMGainMode(e EMGainManager) http.HandlerFunc {
 }
 
 // GetEMGainRange returns the min/max EM gain over HTTP as JSON
-func GetEMGainRange(e EMGainManager) http.HandlerFunc {
+func GetEMGainRange(e EMGainController) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		min, max, err := e.GetEMGainRange()
 		if err != nil {
@@ -623,22 +1083,44 @@ func GetEMGainRange(e EMGainManager) http.HandlerFunc {
 }
 
 // GetEMGain gets the EM gain over HTTP as JSON
-func GetEMGain(e EMGainManager) http.HandlerFunc {
+func GetEMGain(e EMGainController) http.HandlerFunc {
 	return generichttp.GetInt(e.GetEMGain)
 }
 
 // SetEMGain sets the EM gain over HTTP as JSON
-func SetEMGain(e EMGainManager) http.HandlerFunc {
+func SetEMGain(e EMGainController) http.HandlerFunc {
 	return generichttp.SetInt(e.SetEMGain)
 }
 
-// HTTPEMGainManager binds routes that control EM gain to the table
-func HTTPEMGainManager(e EMGainManager, table generichttp.RouteTable) {
+// HTTPEMGainController binds routes that set EM gain to the table
+func HTTPEMGainController(e EMGainController, table generichttp.RouteTable) {
 	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/em-gain"}] = GetEMGain(e)
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/em-gain"}] = SetEMGain(e)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/em-gain-range"}] = GetEMGainRange(e)
+}
+
+// HTTPEMGainManager binds routes that control EM gain to the table
+func HTTPEMGainManager(e EMGainManager, table generichttp.RouteTable) {
+	HTTPEMGainController(e, table)
 	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/em-gain-mode"}] = GetEMGainMode(e)
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/em-gain-mode"}] = SetEMGainMode(e)
-	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/em-gain-range"}] = GetEMGainRange(e)
+}
+
+// BaselineClampManager describes a camera which can enable or disable
+// clamping of its baseline (bias) level
+type BaselineClampManager interface {
+	// GetBaselineClamp returns true if the baseline clamp is enabled
+	GetBaselineClamp() (bool, error)
+
+	// SetBaselineClamp enables or disables the baseline clamp
+	SetBaselineClamp(bool) error
+}
+
+// HTTPBaselineClampManager binds routes that control the baseline clamp to
+// the table
+func HTTPBaselineClampManager(b BaselineClampManager, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/baseline-clamp"}] = generichttp.GetBool(b.GetBaselineClamp)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/baseline-clamp"}] = generichttp.SetBool(b.SetBaselineClamp)
 }
 
 // ShutterController describes an interface to a camera which may manipulate its shutter
@@ -778,15 +1260,27 @@ func Features(f FeatureManager) http.HandlerFunc {
 	}
 }
 
+// timeFeatures are the features whose values are times in seconds, which
+// may be given and returned in another unit with the unit query parameter
+var timeFeatures = map[string]bool{"ExposureTime": true}
+
 // GetFeature returns a feature that is URL encoded from the manager
 func GetFeature(f FeatureManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		feature := chi.URLParam(r, "feature")
+		unit, err := timeUnit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		v, err := f.GetFeature(feature)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if fv, ok := v.(float64); ok && timeFeatures[feature] {
+			v = fv * float64(time.Second) / float64(unit)
+		}
 		var hp generichttp.HumanPayload
 		switch vv := v.(type) {
 		case int:
@@ -825,6 +1319,62 @@ func GetFeatureInfo(f FeatureManager) http.HandlerFunc {
 	}
 }
 
+// FeatureInfoBatch is the response of /features/info/batch
+type FeatureInfoBatch struct {
+	// Info holds the information of each feature, as from GetFeatureInfo
+	Info map[string]map[string]interface{} `json:"info"`
+
+	// Errors holds the error for each feature whose information could not be
+	// read, such as unknown features
+	Errors map[string]string `json:"errors"`
+}
+
+// GetFeatureInfoBatch returns an HTTP handler func which reads the information
+// of every feature in {"features": [...]}, or of every feature of f if the list
+// is empty.  Features which fail are reported in the errors of the response
+// instead of failing the request
+func GetFeatureInfoBatch(f FeatureManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Features []string `json:"features"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Features) == 0 {
+			all, err := f.Features()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for name := range all {
+				req.Features = append(req.Features, name)
+			}
+			sort.Strings(req.Features)
+		}
+		batch := FeatureInfoBatch{
+			Info:   make(map[string]map[string]interface{}, len(req.Features)),
+			Errors: map[string]string{}}
+		for _, feature := range req.Features {
+			info, err := f.GetFeatureInfo(feature)
+			if err != nil {
+				batch.Errors[feature] = err.Error()
+				continue
+			}
+			batch.Info[feature] = info
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(batch)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // featureValue packages a feature and a value together
 type featureValue struct {
 	// Feature is the feature being set
@@ -834,18 +1384,32 @@ type featureValue struct {
 	Value interface{} `json:"value"`
 }
 
-// SetFeature sets a particular feature on f
+// SetFeature sets a particular feature on f.  Times, such as ExposureTime, are
+// in seconds unless the unit query parameter is ms or us
 func SetFeature(f FeatureManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		feature := chi.URLParam(r, "feature")
+		unit, err := timeUnit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		var fv featureValue
-		err := json.NewDecoder(r.Body).Decode(&fv)
+		err = json.NewDecoder(r.Body).Decode(&fv)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if v, ok := fv.Value.(float64); ok && timeFeatures[feature] {
+			fv.Value = v * float64(unit) / float64(time.Second)
+		}
 		err = f.SetFeature(feature, fv.Value)
 		if err != nil {
+			var fre FrameRateRangeError
+			if errors.As(err, &fre) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -858,9 +1422,161 @@ func HTTPFeatureManager(f FeatureManager, rt generichttp.RouteTable) {
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/feature"}] = Features(f)
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/feature/{feature}"}] = GetFeature(f)
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/feature/{feature}/options"}] = GetFeatureInfo(f)
+	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/features/info/batch"}] = GetFeatureInfoBatch(f)
 	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/feature/{feature}"}] = SetFeature(f)
 }
 
+// ReadoutMode is the combination of settings which determine how the sensor is
+// read out.  Empty or nil fields are left unchanged when it is set
+type ReadoutMode struct {
+	// Shuttering is the electronic shuttering mode, e.g. Rolling or Global
+	Shuttering string `json:"shuttering,omitempty"`
+
+	// ReadoutRate is the pixel readout rate, e.g. "280 MHz"
+	ReadoutRate string `json:"readoutRate,omitempty"`
+
+	// Overlap is true if an exposure may begin while the last is read out
+	Overlap *bool `json:"overlap,omitempty"`
+}
+
+// ReadoutModeSetter is a camera whose readout settings depend on each other,
+// and so must be set together in the right order
+type ReadoutModeSetter interface {
+	// GetReadoutMode returns the present readout mode
+	GetReadoutMode() (ReadoutMode, error)
+
+	// SetReadoutMode validates and applies a readout mode
+	SetReadoutMode(ReadoutMode) error
+}
+
+// GetReadoutMode returns an HTTP handler func that responds with the readout mode as JSON
+func GetReadoutMode(rm ReadoutModeSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode, err := rm.GetReadoutMode()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(mode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// SetReadoutMode returns an HTTP handler func that applies a readout mode sent as JSON
+func SetReadoutMode(rm ReadoutModeSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var mode ReadoutMode
+		err := json.NewDecoder(r.Body).Decode(&mode)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = rm.SetReadoutMode(mode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HTTPReadoutMode adds GET and POST /readout-mode routes to the table
+func HTTPReadoutMode(rm ReadoutModeSetter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/readout-mode"}] = GetReadoutMode(rm)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/readout-mode"}] = SetReadoutMode(rm)
+}
+
+// Resetter is a camera which can recover from a bad state by reopening itself
+type Resetter interface {
+	// Reset reinitializes the camera and returns its settings afterwards
+	Reset() (map[string]interface{}, error)
+}
+
+// resetResponse is the JSON response of a reset
+type resetResponse struct {
+	// State holds the settings of the camera after the reset
+	State map[string]interface{} `json:"state"`
+
+	// Error describes any settings which could not be restored
+	Error string `json:"error,omitempty"`
+}
+
+// Reset returns an HTTP handler func that resets the camera.  Errors in
+// restoring settings are reported alongside the state; a reset which fails
+// outright is a 500
+func Reset(rs Resetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := rs.Reset()
+		if state == nil {
+			if err == nil {
+				err = errors.New("generichttp/camera: reset returned no state")
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := resetResponse{State: state}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPResetter adds the POST /admin/reset route to the table
+func HTTPResetter(rs Resetter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/admin/reset"}] = Reset(rs)
+}
+
+// SoftwareTriggerer is a camera which can be triggered by software, one frame
+// per trigger
+type SoftwareTriggerer interface {
+	// SoftwareTrigger fires a single trigger.  It returns a TriggerModeError
+	// if the camera is not in software trigger mode
+	SoftwareTrigger() error
+}
+
+// TriggerModeError is returned when a software trigger is fired at a camera
+// which is not in software trigger mode
+type TriggerModeError struct {
+	// Mode is the present trigger mode of the camera
+	Mode string
+}
+
+func (e TriggerModeError) Error() string {
+	return fmt.Sprintf("generichttp/camera: cannot fire a software trigger with TriggerMode %s, set it to Software first", e.Mode)
+}
+
+// SoftwareTrigger returns an HTTP handler func that fires a software trigger.
+// A camera in another trigger mode is a 409
+func SoftwareTrigger(st SoftwareTriggerer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := st.SoftwareTrigger()
+		if err != nil {
+			var tme TriggerModeError
+			if errors.As(err, &tme) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HTTPSoftwareTriggerer adds the POST /trigger route to the table
+func HTTPSoftwareTriggerer(st SoftwareTriggerer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/trigger"}] = SoftwareTrigger(st)
+}
+
 // Camera describes the most basic camera possible
 type Camera interface {
 	// GetFrame returns a frame from the device as a strided array
@@ -872,21 +1588,52 @@ type HTTPCamera struct {
 	PictureTaker
 
 	RouteTable generichttp.RouteTable
+
+	// snapshots is held alone by a snapshot, see SnapshotGuard
+	snapshots *sync.RWMutex
 }
 
 // NewHTTPCamera returns a new HTTP wrapper around a camera
 func NewHTTPCamera(p PictureTaker, rec *imgrec.Recorder) HTTPCamera {
-	w := HTTPCamera{PictureTaker: p}
+	w := HTTPCamera{PictureTaker: p, snapshots: &sync.RWMutex{}}
 	rt := generichttp.RouteTable{}
-	HTTPPicture(p, rt, rec)
+	darks := NewDarkStore(p)
+	darks.Inject(rt)
+	defects := NewDefectMap(p)
+	defects.Inject(rt)
+	HTTPPicture(p, rt, rec, darks, defects)
+	HTTPSnapshot(p, rt, darks, defects)
+	HTTPAveraged(p, rt, darks, defects)
+	HTTPArray(p, rt, darks, defects)
+	NewFeatureEvents(p, time.Second).Inject(rt)
+	HTTPBracket(p, rt)
 	if thermal, ok := p.(ThermalManager); ok {
 		HTTPThermalManager(thermal, rt)
 	}
 	if aoi, ok := p.(AOIManipulator); ok {
 		HTTPAOIManipulator(aoi, rt)
 	}
-	if em, ok := p.(EMGainManager); ok {
-		HTTPEMGainManager(em, rt)
+	if c, ok := p.(CenteredAOISetter); ok {
+		HTTPCenteredAOISetter(c, rt)
+	}
+	if c, ok := p.(FullFrameSetter); ok {
+		HTTPFullAOI(c, rt)
+	}
+	if f, ok := p.(FrameRateReporter); ok {
+		HTTPFrameRateReporter(f, rt)
+	}
+	if f, ok := p.(FrameRateLimiter); ok {
+		HTTPFrameRateLimiter(f, rt)
+	}
+	if capable(p, CapEMGain) {
+		if em, ok := p.(EMGainManager); ok {
+			HTTPEMGainManager(em, rt)
+		} else if em, ok := p.(EMGainController); ok {
+			HTTPEMGainController(em, rt)
+		}
+	}
+	if bc, ok := p.(BaselineClampManager); ok && capable(p, CapBaselineClamp) {
+		HTTPBaselineClampManager(bc, rt)
 	}
 	if sh, ok := p.(ShutterController); ok {
 		HTTPShutterController(sh, rt)
@@ -895,12 +1642,24 @@ func NewHTTPCamera(p PictureTaker, rec *imgrec.Recorder) HTTPCamera {
 		HTTPExtendedShutterController(sh, rt)
 	}
 	if b, ok := p.(Burster); ok {
-		wrap := BurstWrapper{B: b}
+		wrap := BurstWrapper{B: b, Rec: rec}
 		wrap.Inject(rt)
 
 	}
 	if fm, ok := p.(FeatureManager); ok {
-		HTTPFeatureManager(fm, rt)
+		NewFeatureInfoCache(fm, DefaultFeatureInfoTTL).Inject(rt)
+	}
+	if id, ok := p.(generichttp.Identifier); ok {
+		generichttp.HTTPIdentify(id, rt)
+	}
+	if rs, ok := p.(Resetter); ok {
+		HTTPResetter(rs, rt)
+	}
+	if rm, ok := p.(ReadoutModeSetter); ok {
+		HTTPReadoutMode(rm, rt)
+	}
+	if st, ok := p.(SoftwareTriggerer); ok {
+		HTTPSoftwareTriggerer(st, rt)
 	}
 
 	w.RouteTable = rt
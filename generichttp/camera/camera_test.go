@@ -0,0 +1,1018 @@
+package camera_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astrogo/fitsio"
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/generichttp/camera"
+)
+
+func newMockServer(t *testing.T) (*camera.MockCamera, *httptest.Server) {
+	m := camera.NewMockCamera(64, 48)
+	h := camera.NewHTTPCamera(m, nil)
+	r := chi.NewRouter()
+	r.Use(h.SnapshotGuard)
+	h.RT().Bind(r)
+	return m, httptest.NewServer(r)
+}
+
+func postJSON(t *testing.T, url string, v interface{}) *http.Response {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, "application/json", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestMockCameraAOIRoundTrip(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	want := camera.AOI{Left: 5, Top: 3, Width: 16, Height: 8}
+	resp := postJSON(t, srv.URL+"/aoi", want)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 setting AOI, got %d", resp.StatusCode)
+	}
+	resp, err := http.Get(srv.URL + "/aoi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got camera.AOI
+	if err = json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("expected AOI %+v, got %+v", want, got)
+	}
+}
+
+func TestMockCameraRejectsAOIOffSensor(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp := postJSON(t, srv.URL+"/aoi", camera.AOI{Left: 60, Top: 1, Width: 16, Height: 8})
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("expected an AOI extending past the sensor to be rejected")
+	}
+}
+
+func TestMockCameraImageHonorsAOIAndBinning(t *testing.T) {
+	m, srv := newMockServer(t)
+	defer srv.Close()
+	if err := m.SetBinning(camera.Binning{H: 2, V: 2}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(srv.URL + "/image?fmt=png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 24 {
+		t.Errorf("expected a 32x24 frame with 2x2 binning, got %dx%d", b.Dx(), b.Dy())
+	}
+	// the pattern increases along the diagonal
+	g := img.(*image.Gray)
+	if g.GrayAt(20, 20).Y <= g.GrayAt(0, 0).Y {
+		t.Error("expected the test pattern to increase along the diagonal")
+	}
+}
+
+func TestMockCameraFeatures(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp := postJSON(t, srv.URL+"/feature/FrameRate", map[string]interface{}{"value": 25})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 setting feature, got %d", resp.StatusCode)
+	}
+	resp, err := http.Get(srv.URL + "/feature/FrameRate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var f struct {
+		F64 float64 `json:"f64"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.F64 != 25 {
+		t.Errorf("expected FrameRate 25, got %f", f.F64)
+	}
+}
+
+func TestBracketSkipsOutOfRangeAndRestoresExposure(t *testing.T) {
+	m, srv := newMockServer(t)
+	defer srv.Close()
+	resp := postJSON(t, srv.URL+"/bracket", map[string]interface{}{"exposures": []float64{0.001, 0.002, 100}})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Bracket-Warnings") == "" {
+		t.Error("expected a warning for the 100 s exposure")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// FITS headers are 80 character ASCII cards, so check them directly
+	hdr := string(body[:2880])
+	if !strings.Contains(hdr, fmt.Sprintf("%-8s= %20d", "NAXIS3", 2)) {
+		t.Errorf("expected a cube of 2 frames, header was %q", hdr)
+	}
+	if !strings.Contains(hdr, "EXPT002 =           0.00200000") {
+		t.Errorf("expected EXPT002 = 0.002, header was %q", hdr)
+	}
+	if d, _ := m.GetExposureTime(); d != time.Millisecond {
+		t.Errorf("expected exposure time to be restored to 1ms, got %v", d)
+	}
+}
+
+func TestDarkSubtractionMatchesSettings(t *testing.T) {
+	m, srv := newMockServer(t)
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/dark/capture", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 capturing dark, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/image?fmt=png&subtract_dark=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("X-Dark-Subtracted") != "true" {
+		t.Errorf("expected X-Dark-Subtracted: true, got %q", resp.Header.Get("X-Dark-Subtracted"))
+	}
+	img, err := png.Decode(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := img.(*image.Gray)
+	for i, v := range g.Pix {
+		if v != 0 {
+			t.Fatalf("expected a dark subtracted mock frame to be zero, pixel %d was %d", i, v)
+		}
+	}
+
+	// a different exposure time has no dark
+	if err = m.SetExposureTime(2 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Get(srv.URL + "/image?fmt=png&subtract_dark=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Dark-Subtracted") != "false" {
+		t.Errorf("expected X-Dark-Subtracted: false without a matching dark, got %q", resp.Header.Get("X-Dark-Subtracted"))
+	}
+}
+
+func TestDefectMapIsAppliedOnRequest(t *testing.T) {
+	m, srv := newMockServer(t)
+	defer srv.Close()
+	resp := postJSON(t, srv.URL+"/defect-map", []camera.Defect{{X: 10, Y: 10}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 setting defect map, got %d", resp.StatusCode)
+	}
+	if err := m.SetAOI(camera.AOI{Left: 9, Top: 9, Width: 8, Height: 8}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(srv.URL + "/image?fmt=png&mask_defects=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Defects-Masked"); got != "1" {
+		t.Errorf("expected one defect to be masked, got %q", got)
+	}
+	resp, err = http.Get(srv.URL + "/image?fmt=png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Defects-Masked"); got != "" {
+		t.Errorf("expected no masking without mask_defects, got %q", got)
+	}
+}
+
+func TestMalformedQueryParametersAre400(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	for _, q := range []string{
+		"/image?fmt=png&subtract_dark=maybe",
+		"/image?fmt=png&mask_defects=2x",
+		"/image/averaged?n=abc",
+		"/image/averaged?n=4&subtract_dark=maybe",
+	} {
+		resp, err := http.Get(srv.URL + q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestProfileReportsPerFeatureErrors(t *testing.T) {
+	m := camera.NewMockCamera(64, 48)
+	pm := camera.NewProfileManager(m, map[string]camera.Profile{
+		"fast": {"FrameRate": 100., "NotAFeature": 1, "MetadataEnable": true},
+	})
+	rt := generichttp.RouteTable{}
+	pm.Inject(rt)
+	r := chi.NewRouter()
+	rt.Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp := postJSON(t, srv.URL+"/configure/profile", map[string]string{"name": "fast"})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var res camera.ProfileResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Applied) != 2 {
+		t.Errorf("expected 2 features to be applied, got %v", res.Applied)
+	}
+	if _, ok := res.Errors["NotAFeature"]; !ok || len(res.Errors) != 1 {
+		t.Errorf("expected only NotAFeature to fail, got %v", res.Errors)
+	}
+	if v, _ := m.GetFeature("FrameRate"); v != 100. {
+		t.Errorf("expected FrameRate 100, got %v", v)
+	}
+
+	resp2 := postJSON(t, srv.URL+"/configure/profile", map[string]string{"name": "missing"})
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown profile, got %d", resp2.StatusCode)
+	}
+}
+
+func TestStartupProfileFallsBackToDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "startup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	good := filepath.Join(dir, "good.yml")
+	bad := filepath.Join(dir, "bad.yml")
+	if err = ioutil.WriteFile(good, []byte("FrameRate: 20.0\nTriggerMode: Software\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(bad, []byte("FrameRate: 1000.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defaults := camera.Profile{"FrameRate": 5.}
+
+	m := camera.NewMockCamera(64, 48)
+	rep := camera.ApplyStartupProfile(m, good, defaults)
+	if rep.Error != "" || rep.Fallback != nil || len(rep.Result.Applied) != 2 {
+		t.Errorf("expected the good profile to apply cleanly, got %+v", rep)
+	}
+	if v, _ := m.GetFeature("FrameRate"); v != 20. {
+		t.Errorf("expected FrameRate 20, got %v", v)
+	}
+
+	for _, path := range []string{bad, filepath.Join(dir, "missing.yml")} {
+		rep = camera.ApplyStartupProfile(m, path, defaults)
+		if rep.Error == "" || rep.Fallback == nil {
+			t.Errorf("%s: expected an error and a fallback, got %+v", path, rep)
+		}
+		if v, _ := m.GetFeature("FrameRate"); v != 5. {
+			t.Errorf("%s: expected the default FrameRate 5, got %v", path, v)
+		}
+	}
+
+	pm := camera.NewProfileManager(m, nil)
+	pm.SetStartup(rep)
+	rt := generichttp.RouteTable{}
+	pm.Inject(rt)
+	r := chi.NewRouter()
+	rt.Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/configure/startup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got camera.StartupReport
+	if err = json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != rep.Path || got.Error == "" {
+		t.Errorf("expected the startup report back, got %+v", got)
+	}
+}
+
+func TestStartupProfileRestoresOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "startup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "half.yml")
+	if err = ioutil.WriteFile(path, []byte("TriggerMode: Software\nFrameRate: 1000.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := camera.NewMockCamera(64, 48)
+	rep := camera.ApplyStartupProfile(m, path, camera.Profile{"FrameRate": 5.})
+	if rep.Restored == nil || len(rep.Restored.Applied) != 1 || rep.Restored.Applied[0] != "TriggerMode" {
+		t.Errorf("expected TriggerMode to be restored, got %+v", rep.Restored)
+	}
+	if v, _ := m.GetFeature("TriggerMode"); v != "Internal" {
+		t.Errorf("expected TriggerMode to be put back to Internal, got %v", v)
+	}
+}
+
+func TestCenteredAOI(t *testing.T) {
+	m, srv := newMockServer(t)
+	defer srv.Close()
+	if err := m.SetBinning(camera.Binning{H: 2, V: 2}); err != nil {
+		t.Fatal(err)
+	}
+	resp := postJSON(t, srv.URL+"/aoi/centered", map[string]int{"width": 10, "height": 6})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var got camera.AOI
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// 64x48 sensor, 20x12 unbinned window => 22 px on each side, 18 above and below
+	want := camera.AOI{Left: 23, Top: 19, Width: 10, Height: 6}
+	if got != want {
+		t.Errorf("expected AOI %+v, got %+v", want, got)
+	}
+}
+
+func TestAOIConstraintsCenter(t *testing.T) {
+	cons := camera.AOIConstraints{MinWidth: 6, MinHeight: 4, WidthStep: 4, HeightStep: 2, AlignToBinning: true}
+	cases := []struct {
+		name          string
+		width, height int
+		b             camera.Binning
+		want          camera.AOI
+	}{
+		{"already valid", 16, 8, camera.Binning{H: 1, V: 1}, camera.AOI{Left: 25, Top: 21, Width: 16, Height: 8}},
+		{"rounded down to the step", 18, 9, camera.Binning{H: 1, V: 1}, camera.AOI{Left: 25, Top: 21, Width: 16, Height: 8}},
+		{"minimum rounded up to the step", 1, 1, camera.Binning{H: 1, V: 1}, camera.AOI{Left: 29, Top: 23, Width: 8, Height: 4}},
+		{"larger than the sensor", 100, 100, camera.Binning{H: 1, V: 1}, camera.AOI{Left: 1, Top: 1, Width: 64, Height: 48}},
+		// 12 superpixels of 3 = 36 px, (64-36)/2+1 = 15, on the grid at 13
+		{"aligned to the binning", 12, 4, camera.Binning{H: 3, V: 3}, camera.AOI{Left: 13, Top: 19, Width: 12, Height: 4}},
+	}
+	for _, c := range cases {
+		got := cons.Center(64, 48, c.width, c.height, c.b)
+		if got != c.want {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestValidateAOINamesNearestValidValue(t *testing.T) {
+	b := camera.Binning{H: 2, V: 2}
+	err := camera.ValidateAOI(camera.AOI{Left: 41, Top: 1, Width: 50, Height: 10}, 128, 128, 4, 4, b)
+	if !errors.Is(err, camera.ErrAOIOutOfBounds) {
+		t.Fatalf("expected ErrAOIOutOfBounds, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "nearest valid width is 44") {
+		t.Errorf("expected the error to name width 44, got %q", err)
+	}
+	err = camera.ValidateAOI(camera.AOI{Left: 1, Top: 1, Width: 2, Height: 10}, 128, 128, 4, 4, b)
+	if err == nil || !strings.Contains(err.Error(), "minimum of 4") {
+		t.Errorf("expected a minimum width error, got %v", err)
+	}
+	if err = camera.ValidateAOI(camera.AOI{Left: 1, Top: 65, Width: 64, Height: 32}, 128, 128, 4, 4, b); err != nil {
+		t.Errorf("expected a full-width AOI on the lower half to be valid, got %v", err)
+	}
+}
+
+func TestAOIConstraintsValidate(t *testing.T) {
+	cons := camera.AOIConstraints{MinWidth: 4, MinHeight: 4, WidthStep: 4, HeightStep: 2, AlignToBinning: true}
+	b := camera.Binning{H: 2, V: 2}
+	cases := []struct {
+		aoi  camera.AOI
+		want string // in the error, empty if valid
+	}{
+		{camera.AOI{Left: 1, Top: 1, Width: 8, Height: 8}, ""},
+		{camera.AOI{Left: 1, Top: 1, Width: 10, Height: 8}, "nearest valid width is 8"},
+		{camera.AOI{Left: 1, Top: 1, Width: 8, Height: 7}, "nearest valid height is 6"},
+		{camera.AOI{Left: 4, Top: 1, Width: 8, Height: 8}, "nearest valid left is 3"},
+		{camera.AOI{Left: 3, Top: 6, Width: 8, Height: 8}, "nearest valid top is 5"},
+		{camera.AOI{Left: 1, Top: 1, Width: 2, Height: 8}, "minimum of 4"},
+	}
+	for _, c := range cases {
+		err := cons.Validate(c.aoi, 64, 48, b)
+		if c.want == "" {
+			if err != nil {
+				t.Errorf("%+v: expected valid, got %v", c.aoi, err)
+			}
+			continue
+		}
+		if !errors.Is(err, camera.ErrAOIOutOfBounds) || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%+v: expected an error naming %q, got %v", c.aoi, c.want, err)
+		}
+	}
+}
+
+func TestSnapshotGuardHoldsOffSettings(t *testing.T) {
+	h := camera.NewHTTPCamera(camera.NewMockCamera(8, 8), nil)
+	release := make(chan struct{})
+	inSnapshot := make(chan struct{})
+	set := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			close(inSnapshot)
+			<-release
+			return
+		}
+		close(set)
+	})
+	guarded := h.SnapshotGuard(next)
+	go guarded.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cam/snapshot", nil))
+	<-inSnapshot
+	go guarded.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cam/exposure-time", nil))
+	select {
+	case <-set:
+		t.Fatal("a setting was changed during a snapshot")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-set:
+	case <-time.After(time.Second):
+		t.Fatal("the setting was not changed after the snapshot")
+	}
+}
+
+func TestSnapshotIsMultipart(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/snapshot?fmt=png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	mediatype, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediatype != "multipart/mixed" {
+		t.Fatalf("expected a multipart/mixed response, got %q (%v)", mediatype, err)
+	}
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := part.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected the first part to be image/png, got %q", ct)
+	}
+	if _, err = png.Decode(part); err != nil {
+		t.Errorf("expected the first part to decode as a PNG, got %v", err)
+	}
+	part, err = mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md struct {
+		ExposureTime float64                `json:"exposureTime"`
+		Features     map[string]interface{} `json:"features"`
+	}
+	if err = json.NewDecoder(part).Decode(&md); err != nil {
+		t.Fatal(err)
+	}
+	if md.ExposureTime != 0.001 {
+		t.Errorf("expected exposure time 0.001, got %v", md.ExposureTime)
+	}
+	if md.Features["CameraModel"] != "MOCK" {
+		t.Errorf("expected CameraModel MOCK in the metadata, got %v", md.Features)
+	}
+}
+
+// emMock is a mock camera with EM gain, which may or may not report it as
+// supported
+type emMock struct {
+	*camera.MockCamera
+	gain    int
+	capable bool
+}
+
+func (e *emMock) GetEMGain() (int, error)           { return e.gain, nil }
+func (e *emMock) SetEMGain(g int) error             { e.gain = g; return nil }
+func (e *emMock) GetEMGainRange() (int, int, error) { return 0, 300, nil }
+func (e *emMock) Capable(capability string) bool    { return e.capable }
+
+func TestEMGainRoutesAreGatedByCapability(t *testing.T) {
+	for _, capable := range []bool{true, false} {
+		h := camera.NewHTTPCamera(&emMock{MockCamera: camera.NewMockCamera(8, 8), capable: capable}, nil)
+		_, has := h.RT()[generichttp.MethodPath{Method: http.MethodPost, Path: "/em-gain"}]
+		if has != capable {
+			t.Errorf("capable=%v, but /em-gain registered=%v", capable, has)
+		}
+	}
+}
+
+func TestEventsStreamAndStopOnDisconnect(t *testing.T) {
+	m := camera.NewMockCamera(8, 8)
+	fe := camera.NewFeatureEvents(m, 10*time.Millisecond, "FrameRate")
+	srv := httptest.NewServer(fe)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	seen := map[string]bool{}
+	for sc.Scan() && !(seen["exposureTime"] && seen["FrameRate"]) {
+		line := sc.Text()
+		if strings.HasPrefix(line, "data: ") {
+			var ev camera.FeatureEvent
+			if err := json.Unmarshal([]byte(line[6:]), &ev); err != nil {
+				t.Fatal(err)
+			}
+			seen[ev.Name] = true
+		}
+	}
+	if !fe.Running() {
+		t.Error("expected the poller to run while a client is connected")
+	}
+	cancel()
+	resp.Body.Close()
+	deadline := time.Now().Add(time.Second)
+	for fe.Running() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if fe.Running() {
+		t.Error("expected the poller to stop after the client disconnected")
+	}
+}
+
+type countingFeatures struct {
+	camera.FeatureManager
+	infos int
+}
+
+func (c *countingFeatures) GetFeatureInfo(feature string) (map[string]interface{}, error) {
+	c.infos++
+	return c.FeatureManager.GetFeatureInfo(feature)
+}
+
+func TestFeatureInfoCache(t *testing.T) {
+	fm := &countingFeatures{FeatureManager: camera.NewMockCamera(64, 48)}
+	c := camera.NewFeatureInfoCache(fm, time.Hour)
+	for i := 0; i < 3; i++ {
+		info, err := c.GetFeatureInfo("FrameRate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		info["min"] = -1 // must not leak into the cache
+	}
+	if fm.infos != 1 {
+		t.Errorf("expected one query of the camera, got %d", fm.infos)
+	}
+	info, _ := c.GetFeatureInfo("FrameRate")
+	if info["min"] == -1 {
+		t.Error("expected the cache to be unaffected by changes to a response")
+	}
+	if err := c.SetFeature("FrameRate", 25.); err != nil {
+		t.Fatal(err)
+	}
+	c.GetFeatureInfo("FrameRate")
+	if fm.infos != 2 {
+		t.Errorf("expected setting a feature to clear the cache, %d queries were made", fm.infos)
+	}
+}
+
+func TestExposureTimeUnits(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	var f struct {
+		F64 float64 `json:"f64"`
+	}
+	resp := postJSON(t, srv.URL+"/exposure-time?unit=ms", map[string]interface{}{"f64": 2.5})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 setting the exposure time, got %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.F64 != 2.5 {
+		t.Errorf("expected the applied exposure time of 2.5 ms, got %f", f.F64)
+	}
+	resp, err := http.Get(srv.URL + "/exposure-time?unit=us")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.F64 != 2500 {
+		t.Errorf("expected 2500 us, got %f", f.F64)
+	}
+	resp = postJSON(t, srv.URL+"/exposure-time?unit=min", map[string]interface{}{"f64": 1})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown unit, got %d", resp.StatusCode)
+	}
+}
+
+type stubResetter struct {
+	err error
+}
+
+func (s stubResetter) Reset() (map[string]interface{}, error) {
+	return map[string]interface{}{"ExposureTime": 0.1}, s.err
+}
+
+func TestResetReportsStateAndRestoreErrors(t *testing.T) {
+	rt := generichttp.RouteTable{}
+	camera.HTTPResetter(stubResetter{err: errors.New("restoring FrameRate: not writable")}, rt)
+	r := chi.NewRouter()
+	rt.Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/admin/reset", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		State map[string]interface{} `json:"state"`
+		Error string                 `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.State["ExposureTime"] != 0.1 || !strings.Contains(body.Error, "FrameRate") {
+		t.Errorf("expected the state and the restore error, got %+v", body)
+	}
+}
+
+type stubReadout struct {
+	set camera.ReadoutMode
+}
+
+func (s *stubReadout) GetReadoutMode() (camera.ReadoutMode, error) {
+	return s.set, nil
+}
+
+func (s *stubReadout) SetReadoutMode(m camera.ReadoutMode) error {
+	if m.ReadoutRate == "1 GHz" {
+		return errors.New("PixelReadoutRate \"1 GHz\" is not offered by this camera")
+	}
+	s.set = m
+	return nil
+}
+
+func TestReadoutModeSetsAllFieldsAtOnce(t *testing.T) {
+	stub := &stubReadout{}
+	rt := generichttp.RouteTable{}
+	camera.HTTPReadoutMode(stub, rt)
+	r := chi.NewRouter()
+	rt.Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	body := `{"shuttering": "Global", "readoutRate": "100 MHz", "overlap": true}`
+	resp, err := http.Post(srv.URL+"/readout-mode", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if stub.set.Shuttering != "Global" || stub.set.ReadoutRate != "100 MHz" || stub.set.Overlap == nil || !*stub.set.Overlap {
+		t.Errorf("expected all three fields to be set, got %+v", stub.set)
+	}
+	resp, err = http.Post(srv.URL+"/readout-mode", "application/json", strings.NewReader(`{"readoutRate": "1 GHz"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a rate the camera does not offer, got %d", resp.StatusCode)
+	}
+}
+
+func TestUserFITSCards(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/image?fmt=fits&hdr.OBJECT=M31&hdr.FILTER=R&hdr.EXPNUM=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	f, err := fitsio.Open(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	hdr := f.HDU(0).Header()
+	if c := hdr.Get("OBJECT"); c == nil || c.Value != "M31" {
+		t.Errorf("expected OBJECT = M31, got %+v", c)
+	}
+	if c := hdr.Get("EXPNUM"); c == nil || c.Value != 7 {
+		t.Errorf("expected EXPNUM = 7, got %+v", c)
+	}
+	if keys := strings.Join(hdr.Keys(), ","); !strings.Contains(keys, "EXPNUM,FILTER,OBJECT") {
+		t.Errorf("expected the user cards sorted, got %s", keys)
+	}
+
+	for _, q := range []string{"hdr.NAXIS1=4", "hdr.BITPIX=8", "hdr.OBSERVATORY=JPL"} {
+		resp, err = http.Get(srv.URL + "/image?fmt=fits&" + q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestFullAOIRestoresSensorAndBinning(t *testing.T) {
+	m, srv := newMockServer(t)
+	defer srv.Close()
+	if err := m.SetBinning(camera.Binning{H: 2, V: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAOI(camera.AOI{Left: 5, Top: 5, Width: 8, Height: 8}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(srv.URL+"/aoi/full", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	want := camera.AOI{Left: 1, Top: 1, Width: 64, Height: 48}
+	if aoi, _ := m.GetAOI(); aoi != want {
+		t.Errorf("expected AOI %+v, got %+v", want, aoi)
+	}
+	if b, _ := m.GetBinning(); b != (camera.Binning{H: 1, V: 1}) {
+		t.Errorf("expected 1x1 binning, got %s", b.HxV())
+	}
+}
+
+func TestFrameRateMeter(t *testing.T) {
+	var m camera.FrameRateMeter
+	if fps, n := m.Rate(); fps != 0 || n != 0 {
+		t.Errorf("expected no rate before any frames, got %g from %d", fps, n)
+	}
+	t0 := time.Unix(0, 0)
+	// more than a window of frames, the early ones at a slower rate
+	for i := 0; i < 10; i++ {
+		m.Mark(t0.Add(time.Duration(i) * 100 * time.Millisecond))
+	}
+	t1 := t0.Add(time.Second)
+	for i := 0; i < camera.FrameRateWindow; i++ {
+		m.Mark(t1.Add(time.Duration(i) * 10 * time.Millisecond))
+	}
+	fps, n := m.Rate()
+	if n != camera.FrameRateWindow || fps < 99.9 || fps > 100.1 {
+		t.Errorf("expected 100 fps over %d frames, got %g over %d", camera.FrameRateWindow, fps, n)
+	}
+}
+
+// flakyCamera fails every frame after the first few
+type flakyCamera struct {
+	*camera.MockCamera
+	left int
+}
+
+func (f *flakyCamera) GetFrame() (image.Image, error) {
+	if f.left == 0 {
+		return nil, errors.New("acquisition timed out")
+	}
+	f.left--
+	return f.MockCamera.GetFrame()
+}
+
+func TestAveragedKeepsFramesTakenBeforeAFailure(t *testing.T) {
+	rt := generichttp.RouteTable{}
+	camera.HTTPAveraged(&flakyCamera{MockCamera: camera.NewMockCamera(8, 4), left: 3}, rt)
+	r := chi.NewRouter()
+	rt.Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/image/averaged?n=16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	f, err := fitsio.Open(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img := f.HDU(0).(fitsio.Image)
+	hdr := img.Header()
+	if hdr.Bitpix() != -32 {
+		t.Errorf("expected a 32-bit float image, got BITPIX %d", hdr.Bitpix())
+	}
+	if c := hdr.Get("NFRAMES"); c == nil || c.Value != 3 {
+		t.Errorf("expected NFRAMES = 3, got %+v", c)
+	}
+	if c := hdr.Get("ERR"); c == nil || !strings.Contains(fmt.Sprint(c.Value), "timed out") {
+		t.Errorf("expected the error in the header, got %+v", c)
+	}
+	pix := make([]float32, 8*4)
+	if err := img.Read(&pix); err != nil {
+		t.Fatal(err)
+	}
+	// the mock's pixel (x, y) is x + y plus 1 for the 1 ms exposure
+	if pix[0] != 1 || pix[8+2] != 4 {
+		t.Errorf("expected the mean of identical frames to equal them, got %v", pix[:11])
+	}
+}
+
+func TestArrayIsRawLittleEndian(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/array")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Array-Width") != "64" || resp.Header.Get("X-Array-Height") != "48" ||
+		resp.Header.Get("X-Array-Dtype") != "<u2" || resp.Header.Get("X-Array-Bitdepth") != "16" {
+		t.Errorf("unexpected array headers %v", resp.Header)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 64*48*2 {
+		t.Fatalf("expected %d bytes, got %d", 64*48*2, len(b))
+	}
+	// pixel (x, y) of the mock is x + y + 1; check (3, 2) in row major order
+	i := 2 * (2*64 + 3)
+	if v := int(b[i]) | int(b[i+1])<<8; v != 6 {
+		t.Errorf("expected pixel (3, 2) to be 6, got %d", v)
+	}
+}
+
+func TestFeatureInfoBatch(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp := postJSON(t, srv.URL+"/features/info/batch", map[string]interface{}{
+		"features": []string{"PixelEncoding", "FrameRate", "NotAFeature"}})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var batch camera.FeatureInfoBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Info) != 2 || batch.Info["PixelEncoding"]["type"] != "enum" || batch.Info["FrameRate"]["max"] != 100. {
+		t.Errorf("unexpected info %v", batch.Info)
+	}
+	if _, ok := batch.Errors["NotAFeature"]; !ok || len(batch.Errors) != 1 {
+		t.Errorf("expected only NotAFeature to fail, got %v", batch.Errors)
+	}
+}
+
+func TestFrameRateAboveMaximumIsRejected(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/framerate/range")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rng struct {
+		Min float64 `json:"min"`
+		Max float64 `json:"max"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&rng)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = postJSON(t, srv.URL+"/feature/FrameRate", map[string]interface{}{"value": rng.Max * 2})
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), fmt.Sprint(rng.Max)) {
+		t.Errorf("expected the error to name the maximum %g, got %q", rng.Max, body)
+	}
+}
+
+func TestSoftwareTriggerRequiresSoftwareMode(t *testing.T) {
+	_, srv := newMockServer(t)
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/trigger", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("trigger in Internal mode: expected 409, got %d", resp.StatusCode)
+	}
+	resp = postJSON(t, srv.URL+"/feature/TriggerMode", map[string]interface{}{"value": "Software"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("setting TriggerMode: expected 200, got %d", resp.StatusCode)
+	}
+	resp, err = http.Post(srv.URL+"/trigger", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("trigger in Software mode: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+// gainCoupled is a FeatureManager on which PixelEncoding Mono12 may only be
+// set once SimplePreAmpGainControl is 12-bit, as on the Zyla
+type gainCoupled struct {
+	camera.FeatureManager
+	vals map[string]interface{}
+}
+
+func (g *gainCoupled) GetFeature(k string) (interface{}, error) { return g.vals[k], nil }
+
+func (g *gainCoupled) SetFeature(k string, v interface{}) error {
+	if k == "PixelEncoding" && v == "Mono12" && g.vals["SimplePreAmpGainControl"] != "12-bit (low noise)" {
+		return errors.New("Mono12 is not available with 16-bit gain")
+	}
+	g.vals[k] = v
+	return nil
+}
+
+func TestProfileRetriesDependentFeatures(t *testing.T) {
+	g := &gainCoupled{vals: map[string]interface{}{
+		"SimplePreAmpGainControl": "16-bit (low noise & high well capacity)",
+		"PixelEncoding":           "Mono16"}}
+	res := camera.ApplyProfile(g, camera.Profile{
+		"PixelEncoding":           "Mono12",
+		"SimplePreAmpGainControl": "12-bit (low noise)"})
+	if len(res.Errors) != 0 || len(res.Applied) != 2 {
+		t.Errorf("expected both features to be applied, got %+v", res)
+	}
+	if g.vals["PixelEncoding"] != "Mono12" {
+		t.Errorf("expected PixelEncoding Mono12, got %v", g.vals["PixelEncoding"])
+	}
+}
@@ -0,0 +1,177 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"image"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
+)
+
+// FrameProcessor modifies a frame after it is read from the camera and before
+// it is encoded and returned to the client.  Processors may inspect the
+// request (e.g. for query parameters) and set response headers.  Any cards
+// returned are added to the header of FITS responses
+type FrameProcessor interface {
+	// ProcessFrame returns the processed image and any FITS cards describing
+	// what was done to it
+	ProcessFrame(w http.ResponseWriter, r *http.Request, img image.Image) (image.Image, []fitsio.Card, error)
+}
+
+// DarkStore captures and holds dark frames for a camera and subtracts them
+// from later frames.  Darks are keyed by the AOI, binning, and exposure time
+// they were taken with, so a dark is only applied to frames taken with
+// matching settings
+type DarkStore struct {
+	p PictureTaker
+
+	mu    sync.Mutex
+	darks map[string]*image.Gray16
+}
+
+// NewDarkStore returns a new, empty dark store for p
+func NewDarkStore(p PictureTaker) *DarkStore {
+	return &DarkStore{p: p, darks: make(map[string]*image.Gray16)}
+}
+
+// key returns the key for the camera's present configuration
+func (d *DarkStore) key() (string, error) {
+	texp, err := d.p.GetExposureTime()
+	if err != nil {
+		return "", err
+	}
+	key := "texp=" + texp.String()
+	if aoiM, ok := d.p.(AOIManipulator); ok {
+		aoi, err := aoiM.GetAOI()
+		if err != nil {
+			return "", err
+		}
+		bin, err := aoiM.GetBinning()
+		if err != nil {
+			return "", err
+		}
+		key = fmt.Sprintf("aoi=%d,%d,%d,%d;bin=%s;%s", aoi.Left, aoi.Top, aoi.Width, aoi.Height, bin.HxV(), key)
+	}
+	return key, nil
+}
+
+// Capture takes a frame and stores it as the dark for the present
+// configuration, replacing any existing dark with the same key.  The camera's
+// shutter is not touched; the caller is responsible for blocking the light
+func (d *DarkStore) Capture() (string, error) {
+	key, err := d.key()
+	if err != nil {
+		return "", err
+	}
+	img, err := d.p.GetFrame()
+	if err != nil {
+		return "", err
+	}
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return "", fmt.Errorf("generichttp/camera: dark frames must be 16-bit grayscale, got %T", img)
+	}
+	dark := &image.Gray16{Pix: make([]byte, len(g16.Pix)), Stride: g16.Stride, Rect: g16.Rect}
+	copy(dark.Pix, g16.Pix)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.darks[key] = dark
+	return key, nil
+}
+
+// Keys returns the keys of all stored darks, sorted
+func (d *DarkStore) Keys() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	keys := make([]string, 0, len(d.darks))
+	for k := range d.darks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Subtract subtracts the dark matching the present configuration from img,
+// clamping at zero.  img is modified in place.  The returned bool is false if
+// no dark matched and img was left untouched
+func (d *DarkStore) Subtract(img image.Image) (bool, error) {
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return false, nil
+	}
+	key, err := d.key()
+	if err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	dark, ok := d.darks[key]
+	d.mu.Unlock()
+	if !ok || len(dark.Pix) != len(g16.Pix) || len(g16.Pix) == 0 {
+		return false, nil
+	}
+	subtractClamped(bytesToUint(g16.Pix), bytesToUint(dark.Pix))
+	return true, nil
+}
+
+// subtractClamped computes dst -= dark elementwise, clamping at zero
+func subtractClamped(dst, dark []uint16) {
+	for i, v := range dark {
+		if dst[i] > v {
+			dst[i] -= v
+		} else {
+			dst[i] = 0
+		}
+	}
+}
+
+// ProcessFrame satisfies FrameProcessor.  The dark is subtracted only when the
+// request has the query parameter subtract_dark=true.  The X-Dark-Subtracted
+// header and DARKSUB card report whether subtraction actually occurred
+func (d *DarkStore) ProcessFrame(w http.ResponseWriter, r *http.Request, img image.Image) (image.Image, []fitsio.Card, error) {
+	want, err := server.QueryBool(r, "subtract_dark", false)
+	if err != nil {
+		return img, nil, generichttp.BadRequest(err)
+	}
+	if !want {
+		return img, nil, nil
+	}
+	did, err := d.Subtract(img)
+	if err != nil {
+		return img, nil, err
+	}
+	w.Header().Set("X-Dark-Subtracted", strconv.FormatBool(did))
+	return img, []fitsio.Card{{Name: "DARKSUB", Value: did, Comment: "dark frame subtracted"}}, nil
+}
+
+// CaptureDark captures a dark frame on a POST request and responds with its key
+func (d *DarkStore) CaptureDark(w http.ResponseWriter, r *http.Request) {
+	key, err := d.Capture()
+	if err != nil {
+		generichttp.Error(w, err)
+		return
+	}
+	hp := generichttp.HumanPayload{T: types.String, String: key}
+	hp.EncodeAndRespond(w, r)
+}
+
+// ListDarks responds with the keys of all stored darks
+func (d *DarkStore) ListDarks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(d.Keys())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Inject adds the dark frame routes to the table
+func (d *DarkStore) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/dark/capture"}] = d.CaptureDark
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/dark"}] = d.ListDarks
+}
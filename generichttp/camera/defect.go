@@ -0,0 +1,202 @@
+package camera
+
+import (
+	"encoding/json"
+	"image"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
+)
+
+// Defect is the location of a defective pixel, in 0-based full-sensor
+// coordinates, independent of AOI and binning
+type Defect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// DefectMap holds a list of known defective (hot, dead) pixels and replaces
+// them with the median of their neighbors in frames served over HTTP.
+// The map lives for the life of the server process
+type DefectMap struct {
+	p Camera
+
+	mu      sync.Mutex
+	defects []Defect
+}
+
+// NewDefectMap returns a new, empty defect map for p
+func NewDefectMap(p Camera) *DefectMap {
+	return &DefectMap{p: p}
+}
+
+// SetDefects replaces the defect list.  An empty list clears the map
+func (d *DefectMap) SetDefects(defects []Defect) {
+	cpy := make([]Defect, len(defects))
+	copy(cpy, defects)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.defects = cpy
+}
+
+// Defects returns a copy of the defect list
+func (d *DefectMap) Defects() []Defect {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cpy := make([]Defect, len(d.defects))
+	copy(cpy, d.defects)
+	return cpy
+}
+
+// framePoints converts the defect list to 0-based coordinates within the
+// present frame, accounting for AOI and binning if p is an AOIManipulator.
+// Defects which fall outside the frame are dropped
+func (d *DefectMap) framePoints(bounds image.Rectangle) ([]image.Point, error) {
+	defects := d.Defects()
+	var (
+		aoi AOI
+		bin = Binning{H: 1, V: 1}
+	)
+	if aoiM, ok := d.p.(AOIManipulator); ok {
+		var err error
+		aoi, err = aoiM.GetAOI()
+		if err != nil {
+			return nil, err
+		}
+		bin, err = aoiM.GetBinning()
+		if err != nil {
+			return nil, err
+		}
+		if bin.H < 1 {
+			bin.H = 1
+		}
+		if bin.V < 1 {
+			bin.V = 1
+		}
+	} else {
+		aoi = AOI{Left: 1, Top: 1}
+	}
+	pts := make([]image.Point, 0, len(defects))
+	for _, def := range defects {
+		x, y := def.X-(aoi.Left-1), def.Y-(aoi.Top-1)
+		if x < 0 || y < 0 {
+			continue
+		}
+		pt := image.Point{X: x / bin.H, Y: y / bin.V}
+		if !pt.In(image.Rect(0, 0, bounds.Dx(), bounds.Dy())) {
+			continue
+		}
+		pts = append(pts, pt)
+	}
+	return pts, nil
+}
+
+// replaceWithMedian replaces each of the flagged pixels in the width x height
+// array pix with the median of its (up to 8) unflagged neighbors.  Pixels
+// with no unflagged neighbors are left alone.  For an even number of
+// neighbors, the mean of the two middle values is used
+func replaceWithMedian(pix []uint16, width, height int, flagged []image.Point) {
+	bad := make(map[int]struct{}, len(flagged))
+	for _, pt := range flagged {
+		bad[pt.Y*width+pt.X] = struct{}{}
+	}
+	// compute all replacements before writing any, so that the result does
+	// not depend on the order of flagged
+	repl := make(map[int]uint16, len(bad))
+	neighbors := make([]uint16, 0, 8)
+	for idx := range bad {
+		x, y := idx%width, idx/width
+		neighbors = neighbors[:0]
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				xx, yy := x+dx, y+dy
+				if (dx == 0 && dy == 0) || xx < 0 || yy < 0 || xx >= width || yy >= height {
+					continue
+				}
+				j := yy*width + xx
+				if _, isBad := bad[j]; isBad {
+					continue
+				}
+				neighbors = append(neighbors, pix[j])
+			}
+		}
+		n := len(neighbors)
+		if n == 0 {
+			continue
+		}
+		sort.Slice(neighbors, func(i, j int) bool { return neighbors[i] < neighbors[j] })
+		if n%2 == 1 {
+			repl[idx] = neighbors[n/2]
+		} else {
+			repl[idx] = uint16((uint32(neighbors[n/2-1]) + uint32(neighbors[n/2])) / 2)
+		}
+	}
+	for idx, v := range repl {
+		pix[idx] = v
+	}
+}
+
+// ProcessFrame satisfies FrameProcessor.  Defects are masked only when the
+// request has the query parameter mask_defects=true.  The X-Defects-Masked
+// header and DEFMASK card report the number of pixels replaced
+func (d *DefectMap) ProcessFrame(w http.ResponseWriter, r *http.Request, img image.Image) (image.Image, []fitsio.Card, error) {
+	want, err := server.QueryBool(r, "mask_defects", false)
+	if err != nil {
+		return img, nil, generichttp.BadRequest(err)
+	}
+	if !want {
+		return img, nil, nil
+	}
+	g16, ok := img.(*image.Gray16)
+	if !ok || len(g16.Pix) == 0 {
+		return img, nil, nil
+	}
+	pts, err := d.framePoints(g16.Bounds())
+	if err != nil {
+		return img, nil, err
+	}
+	b := g16.Bounds()
+	replaceWithMedian(bytesToUint(g16.Pix), b.Dx(), b.Dy(), pts)
+	w.Header().Set("X-Defects-Masked", strconv.Itoa(len(pts)))
+	return img, []fitsio.Card{{Name: "DEFMASK", Value: len(pts), Comment: "defective pixels replaced by local median"}}, nil
+}
+
+// SetDefectMap replaces the defect map with the JSON list of {x, y} points
+// in the body of a POST request
+func (d *DefectMap) SetDefectMap(w http.ResponseWriter, r *http.Request) {
+	var defects []Defect
+	err := json.NewDecoder(r.Body).Decode(&defects)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, def := range defects {
+		if def.X < 0 || def.Y < 0 {
+			http.Error(w, "defect coordinates must be non-negative", http.StatusBadRequest)
+			return
+		}
+	}
+	d.SetDefects(defects)
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetDefectMap responds with the defect map as a JSON list of {x, y} points
+func (d *DefectMap) GetDefectMap(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(d.Defects())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Inject adds the defect map routes to the table
+func (d *DefectMap) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/defect-map"}] = d.GetDefectMap
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/defect-map"}] = d.SetDefectMap
+}
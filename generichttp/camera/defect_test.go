@@ -0,0 +1,43 @@
+package camera
+
+import (
+	"image"
+	"testing"
+)
+
+func TestReplaceWithMedianInterior(t *testing.T) {
+	pix := []uint16{
+		1, 2, 3,
+		4, 9999, 6,
+		7, 8, 9,
+	}
+	replaceWithMedian(pix, 3, 3, []image.Point{{X: 1, Y: 1}})
+	// neighbors 1,2,3,4,6,7,8,9 => (4+6)/2
+	if pix[4] != 5 {
+		t.Errorf("expected center to be replaced with 5, got %d", pix[4])
+	}
+}
+
+func TestReplaceWithMedianCornerIgnoresOtherDefects(t *testing.T) {
+	pix := []uint16{
+		9999, 9999, 3,
+		10, 20, 6,
+		7, 8, 9,
+	}
+	replaceWithMedian(pix, 3, 3, []image.Point{{X: 0, Y: 0}, {X: 1, Y: 0}})
+	// (0,0) sees 10, 20 => 15; (1,0) sees 3, 10, 20, 6 => (6+10)/2
+	if pix[0] != 15 {
+		t.Errorf("expected corner to be replaced with 15, got %d", pix[0])
+	}
+	if pix[1] != 8 {
+		t.Errorf("expected edge to be replaced with 8, got %d", pix[1])
+	}
+}
+
+func TestReplaceWithMedianAllFlaggedIsUnchanged(t *testing.T) {
+	pix := []uint16{100}
+	replaceWithMedian(pix, 1, 1, []image.Point{{X: 0, Y: 0}})
+	if pix[0] != 100 {
+		t.Errorf("expected an isolated defect to be left alone, got %d", pix[0])
+	}
+}
@@ -0,0 +1,203 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// FeatureEvent is a change in the value of a feature
+type FeatureEvent struct {
+	// Name is the name of the feature
+	Name string `json:"name"`
+
+	// Value is its new value
+	Value interface{} `json:"value"`
+}
+
+// FeatureEvents polls a camera in the background and pushes changes to
+// subscribers as server-sent events.  The poller only runs while at least one
+// client is subscribed.
+//
+// The exposure time (exposureTime, in seconds), sensor temperature
+// (temperature) and cooling status (temperatureStatus) are polled if the
+// camera implements the relevant interfaces, in addition to any features
+// named in Features
+type FeatureEvents struct {
+	p Camera
+
+	// Features is the list of FeatureManager features to poll
+	Features []string
+
+	// Interval is the time between polls
+	Interval time.Duration
+
+	mu   sync.Mutex
+	subs map[chan FeatureEvent]struct{}
+	last map[string]interface{}
+	stop chan struct{}
+}
+
+// NewFeatureEvents returns a new event source for p which polls every
+// interval
+func NewFeatureEvents(p Camera, interval time.Duration, features ...string) *FeatureEvents {
+	return &FeatureEvents{
+		p:        p,
+		Features: features,
+		Interval: interval,
+		subs:     make(map[chan FeatureEvent]struct{})}
+}
+
+// Subscribe returns a channel which receives each change observed by the
+// poller, starting the poller if it is not running.  The caller must call
+// Unsubscribe when done
+func (fe *FeatureEvents) Subscribe() chan FeatureEvent {
+	ch := make(chan FeatureEvent, 16)
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.subs[ch] = struct{}{}
+	if fe.stop == nil {
+		// a fresh poller reports every value once, so new clients see the
+		// present state without waiting for a change
+		fe.last = make(map[string]interface{})
+		fe.stop = make(chan struct{})
+		go fe.poll(fe.stop)
+	} else {
+		// catch the new client up on the present state
+		for k, v := range fe.last {
+			if len(ch) == cap(ch) {
+				break
+			}
+			ch <- FeatureEvent{Name: k, Value: v}
+		}
+	}
+	return ch
+}
+
+// Unsubscribe removes ch from the subscribers, stopping the poller if there
+// are none left
+func (fe *FeatureEvents) Unsubscribe(ch chan FeatureEvent) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	delete(fe.subs, ch)
+	if len(fe.subs) == 0 && fe.stop != nil {
+		close(fe.stop)
+		fe.stop = nil
+	}
+}
+
+// Running returns true if the poller is running
+func (fe *FeatureEvents) Running() bool {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	return fe.stop != nil
+}
+
+// read returns the present value of every polled quantity.  Quantities which
+// produce an error are omitted
+func (fe *FeatureEvents) read() map[string]interface{} {
+	ret := make(map[string]interface{})
+	if pt, ok := fe.p.(PictureTaker); ok {
+		if d, err := pt.GetExposureTime(); err == nil {
+			ret["exposureTime"] = d.Seconds()
+		}
+	}
+	if tm, ok := fe.p.(ThermalManager); ok {
+		if t, err := tm.GetTemperature(); err == nil {
+			ret["temperature"] = t
+		}
+		if s, err := tm.GetTemperatureStatus(); err == nil {
+			ret["temperatureStatus"] = s
+		}
+	}
+	if fm, ok := fe.p.(FeatureManager); ok {
+		for _, f := range fe.Features {
+			if v, err := fm.GetFeature(f); err == nil {
+				ret[f] = v
+			}
+		}
+	}
+	return ret
+}
+
+func (fe *FeatureEvents) poll(stop chan struct{}) {
+	ticker := time.NewTicker(fe.Interval)
+	defer ticker.Stop()
+	for {
+		values := fe.read()
+		fe.mu.Lock()
+		select {
+		case <-stop:
+			// unsubscribed while reading; fe.last may belong to a new poller
+			fe.mu.Unlock()
+			return
+		default:
+		}
+		for k, v := range values {
+			if old, ok := fe.last[k]; ok && reflect.DeepEqual(old, v) {
+				continue
+			}
+			fe.last[k] = v
+			ev := FeatureEvent{Name: k, Value: v}
+			for ch := range fe.subs {
+				// a slow client drops events rather than stalling the others
+				select {
+				case ch <- ev:
+				default:
+				}
+			}
+		}
+		fe.mu.Unlock()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP streams events to the client as text/event-stream until the
+// client disconnects.  Each is sent as "event: feature" with a JSON body of
+// {"name": ..., "value": ...}
+func (fe *FeatureEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported by this connection", http.StatusInternalServerError)
+		return
+	}
+	hdr := w.Header()
+	hdr.Set("Content-Type", "text/event-stream")
+	hdr.Set("Cache-Control", "no-cache")
+	hdr.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := fe.Subscribe()
+	defer fe.Unsubscribe(ch)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			b, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "event: feature\ndata: %s\n\n", b)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Inject adds the /events route to the table
+func (fe *FeatureEvents) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/events"}] = fe.ServeHTTP
+}
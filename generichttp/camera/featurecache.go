@@ -0,0 +1,89 @@
+package camera
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// DefaultFeatureInfoTTL is how long NewHTTPCamera caches feature information
+const DefaultFeatureInfoTTL = 10 * time.Second
+
+type featureInfoEntry struct {
+	info    map[string]interface{}
+	expires time.Time
+}
+
+// FeatureInfoCache is a FeatureManager which caches the responses of
+// GetFeatureInfo, such as enum options and ranges, for TTL.  Feature values
+// are never cached.  Setting any feature through the cache clears it, since
+// the range of one feature may depend on the value of another
+type FeatureInfoCache struct {
+	FeatureManager
+
+	// TTL is how long a response is reused.  Zero disables caching
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]featureInfoEntry
+}
+
+// NewFeatureInfoCache returns a cache in front of f
+func NewFeatureInfoCache(f FeatureManager, ttl time.Duration) *FeatureInfoCache {
+	return &FeatureInfoCache{FeatureManager: f, TTL: ttl, entries: map[string]featureInfoEntry{}}
+}
+
+// GetFeatureInfo returns the cached information about a feature, or asks the
+// underlying FeatureManager if it is absent or expired
+func (c *FeatureInfoCache) GetFeatureInfo(feature string) (map[string]interface{}, error) {
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.entries[feature]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return copyInfo(e.info), nil
+	}
+	info, err := c.FeatureManager.GetFeatureInfo(feature)
+	if err != nil || c.TTL <= 0 {
+		return info, err
+	}
+	c.mu.Lock()
+	c.entries[feature] = featureInfoEntry{info: copyInfo(info), expires: now.Add(c.TTL)}
+	c.mu.Unlock()
+	return info, nil
+}
+
+// SetFeature clears the cache and sets the feature
+func (c *FeatureInfoCache) SetFeature(feature string, v interface{}) error {
+	c.Clear()
+	return c.FeatureManager.SetFeature(feature, v)
+}
+
+// Clear empties the cache
+func (c *FeatureInfoCache) Clear() {
+	c.mu.Lock()
+	c.entries = map[string]featureInfoEntry{}
+	c.mu.Unlock()
+}
+
+// Inject adds the feature routes, backed by the cache, and a POST route to
+// /feature/cache/clear which empties it
+func (c *FeatureInfoCache) Inject(rt generichttp.RouteTable) {
+	HTTPFeatureManager(c, rt)
+	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/feature/cache/clear"}] = func(w http.ResponseWriter, r *http.Request) {
+		c.Clear()
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// copyInfo makes a shallow copy of info, so that callers of GetFeatureInfo
+// cannot modify the cache
+func copyInfo(info map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(info))
+	for k, v := range info {
+		ret[k] = v
+	}
+	return ret
+}
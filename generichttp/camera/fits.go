@@ -1,9 +1,16 @@
 package camera
 
 import (
+	"encoding/json"
+	"fmt"
 	"image"
 	"io"
+	"net/http"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"unsafe"
 
 	"github.com/astrogo/fitsio"
@@ -61,3 +68,117 @@ func bytesToUint(b []byte) []uint16 {
 	hdr.Cap = cap(b) / 2
 	return ary
 }
+
+// UserCardPrefix marks query parameters which are FITS header cards, as in
+// ?hdr.OBJECT=M31&hdr.FILTER=R
+const UserCardPrefix = "hdr."
+
+var (
+	// fitsKeyword is the set of characters allowed in a FITS keyword
+	fitsKeyword = regexp.MustCompile(`^[A-Z0-9_-]{1,8}$`)
+
+	// naxisN matches the NAXISn keywords, which describe the array
+	naxisN = regexp.MustCompile(`^NAXIS[0-9]+$`)
+
+	// reservedKeywords are written by WriteFits or describe the structure
+	// of the file, and may not be supplied by a client
+	reservedKeywords = map[string]struct{}{
+		"SIMPLE": {}, "BITPIX": {}, "NAXIS": {}, "EXTEND": {}, "END": {},
+		"BZERO": {}, "BSCALE": {}, "XTENSION": {}, "PCOUNT": {}, "GCOUNT": {},
+	}
+)
+
+// maxCardString is the longest string value which fits on one card
+const maxCardString = 68
+
+// checkUserCard returns an error if a card may not be written on behalf of a
+// client.  Keywords are upper cased first
+func checkUserCard(c *fitsio.Card) error {
+	c.Name = strings.ToUpper(c.Name)
+	if !fitsKeyword.MatchString(c.Name) {
+		return fmt.Errorf("generichttp/camera: FITS keyword %q must be 1 to 8 characters of A-Z, 0-9, _, or -", c.Name)
+	}
+	if _, ok := reservedKeywords[c.Name]; ok || naxisN.MatchString(c.Name) {
+		return fmt.Errorf("generichttp/camera: FITS keyword %s is reserved", c.Name)
+	}
+	switch v := c.Value.(type) {
+	case string:
+		if len(v) > maxCardString {
+			return fmt.Errorf("generichttp/camera: value of FITS keyword %s is longer than %d characters", c.Name, maxCardString)
+		}
+	case bool, int, float64:
+	default:
+		return fmt.Errorf("generichttp/camera: value of FITS keyword %s must be a string, number, or bool, not %T", c.Name, v)
+	}
+	return nil
+}
+
+// parseCardValue converts a query parameter to the narrowest FITS type
+func parseCardValue(s string) interface{} {
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+		return b
+	}
+	return s
+}
+
+// UserCards returns the header cards a client attached to a request, either
+// as query parameters with UserCardPrefix or as a JSON object body of keyword
+// to value.  The cards are sorted by keyword, so that the header is the same
+// from one request to the next.  An error is returned if any keyword is too
+// long, reserved, or has a value which cannot be written
+func UserCards(r *http.Request) ([]fitsio.Card, error) {
+	var cards []fitsio.Card
+	for k, vs := range r.URL.Query() {
+		if !strings.HasPrefix(k, UserCardPrefix) || len(vs) == 0 {
+			continue
+		}
+		cards = append(cards, fitsio.Card{Name: strings.TrimPrefix(k, UserCardPrefix), Value: parseCardValue(vs[0])})
+	}
+	if r.Body != nil && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var m map[string]interface{}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil && err != io.EOF {
+			return nil, err
+		}
+		for k, v := range m {
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					v = int(i)
+				} else if f, err := n.Float64(); err == nil {
+					v = f
+				}
+			}
+			cards = append(cards, fitsio.Card{Name: k, Value: v})
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Name < cards[j].Name })
+	for i := range cards {
+		if err := checkUserCard(&cards[i]); err != nil {
+			return nil, err
+		}
+	}
+	return cards, nil
+}
+
+// appendUserCards appends user to cards, returning an error if a keyword in
+// user is already present
+func appendUserCards(cards, user []fitsio.Card) ([]fitsio.Card, error) {
+	have := make(map[string]struct{}, len(cards))
+	for _, c := range cards {
+		have[c.Name] = struct{}{}
+	}
+	for _, c := range user {
+		if _, dup := have[c.Name]; dup {
+			return nil, fmt.Errorf("generichttp/camera: FITS keyword %s is already written by the camera", c.Name)
+		}
+		have[c.Name] = struct{}{}
+	}
+	return append(cards, user...), nil
+}
@@ -0,0 +1,147 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// FrameRateWindow is the number of recent frames FrameRateMeter measures over
+const FrameRateWindow = 64
+
+// FrameRateMeter measures the rate at which frames arrive.  The zero value
+// is ready to use
+type FrameRateMeter struct {
+	mu    sync.Mutex
+	times [FrameRateWindow]time.Time
+	next  int
+	count int
+}
+
+// Mark records the arrival of a frame at t
+func (m *FrameRateMeter) Mark(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.times[m.next] = t
+	m.next = (m.next + 1) % FrameRateWindow
+	if m.count < FrameRateWindow {
+		m.count++
+	}
+}
+
+// Reset forgets every frame, as when acquisition restarts
+func (m *FrameRateMeter) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next, m.count = 0, 0
+}
+
+// Rate returns the mean frame rate in Hz over the recent frames and the number
+// of frames it is measured from.  Fewer than two frames give a rate of zero
+func (m *FrameRateMeter) Rate() (float64, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.count < 2 {
+		return 0, m.count
+	}
+	newest := m.times[(m.next+FrameRateWindow-1)%FrameRateWindow]
+	oldest := m.times[(m.next+FrameRateWindow-m.count)%FrameRateWindow]
+	dt := newest.Sub(oldest).Seconds()
+	if dt <= 0 {
+		return 0, m.count
+	}
+	return float64(m.count-1) / dt, m.count
+}
+
+// FrameRateReport compares the frame rate a camera was asked for to the rate
+// frames actually arrived at
+type FrameRateReport struct {
+	// Requested is the frame rate setting of the camera, in Hz
+	Requested float64 `json:"requested"`
+
+	// Measured is the rate frames arrived at recently, in Hz
+	Measured float64 `json:"measured"`
+
+	// Frames is the number of frames Measured is computed from
+	Frames int `json:"frames"`
+}
+
+// FrameRateReporter is a camera which measures its achieved frame rate
+type FrameRateReporter interface {
+	// GetFrameRateReport returns the requested and measured frame rates
+	GetFrameRateReport() (FrameRateReport, error)
+}
+
+// HTTPFrameRateReporter adds the GET /framerate/actual route to the table
+func HTTPFrameRateReporter(f FrameRateReporter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/framerate/actual"}] = func(w http.ResponseWriter, r *http.Request) {
+		rep, err := f.GetFrameRateReport()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(rep)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// FrameRateRangeError is returned when a frame rate is set outside the range
+// the camera can achieve with its present settings
+type FrameRateRangeError struct {
+	// Requested is the frame rate asked for, in Hz
+	Requested float64
+
+	// Min and Max bound the achievable frame rate, in Hz
+	Min, Max float64
+}
+
+func (e FrameRateRangeError) Error() string {
+	if e.Requested > e.Max {
+		return fmt.Sprintf("frame rate %g Hz exceeds the maximum of %g Hz with the present AOI, readout, and exposure time", e.Requested, e.Max)
+	}
+	return fmt.Sprintf("frame rate %g Hz is below the minimum of %g Hz", e.Requested, e.Min)
+}
+
+// CheckFrameRate returns a FrameRateRangeError if fps is outside [min, max]
+func CheckFrameRate(fps, min, max float64) error {
+	if fps < min || fps > max {
+		return FrameRateRangeError{Requested: fps, Min: min, Max: max}
+	}
+	return nil
+}
+
+// FrameRateLimiter is a camera whose achievable frame rate depends on its
+// other settings
+type FrameRateLimiter interface {
+	// GetFrameRateRange returns the lowest and highest frame rate, in Hz,
+	// achievable with the present settings
+	GetFrameRateRange() (float64, float64, error)
+}
+
+// HTTPFrameRateLimiter adds the GET /framerate/range route to the table, which
+// responds with {"min": 0.1, "max": 100}
+func HTTPFrameRateLimiter(f FrameRateLimiter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/framerate/range"}] = func(w http.ResponseWriter, r *http.Request) {
+		min, max, err := f.GetFrameRateRange()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		ret := struct {
+			Min float64 `json:"min"`
+			Max float64 `json:"max"`
+		}{Min: min, Max: max}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(ret)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
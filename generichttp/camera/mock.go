@@ -0,0 +1,306 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// MockCamera is a camera with no hardware behind it.  It honors the AOI,
+// binning, and exposure time and returns a diagonal ramp test pattern,
+//
+// pixel(x, y) = ((X + Y) % 4096) * binning.H * binning.V + exposure in ms
+//
+// where X, Y are the 0-based sensor coordinates of the first (unbinned) pixel
+// of the superpixel, clipped to 65535.  Binned pixels are summed as on the
+// hardware, and the exposure offset makes it easy to tell frames apart
+type MockCamera struct {
+	sync.Mutex
+
+	// SensorWidth is the width of the sensor in pixels
+	SensorWidth int
+
+	// SensorHeight is the height of the sensor in pixels
+	SensorHeight int
+
+	// Constraints limits the AOI beyond the size of the sensor, as on a real
+	// camera.  The zero value allows any AOI which fits
+	Constraints AOIConstraints
+
+	aoi      AOI
+	bin      Binning
+	exposure time.Duration
+	features map[string]interface{}
+	triggers int
+}
+
+// the frame rate range of the mock, in Hz
+const (
+	mockMinFrameRate = 0.1
+	mockMaxFrameRate = 100.
+)
+
+var (
+	// ErrAOIOutOfBounds is generated when an AOI would extend past the edge of the sensor
+	ErrAOIOutOfBounds = errors.New("generichttp/camera: AOI is larger than the sensor or extends past its edge")
+
+	// mockFeatureTypes maps the mock's feature names to their types, in the
+	// same vocabulary as andor/sdk3.Features
+	mockFeatureTypes = map[string]string{
+		"CameraModel":    "string",
+		"SerialNumber":   "string",
+		"PixelEncoding":  "enum",
+		"FrameRate":      "float",
+		"FrameCount":     "int",
+		"MetadataEnable": "bool",
+		"TriggerMode":    "enum",
+	}
+
+	// mockEnumOptions holds the options of the mock's enum features
+	mockEnumOptions = map[string][]string{
+		"PixelEncoding": {"Mono12", "Mono16"},
+		"TriggerMode":   {"Internal", "Software", "External"},
+	}
+)
+
+// NewMockCamera returns a new mock camera of the given sensor size, with a
+// full frame AOI, no binning, and 1 ms exposure time
+func NewMockCamera(width, height int) *MockCamera {
+	return &MockCamera{
+		SensorWidth:  width,
+		SensorHeight: height,
+		aoi:          AOI{Left: 1, Top: 1, Width: width, Height: height},
+		bin:          Binning{H: 1, V: 1},
+		exposure:     time.Millisecond,
+		features: map[string]interface{}{
+			"CameraModel":    "MOCK",
+			"SerialNumber":   "MOCK-0000",
+			"PixelEncoding":  "Mono16",
+			"FrameRate":      10.,
+			"FrameCount":     1,
+			"MetadataEnable": false,
+			"TriggerMode":    "Internal",
+		},
+	}
+}
+
+// GetFrame returns a frame of the test pattern
+func (m *MockCamera) GetFrame() (image.Image, error) {
+	m.Lock()
+	defer m.Unlock()
+	w, h := m.aoi.Width, m.aoi.Height
+	pix := make([]byte, w*h*2)
+	px := bytesToUint(pix)
+	offset := int(m.exposure / time.Millisecond)
+	for y := 0; y < h; y++ {
+		Y := m.aoi.Top - 1 + y*m.bin.V
+		for x := 0; x < w; x++ {
+			X := m.aoi.Left - 1 + x*m.bin.H
+			v := ((X+Y)%4096)*m.bin.H*m.bin.V + offset
+			if v > 65535 {
+				v = 65535
+			}
+			px[y*w+x] = uint16(v)
+		}
+	}
+	return &image.Gray16{Pix: pix, Stride: w * 2, Rect: image.Rect(0, 0, w, h)}, nil
+}
+
+// SetExposureTime sets the exposure time
+func (m *MockCamera) SetExposureTime(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("generichttp/camera: exposure time %v is negative", d)
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.exposure = d
+	return nil
+}
+
+// GetExposureTime returns the exposure time
+func (m *MockCamera) GetExposureTime() (time.Duration, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.exposure, nil
+}
+
+// GetExposureTimeRange returns the minimum and maximum exposure time, 10 us
+// and 30 s
+func (m *MockCamera) GetExposureTimeRange() (time.Duration, time.Duration, error) {
+	return 10 * time.Microsecond, 30 * time.Second, nil
+}
+
+// SetAOI sets the AOI.  Width and Height are in superpixels
+func (m *MockCamera) SetAOI(aoi AOI) error {
+	m.Lock()
+	defer m.Unlock()
+	if err := m.Constraints.Validate(aoi, m.SensorWidth, m.SensorHeight, m.bin); err != nil {
+		return err
+	}
+	m.aoi = aoi
+	return nil
+}
+
+// SetCenteredAOI sets the AOI nearest width x height centered on the sensor
+// which meets Constraints
+func (m *MockCamera) SetCenteredAOI(width, height int) (AOI, error) {
+	b, _ := m.GetBinning()
+	aoi := m.Constraints.Center(m.SensorWidth, m.SensorHeight, width, height, b)
+	return aoi, m.SetAOI(aoi)
+}
+
+// GetSensorWidth returns the width of the sensor
+func (m *MockCamera) GetSensorWidth() (int, error) {
+	return m.SensorWidth, nil
+}
+
+// GetSensorHeight returns the height of the sensor
+func (m *MockCamera) GetSensorHeight() (int, error) {
+	return m.SensorHeight, nil
+}
+
+// GetAOI returns the AOI
+func (m *MockCamera) GetAOI() (AOI, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.aoi, nil
+}
+
+// SetBinning sets the binning.  The AOI is shrunk to fit on the sensor
+func (m *MockCamera) SetBinning(b Binning) error {
+	if b.H < 1 || b.V < 1 {
+		return fmt.Errorf("generichttp/camera: binning %s is invalid", b.HxV())
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.bin = b
+	if maxW := (m.SensorWidth - m.aoi.Left + 1) / b.H; m.aoi.Width > maxW {
+		m.aoi.Width = maxW
+	}
+	if maxH := (m.SensorHeight - m.aoi.Top + 1) / b.V; m.aoi.Height > maxH {
+		m.aoi.Height = maxH
+	}
+	return nil
+}
+
+// GetBinning returns the binning
+func (m *MockCamera) GetBinning() (Binning, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.bin, nil
+}
+
+// Features returns the mapping of feature names to types
+func (m *MockCamera) Features() (map[string]string, error) {
+	return mockFeatureTypes, nil
+}
+
+// GetFeature returns the value of a feature
+func (m *MockCamera) GetFeature(feature string) (interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	v, ok := m.features[feature]
+	if !ok {
+		return nil, fmt.Errorf("generichttp/camera: feature %s not found", feature)
+	}
+	return v, nil
+}
+
+// GetFeatureInfo returns the type of the feature and its range or options
+func (m *MockCamera) GetFeatureInfo(feature string) (map[string]interface{}, error) {
+	t, ok := mockFeatureTypes[feature]
+	if !ok {
+		return nil, fmt.Errorf("generichttp/camera: feature %s not found", feature)
+	}
+	ret := map[string]interface{}{"type": t}
+	switch t {
+	case "int":
+		ret["min"] = 1
+		ret["max"] = 1000
+	case "float":
+		ret["min"] = mockMinFrameRate
+		ret["max"] = mockMaxFrameRate
+	case "enum":
+		ret["options"] = mockEnumOptions[feature]
+	case "string":
+		ret["maxLength"] = 64
+	}
+	return ret, nil
+}
+
+// GetFrameRateRange returns the fixed frame rate range of the mock
+func (m *MockCamera) GetFrameRateRange() (float64, float64, error) {
+	return mockMinFrameRate, mockMaxFrameRate, nil
+}
+
+// SetFeature sets the value of a feature.  Numeric values are converted to
+// the type of the feature, and FrameRate is checked against GetFrameRateRange
+func (m *MockCamera) SetFeature(feature string, v interface{}) error {
+	t, ok := mockFeatureTypes[feature]
+	if !ok {
+		return fmt.Errorf("generichttp/camera: feature %s not found", feature)
+	}
+	m.Lock()
+	defer m.Unlock()
+	switch t {
+	case "int":
+		switch vv := v.(type) {
+		case int:
+			m.features[feature] = vv
+		case float64:
+			m.features[feature] = int(vv)
+		default:
+			return fmt.Errorf("generichttp/camera: feature %s set with type %T, expected %s", feature, v, t)
+		}
+	case "float":
+		var f float64
+		switch vv := v.(type) {
+		case int:
+			f = float64(vv)
+		case float64:
+			f = vv
+		default:
+			return fmt.Errorf("generichttp/camera: feature %s set with type %T, expected %s", feature, v, t)
+		}
+		if feature == "FrameRate" {
+			if err := CheckFrameRate(f, mockMinFrameRate, mockMaxFrameRate); err != nil {
+				return err
+			}
+		}
+		m.features[feature] = f
+	case "bool":
+		vv, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("generichttp/camera: feature %s set with type %T, expected %s", feature, v, t)
+		}
+		m.features[feature] = vv
+	default:
+		vv, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("generichttp/camera: feature %s set with type %T, expected %s", feature, v, t)
+		}
+		m.features[feature] = vv
+	}
+	return nil
+}
+
+// SoftwareTrigger counts a trigger if TriggerMode is Software
+func (m *MockCamera) SoftwareTrigger() error {
+	m.Lock()
+	defer m.Unlock()
+	mode := m.features["TriggerMode"].(string)
+	if mode != "Software" {
+		return TriggerModeError{Mode: mode}
+	}
+	m.triggers++
+	return nil
+}
+
+// Identify returns the identity of the mock
+func (m *MockCamera) Identify() (generichttp.DeviceInfo, error) {
+	return generichttp.DeviceInfo{Vendor: "golaborate", Model: "MOCK", Serial: "MOCK-0000", Version: "0"}, nil
+}
@@ -0,0 +1,288 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"gopkg.in/yaml.v2"
+)
+
+// Profile is a named set of feature values, e.g. a "lownoise" or "highspeed"
+// operating mode
+type Profile map[string]interface{}
+
+// ProfileResult is the outcome of applying a profile.  Each feature is either
+// in Applied or a key in Errors
+type ProfileResult struct {
+	// Applied is the list of features which were set without error
+	Applied []string `json:"applied"`
+
+	// Errors maps features which could not be set to the reason why
+	Errors map[string]string `json:"errors"`
+}
+
+// ApplyProfile sets each feature in p on f.  Some features constrain others,
+// e.g. the PixelEncodings allowed depend on SimplePreAmpGainControl, so no
+// one order suits every profile.  Features are set in sorted order, and those
+// which fail are tried again for as long as each pass sets at least one more.
+// A feature which still cannot be set does not stop the others from being set
+func ApplyProfile(f FeatureManager, p Profile) ProfileResult {
+	pending := make([]string, 0, len(p))
+	for k := range p {
+		pending = append(pending, k)
+	}
+	sort.Strings(pending)
+	res := ProfileResult{Applied: []string{}, Errors: map[string]string{}}
+	for len(pending) > 0 {
+		var failed []string
+		res.Errors = map[string]string{}
+		for _, k := range pending {
+			err := f.SetFeature(k, p[k])
+			if err != nil {
+				failed = append(failed, k)
+				res.Errors[k] = err.Error()
+				continue
+			}
+			res.Applied = append(res.Applied, k)
+		}
+		if len(failed) == len(pending) {
+			break
+		}
+		pending = failed
+	}
+	return res
+}
+
+// LoadProfile reads a profile from a YAML file, or JSON, which is a subset
+func LoadProfile(path string) (Profile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Profile
+	err = yaml.Unmarshal(b, &p)
+	if err != nil {
+		return nil, fmt.Errorf("generichttp/camera: startup profile %s: %w", path, err)
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("generichttp/camera: startup profile %s sets no features", path)
+	}
+	return p, nil
+}
+
+// StartupReport describes the profile applied when the server started
+type StartupReport struct {
+	// Path is the file the startup profile was read from, empty if there was
+	// none
+	Path string `json:"path"`
+
+	// Profile is the profile read from Path
+	Profile Profile `json:"profile"`
+
+	// Result is the outcome of applying Profile
+	Result ProfileResult `json:"result"`
+
+	// Error describes why the startup profile could not be used
+	Error string `json:"error,omitempty"`
+
+	// Restored is the outcome of returning the features the startup profile
+	// did set to their prior values, nil if the profile was applied without
+	// error or never read
+	Restored *ProfileResult `json:"restored,omitempty"`
+
+	// Fallback is the outcome of applying the defaults in its place, nil if
+	// the startup profile was applied without error
+	Fallback *ProfileResult `json:"fallback,omitempty"`
+}
+
+// snapshot reads the present value of each of keys from f.  Features which
+// cannot be read are left out
+func snapshot(f FeatureManager, keys []string) Profile {
+	p := make(Profile, len(keys))
+	for _, k := range keys {
+		v, err := f.GetFeature(k)
+		if err == nil {
+			p[k] = v
+		}
+	}
+	return p
+}
+
+// ApplyStartupProfile reads the profile at path and applies it to f.  If the
+// file cannot be read, or any feature of it cannot be set, the features it
+// did set are returned to their prior values and defaults are applied, so the
+// camera is not left half configured
+func ApplyStartupProfile(f FeatureManager, path string, defaults Profile) StartupReport {
+	rep := StartupReport{Path: path}
+	p, err := LoadProfile(path)
+	if err == nil {
+		rep.Profile = p
+		keys := make([]string, 0, len(p))
+		for k := range p {
+			keys = append(keys, k)
+		}
+		prior := snapshot(f, keys)
+		rep.Result = ApplyProfile(f, p)
+		if len(rep.Result.Errors) == 0 {
+			return rep
+		}
+		err = fmt.Errorf("generichttp/camera: startup profile %s: %d of %d features could not be set", path, len(rep.Result.Errors), len(p))
+		undo := Profile{}
+		for _, k := range rep.Result.Applied {
+			if v, ok := prior[k]; ok {
+				undo[k] = v
+			}
+		}
+		restored := ApplyProfile(f, undo)
+		rep.Restored = &restored
+	}
+	rep.Error = err.Error()
+	fb := ApplyProfile(f, defaults)
+	rep.Fallback = &fb
+	return rep
+}
+
+// ProfileManager holds named profiles for a camera and applies them on request
+type ProfileManager struct {
+	f FeatureManager
+
+	mu       sync.Mutex
+	profiles map[string]Profile
+	startup  StartupReport
+}
+
+// NewProfileManager returns a new profile manager for f with the given
+// profiles, which may be nil
+func NewProfileManager(f FeatureManager, profiles map[string]Profile) *ProfileManager {
+	pm := &ProfileManager{f: f, profiles: make(map[string]Profile)}
+	for name, p := range profiles {
+		pm.profiles[name] = p
+	}
+	return pm
+}
+
+// Names returns the names of the known profiles, sorted
+func (pm *ProfileManager) Names() []string {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	names := make([]string, 0, len(pm.profiles))
+	for k := range pm.profiles {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply applies the named profile
+func (pm *ProfileManager) Apply(name string) (ProfileResult, error) {
+	pm.mu.Lock()
+	p, ok := pm.profiles[name]
+	pm.mu.Unlock()
+	if !ok {
+		return ProfileResult{}, fmt.Errorf("generichttp/camera: no profile named %q", name)
+	}
+	return ApplyProfile(pm.f, p), nil
+}
+
+// profileRequest is the body of a POST to /configure/profile.  Exactly one of
+// the fields should be given
+type profileRequest struct {
+	// Name is the name of a stored profile to apply
+	Name string `json:"name"`
+
+	// Features is an ad-hoc profile to apply
+	Features Profile `json:"features"`
+
+	// Save, if non-empty, stores Features under this name for later use
+	Save string `json:"save"`
+}
+
+// ApplyProfile applies a profile on a POST request.  The body is either
+// {"name": "lownoise"} to apply a stored profile, or {"features": {...}} to
+// apply an ad-hoc one, optionally with "save": "name" to store it.  The
+// response is a ProfileResult; the status is 200 even if some features
+// failed, so the caller must inspect the errors
+func (pm *ProfileManager) ApplyProfile(w http.ResponseWriter, r *http.Request) {
+	req := profileRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var res ProfileResult
+	switch {
+	case req.Name != "" && req.Features != nil:
+		http.Error(w, "only one of name and features may be given", http.StatusBadRequest)
+		return
+	case req.Name != "":
+		res, err = pm.Apply(req.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	case req.Features != nil:
+		if req.Save != "" {
+			pm.mu.Lock()
+			pm.profiles[req.Save] = req.Features
+			pm.mu.Unlock()
+		}
+		res = ApplyProfile(pm.f, req.Features)
+	default:
+		http.Error(w, "one of name or features must be given", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ListProfiles responds with the stored profiles on a GET request
+func (pm *ProfileManager) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	pm.mu.Lock()
+	cpy := make(map[string]Profile, len(pm.profiles))
+	for k, v := range pm.profiles {
+		cpy[k] = v
+	}
+	pm.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(cpy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SetStartup records the outcome of the startup profile for
+// GET /configure/startup
+func (pm *ProfileManager) SetStartup(rep StartupReport) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.startup = rep
+}
+
+// GetStartup responds with the StartupReport on a GET request.  The path is
+// empty if no startup profile was given
+func (pm *ProfileManager) GetStartup(w http.ResponseWriter, r *http.Request) {
+	pm.mu.Lock()
+	rep := pm.startup
+	pm.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(rep)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Inject adds the profile routes to the table
+func (pm *ProfileManager) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/configure/profile"}] = pm.ListProfiles
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/configure/profile"}] = pm.ApplyProfile
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/configure/startup"}] = pm.GetStartup
+}
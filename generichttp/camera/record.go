@@ -0,0 +1,115 @@
+package camera
+
+import (
+	"image"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/imgrec"
+)
+
+/* With record-on-acquire, every frame of a burst is handed to the recorder as
+it arrives from the camera, before it is spooled for /burst/frame.  The frames
+are written by a goroutine of their own from a buffer of RecordBuffer frames,
+so the camera is only held up by the disk once the buffer is full.  Frames the
+spool drops under the "oldest" policy are still recorded, and frames read from
+the spool are not recorded again.  Each frame is its own file, and the recorder
+moves to the next file after each, so nothing is left half written when the
+burst ends.
+*/
+
+// RecordBuffer is the number of frames of a burst which may wait to be
+// recorded before acquisition waits on the recorder
+var RecordBuffer = 64
+
+// recordFrames hands each frame from in to a recorder goroutine, which writes
+// it to rec as FITS with the given cards and a FRAME card counting from 1,
+// and forwards it to out.  out is closed when in is.  recorded is incremented
+// for each frame written.  A frame which cannot be written is still
+// forwarded, and the error is returned once every frame has been handled
+func recordFrames(in <-chan image.Image, out chan<- image.Image, rec *imgrec.Recorder, cards []fitsio.Card, recorded *uint64) error {
+	frames := make(chan image.Image, RecordBuffer)
+	done := make(chan error, 1)
+	go func() {
+		done <- writeFrames(frames, rec, cards, recorded)
+	}()
+	for img := range in {
+		frames <- img
+		out <- img
+	}
+	close(out)
+	close(frames)
+	return <-done
+}
+
+// writeFrames writes each frame from frames to rec, as described by
+// recordFrames.  Once a frame cannot be written the rest are discarded, and
+// the error is returned when frames is closed
+func writeFrames(frames <-chan image.Image, rec *imgrec.Recorder, cards []fitsio.Card, recorded *uint64) error {
+	var (
+		firstErr error
+		n        int
+	)
+	for img := range frames {
+		n++
+		if firstErr != nil {
+			continue
+		}
+		frameCards := append(append([]fitsio.Card{}, cards...),
+			fitsio.Card{Name: "FRAME", Value: n, Comment: "frame number in the burst"})
+		err := WriteFits(rec, frameCards, []image.Image{img})
+		rec.Incr()
+		if err != nil {
+			firstErr = err
+			continue
+		}
+		rec.Annotate(cardsToMap(frameCards))
+		atomic.AddUint64(recorded, 1)
+	}
+	return firstErr
+}
+
+// recordTarget returns the channel the camera should write a burst to, which
+// is out itself unless record-on-acquire is on and the recorder has a root, in
+// which case the frames are recorded on their way to out
+func (b *BurstWrapper) recordTarget(out chan<- image.Image) chan<- image.Image {
+	atomic.StoreUint64(&b.recorded, 0)
+	if atomic.LoadUint32(&b.recordOnAcquire) == 0 || b.Rec == nil || b.Rec.Root == "" {
+		return out
+	}
+	var cards []fitsio.Card
+	if carder, ok := b.B.(MetadataMaker); ok {
+		cards = carder.CollectHeaderMetadata()
+	}
+	in := make(chan image.Image)
+	go func() {
+		err := recordFrames(in, out, b.Rec, cards, &b.recorded)
+		b.mu.Lock()
+		b.recErr = err
+		b.mu.Unlock()
+	}()
+	return in
+}
+
+// GetRecordOnAcquire responds with whether bursts are recorded as they are
+// acquired
+func (b *BurstWrapper) GetRecordOnAcquire(w http.ResponseWriter, r *http.Request) {
+	generichttp.GetBool(func() (bool, error) {
+		return atomic.LoadUint32(&b.recordOnAcquire) == 1, nil
+	})(w, r)
+}
+
+// SetRecordOnAcquire turns recording of bursts as they are acquired on or off
+// from {"bool": true}.  It takes effect at the next burst
+func (b *BurstWrapper) SetRecordOnAcquire(w http.ResponseWriter, r *http.Request) {
+	generichttp.SetBool(func(on bool) error {
+		var v uint32
+		if on {
+			v = 1
+		}
+		atomic.StoreUint32(&b.recordOnAcquire, v)
+		return nil
+	})(w, r)
+}
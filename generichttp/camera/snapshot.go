@@ -0,0 +1,195 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// snapshotMetadata is the JSON part of a snapshot
+type snapshotMetadata struct {
+	// ExposureTime is the exposure time, in seconds, if known
+	ExposureTime *float64 `json:"exposureTime,omitempty"`
+
+	// Features maps each feature to its value
+	Features map[string]interface{} `json:"features"`
+
+	// Errors maps each feature that could not be read to the reason
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// collectSnapshotMetadata reads the exposure time and every feature of p
+func collectSnapshotMetadata(p Camera) snapshotMetadata {
+	md := snapshotMetadata{Features: map[string]interface{}{}, Errors: map[string]string{}}
+	if pt, ok := p.(PictureTaker); ok {
+		if d, err := pt.GetExposureTime(); err == nil {
+			s := d.Seconds()
+			md.ExposureTime = &s
+		} else {
+			md.Errors["exposureTime"] = err.Error()
+		}
+	}
+	if fm, ok := p.(FeatureManager); ok {
+		features, err := fm.Features()
+		if err != nil {
+			md.Errors["features"] = err.Error()
+		}
+		for name := range features {
+			v, err := fm.GetFeature(name)
+			if err != nil {
+				md.Errors[name] = err.Error()
+				continue
+			}
+			md.Features[name] = v
+		}
+	}
+	return md
+}
+
+// gray16To8 converts a 16-bit image to 8 bits for formats which do not
+// support 16-bit data well.  Other images are returned unchanged
+func gray16To8(img image.Image) image.Image {
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return img
+	}
+	uints := bytesToUint(g16.Pix)
+	b := make([]byte, len(uints))
+	for i, u := range uints {
+		b[i] = byte(u / 255)
+	}
+	bound := g16.Bounds()
+	return &image.Gray{Pix: b, Stride: bound.Dx(), Rect: bound}
+}
+
+// Snapshot returns an HTTP handler func which takes a frame and responds
+// with a multipart/mixed body of two parts: the image, and a JSON object of
+// the exposure time and every feature of the camera, read immediately after
+// the frame.  Serve it behind HTTPCamera.SnapshotGuard so that the two agree.
+// The image format is given by the fmt query parameter, one of fits (the
+// default), png, or jpg.
+//
+// procs are applied to the frame as in GetFrame, and FITS header cards may be
+// attached as described by UserCards
+func Snapshot(p Camera, procs ...FrameProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userCards, err := UserCards(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := r.URL.Query().Get("fmt")
+		if format == "" {
+			format = "fits"
+		}
+		var ctype string
+		switch format {
+		case "fits":
+			ctype = "image/fits"
+		case "png":
+			ctype = "image/png"
+		case "jpg":
+			ctype = "image/jpeg"
+		default:
+			http.Error(w, fmt.Sprintf("unknown image format %q, must be fits, png, or jpg", format), http.StatusBadRequest)
+			return
+		}
+
+		img, err := p.GetFrame()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		md := collectSnapshotMetadata(p)
+		var cards []fitsio.Card
+		for _, proc := range procs {
+			var c []fitsio.Card
+			img, c, err = proc.ProcessFrame(w, r, img)
+			if err != nil {
+				generichttp.Error(w, err)
+				return
+			}
+			cards = append(cards, c...)
+		}
+		if carder, ok := p.(MetadataMaker); ok {
+			cards = append(carder.CollectHeaderMetadata(), cards...)
+		}
+		cards, err = appendUserCards(cards, userCards)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		w.WriteHeader(http.StatusOK)
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {ctype},
+			"Content-Disposition": {"attachment; filename=image." + format}})
+		if err != nil {
+			return
+		}
+		err = writeImage(part, img, format, cards)
+		if err != nil {
+			return
+		}
+		part, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {"application/json"},
+			"Content-Disposition": {"attachment; filename=metadata.json"}})
+		if err != nil {
+			return
+		}
+		err = json.NewEncoder(part).Encode(md)
+		if err != nil {
+			return
+		}
+		mw.Close()
+	}
+}
+
+// writeImage encodes img to w in the given format
+func writeImage(w io.Writer, img image.Image, format string, cards []fitsio.Card) error {
+	switch format {
+	case "fits":
+		return WriteFits(w, cards, []image.Image{img})
+	case "png":
+		return png.Encode(w, gray16To8(img))
+	case "jpg":
+		return jpeg.Encode(w, gray16To8(img), nil)
+	default:
+		return fmt.Errorf("generichttp/camera: unknown image format %q", format)
+	}
+}
+
+// HTTPSnapshot adds the snapshot route to the table
+func HTTPSnapshot(p Camera, table generichttp.RouteTable, procs ...FrameProcessor) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/snapshot"}] = Snapshot(p, procs...)
+}
+
+// SnapshotGuard is middleware which makes each snapshot, and each request
+// which may change a setting (any but GET and HEAD), wait for the other.  The
+// metadata of a snapshot then describes the settings its frame was taken
+// with.  Requests which change settings do not wait for one another
+func (h HTTPCamera) SnapshotGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/snapshot"):
+			h.snapshots.Lock()
+			defer h.snapshots.Unlock()
+		case r.Method != http.MethodGet && r.Method != http.MethodHead:
+			h.snapshots.RLock()
+			defer h.snapshots.RUnlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -4,13 +4,16 @@ package generichttp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/types"
+	"math"
 	"net/http"
 	"sort"
 	"strings"
 
 	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/comm"
 	"github.com/nasa-jpl/golaborate/util"
 )
 
@@ -27,6 +30,14 @@ type FloatT struct {
 	F64 float64 `json:"f64"`
 }
 
+// nonFiniteFloatT is the JSON form of a float which is NaN or infinite, which
+// JSON cannot represent.  F64 is always null and NonFinite is one of "NaN",
+// "+Inf", or "-Inf"
+type nonFiniteFloatT struct {
+	F64       *float64 `json:"f64"`
+	NonFinite string   `json:"nonfinite"`
+}
+
 // UintT is a struct with a single Int field
 type UintT struct {
 	Int uint16 `json:"int"`
@@ -84,63 +95,181 @@ type HumanPayload struct {
 	T types.BasicKind
 }
 
-// EncodeAndRespond converts the humanpayload to a smaller struct with only one
-// field and writes it to w as JSON.
-func (hp *HumanPayload) EncodeAndRespond(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+// PayloadShape selects the JSON shape of a HumanPayload, one of ShapeTyped or
+// ShapeValue.  It may be set at build time with
+// -ldflags "-X github.com/nasa-jpl/golaborate/generichttp.PayloadShape=value"
+// or by the server at startup
+var PayloadShape = ShapeTyped
+
+const (
+	// ShapeTyped names the field for the type of the value, as in
+	// {"f64": 1.5}, {"int": 2}, {"str": "a"}, {"bool": true}
+	ShapeTyped = "typed"
+
+	// ShapeValue puts every type under the same key, as in {"value": 1.5}
+	ShapeValue = "value"
+)
 
+// ValueT is the ShapeValue form of a payload
+type ValueT struct {
+	Value interface{} `json:"value"`
+
+	// NonFinite is "NaN", "+Inf", or "-Inf" for floats JSON cannot represent,
+	// when Value is null
+	NonFinite string `json:"nonfinite,omitempty"`
+}
+
+// nonFinite returns the name of f if it is NaN or infinite, or ""
+func nonFinite(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return ""
+}
+
+// typed returns the ShapeTyped form of the payload, or nil if T is not
+// supported
+func (hp *HumanPayload) typed() interface{} {
 	switch hp.T {
 	case types.Bool:
-		obj := BoolT{Bool: hp.Bool}
-
-		// the logic from err to the closing brace is copy pasted a bunch in here
-		err := json.NewEncoder(w).Encode(obj)
-		if err != nil {
-			fstr := fmt.Sprintf("error encoding %+v hp to JSON, %q", hp, err)
-			http.Error(w, fstr, http.StatusInternalServerError)
-		}
+		return BoolT{Bool: hp.Bool}
 	// skip bytes case, unhandled in Unpack
 	case types.Byte:
-		obj := ByteT{Int: hp.Byte} // Byte -> int for consistency with uints
-
-		err := json.NewEncoder(w).Encode(obj)
-		if err != nil {
-			fstr := fmt.Sprintf("error encoding %+v hp to JSON, %q", hp, err)
-			http.Error(w, fstr, http.StatusInternalServerError)
-		}
+		return ByteT{Int: hp.Byte} // Byte -> int for consistency with uints
 	case types.Int:
-		obj := IntT{Int: hp.Int} // Byte -> int for consistency with uints
-
-		err := json.NewEncoder(w).Encode(obj)
-		if err != nil {
-			fstr := fmt.Sprintf("error encoding %+v hp to JSON, %q", hp, err)
-			http.Error(w, fstr, http.StatusInternalServerError)
-		}
+		return IntT{Int: hp.Int}
 	case types.Float64:
-		obj := FloatT{F64: hp.Float}
-
-		err := json.NewEncoder(w).Encode(obj)
-		if err != nil {
-			fstr := fmt.Sprintf("error encoding %+v hp to JSON, %q", hp, err)
-			http.Error(w, fstr, http.StatusInternalServerError)
+		if nf := nonFinite(hp.Float); nf != "" {
+			return nonFiniteFloatT{NonFinite: nf}
 		}
+		return FloatT{F64: hp.Float}
 	case types.String:
-		obj := StrT{Str: hp.String}
-		err := json.NewEncoder(w).Encode(obj)
-		if err != nil {
-			fstr := fmt.Sprintf("error encoding %+v hp to JSON, %q", hp, err)
-			http.Error(w, fstr, http.StatusInternalServerError)
-		}
+		return StrT{Str: hp.String}
 	case types.Uint16:
-		obj := UintT{Int: hp.Uint16}
-		err := json.NewEncoder(w).Encode(obj)
-		if err != nil {
-			fstr := fmt.Sprintf("error encoding %+v hp to JSON, %q", hp, err)
-			http.Error(w, fstr, http.StatusInternalServerError)
+		return UintT{Int: hp.Uint16}
+	}
+	return nil
+}
+
+// value returns the ShapeValue form of the payload, or nil if T is not
+// supported
+func (hp *HumanPayload) value() interface{} {
+	switch hp.T {
+	case types.Bool:
+		return ValueT{Value: hp.Bool}
+	case types.Byte:
+		return ValueT{Value: hp.Byte}
+	case types.Int:
+		return ValueT{Value: hp.Int}
+	case types.Float64:
+		if nf := nonFinite(hp.Float); nf != "" {
+			return ValueT{NonFinite: nf}
 		}
+		return ValueT{Value: hp.Float}
+	case types.String:
+		return ValueT{Value: hp.String}
+	case types.Uint16:
+		return ValueT{Value: hp.Uint16}
+	}
+	return nil
+}
+
+// EncodeAndRespond converts the humanpayload to a smaller struct with only one
+// field and writes it to w as JSON, in the shape of PayloadShape.
+//
+// Floats which are NaN or infinite, as some sensors return for a bad reading,
+// are sent as {"f64": null, "nonfinite": "NaN"} (or "+Inf", "-Inf"), since
+// JSON has no representation for them
+func (hp *HumanPayload) EncodeAndRespond(w http.ResponseWriter, r *http.Request) {
+	if PayloadShape == ShapeValue {
+		hp.respond(w, hp.value())
+		return
+	}
+	hp.respond(w, hp.typed())
+}
+
+// EncodeValueAndRespond writes the payload to w as {"value": x} regardless of
+// PayloadShape, for handlers whose clients should not branch on its type
+func (hp *HumanPayload) EncodeValueAndRespond(w http.ResponseWriter, r *http.Request) {
+	hp.respond(w, hp.value())
+}
+
+// respond writes obj to w as JSON.  A nil obj writes only the header, as for
+// types without a JSON form
+func (hp *HumanPayload) respond(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if obj == nil {
+		return
+	}
+	err := json.NewEncoder(w).Encode(obj)
+	if err != nil {
+		fstr := fmt.Sprintf("error encoding %+v hp to JSON, %q", hp, err)
+		http.Error(w, fstr, http.StatusInternalServerError)
+	}
+}
 
+// statusError is an error which carries the status code it is reported with
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e statusError) Error() string { return e.err.Error() }
+
+func (e statusError) Unwrap() error { return e.err }
+
+// BadRequest marks err as caused by invalid input, such as a channel the
+// device does not have, so that Error reports it as StatusBadRequest
+func BadRequest(err error) error {
+	return statusError{code: http.StatusBadRequest, err: err}
+}
+
+// Conflict marks err as caused by the state of the device, such as a command
+// it cannot take until something else is done first, so that Error reports it
+// as StatusConflict
+func Conflict(err error) error {
+	return statusError{code: http.StatusConflict, err: err}
+}
+
+// Error replies to the request with the error message and a status code
+// appropriate to the error.  Errors marked by BadRequest or Conflict carry
+// their own; errors caused by the device being unplugged are
+// StatusServiceUnavailable, all others StatusInternalServerError
+func Error(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	var se statusError
+	switch {
+	case errors.As(err, &se):
+		code = se.code
+	case errors.Is(err, comm.ErrPortDisconnected):
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), code)
+}
+
+// decodeValue decodes the body of r, {key: x} or {"value": x} as in either
+// PayloadShape, into v.  A body with neither leaves v as it is
+func decodeValue(r *http.Request, key string, v interface{}) error {
+	defer r.Body.Close()
+	var fields map[string]json.RawMessage
+	err := json.NewDecoder(r.Body).Decode(&fields)
+	if err != nil {
+		return err
+	}
+	raw, ok := fields[key]
+	if !ok {
+		raw, ok = fields["value"]
+	}
+	if !ok {
+		return nil
 	}
+	return json.Unmarshal(raw, v)
 }
 
 // GetFloat calls a float-getting function and returns the response
@@ -149,7 +278,7 @@ func GetFloat(fcn func() (float64, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		f, err := fcn()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		hp := HumanPayload{T: types.Float64, Float: f}
@@ -158,20 +287,19 @@ func GetFloat(fcn func() (float64, error)) http.HandlerFunc {
 	}
 }
 
-// SetFloat parses a JSON input of {'f64': value} and
+// SetFloat parses a JSON input of {'f64': value}, or {'value': value}, and
 // calls fcn with it
 func SetFloat(fcn func(float64) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		f := FloatT{}
-		err := json.NewDecoder(r.Body).Decode(&f)
-		defer r.Body.Close()
+		var f float64
+		err := decodeValue(r, "f64", &f)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = fcn(f.F64)
+		err = fcn(f)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -184,7 +312,7 @@ func GetInt(fcn func() (int, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		i, err := fcn()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		hp := HumanPayload{T: types.Int, Int: i}
@@ -193,20 +321,19 @@ func GetInt(fcn func() (int, error)) http.HandlerFunc {
 	}
 }
 
-// SetInt parses a JSON input of {'int': value} and
+// SetInt parses a JSON input of {'int': value}, or {'value': value}, and
 // calls fcn with it
 func SetInt(fcn func(int) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		f := IntT{}
-		err := json.NewDecoder(r.Body).Decode(&f)
-		defer r.Body.Close()
+		var f int
+		err := decodeValue(r, "int", &f)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = fcn(f.Int)
+		err = fcn(f)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -219,7 +346,7 @@ func GetString(fcn func() (string, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		s, err := fcn()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		hp := HumanPayload{T: types.String, String: s}
@@ -228,20 +355,19 @@ func GetString(fcn func() (string, error)) http.HandlerFunc {
 	}
 }
 
-// SetString parses a JSON input of {'int': value} and
+// SetString parses a JSON input of {'str': value}, or {'value': value}, and
 // calls fcn with it
 func SetString(fcn func(string) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		s := StrT{}
-		err := json.NewDecoder(r.Body).Decode(&s)
-		defer r.Body.Close()
+		var s string
+		err := decodeValue(r, "str", &s)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = fcn(s.Str)
+		err = fcn(s)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -254,7 +380,7 @@ func GetBool(fcn func() (bool, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		b, err := fcn()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		hp := HumanPayload{T: types.Bool, Bool: b}
@@ -263,20 +389,19 @@ func GetBool(fcn func() (bool, error)) http.HandlerFunc {
 	}
 }
 
-// SetBool parses a JSON input of {'bool': value} and
+// SetBool parses a JSON input of {'bool': value}, or {'value': value}, and
 // calls fcn with it
 func SetBool(fcn func(bool) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		b := BoolT{}
-		err := json.NewDecoder(r.Body).Decode(&b)
-		defer r.Body.Close()
+		var b bool
+		err := decodeValue(r, "bool", &b)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = fcn(b.Bool)
+		err = fcn(b)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -318,7 +443,7 @@ func (rt RouteTable) EndpointsHTTP() func(http.ResponseWriter, *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		err := json.NewEncoder(w).Encode(endpts)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			Error(w, err)
 		}
 	}
 }
@@ -0,0 +1,177 @@
+package generichttp_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/types"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+func TestHumanPayloadNonFiniteFloats(t *testing.T) {
+	cases := []struct {
+		f    float64
+		want string
+	}{
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		hp := generichttp.HumanPayload{T: types.Float64, Float: c.f}
+		hp.EncodeAndRespond(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%v: expected 200, got %d", c.f, w.Code)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%v: invalid JSON %q: %v", c.f, w.Body.String(), err)
+		}
+		if v, ok := got["f64"]; !ok || v != nil {
+			t.Errorf("%v: expected f64 to be null, got %v", c.f, got)
+		}
+		if got["nonfinite"] != c.want {
+			t.Errorf("%v: expected nonfinite %q, got %v", c.f, c.want, got["nonfinite"])
+		}
+	}
+}
+
+func TestHumanPayloadFiniteFloat(t *testing.T) {
+	w := httptest.NewRecorder()
+	hp := generichttp.HumanPayload{T: types.Float64, Float: 1.5}
+	hp.EncodeAndRespond(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	f := generichttp.FloatT{}
+	if err := json.Unmarshal(w.Body.Bytes(), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.F64 != 1.5 {
+		t.Errorf("expected 1.5, got %f", f.F64)
+	}
+	if w.Body.String() != "{\"f64\":1.5}\n" {
+		t.Errorf("expected no extra fields for a finite float, got %q", w.Body.String())
+	}
+}
+
+func TestHumanPayloadValueShape(t *testing.T) {
+	defer func() { generichttp.PayloadShape = generichttp.ShapeTyped }()
+	generichttp.PayloadShape = generichttp.ShapeValue
+	cases := []struct {
+		hp   generichttp.HumanPayload
+		want string
+	}{
+		{generichttp.HumanPayload{T: types.Float64, Float: 1.5}, "{\"value\":1.5}\n"},
+		{generichttp.HumanPayload{T: types.Int, Int: 2}, "{\"value\":2}\n"},
+		{generichttp.HumanPayload{T: types.Bool, Bool: true}, "{\"value\":true}\n"},
+		{generichttp.HumanPayload{T: types.String, String: "a"}, "{\"value\":\"a\"}\n"},
+		{generichttp.HumanPayload{T: types.Float64, Float: math.NaN()}, "{\"value\":null,\"nonfinite\":\"NaN\"}\n"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		c.hp.EncodeAndRespond(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Body.String() != c.want {
+			t.Errorf("expected %q, got %q", c.want, w.Body.String())
+		}
+	}
+}
+
+func TestOpenAPIDescribesTypedRoutes(t *testing.T) {
+	rt := generichttp.RouteTable{
+		{Method: http.MethodGet, Path: "/axis/{axis}/pos"}:    generichttp.GetFloat(func() (float64, error) { return 0, nil }),
+		{Method: http.MethodPost, Path: "/axis/{axis}/pos"}:   generichttp.SetFloat(func(float64) error { return nil }),
+		{Method: http.MethodPost, Path: "/enabled"}:           generichttp.SetBool(func(bool) error { return nil }),
+		{Method: http.MethodGet, Path: "/custom/{id:[0-9]+}"}: func(w http.ResponseWriter, r *http.Request) {},
+	}
+	schemas := generichttp.Schemas{
+		{Method: http.MethodGet, Path: "/axis/{axis}/pos"}:  generichttp.FloatGetter,
+		{Method: http.MethodPost, Path: "/axis/{axis}/pos"}: generichttp.FloatSetter,
+		{Method: http.MethodPost, Path: "/enabled"}:         generichttp.BoolSetter,
+	}
+	doc := generichttp.OpenAPI("test", "1",
+		map[string]generichttp.RouteTable{"/omc/stage/": rt},
+		map[string]generichttp.Schemas{"/omc/stage/": schemas})
+
+	get, ok := doc.Paths["/omc/stage/axis/{axis}/pos"]["get"]
+	if !ok {
+		t.Fatalf("expected GET /omc/stage/axis/{axis}/pos, got paths %v", doc.Paths)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "axis" {
+		t.Errorf("expected an axis path parameter, got %+v", get.Parameters)
+	}
+	schema := get.Responses["200"].Content["application/json"]["schema"].(map[string]interface{})
+	if schema["$ref"] != "#/components/schemas/FloatT" {
+		t.Errorf("expected the response to be a FloatT, got %v", schema)
+	}
+	set := doc.Paths["/omc/stage/enabled"]["post"]
+	if set.RequestBody == nil {
+		t.Fatal("expected a request body for a setter")
+	}
+	oneOf := set.RequestBody.Content["application/json"]["schema"].(map[string]interface{})["oneOf"].([]interface{})
+	if oneOf[0].(map[string]interface{})["$ref"] != "#/components/schemas/BoolT" {
+		t.Errorf("expected the request to be a BoolT or a value, got %+v", oneOf)
+	}
+	custom, ok := doc.Paths["/omc/stage/custom/{id}"]["get"]
+	if !ok || custom.RequestBody != nil || custom.Responses["200"].Content != nil {
+		t.Errorf("expected an untyped route with the regexp stripped, got %+v", doc.Paths)
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSettersTakeEitherShape(t *testing.T) {
+	var got float64
+	h := generichttp.SetFloat(func(f float64) error {
+		got = f
+		return nil
+	})
+	for _, body := range []string{`{"f64": 1.5}`, `{"value": 1.5}`} {
+		got = 0
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if w.Code != http.StatusOK || got != 1.5 {
+			t.Errorf("%s: expected 1.5 to be set, got %g (%d)", body, got, w.Code)
+		}
+	}
+	var on bool
+	hb := generichttp.SetBool(func(b bool) error {
+		on = b
+		return nil
+	})
+	w := httptest.NewRecorder()
+	hb(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"value": true}`)))
+	if !on {
+		t.Error(`expected {"value": true} to set true`)
+	}
+	w = httptest.NewRecorder()
+	hb(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"value": "yes"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a value of the wrong type to be 400, got %d", w.Code)
+	}
+}
+
+func TestErrorStatusCodes(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{errors.New("boom"), http.StatusInternalServerError},
+		{fmt.Errorf("dev: %w", comm.ErrPortDisconnected), http.StatusServiceUnavailable},
+		{generichttp.BadRequest(errors.New("no channel Z")), http.StatusBadRequest},
+		{fmt.Errorf("dev: %w", generichttp.Conflict(errors.New("busy"))), http.StatusConflict},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		generichttp.Error(w, c.err)
+		if w.Code != c.code {
+			t.Errorf("%v: expected %d, got %d", c.err, c.code, w.Code)
+		}
+	}
+}
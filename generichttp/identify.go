@@ -0,0 +1,89 @@
+package generichttp
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DeviceInfo describes what a piece of hardware is
+type DeviceInfo struct {
+	// Vendor is the manufacturer of the device
+	Vendor string `json:"vendor"`
+
+	// Model is the model name or number of the device
+	Model string `json:"model"`
+
+	// Serial is the serial number of the device
+	Serial string `json:"serial"`
+
+	// Version is the firmware or software version of the device
+	Version string `json:"version"`
+}
+
+// Identifier is a type which can describe itself
+type Identifier interface {
+	// Identify returns the vendor, model, serial, and version of the device
+	Identify() (DeviceInfo, error)
+}
+
+// Identify returns an HTTP handler func that responds with the device info as JSON
+func Identify(i Identifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := i.Identify()
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(info)
+		if err != nil {
+			Error(w, err)
+		}
+		return
+	}
+}
+
+// Reconnector is a device which can discard its connections and open new
+// ones, to recover from a connection which has gone stale
+type Reconnector interface {
+	// Reconnect closes the idle connections to the device.  The next
+	// operation opens a new one
+	Reconnect() error
+}
+
+// Reconnect returns an HTTP handler func that calls r.Reconnect
+func Reconnect(rc Reconnector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := rc.Reconnect()
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Pinger is a device which can be checked for life with a cheap query, for
+// devices which cannot identify themselves or for which that is expensive
+type Pinger interface {
+	// Ping returns an error if the device does not answer
+	Ping() error
+}
+
+// Shutdowner is a device which holds connections that must be released when
+// the server exits, such as a serial port
+type Shutdowner interface {
+	// Shutdown closes every connection to the device
+	Shutdown() error
+}
+
+// HTTPReconnect adds the /admin/reconnect route to the table
+func HTTPReconnect(iface Reconnector, table RouteTable) {
+	table[MethodPath{Method: http.MethodPost, Path: "/admin/reconnect"}] = Reconnect(iface)
+}
+
+// HTTPIdentify adds the /whoami route to the table
+func HTTPIdentify(iface Identifier, table RouteTable) {
+	table[MethodPath{Method: http.MethodGet, Path: "/whoami"}] = Identify(iface)
+}
@@ -27,7 +27,7 @@ func (cb CenterBandwidth) ToShortLong() (float64, float64) {
 	hb := cb.Bandwidth / 2
 	low := cb.Center - hb
 	high := cb.Center + hb
-	return high, low
+	return low, high
 }
 
 // Controller is a basic interface for laser controllers
@@ -87,6 +87,69 @@ func GetPower(c PowerController) http.HandlerFunc {
 	return generichttp.GetFloat(c.GetPower)
 }
 
+// PowerMonitor measures the output of the laser, as opposed to its setpoints
+type PowerMonitor interface {
+	// GetOpticalPower returns the optical power measured at the output
+	GetOpticalPower() (float64, error)
+
+	// GetMonitorCurrent returns the current of the monitor photodiode
+	GetMonitorCurrent() (float64, error)
+}
+
+// GetOpticalPower queries the measured output power of the laser
+func GetOpticalPower(m PowerMonitor) http.HandlerFunc {
+	return generichttp.GetFloat(m.GetOpticalPower)
+}
+
+// GetMonitorCurrent queries the monitor photodiode current of the laser
+func GetMonitorCurrent(m PowerMonitor) http.HandlerFunc {
+	return generichttp.GetFloat(m.GetMonitorCurrent)
+}
+
+// Modulation is the modulation state of a laser
+type Modulation struct {
+	// Mode names the modulation source, or is "off"
+	Mode string `json:"mode"`
+
+	// Frequency is the frequency of internal modulation in Hz
+	Frequency float64 `json:"frequency"`
+
+	// Depth is the depth of internal modulation in percent
+	Depth float64 `json:"depth"`
+}
+
+// Modulator can modulate the output of the laser
+type Modulator interface {
+	// GetModulation returns the modulation mode, frequency, and depth
+	GetModulation() (Modulation, error)
+
+	// SetModulationMode sets the modulation mode, returning an error listing
+	// the valid modes if mode is not one of them
+	SetModulationMode(string) error
+
+	// SetModulationFrequency sets the frequency of internal modulation
+	SetModulationFrequency(float64) error
+
+	// SetModulationDepth sets the depth of internal modulation
+	SetModulationDepth(float64) error
+}
+
+// GetModulation retrieves the modulation state as JSON
+func GetModulation(m Modulator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mod, err := m.GetModulation()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(mod)
+		if err != nil {
+			generichttp.Error(w, err)
+		}
+	}
+}
+
 // NDController can control the strength of an ND filter
 type NDController interface {
 	// GetND retrieves the strength of the ND
@@ -156,14 +219,14 @@ func GetCenterBandwidth(c BandwidthController) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cbw, err := c.GetCenterBandwidth()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		err = json.NewEncoder(w).Encode(cbw)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 		}
 		return
 	}
@@ -181,7 +244,38 @@ func SetCenterBandwidth(c BandwidthController) http.HandlerFunc {
 		}
 		err = c.SetCenterBandwidth(cbw)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
+			return
+		}
+	}
+}
+
+// BandSetter can set both edges of its output band at once
+type BandSetter interface {
+	// SetBand sets the output to a band of width nm about center nm
+	SetBand(center, width float64) error
+}
+
+// SetBand configures the output band from JSON {"center": nm, "width": nm}
+func SetBand(b BandSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		band := struct {
+			Center *float64 `json:"center"`
+			Width  *float64 `json:"width"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(&band)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if band.Center == nil || band.Width == nil {
+			http.Error(w, "both center and width must be given", http.StatusBadRequest)
+			return
+		}
+		err = b.SetBand(*band.Center, *band.Width)
+		if err != nil {
+			generichttp.Error(w, err)
 			return
 		}
 	}
@@ -204,6 +298,9 @@ type HTTPLaserController struct {
 
 	// RouteTable maps URLs to functions
 	RouteTable generichttp.RouteTable
+
+	// schemas describes the bodies of the typed routes of RouteTable
+	schemas generichttp.Schemas
 }
 
 // NewHTTPLaserController returns a new HTTP wrapper around an existing laser controller
@@ -221,6 +318,16 @@ func NewHTTPLaserController(ctl Controller) HTTPLaserController {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/power"}] = GetPower(powerctl)
 		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/power"}] = SetPower(powerctl)
 	}
+	if monitor, ok := ctl.(PowerMonitor); ok {
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/power/measured"}] = GetOpticalPower(monitor)
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/monitor-current"}] = GetMonitorCurrent(monitor)
+	}
+	if mod, ok := ctl.(Modulator); ok {
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/modulation"}] = GetModulation(mod)
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/modulation/mode"}] = generichttp.SetString(mod.SetModulationMode)
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/modulation/frequency"}] = generichttp.SetFloat(mod.SetModulationFrequency)
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/modulation/depth"}] = generichttp.SetFloat(mod.SetModulationDepth)
+	}
 	if ndctl, ok := ctl.(NDController); ok {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/nd"}] = GetND(ndctl)
 		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/nd"}] = SetND(ndctl)
@@ -235,13 +342,49 @@ func NewHTTPLaserController(ctl Controller) HTTPLaserController {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/wvl/center-bandwidth"}] = GetCenterBandwidth(bwctl)
 		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/wvl/center-bandwidth"}] = SetCenterBandwidth(bwctl)
 	}
+	if bs, ok := ctl.(BandSetter); ok {
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/band"}] = SetBand(bs)
+	}
 	if emh, ok := ctl.(EmissionInfoHaver); ok {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/emission-runtime"}] = GetEmissionRuntime(emh)
 	}
 	h.RouteTable = rt
+	h.schemas = generichttp.Schemas{}
+	for mp, rs := range laserSchemas {
+		if _, ok := rt[mp]; ok {
+			h.schemas[mp] = rs
+		}
+	}
 	return h
 }
 
+// laserSchemas describes the typed routes a laser controller may have
+var laserSchemas = generichttp.Schemas{
+	{Method: http.MethodGet, Path: "/emission"}:              generichttp.BoolGetter,
+	{Method: http.MethodPost, Path: "/emission"}:             generichttp.BoolSetter,
+	{Method: http.MethodGet, Path: "/current"}:               generichttp.FloatGetter,
+	{Method: http.MethodPost, Path: "/current"}:              generichttp.FloatSetter,
+	{Method: http.MethodGet, Path: "/power"}:                 generichttp.FloatGetter,
+	{Method: http.MethodPost, Path: "/power"}:                generichttp.FloatSetter,
+	{Method: http.MethodGet, Path: "/power/measured"}:        generichttp.FloatGetter,
+	{Method: http.MethodGet, Path: "/monitor-current"}:       generichttp.FloatGetter,
+	{Method: http.MethodPost, Path: "/modulation/mode"}:      generichttp.StringSetter,
+	{Method: http.MethodPost, Path: "/modulation/frequency"}: generichttp.FloatSetter,
+	{Method: http.MethodPost, Path: "/modulation/depth"}:     generichttp.FloatSetter,
+	{Method: http.MethodGet, Path: "/nd"}:                    generichttp.FloatGetter,
+	{Method: http.MethodPost, Path: "/nd"}:                   generichttp.FloatSetter,
+	{Method: http.MethodGet, Path: "/wvl/short"}:             generichttp.FloatGetter,
+	{Method: http.MethodPost, Path: "/wvl/short"}:            generichttp.FloatSetter,
+	{Method: http.MethodGet, Path: "/wvl/long"}:              generichttp.FloatGetter,
+	{Method: http.MethodPost, Path: "/wvl/long"}:             generichttp.FloatSetter,
+	{Method: http.MethodGet, Path: "/emission-runtime"}:      generichttp.FloatGetter,
+}
+
+// Schemas satisfies the generichttp.Describer interface
+func (h HTTPLaserController) Schemas() generichttp.Schemas {
+	return h.schemas
+}
+
 // RT safisfies the generichttp.HTTPer interface
 func (h HTTPLaserController) RT() generichttp.RouteTable {
 	return h.RouteTable
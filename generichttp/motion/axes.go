@@ -0,0 +1,79 @@
+package motion
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// AxisLister is a type which can enumerate its axes
+type AxisLister interface {
+	// Axes returns the names of the axes
+	Axes() ([]string, error)
+}
+
+// StaticAxes is an AxisLister for a fixed list of axes, for controllers which
+// cannot enumerate their own
+type StaticAxes []string
+
+// Axes returns the list
+func (s StaticAxes) Axes() ([]string, error) {
+	return append([]string{}, s...), nil
+}
+
+// GetAxes returns an http.HandlerFunc for a.Axes which responds with a JSON
+// array of axis names
+func GetAxes(a AxisLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axes, err := a.Axes()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		if axes == nil {
+			axes = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(axes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPAxes adds the /axes route to the table
+func HTTPAxes(iface AxisLister, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axes"}] = GetAxes(iface)
+}
+
+// AxisAliaser is a type whose axes may be addressed by more than one name
+type AxisAliaser interface {
+	// AxisAliases returns a map of every alias to the axis it names
+	AxisAliases() (map[string]string, error)
+}
+
+// GetAxisAliases returns an http.HandlerFunc for a.AxisAliases which responds
+// with a JSON object of alias: axis pairs
+func GetAxisAliases(a AxisAliaser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aliases, err := a.AxisAliases()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		if aliases == nil {
+			aliases = map[string]string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(aliases)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPAxisAliases adds the /axes/aliases route to the table
+func HTTPAxisAliases(iface AxisAliaser, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axes/aliases"}] = GetAxisAliases(iface)
+}
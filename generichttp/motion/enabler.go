@@ -35,7 +35,7 @@ func SetEnabled(e Enabler) http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&boolT)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		if boolT.Bool {
@@ -44,7 +44,7 @@ func SetEnabled(e Enabler) http.HandlerFunc {
 			err = e.Disable(axis)
 		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -58,7 +58,7 @@ func GetEnabled(e Enabler) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		enabled, err := e.GetEnabled(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Bool, Bool: enabled}
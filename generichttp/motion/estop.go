@@ -0,0 +1,39 @@
+package motion
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// EmergencyStopper is a type which can halt every axis at once
+type EmergencyStopper interface {
+	// StopAll immediately halts motion on all axes.  It must be safe to call
+	// when nothing is moving
+	StopAll() error
+}
+
+// EmergencyStop returns an http.HandlerFunc for e.StopAll.  Every call is
+// logged, with the time, client, and outcome
+func EmergencyStop(e EmergencyStopper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+		err := e.StopAll()
+		if err != nil {
+			log.Printf("EMERGENCY STOP requested by %s at %s FAILED: %v\n", r.RemoteAddr, t.Format(time.RFC3339Nano), err)
+			generichttp.Error(w, err)
+			return
+		}
+		log.Printf("EMERGENCY STOP requested by %s at %s\n", r.RemoteAddr, t.Format(time.RFC3339Nano))
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HTTPEmergencyStop adds the /emergency-stop route to the table.  The route
+// is exempt from the locker middleware, so that a stuck lock cannot prevent
+// a halt
+func HTTPEmergencyStop(iface EmergencyStopper, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/emergency-stop"}] = EmergencyStop(iface)
+}
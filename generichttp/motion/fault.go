@@ -0,0 +1,69 @@
+package motion
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// FaultManager is a type which can query and clear faults on its axes
+type FaultManager interface {
+	// GetAxisFault returns the fault mask of an axis; zero means no fault
+	GetAxisFault(string) (uint32, error)
+
+	// DecodeFault converts a fault mask to a list of named reasons
+	DecodeFault(uint32) []string
+
+	// ClearFault acknowledges and clears the fault on an axis
+	ClearFault(string) error
+}
+
+// FaultReport is the JSON representation of an axis fault
+type FaultReport struct {
+	// Code is the raw fault mask
+	Code uint32 `json:"code"`
+
+	// Reasons contains the named reason for each set bit of the mask
+	Reasons []string `json:"reasons"`
+}
+
+// GetFault returns an HTTP handler func that reports the fault state of an axis
+func GetFault(f FaultManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		code, err := f.GetAxisFault(axis)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		rep := FaultReport{Code: code, Reasons: f.DecodeFault(code)}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(rep)
+		if err != nil {
+			generichttp.Error(w, err)
+		}
+		return
+	}
+}
+
+// ClearFault returns an HTTP handler func that clears the fault on an axis
+func ClearFault(f FaultManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		err := f.ClearFault(axis)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HTTPFault adds routes for the fault manager to the route table
+func HTTPFault(iface FaultManager, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/fault"}] = GetFault(iface)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/fault/clear"}] = ClearFault(iface)
+}
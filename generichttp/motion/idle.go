@@ -0,0 +1,147 @@
+package motion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
+)
+
+// DefaultIdleTimeout is how long /wait-idle waits if the client does not give
+// ?timeout=
+const DefaultIdleTimeout = 30 * time.Second
+
+// IdlePollInterval is how often the axes are checked while waiting for them
+// to stop
+var IdlePollInterval = 50 * time.Millisecond
+
+// StillMovingError is returned when axes are still moving at the end of a wait
+type StillMovingError struct {
+	// Axes are the axes still moving
+	Axes []string
+
+	// Timeout is how long was waited
+	Timeout time.Duration
+}
+
+func (e StillMovingError) Error() string {
+	return fmt.Sprintf("motion: axes %s still moving after %v", strings.Join(e.Axes, ", "), e.Timeout)
+}
+
+// IdleWaiter is a type which can wait for all of its axes to stop
+type IdleWaiter interface {
+	// WaitIdle returns once no axis is moving, or a StillMovingError after
+	// the timeout
+	WaitIdle(time.Duration) error
+}
+
+// MovingQueryer is a type which can list the axes that are moving
+type MovingQueryer interface {
+	// MovingAxes returns the names of the axes in motion
+	MovingAxes() ([]string, error)
+}
+
+// inPositionMoving adapts a controller which can list its axes and tell if
+// each is in position to a MovingQueryer.  An axis is moving while it is not
+// in position
+type inPositionMoving struct {
+	AxisLister
+	InPositionQueryer
+}
+
+func (i inPositionMoving) MovingAxes() ([]string, error) {
+	axes, err := i.Axes()
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, axis := range axes {
+		in, err := i.GetInPosition(axis)
+		if err != nil {
+			return nil, err
+		}
+		if !in {
+			ret = append(ret, axis)
+		}
+	}
+	return ret, nil
+}
+
+// WaitIdle polls q every IdlePollInterval until no axis is moving, ctx is
+// done, or the timeout elapses, in which case a StillMovingError is returned
+func WaitIdle(ctx context.Context, q MovingQueryer, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(IdlePollInterval)
+	defer ticker.Stop()
+	for {
+		moving, err := q.MovingAxes()
+		if err != nil {
+			return err
+		}
+		if len(moving) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			sort.Strings(moving)
+			return StillMovingError{Axes: moving, Timeout: timeout}
+		case <-ticker.C:
+		}
+	}
+}
+
+// idleWaiterFor returns a function which waits for c to be idle, or nil if c
+// cannot tell if its axes are moving
+func idleWaiterFor(c interface{}) func(context.Context, time.Duration) error {
+	if w, ok := c.(IdleWaiter); ok {
+		return func(_ context.Context, d time.Duration) error { return w.WaitIdle(d) }
+	}
+	var q MovingQueryer
+	if mq, ok := c.(MovingQueryer); ok {
+		q = mq
+	} else {
+		lister, ok1 := c.(AxisLister)
+		inpos, ok2 := c.(InPositionQueryer)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		q = inPositionMoving{lister, inpos}
+	}
+	return func(ctx context.Context, d time.Duration) error { return WaitIdle(ctx, q, d) }
+}
+
+// GetWaitIdle returns an http.HandlerFunc which responds once no axis is
+// moving, or with 504 and the axes still moving after ?timeout=, e.g. 10s
+func GetWaitIdle(wait func(context.Context, time.Duration) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout, err := server.QueryDuration(r, "timeout", DefaultIdleTimeout)
+		if err != nil || timeout <= 0 {
+			http.Error(w, fmt.Sprintf("timeout %q must be a positive duration, e.g. 10s", r.URL.Query().Get("timeout")), http.StatusBadRequest)
+			return
+		}
+		err = wait(r.Context(), timeout)
+		if err != nil {
+			if _, ok := err.(StillMovingError); ok {
+				http.Error(w, err.Error(), http.StatusGatewayTimeout)
+				return
+			}
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HTTPWaitIdle adds the GET /wait-idle route to the table, if c is an
+// IdleWaiter, a MovingQueryer, or both an AxisLister and InPositionQueryer
+func HTTPWaitIdle(c interface{}, table generichttp.RouteTable) {
+	if wait := idleWaiterFor(c); wait != nil {
+		table[generichttp.MethodPath{Method: http.MethodGet, Path: "/wait-idle"}] = GetWaitIdle(wait)
+	}
+}
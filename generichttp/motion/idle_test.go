@@ -0,0 +1,31 @@
+package motion
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countdown is a MovingQueryer whose axis stops after n polls
+type countdown struct{ n int }
+
+func (c *countdown) MovingAxes() ([]string, error) {
+	if c.n == 0 {
+		return nil, nil
+	}
+	c.n--
+	return []string{"X"}, nil
+}
+
+func TestWaitIdle(t *testing.T) {
+	IdlePollInterval = time.Millisecond
+	err := WaitIdle(context.Background(), &countdown{n: 3}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WaitIdle(context.Background(), &countdown{n: 1 << 30}, 20*time.Millisecond)
+	sm, ok := err.(StillMovingError)
+	if !ok || len(sm.Axes) != 1 || sm.Axes[0] != "X" {
+		t.Errorf("expected X to still be moving, got %v", err)
+	}
+}
@@ -24,7 +24,7 @@ func Initialize(i Initializer) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		err := i.Initialize(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -20,7 +20,7 @@ func GetInPosition(i InPositionQueryer) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		enabled, err := i.GetInPosition(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Bool, Bool: enabled}
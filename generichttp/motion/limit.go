@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi"
@@ -26,11 +28,16 @@ type LimitMiddleware struct {
 
 	// Mov is a reference to the mover, used to query axis positions
 	Mov Mover
+
+	// Clamp causes moves outside the limits to be clamped to them instead of
+	// rejected.  Clamped moves have the X-Motion-Clamped response header set
+	// to the position actually commanded
+	Clamp bool
 }
 
 // Check verifies if a motion would violate the axis limit, if it exists,
-// and if it does, responds with StatusBadRequest
-// otherwise, flows control to the next handler
+// and if it does, responds with StatusBadRequest, or clamps the move if
+// l.Clamp is true.  Otherwise, flows control to the next handler
 func (l *LimitMiddleware) Check(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !strings.Contains(r.URL.String(), "pos") || r.Method != http.MethodPost {
@@ -46,7 +53,7 @@ func (l *LimitMiddleware) Check(next http.Handler) http.Handler {
 			return
 		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		// get the command
@@ -58,29 +65,60 @@ func (l *LimitMiddleware) Check(next http.Handler) http.Handler {
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyContent))
 		err = json.NewDecoder(bytes.NewReader(bodyContent)).Decode(&f)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		cmd := f.F64
+		var currPos float64
 		if relative {
 			// in the relative case, shift the command by currPos
-			currPos, err := l.Mov.GetPos(axis)
+			currPos, err = l.Mov.GetPos(axis)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				generichttp.Error(w, err)
 				return
 			}
 			cmd += currPos
 		}
-		ok = limiter.Check(cmd)
-		if !ok {
-			http.Error(w, errClamped.Error(), http.StatusBadRequest)
-			return
+		if !limiter.Contains(cmd) {
+			if !l.Clamp {
+				http.Error(w, l.rejection(axis), http.StatusBadRequest)
+				return
+			}
+			cmd = limiter.Clamp(cmd)
+			f.F64 = cmd
+			if relative {
+				f.F64 -= currPos
+			}
+			bodyContent, err = json.Marshal(f)
+			if err != nil {
+				generichttp.Error(w, err)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyContent))
+			r.ContentLength = int64(len(bodyContent))
+			w.Header().Set("X-Motion-Clamped", strconv.FormatFloat(cmd, 'g', -1, 64))
 		}
 		// at this point, all checks have passed and we can move on
 		next.ServeHTTP(w, r)
 	})
 }
 
+// rejection returns the message sent when a move is refused.  If the mover
+// can report faults and the axis is faulted, the reasons are appended so the
+// operator knows the stage is not simply ignoring them
+func (l *LimitMiddleware) rejection(axis string) string {
+	msg := errClamped.Error()
+	faulter, ok := l.Mov.(FaultManager)
+	if !ok {
+		return msg
+	}
+	code, err := faulter.GetAxisFault(axis)
+	if err != nil || code == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s; axis %s is faulted: %s", msg, axis, strings.Join(faulter.DecodeFault(code), ", "))
+}
+
 // Inject places a /axis/{axis}/limits route on the table of the HTTPer
 func (l LimitMiddleware) Inject(h generichttp.HTTPer) {
 	h.RT()[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/limits"}] = Limits(l)
@@ -100,7 +138,7 @@ func Limits(l LimitMiddleware) http.HandlerFunc {
 			err = json.NewEncoder(w).Encode(lim)
 		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 		}
 		return
 	}
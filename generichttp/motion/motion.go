@@ -34,6 +34,7 @@ func NewHTTPMotionController(c Controller) HTTPMotionController {
 		ascii.InjectRawComm(rt, rawer)
 	}
 	HTTPMove(c, rt)
+	HTTPStreamPosition(c, rt)
 	if enabler, ok := (c).(Enabler); ok {
 		HTTPEnable(enabler, rt)
 	}
@@ -46,15 +47,34 @@ func NewHTTPMotionController(c Controller) HTTPMotionController {
 	if syncer, ok := (c).(SynchronizationController); ok {
 		HTTPSync(syncer, rt)
 	}
+	if lister, ok := (c).(AxisLister); ok {
+		HTTPAxes(lister, rt)
+	}
+	if aliaser, ok := (c).(AxisAliaser); ok {
+		HTTPAxisAliases(aliaser, rt)
+	}
+	if positioner, ok := (c).(PositionsQueryer); ok {
+		HTTPPositions(positioner, rt)
+	}
 	if inposer, ok := (c).(InPositionQueryer); ok {
 		HTTPInPosition(inposer, rt)
 	}
+	HTTPWaitIdle(c, rt)
 	if homequerier, ok := (c).(HomeQuerier); ok {
 		HTTPHomeQuery(homequerier, rt)
 	}
 	if stopper, ok := (c).(Stopper); ok {
 		HTTPStop(stopper, rt)
 	}
+	if halter, ok := (c).(Halter); ok {
+		HTTPHalt(halter, rt)
+	}
+	if estopper, ok := (c).(EmergencyStopper); ok {
+		HTTPEmergencyStop(estopper, rt)
+	}
+	if faulter, ok := (c).(FaultManager); ok {
+		HTTPFault(faulter, rt)
+	}
 	w.RouteTable = rt
 	return w
 }
@@ -2,12 +2,15 @@ package motion
 
 import (
 	"encoding/json"
+	"fmt"
 	"go/types"
+	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi"
 	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
 )
 
 // Mover describes an interface with position-related methods for axes
@@ -25,6 +28,13 @@ type Mover interface {
 	Home(string) error
 }
 
+// VerifiedMover can confirm a move reached its target within a tolerance
+type VerifiedMover interface {
+	// MoveAbsVerified moves an axis to an absolute position and returns an
+	// error if the final position is more than tol from it
+	MoveAbsVerified(axis string, pos, tol float64) error
+}
+
 type HomeQuerier interface {
 	// Homed gets if an axis is homed
 	Homed(string) (bool, error)
@@ -48,7 +58,7 @@ func GetPos(m Mover) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		pos, err := m.GetPos(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Float64, Float: pos}
@@ -66,8 +76,37 @@ func popAxisRelative(r *http.Request) (string, bool, error) {
 	return axis, b, err
 }
 
+// moveVerified moves an axis and checks that it reached the target within
+// tol, using the controller's own verification if it has one
+func moveVerified(m Mover, axis string, pos float64, relative bool, tol float64) error {
+	if relative {
+		start, err := m.GetPos(axis)
+		if err != nil {
+			return err
+		}
+		pos += start
+	}
+	if vm, ok := m.(VerifiedMover); ok {
+		return vm.MoveAbsVerified(axis, pos, tol)
+	}
+	err := m.MoveAbs(axis, pos)
+	if err != nil {
+		return err
+	}
+	actual, err := m.GetPos(axis)
+	if err != nil {
+		return err
+	}
+	if math.Abs(actual-pos) > tol {
+		return fmt.Errorf("axis %s settled at %g after a move to %g, outside the tolerance of %g", axis, actual, pos, tol)
+	}
+	return nil
+}
+
 // SetPos returns an HTTP handler func from a mover that triggers an absolute or
-// relative move on an axis based on the relative query parameter
+// relative move on an axis based on the relative query parameter.  If the tol
+// query parameter is given, the final position is read back and the request
+// fails if it is further than tol from the target
 func SetPos(m Mover) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		axis, b, err := popAxisRelative(r)
@@ -75,20 +114,32 @@ func SetPos(m Mover) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		_, verify := r.URL.Query()["tol"]
+		tol, err := server.QueryFloat(r, "tol", 0)
+		if err == nil && verify && !(tol >= 0) {
+			err = fmt.Errorf("tol must be a non-negative number, got %g", tol)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		f := generichttp.FloatT{}
 		err = json.NewDecoder(r.Body).Decode(&f)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
-		if b {
+		switch {
+		case verify:
+			err = moveVerified(m, axis, f.F64, b, tol)
+		case b:
 			err = m.MoveRel(axis, f.F64)
-		} else {
+		default:
 			err = m.MoveAbs(axis, f.F64)
 		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -101,7 +152,7 @@ func Home(m Mover) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		err := m.Home(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 	}
@@ -113,7 +164,7 @@ func Homed(e HomeQuerier) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		homed, err := e.Homed(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Bool, Bool: homed}
@@ -0,0 +1,50 @@
+package motion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+// recordingMover is a Mover which records the moves it is asked to make
+type recordingMover struct {
+	Mover
+	moves int
+}
+
+func (m *recordingMover) MoveAbs(axis string, pos float64) error {
+	m.moves++
+	return nil
+}
+
+func (m *recordingMover) GetPos(axis string) (float64, error) { return 0, nil }
+
+func TestSetPosTolerance(t *testing.T) {
+	cases := []struct {
+		query string
+		code  int
+	}{
+		{"", http.StatusOK},
+		{"?tol=0.5", http.StatusOK},
+		{"?tol=0", http.StatusOK},
+		{"?tol=-1", http.StatusBadRequest},
+		{"?tol=NaN", http.StatusBadRequest},
+		{"?tol=wide", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		m := &recordingMover{}
+		r := chi.NewRouter()
+		r.Post("/axis/{axis}/pos", SetPos(m))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/axis/X/pos"+c.query, strings.NewReader(`{"f64": 0}`)))
+		if w.Code != c.code {
+			t.Errorf("%q: expected %d, got %d: %s", c.query, c.code, w.Code, w.Body)
+		}
+		if (c.code == http.StatusOK) != (m.moves == 1) {
+			t.Errorf("%q: expected a move only when the request is accepted, got %d", c.query, m.moves)
+		}
+	}
+}
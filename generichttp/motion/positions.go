@@ -0,0 +1,38 @@
+package motion
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// PositionsQueryer is a type which can read the position of every axis it
+// knows about in one call.  Controllers which cannot batch the query may loop
+// over their axes internally
+type PositionsQueryer interface {
+	// PositionsAll returns a map of axis label to position
+	PositionsAll() (map[string]float64, error)
+}
+
+// GetPositions returns an http.HandlerFunc for p.PositionsAll which responds
+// with a JSON object of axis label to position
+func GetPositions(p PositionsQueryer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pos, err := p.PositionsAll()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(pos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPPositions adds the /positions route to the table
+func HTTPPositions(iface PositionsQueryer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/positions"}] = GetPositions(iface)
+}
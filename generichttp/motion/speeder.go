@@ -32,12 +32,12 @@ func SetVelocity(s Speeder) http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&floatT)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		err = s.SetVelocity(axis, floatT.F64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -51,7 +51,7 @@ func GetVelocity(s Speeder) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		vel, err := s.GetVelocity(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Float64, Float: vel}
@@ -9,10 +9,35 @@ import (
 
 // Stopper describes an interface with stop-related methods for axes
 type Stopper interface {
-	// Stop aborts motion of the axis
+	// Stop brings the axis to a controlled, decelerated stop
 	Stop(string) error
 }
 
+// Halter is a type which can stop an axis as quickly as the controller allows,
+// without the deceleration of Stop
+type Halter interface {
+	// Halt stops the axis immediately
+	Halt(string) error
+}
+
+// HTTPHalt adds the POST /axis/{axis}/halt route to the table
+func HTTPHalt(iface Halter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/halt"}] = Halt(iface)
+}
+
+// Halt returns an HTTP handler func that halts an axis
+func Halt(h Halter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		err := h.Halt(axis)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
 // HTTPStop adds routes for the mover to the route tabler
 func HTTPStop(iface Stopper, table generichttp.RouteTable) {
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/stop"}] = Stop(iface)
@@ -24,7 +49,7 @@ func Stop(m Stopper) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		err := m.Stop(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 	}
@@ -0,0 +1,105 @@
+package motion
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
+	"github.com/nasa-jpl/golaborate/server/websocket"
+)
+
+// DefaultStreamRate is the rate, in Hz, positions are sent at while an axis
+// moves, if the client does not give ?rate=
+const DefaultStreamRate = 20
+
+// MaxStreamRate is the highest rate a client may ask for, to spare the bus
+const MaxStreamRate = 100
+
+// StreamIdle is how often the position of a stationary axis is read, to
+// notice when it starts to move
+var StreamIdle = time.Second
+
+// PositionUpdate is one message of a position stream
+type PositionUpdate struct {
+	Axis   string    `json:"axis"`
+	Pos    float64   `json:"pos"`
+	Moving bool      `json:"moving"`
+	Time   time.Time `json:"time"`
+
+	// Error is the error reading the position, if any
+	Error string `json:"error,omitempty"`
+}
+
+// StreamPosition returns an http.HandlerFunc which upgrades to a websocket
+// and pushes a PositionUpdate as JSON whenever the position of the axis
+// changes.  While the axis moves it is read ?rate= times a second; otherwise
+// it is read every StreamIdle.  The axis is considered moving if the
+// controller says it is not in position, or, for controllers which cannot
+// tell, if its position changed since the last reading.  The stream ends
+// when the client closes the socket
+func StreamPosition(m Mover) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		rate, err := server.QueryFloat(r, "rate", DefaultStreamRate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rate <= 0 || rate > MaxStreamRate {
+			http.Error(w, fmt.Sprintf("rate %g must be more than 0 and at most %d Hz", rate, MaxStreamRate), http.StatusBadRequest)
+			return
+		}
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		fast := time.Duration(float64(time.Second) / rate)
+		inpos, canTell := m.(InPositionQueryer)
+		var last PositionUpdate
+		first := true
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-conn.Done():
+				return
+			case <-timer.C:
+			}
+			up := PositionUpdate{Axis: axis, Time: time.Now()}
+			up.Pos, err = m.GetPos(axis)
+			if err != nil {
+				up.Error = err.Error()
+			} else if canTell {
+				in, err := inpos.GetInPosition(axis)
+				if err != nil {
+					up.Error = err.Error()
+				}
+				up.Moving = err == nil && !in
+			} else {
+				up.Moving = !first && up.Pos != last.Pos
+			}
+			if first || up.Pos != last.Pos || up.Moving != last.Moving || up.Error != last.Error {
+				if err := conn.WriteJSON(up); err != nil {
+					return
+				}
+			}
+			first = false
+			last = up
+			if up.Moving {
+				timer.Reset(fast)
+			} else {
+				timer.Reset(StreamIdle)
+			}
+		}
+	}
+}
+
+// HTTPStreamPosition adds the GET /axis/{axis}/ws route to the table
+func HTTPStreamPosition(iface Mover, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/ws"}] = StreamPosition(iface)
+}
@@ -27,12 +27,12 @@ func SetSynchronous(s SynchronizationController) http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&boolT)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		err = s.SetSynchronous(axis, boolT.Bool)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -46,7 +46,7 @@ func GetSynchronous(s SynchronizationController) http.HandlerFunc {
 		axis := chi.URLParam(r, "axis")
 		enabled, err := s.GetSynchronous(axis)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Bool, Bool: enabled}
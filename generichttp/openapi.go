@@ -0,0 +1,190 @@
+package generichttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification OpenAPI follows
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPIDoc is an OpenAPI document.  Only the parts OpenAPI fills in are
+// present
+type OpenAPIDoc struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+	Components map[string]map[string]interface{}      `json:"components"`
+}
+
+// OpenAPIInfo is the info object of an OpenAPI document
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIOperation describes one method of one path
+type OpenAPIOperation struct {
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIBody               `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a parameter of an operation
+type OpenAPIParameter struct {
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+// OpenAPIBody is the body of a request or response
+type OpenAPIBody struct {
+	Required bool                              `json:"required,omitempty"`
+	Content  map[string]map[string]interface{} `json:"content"`
+}
+
+// OpenAPIResponse is a response to an operation
+type OpenAPIResponse struct {
+	Description string                            `json:"description"`
+	Content     map[string]map[string]interface{} `json:"content,omitempty"`
+}
+
+// RouteSchema describes the body of a route made by one of the typed Get and
+// Set helpers.  Schema names the schema of FloatT and friends, and Kind is the
+// JSON type of the value
+type RouteSchema struct {
+	Set          bool
+	Schema, Kind string
+}
+
+// The schemas of the routes made by the typed helpers, e.g. FloatGetter for
+// GetFloat and FloatSetter for SetFloat
+var (
+	FloatGetter  = RouteSchema{Schema: "FloatT", Kind: "number"}
+	FloatSetter  = RouteSchema{Set: true, Schema: "FloatT", Kind: "number"}
+	IntGetter    = RouteSchema{Schema: "IntT", Kind: "integer"}
+	IntSetter    = RouteSchema{Set: true, Schema: "IntT", Kind: "integer"}
+	StringGetter = RouteSchema{Schema: "StrT", Kind: "string"}
+	StringSetter = RouteSchema{Set: true, Schema: "StrT", Kind: "string"}
+	BoolGetter   = RouteSchema{Schema: "BoolT", Kind: "boolean"}
+	BoolSetter   = RouteSchema{Set: true, Schema: "BoolT", Kind: "boolean"}
+)
+
+// Schemas maps the routes of a RouteTable to the bodies they send or receive.
+// Routes which are absent are described by their path alone
+type Schemas map[MethodPath]RouteSchema
+
+// Describer is an HTTPer which describes the bodies of its routes
+type Describer interface {
+	Schemas() Schemas
+}
+
+// openAPISchemas are the schemas of the typed payloads
+var openAPISchemas = map[string]interface{}{
+	"FloatT": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"f64":       map[string]interface{}{"type": "number", "nullable": true},
+			"nonfinite": map[string]interface{}{"type": "string", "enum": []string{"NaN", "+Inf", "-Inf"}},
+		},
+		"required": []string{"f64"}},
+	"IntT":  typedSchema("int", "integer"),
+	"StrT":  typedSchema("str", "string"),
+	"BoolT": typedSchema("bool", "boolean"),
+}
+
+// typedSchema returns the schema of an object with the single field key
+func typedSchema(key, kind string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{key: map[string]interface{}{"type": kind}},
+		"required":   []string{key}}
+}
+
+// jsonContent returns the content of a JSON body of the given schema
+func jsonContent(schema map[string]interface{}) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{"application/json": {"schema": schema}}
+}
+
+// pathParam matches chi URL parameters, with an optional regexp
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIOperation describes the route at path, whose body is described by
+// rs if ok.  Otherwise it is described by its path parameters alone
+func openAPIOperation(tag, path string, rs RouteSchema, ok bool) OpenAPIOperation {
+	op := OpenAPIOperation{Responses: map[string]OpenAPIResponse{"200": {Description: "OK"}}}
+	if tag != "" {
+		op.Tags = []string{tag}
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name: m[1], In: "path", Required: true,
+			Schema: map[string]interface{}{"type": "string"}})
+	}
+	if !ok {
+		return op
+	}
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + rs.Schema}
+	if rs.Set {
+		// setters take either shape
+		body := map[string]interface{}{"oneOf": []interface{}{ref, typedSchema("value", rs.Kind)}}
+		op.RequestBody = &OpenAPIBody{Required: true, Content: jsonContent(body)}
+		return op
+	}
+	resp := ref
+	if PayloadShape == ShapeValue {
+		resp = typedSchema("value", rs.Kind)
+	}
+	op.Responses["200"] = OpenAPIResponse{Description: "OK", Content: jsonContent(resp)}
+	return op
+}
+
+// OpenAPI returns an OpenAPI description of the route tables, which map the
+// prefix each table is mounted at to the table.  The routes of each table are
+// tagged with its prefix.  Request and response bodies are described for
+// the routes in schemas, which is keyed by prefix like tables; other routes
+// have only their path and parameters
+func OpenAPI(title, version string, tables map[string]RouteTable, schemas map[string]Schemas) OpenAPIDoc {
+	doc := OpenAPIDoc{
+		OpenAPI:    OpenAPIVersion,
+		Info:       OpenAPIInfo{Title: title, Version: version},
+		Paths:      map[string]map[string]OpenAPIOperation{},
+		Components: map[string]map[string]interface{}{"schemas": openAPISchemas},
+	}
+	prefixes := make([]string, 0, len(tables))
+	for prefix := range tables {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		tag := strings.Trim(prefix, "/")
+		for mp := range tables[prefix] {
+			path := strings.TrimSuffix(prefix, "/") + mp.Path
+			// OpenAPI has no place for the regexps of chi parameters
+			path = pathParam.ReplaceAllString(path, "{$1}")
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = map[string]OpenAPIOperation{}
+			}
+			rs, ok := schemas[prefix][mp]
+			doc.Paths[path][strings.ToLower(mp.Method)] = openAPIOperation(tag, mp.Path, rs, ok)
+		}
+	}
+	return doc
+}
+
+// OpenAPIHTTP returns a handler which responds with OpenAPI(title, version,
+// tables, schemas)
+func OpenAPIHTTP(title, version string, tables map[string]RouteTable, schemas map[string]Schemas) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(OpenAPI(title, version, tables, schemas))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -26,7 +26,7 @@ func GetTemperatureSetpoint(c Controller) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setpt, err := c.GetTemperatureSetpoint()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Float64, Float: setpt}
@@ -41,13 +41,13 @@ func SetTemperatureSetpoint(c Controller) http.HandlerFunc {
 		f := generichttp.FloatT{}
 		err := json.NewDecoder(r.Body).Decode(&f)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		defer r.Body.Close()
 		err = c.SetTemperatureSetpoint(f.F64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -60,7 +60,7 @@ func GetTemperature(c Controller) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		t, err := c.GetTemperature()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			generichttp.Error(w, err)
 			return
 		}
 		hp := generichttp.HumanPayload{T: types.Float64, Float: t}
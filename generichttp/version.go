@@ -0,0 +1,77 @@
+package generichttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+)
+
+// Version, Commit, and BuildDate describe the build of golab a server was
+// made from.  They are injected at build time, e.g.
+//
+//	go build -ldflags "-X github.com/nasa-jpl/golaborate/generichttp.Version=v1.4.0
+//	  -X github.com/nasa-jpl/golaborate/generichttp.Commit=$(git rev-parse --short HEAD)
+//	  -X github.com/nasa-jpl/golaborate/generichttp.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Version is empty if it is not injected, until a server gives its own with
+// DefaultVersion
+var (
+	Version   = ""
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// DefaultVersion sets Version to v, the version number of a server, if it was
+// not injected at build time
+func DefaultVersion(v string) {
+	if Version == "" {
+		Version = v
+	}
+}
+
+// BuildInfo describes the build of a server
+type BuildInfo struct {
+	// Version is the version of golab
+	Version string `json:"version"`
+
+	// Commit is the git commit the server was built from
+	Commit string `json:"commit"`
+
+	// BuildDate is when the server was built
+	BuildDate string `json:"buildDate"`
+
+	// GoVersion is the version of Go the server was built with
+	GoVersion string `json:"goVersion"`
+}
+
+// GetBuildInfo returns the build of this server
+func GetBuildInfo() BuildInfo {
+	v := Version
+	if v == "" {
+		v = "dev"
+	}
+	return BuildInfo{Version: v, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+}
+
+// String returns the build info as one line for the version commands, e.g.
+// "8, commit 1a2b3c4, built 2021-03-04T05:06:07Z, go1.16".  The commit and
+// date are left out if they were not injected
+func (b BuildInfo) String() string {
+	s := b.Version
+	if b.Commit != "unknown" {
+		s += ", commit " + b.Commit
+	}
+	if b.BuildDate != "unknown" {
+		s += ", built " + b.BuildDate
+	}
+	return s + ", " + b.GoVersion
+}
+
+// VersionHTTP responds with the BuildInfo as JSON
+func VersionHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(GetBuildInfo())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
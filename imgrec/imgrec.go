@@ -2,22 +2,36 @@
 package imgrec
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"go/types"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nasa-jpl/golaborate/generichttp"
 )
 
-// Recorder records image sequences with incrementing filenames in yyyy-mm-dd subfolders.  It is not thread safe.
+// Recorder records image sequences with incrementing filenames in yyyy-mm-dd subfolders.  Files should be
+// written from one goroutine at a time, while Target and Recordings may be called from any.
+//
+// If MaxFiles or MaxBytes is set, the recorder rolls over into a new
+// subfolder of the day's folder, named for the time (hhmmss), when the
+// present folder reaches either limit
 type Recorder struct {
+	// mu guards counter, timeFldr, and rollFldr, which Target reads while
+	// files are written
+	mu sync.Mutex
+
 	// counter is the internally incrementing counter
 	counter int
 
@@ -30,28 +44,166 @@ type Recorder struct {
 	// timeFldr is the subfolder with yyy-mm-dd format.
 	timeFldr string
 
+	// rollFldr is the subfolder of timeFldr after a rollover, or "" before
+	// the first rollover of the day
+	rollFldr string
+
+	// MaxFiles is the number of files in a folder which triggers a rollover.
+	// Zero is no limit
+	MaxFiles int
+
+	// MaxBytes is the total size of the files in a folder which triggers a
+	// rollover.  Zero is no limit
+	MaxBytes int64
+
 	// Enabled is a flag unused by this struct that allows consumers to disable its use in their code
 	Enabled bool
+
+	// recMu guards recordings and oldest, which may be read while the
+	// recorder is in use
+	recMu sync.Mutex
+
+	// recordings is a ring of the last MaxRecordings files written
+	recordings []Recording
+
+	// oldest is the index of the oldest recording once the ring is full
+	oldest int
 }
 
-// updateFolder checks the current time and updates the folder and timestamp as needed
-func (r *Recorder) updateFolder() {
+// MaxRecordings is the number of files a Recorder lists in Recordings.  Once
+// it is reached, each new file pushes the oldest out of the list
+var MaxRecordings = 10000
+
+// Recording describes a file written by a Recorder
+type Recording struct {
+	// Path is the path of the file, relative to the root it was written under
+	Path string `json:"path"`
+
+	// Root is the root the file was written under
+	Root string `json:"root"`
+
+	// Size is the number of bytes written to the file
+	Size int64 `json:"size"`
+
+	// Time is when the file was first written to
+	Time time.Time `json:"time"`
+
+	// Metadata is any information attached to the file with Annotate
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// track adds n bytes written to the file at fn to the list of recordings
+func (r *Recorder) track(fn string, n int) {
+	r.recMu.Lock()
+	defer r.recMu.Unlock()
+	rel, err := filepath.Rel(r.Root, fn)
+	if err != nil {
+		rel = fn
+	}
+	if last := r.last(); last != nil && last.Root == r.Root && last.Path == rel {
+		last.Size += int64(n)
+		return
+	}
+	rec := Recording{Path: rel, Root: r.Root, Size: int64(n), Time: time.Now()}
+	if len(r.recordings) < MaxRecordings {
+		r.recordings = append(r.recordings, rec)
+		return
+	}
+	r.recordings[r.oldest] = rec
+	r.oldest = (r.oldest + 1) % len(r.recordings)
+}
+
+// last returns the most recent recording, or nil if there are none.  recMu
+// must be held
+func (r *Recorder) last() *Recording {
+	l := len(r.recordings)
+	if l == 0 {
+		return nil
+	}
+	return &r.recordings[(r.oldest+l-1)%l]
+}
+
+// Annotate attaches metadata to the file most recently written.  It does
+// nothing if no file has been written
+func (r *Recorder) Annotate(md map[string]string) {
+	r.recMu.Lock()
+	defer r.recMu.Unlock()
+	last := r.last()
+	if last == nil {
+		return
+	}
+	if last.Metadata == nil {
+		last.Metadata = make(map[string]string, len(md))
+	}
+	for k, v := range md {
+		last.Metadata[k] = v
+	}
+}
+
+// Recordings returns a copy of the list of files written, oldest first.  At
+// most the last MaxRecordings are listed
+func (r *Recorder) Recordings() []Recording {
+	r.recMu.Lock()
+	defer r.recMu.Unlock()
+	ret := make([]Recording, 0, len(r.recordings))
+	ret = append(ret, r.recordings[r.oldest:]...)
+	return append(ret, r.recordings[:r.oldest]...)
+}
+
+// today returns the name of the folder for the present day, yyyy-mm-dd
+func today() string {
 	now := time.Now()
 	y, m, d := now.Year(), now.Month(), now.Day()
+	return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+}
+
+// updateFolder checks the current time and updates the folder and timestamp as needed
+func (r *Recorder) updateFolder() {
 	// otherwise, timeFldr needs to be reset
-	r.timeFldr = fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+	fldr := today()
+	if fldr != r.timeFldr {
+		// a new day starts a new folder anyway
+		r.rollFldr = ""
+	}
+	r.timeFldr = fldr
 	return
 }
 
+// dir returns the folder files are presently written to
+func (r *Recorder) dir() string {
+	return path.Join(r.Root, r.timeFldr, r.rollFldr)
+}
+
 // mkDir makes the folder and returns it
 func (r *Recorder) mkDir() (string, error) {
-	fldr := path.Join(r.Root, r.timeFldr)
+	fldr := r.dir()
 	err := os.MkdirAll(fldr, 0777)
 	return fldr, err
 }
 
+// full returns true if a folder of files and bytes has reached either limit
+func (r *Recorder) full(files int, bytes int64) bool {
+	return (r.MaxFiles > 0 && files >= r.MaxFiles) || (r.MaxBytes > 0 && bytes >= r.MaxBytes)
+}
+
+// rollover moves the recorder to a new folder named for the present time
+func (r *Recorder) rollover(files int, bytes int64) {
+	old := r.dir()
+	r.updateFolder()
+	now := time.Now().Format("150405")
+	if now == r.rollFldr {
+		// more than one rollover per second, disambiguate
+		now = time.Now().Format("150405.000")
+	}
+	r.rollFldr = now
+	r.counter = 0
+	log.Printf("imgrec: rolled over from %s after %d files (%d bytes) to %s\n", old, files, bytes, r.dir())
+}
+
 // Write implements io.Writer and writes the contents of a fits file to disk
 func (r *Recorder) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	// make sure the folder exists
 	r.updateFolder()
 	fldr, err := r.mkDir()
@@ -74,22 +226,30 @@ func (r *Recorder) Write(p []byte) (n int, err error) {
 	if err != nil {
 		return 0, err
 	}
-	return fid.Write(p)
+	n, err = fid.Write(p)
+	r.track(fn, n)
+	return n, err
 }
 
 // Incr updates the filename counter; it scans the folder to do so.  If there is an error, the counter is not incremented
 func (r *Recorder) Incr() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	dn, _ := r.mkDir()
 	files, err := ioutil.ReadDir(dn)
 	if err != nil {
 		return
 	}
 	count := 0
+	nfiles := 0
+	var nbytes int64
 	for _, file := range files {
 		// skip directories, non-fits, and wrong prefix
 		if file.IsDir() {
 			continue
 		}
+		nfiles++
+		nbytes += file.Size()
 		fn := file.Name()
 		if !strings.HasSuffix(fn, ".fits") || !strings.HasPrefix(fn, r.Prefix) {
 			continue
@@ -105,9 +265,45 @@ func (r *Recorder) Incr() {
 			count = n
 		}
 	}
+	if r.full(nfiles, nbytes) {
+		r.rollover(nfiles, nbytes)
+		return
+	}
 	r.counter = count + 1
 }
 
+// Target describes where a recorder will write next
+type Target struct {
+	// Dir is the folder files are written to
+	Dir string `json:"dir"`
+
+	// Next is the name of the next file
+	Next string `json:"next"`
+
+	// MaxFiles is the file count which triggers a rollover, zero if none
+	MaxFiles int `json:"maxFiles"`
+
+	// MaxBytes is the folder size which triggers a rollover, zero if none
+	MaxBytes int64 `json:"maxBytes"`
+}
+
+// Target returns where the recorder will write next.  It does not change the
+// recorder, so it may be called while files are written
+func (r *Recorder) Target() Target {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dir := r.dir()
+	if fldr := today(); fldr != r.timeFldr {
+		// the next write starts a new day
+		dir = path.Join(r.Root, fldr)
+	}
+	return Target{
+		Dir:      dir,
+		Next:     fmt.Sprintf("%s%06d.fits", r.Prefix, r.counter),
+		MaxFiles: r.MaxFiles,
+		MaxBytes: r.MaxBytes}
+}
+
 // HTTPWrapper is an HTTP wrapper around an image recorder that allows the folder and prefix to be changed on the fly
 //
 // it does not implement generichttp.HTTPer, offering an Inject method allowing it to be injected
@@ -131,9 +327,12 @@ func (h HTTPWrapper) SetRoot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	rec := h.Recorder
+	rec.mu.Lock()
 	rec.Root = str.Str
+	rec.rollFldr = ""
 	rec.updateFolder()
 	_, err = rec.mkDir()
+	rec.mu.Unlock()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -156,8 +355,10 @@ func (h HTTPWrapper) SetPrefix(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.Recorder.mu.Lock()
 	h.Recorder.Prefix = str.Str
 	h.Recorder.counter = 0
+	h.Recorder.mu.Unlock()
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -187,7 +388,59 @@ func (h HTTPWrapper) SetEnabled(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-// Inject adds GET and POST routes for /autorwrite/root and /autowrite/prefix to the HTTPer which manipulate this wrapper's recorder
+// GetTarget responds with the folder and file the recorder will write next,
+// and its rollover limits
+func (h HTTPWrapper) GetTarget(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(h.Recorder.Target())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetRecordings responds with the list of files recorded, as JSON by default
+// or as CSV with ?format=csv.  The CSV has one column for each metadata key
+// used by any recording, after path, root, size, and time
+func (h HTTPWrapper) GetRecordings(w http.ResponseWriter, r *http.Request) {
+	recs := h.Recorder.Recordings()
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(recs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "csv":
+		keys := []string{}
+		seen := map[string]bool{}
+		for _, rec := range recs {
+			for k := range rec.Metadata {
+				if !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=recordings.csv")
+		cw := csv.NewWriter(w)
+		cw.Write(append([]string{"path", "root", "size", "time"}, keys...))
+		for _, rec := range recs {
+			row := []string{rec.Path, rec.Root, strconv.FormatInt(rec.Size, 10), rec.Time.Format(time.RFC3339Nano)}
+			for _, k := range keys {
+				row = append(row, rec.Metadata[k])
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// Inject adds GET and POST routes for /autorwrite/root and /autowrite/prefix to the HTTPer which manipulate this wrapper's recorder,
+// GET /autowrite/target for where it will write next, and GET /recordings to list what it has written
 func (h HTTPWrapper) Inject(rt generichttp.RouteTable) {
 	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/autowrite/root"}] = h.SetRoot
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/root"}] = h.GetRoot
@@ -195,4 +448,6 @@ func (h HTTPWrapper) Inject(rt generichttp.RouteTable) {
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/prefix"}] = h.GetPrefix
 	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/autowrite/enabled"}] = h.SetEnabled
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/enabled"}] = h.GetEnabled
+	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/recordings"}] = h.GetRecordings
+	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/target"}] = h.GetTarget
 }
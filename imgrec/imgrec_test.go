@@ -0,0 +1,136 @@
+package imgrec
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingsListsWrittenFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "imgrec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	rec := &Recorder{Root: root, Prefix: "img"}
+	for i := 0; i < 2; i++ {
+		if _, err := rec.Write([]byte("abc")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rec.Write([]byte("de")); err != nil {
+			t.Fatal(err)
+		}
+		rec.Annotate(map[string]string{"EXPTIME": "0.1"})
+		rec.Incr()
+	}
+	recs := rec.Recordings()
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 recordings, got %d", len(recs))
+	}
+	for _, r := range recs {
+		if r.Size != 5 || r.Metadata["EXPTIME"] != "0.1" {
+			t.Errorf("expected 5 bytes with metadata, got %+v", r)
+		}
+	}
+	if recs[0].Path == recs[1].Path {
+		t.Error("expected distinct files")
+	}
+
+	w := httptest.NewRecorder()
+	NewHTTPWrapper(rec).GetRecordings(w, httptest.NewRequest(http.MethodGet, "/recordings?format=csv", nil))
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][4] != "EXPTIME" || rows[1][0] != recs[0].Path {
+		t.Errorf("unexpected CSV %v", rows)
+	}
+}
+
+func TestRecorderRollsOverAtMaxFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "imgrec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	rec := &Recorder{Root: root, Prefix: "img", MaxFiles: 2}
+	first := rec.Target().Dir
+	for i := 0; i < 2; i++ {
+		if _, err := rec.Write([]byte("abc")); err != nil {
+			t.Fatal(err)
+		}
+		rec.Incr()
+	}
+	tgt := rec.Target()
+	if tgt.Dir == first {
+		t.Fatalf("expected a new folder after %d files, still %s", rec.MaxFiles, tgt.Dir)
+	}
+	if filepath.Dir(tgt.Dir) != first {
+		t.Errorf("expected the new folder to be inside %s, got %s", first, tgt.Dir)
+	}
+	if tgt.Next != "img000000.fits" {
+		t.Errorf("expected numbering to restart, got %s", tgt.Next)
+	}
+}
+
+func TestTargetWhileRecording(t *testing.T) {
+	root, err := ioutil.TempDir("", "imgrec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	rec := &Recorder{Root: root, Prefix: "img"}
+	before := rec.Target()
+	if rec.timeFldr != "" {
+		t.Error("expected Target not to change the recorder")
+	}
+	if before.Dir != filepath.Join(root, today()) || before.Next != "img000000.fits" {
+		t.Errorf("unexpected target %+v", before)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			rec.Write([]byte("abc"))
+			rec.Incr()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		rec.Target()
+	}
+	<-done
+	if next := rec.Target().Next; next != "img000020.fits" {
+		t.Errorf("expected the next file to be img000020.fits, got %s", next)
+	}
+}
+
+func TestRecordingsKeepsTheLastMaxRecordings(t *testing.T) {
+	root, err := ioutil.TempDir("", "imgrec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer func(n int) { MaxRecordings = n }(MaxRecordings)
+	MaxRecordings = 3
+	rec := &Recorder{Root: root, Prefix: "img"}
+	for i := 0; i < 5; i++ {
+		if _, err := rec.Write([]byte("abc")); err != nil {
+			t.Fatal(err)
+		}
+		rec.Annotate(map[string]string{"N": string(rune('0' + i))})
+		rec.Incr()
+	}
+	recs := rec.Recordings()
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 recordings, got %d", len(recs))
+	}
+	for i, r := range recs {
+		if want := string(rune('2' + i)); r.Metadata["N"] != want {
+			t.Errorf("recording %d: expected N=%s, oldest first, got %+v", i, want, r)
+		}
+	}
+}
@@ -19,8 +19,13 @@ type DAQ struct {
 
 // NewDAQ creates a new scope instance
 func NewDAQ(addr string) *DAQ {
+	return NewDAQPooled(addr, 1)
+}
+
+// NewDAQPooled is NewDAQ with a pool of up to poolSize connections
+func NewDAQPooled(addr string, poolSize int) *DAQ {
 	maker := comm.BackingOffTCPConnMaker(addr, 1*time.Second)
-	pool := comm.NewPool(1, time.Hour, maker)
+	pool := comm.NewPool(poolSize, time.Hour, maker)
 	return &DAQ{scpi.SCPI{Pool: pool, Handshaking: true}}
 }
 
@@ -22,8 +22,13 @@ type Scope struct {
 
 // NewScope creates a new scope instance
 func NewScope(addr string) *Scope {
+	return NewScopePooled(addr, 1)
+}
+
+// NewScopePooled is NewScope with a pool of up to poolSize connections
+func NewScopePooled(addr string, poolSize int) *Scope {
 	maker := comm.BackingOffTCPConnMaker(addr, 1*time.Second)
-	pool := comm.NewPool(1, time.Hour, maker)
+	pool := comm.NewPool(poolSize, time.Hour, maker)
 	return &Scope{scpi.SCPI{Pool: pool, Handshaking: true}}
 }
 
@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/nasa-jpl/golaborate/util"
 
 	"github.com/tarm/serial"
 )
@@ -55,6 +58,12 @@ var (
 		{Cmd: "VA", Alias: "set-velocity-linear", Description: "set velocity for linear motors", UsesAxis: true},
 		{Cmd: "VB", Alias: "set-velocity-stepper", Description: "set velocity for stepper motors", UsesAxis: true},
 		{Cmd: "VU", Alias: "set-max-speed", Description: "set maximum speed", UsesAxis: true},
+		{Cmd: "SL", Alias: "set-left-limit", Description: "set left (negative) software travel limit", UsesAxis: true},
+		{Cmd: "SR", Alias: "set-right-limit", Description: "set right (positive) software travel limit", UsesAxis: true},
+		{Cmd: "SN", Alias: "set-units", Description: "set axis displacement units", UsesAxis: true},
+		{Cmd: "SU", Alias: "set-resolution", Description: "set encoder resolution", UsesAxis: true},
+		{Cmd: "OM", Alias: "home-mode", Description: "set home search mode", UsesAxis: true},
+		{Cmd: "MD", Alias: "motion-done", Description: "get motion done status", UsesAxis: true, IsReadOnly: true},
 		{Cmd: "MO", Alias: "enable-axis", Description: "Turn the motor on for an axis", UsesAxis: true},
 		{Cmd: "MF", Alias: "disable-axis", Description: "turn the motor off for an axis", UsesAxis: true},
 
@@ -145,6 +154,67 @@ var (
 	}
 )
 
+// ESPUnits maps the integer returned by the SN command to the name of the unit
+var ESPUnits = map[int]string{
+	0:  "encoder count",
+	1:  "motor step",
+	2:  "millimeter",
+	3:  "micrometer",
+	4:  "inches",
+	5:  "milli-inches",
+	6:  "micro-inches",
+	7:  "degree",
+	8:  "gradian",
+	9:  "radian",
+	10: "milliradian",
+	11: "microradian",
+}
+
+// ESPHomeModes maps the name of a home search mode to its number for the OM
+// and OR commands
+var ESPHomeModes = map[string]int{
+	"zero":                 0, // find the +0 position count
+	"home+index":           1, // find the home and index signals
+	"home":                 2, // find the home signal
+	"positive-limit":       3, // find the positive limit signal
+	"negative-limit":       4, // find the negative limit signal
+	"positive-limit+index": 5, // find the positive limit and index signals
+	"negative-limit+index": 6, // find the negative limit and index signals
+}
+
+// defaultHomeMode is the mode Home uses for axes without SetHomeMode
+const defaultHomeMode = 1
+
+// homeModeName returns the name of a home search mode number
+func homeModeName(mode int) string {
+	for k, v := range ESPHomeModes {
+		if v == mode {
+			return k
+		}
+	}
+	return "unknown"
+}
+
+// StageConfig holds the stage parameters stored on the controller for an axis
+type StageConfig struct {
+	// Min is the left (negative) software travel limit
+	Min float64 `json:"min"`
+
+	// Max is the right (positive) software travel limit
+	Max float64 `json:"max"`
+
+	// Units is the displacement unit of the axis, e.g. millimeter
+	Units string `json:"units"`
+
+	// Resolution is the encoder resolution, in Units per count
+	Resolution float64 `json:"resolution"`
+}
+
+// Limiter converts the travel limits to a util.Limiter
+func (s StageConfig) Limiter() util.Limiter {
+	return util.Limiter{Min: s.Min, Max: s.Max}
+}
+
 // Command describes a command
 type Command struct {
 	Cmd         string `json:"cmd"`
@@ -235,6 +305,19 @@ func makeSerConf(addr string) *serial.Config {
 // ESP301 represents an ESP301 motion controller.
 type ESP301 struct {
 	pool *comm.Pool
+
+	mu        sync.Mutex
+	homeModes map[string]int
+
+	// pending are errors HomeAndWait read for other axes, which are returned
+	// by the next call to ReadErrors
+	pending []string
+
+	// HomeTimeout is how long HomeAndWait waits for a home search to finish
+	HomeTimeout time.Duration
+
+	// debug receives the raw traffic, see SetDebug
+	debug io.Writer
 }
 
 // NewESP301 makes a new ESP301 motion controller instance
@@ -246,7 +329,32 @@ func NewESP301(addr string, connectSerial bool) *ESP301 {
 		maker = comm.BackingOffTCPConnMaker(addr, 1*time.Second)
 	}
 	p := comm.NewPool(1, time.Minute, maker)
-	return &ESP301{pool: p}
+	return &ESP301{pool: p, homeModes: map[string]int{}, HomeTimeout: 2 * time.Minute}
+}
+
+// Reconnect drops the idle connection to the ESP, serial or TCP, so that a
+// controller which was power cycled is reached again
+func (esp *ESP301) Reconnect() error {
+	esp.pool.Drain()
+	return nil
+}
+
+// Shutdown closes the connection to the ESP
+func (esp *ESP301) Shutdown() error {
+	esp.pool.Close()
+	return nil
+}
+
+// Ping asks the ESP for its firmware version with VE?
+func (esp *ESP301) Ping() error {
+	_, err := esp.RawCommand("VE?")
+	return err
+}
+
+// SetDebug logs every message sent to and received from the controller to w,
+// with its terminator.  nil turns logging off
+func (esp *ESP301) SetDebug(w io.Writer) {
+	esp.debug = w
 }
 
 // RawCommand sends a command directly to the motion controller (with EOT appended) and returns the response as-is
@@ -258,6 +366,7 @@ func (esp *ESP301) RawCommand(cmd string) (string, error) {
 	}
 	defer func() { esp.pool.ReturnWithError(conn, err) }()
 	wrapper := comm.NewTerminator(conn, RxTerm, TxTerm)
+	wrapper.Debug = esp.debug
 
 	// acquire an almost imperceptible amount of parallel performance here
 	// the message will be in flight or processed by the ESP while we
@@ -361,15 +470,154 @@ func (esp *ESP301) MoveRel(axis string, pos float64) error {
 	return err
 }
 
-// Home homes an axis.
-// mode 6, negative limit switch + home mark
+// Home homes an axis with the mode from SetHomeMode, or by the home and
+// index signals if it has not been set
 func (esp *ESP301) Home(axis string) error {
+	esp.mu.Lock()
+	mode, ok := esp.homeModes[axis]
+	esp.mu.Unlock()
+	if !ok {
+		mode = defaultHomeMode
+	}
 	cmd, _ := commandFromAlias("origin-search")
-	tele := makeTelegram(cmd, axis, true, 1)
+	tele := makeTelegram(cmd, axis, true, float64(mode))
 	_, err := esp.RawCommand(tele)
 	return err
 }
 
+// SetHomeMode sets the home search mode of an axis, one of the keys of
+// ESPHomeModes.  It is used by Home and HomeAndWait
+func (esp *ESP301) SetHomeMode(axis, mode string) error {
+	n, ok := ESPHomeModes[mode]
+	if !ok {
+		names := make([]string, 0, len(ESPHomeModes))
+		for k := range ESPHomeModes {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("newport/esp301: home mode %q is not one of %s", mode, strings.Join(names, ", "))
+	}
+	c, _ := commandFromAlias("home-mode")
+	_, err := esp.RawCommand(makeTelegram(c, axis, true, float64(n)))
+	if err != nil {
+		return err
+	}
+	esp.mu.Lock()
+	esp.homeModes[axis] = n
+	esp.mu.Unlock()
+	return nil
+}
+
+// GetHomeMode returns the name of the home search mode of an axis
+func (esp *ESP301) GetHomeMode(axis string) (string, error) {
+	esp.mu.Lock()
+	mode, ok := esp.homeModes[axis]
+	esp.mu.Unlock()
+	if !ok {
+		mode = defaultHomeMode
+	}
+	return homeModeName(mode), nil
+}
+
+// HomeAndWait homes an axis and blocks until the search is done, or
+// HomeTimeout elapses.  Errors the controller raised for the axis during the
+// search, such as running into a limit, are returned
+func (esp *ESP301) HomeAndWait(axis string) error {
+	if err := esp.Home(axis); err != nil {
+		return err
+	}
+	c, _ := commandFromAlias("motion-done")
+	tele := makeTelegram(c, axis, false, 0)
+	deadline := time.Now().Add(esp.HomeTimeout)
+	for {
+		resp, err := esp.RawCommand(tele)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(resp) == "1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			c, _ := commandFromAlias("stop")
+			esp.RawCommand(makeTelegram(c, axis, true, 0))
+			return fmt.Errorf("newport/esp301: home search of axis %s did not finish in %v and was stopped", axis, esp.HomeTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	errs, err := esp.ReadErrors()
+	if err != nil {
+		esp.keepErrors(errs)
+		return err
+	}
+	prefix := "AXIS " + axis + " "
+	var mine, others []string
+	for _, e := range errs {
+		if strings.HasPrefix(e, prefix) {
+			mine = append(mine, strings.TrimPrefix(e, prefix))
+		} else {
+			others = append(others, e)
+		}
+	}
+	esp.keepErrors(others)
+	if len(mine) > 0 {
+		return fmt.Errorf("newport/esp301: home search of axis %s failed: %s", axis, strings.Join(mine, ", "))
+	}
+	return nil
+}
+
+// keepErrors holds errs, which were read off the queue of the controller, for
+// the next call to ReadErrors
+func (esp *ESP301) keepErrors(errs []string) {
+	if len(errs) == 0 {
+		return
+	}
+	esp.mu.Lock()
+	esp.pending = append(esp.pending, errs...)
+	esp.mu.Unlock()
+}
+
+// GetStageConfig reads the travel limits, units, and resolution of an axis
+// from the controller
+func (esp *ESP301) GetStageConfig(axis int) (StageConfig, error) {
+	var ret StageConfig
+	ax := strconv.Itoa(axis)
+	readF := func(alias string) (float64, error) {
+		c, _ := commandFromAlias(alias)
+		resp, err := esp.RawCommand(makeTelegram(c, ax, false, 0))
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(resp, 64)
+	}
+	var err error
+	ret.Min, err = readF("set-left-limit")
+	if err != nil {
+		return ret, err
+	}
+	ret.Max, err = readF("set-right-limit")
+	if err != nil {
+		return ret, err
+	}
+	ret.Resolution, err = readF("set-resolution")
+	if err != nil {
+		return ret, err
+	}
+	unit, err := readF("set-units")
+	if err != nil {
+		return ret, err
+	}
+	ret.Units = unitName(unit)
+	return ret, nil
+}
+
+// unitName returns the name of a unit code read with the SN command
+func unitName(code float64) string {
+	if str, ok := ESPUnits[int(code)]; ok && float64(int(code)) == code {
+		return str
+	}
+	return "unknown"
+}
+
 // Wait waits for motion to cease and then returns nil
 func (esp *ESP301) Wait(axis string) error {
 	cmd, _ := commandFromAlias("wait")
@@ -405,9 +653,12 @@ func (esp *ESP301) SetFollowingErrorConfiguration(axis string, enableChecking, d
 // ReadErrors reads all error from the controller and returns a slice of the
 // error messages, which may be empty if there are no errors.  The slice may be
 // partially filled if a communication error is encountered while reading the
-// sequence of errors.
+// sequence of errors.  Errors HomeAndWait read for other axes come first.
 func (esp *ESP301) ReadErrors() ([]string, error) {
-	var errs []string
+	esp.mu.Lock()
+	errs := esp.pending
+	esp.pending = nil
+	esp.mu.Unlock()
 	cmd := "TB?"
 	for {
 		resp, err := esp.RawCommand(cmd)
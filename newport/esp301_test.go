@@ -0,0 +1,84 @@
+package newport
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/comm"
+)
+
+func TestUnitName(t *testing.T) {
+	cases := []struct {
+		code float64
+		name string
+	}{
+		{0, "encoder count"},
+		{2, "millimeter"},
+		{7, "degree"},
+		{8, "gradian"},
+		{9, "radian"},
+		{11, "microradian"},
+		{12, "unknown"},
+		{-1, "unknown"},
+		{2.5, "unknown"},
+	}
+	for _, c := range cases {
+		if got := unitName(c.code); got != c.name {
+			t.Errorf("unit %g: expected %q, got %q", c.code, c.name, got)
+		}
+	}
+}
+
+// fakeESP answers MD? with done, and TB? with the codes in errs, one at a time
+type fakeESP struct {
+	errs []string
+	out  bytes.Buffer
+}
+
+func (f *fakeESP) Write(b []byte) (int, error) {
+	cmd := strings.TrimSuffix(string(b), string(TxTerm))
+	switch {
+	case strings.HasSuffix(cmd, "MD?"):
+		f.out.WriteString("1")
+	case cmd == "TB?":
+		code := "0"
+		if len(f.errs) > 0 {
+			code, f.errs = f.errs[0], f.errs[1:]
+		}
+		f.out.WriteString(code + ", 0, ERROR")
+	default:
+		return len(b), nil
+	}
+	f.out.WriteByte(RxTerm)
+	return len(b), nil
+}
+
+func (f *fakeESP) Read(b []byte) (int, error) { return f.out.Read(b) }
+
+func (f *fakeESP) Close() error { return nil }
+
+func TestHomeAndWaitKeepsErrorsOfOtherAxes(t *testing.T) {
+	f := &fakeESP{errs: []string{"207", "104"}}
+	esp := &ESP301{
+		pool:        comm.NewPool(1, time.Minute, func() (io.ReadWriteCloser, error) { return f, nil }),
+		homeModes:   map[string]int{},
+		HomeTimeout: time.Second,
+	}
+	err := esp.HomeAndWait("1")
+	if err == nil || !strings.Contains(err.Error(), "POSITIVE HARDWARE LIMIT REACHED") {
+		t.Errorf("expected the home search to fail on the positive limit, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "NEGATIVE") {
+		t.Errorf("expected only the errors of axis 1, got %v", err)
+	}
+	errs, err := esp.ReadErrors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0] != "AXIS 2 NEGATIVE SOFTWARE LIMIT REACHED" {
+		t.Errorf("expected the error of axis 2 to be kept, got %q", errs)
+	}
+}
@@ -0,0 +1,338 @@
+package newport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+/* Gathering samples data types such as Group1.Pos.CurrentPosition at a divisor
+of the servo rate into a buffer on the XPS.  GatheringConfigurationSet selects
+the types, GatheringRun starts sampling, and GatheringDataMultipleLinesGet
+reads lines of the buffer back, one sample per line with a column per type:
+
+0.1;0.001
+0.2;0.001
+
+The replies span many TCP packets, so they are read until EndOfAPI instead of
+with a single read as other commands are.
+*/
+
+const (
+	// GatheringChunkLines is the number of lines read from the gathering
+	// buffer per command
+	GatheringChunkLines = 100
+
+	// maxLongReply bounds the size of a reply read by queryLong
+	maxLongReply = 1 << 22
+)
+
+// LongReadTimeout is the time queryLong waits for the rest of a reply
+var LongReadTimeout = 5 * time.Second
+
+// queryLong is openReadWriteClose for replies which may not fit in one packet.
+// It reads until EndOfAPI is seen
+func (xps *XPS) queryLong(cmd string) (xpsResponse, error) {
+	var resp xpsResponse
+	conn, err := xps.pool.Get()
+	if err != nil {
+		return resp, err
+	}
+	defer func() { xps.pool.ReturnWithError(conn, err) }()
+	if nc, ok := conn.(net.Conn); ok {
+		nc.SetDeadline(time.Now().Add(LongReadTimeout))
+		defer nc.SetDeadline(time.Time{})
+	}
+	msg := []byte(cmd)
+	n, err := conn.Write(msg)
+	if err != nil {
+		return resp, err
+	} else if n != len(msg) {
+		err = fmt.Errorf("newport/xps: the XPS did not accept the entire message")
+		return resp, err
+	}
+	var (
+		buf   bytes.Buffer
+		chunk = make([]byte, 1500)
+		end   = []byte("EndOfAPI")
+	)
+	for {
+		n, err = conn.Read(chunk)
+		buf.Write(chunk[:n])
+		if bytes.Contains(buf.Bytes(), end) {
+			err = nil
+			break
+		}
+		if err != nil {
+			return resp, fmt.Errorf("newport/xps: reply to %s ended after %d bytes without EndOfAPI: %w", cmd, buf.Len(), err)
+		}
+		if buf.Len() > maxLongReply {
+			err = fmt.Errorf("newport/xps: reply to %s exceeds %d bytes", cmd, maxLongReply)
+			return resp, err
+		}
+	}
+	return parse(buf.String()), nil
+}
+
+// parseGatheringLines parses lines of ;-separated values into one slice per
+// column.  Every line must have ncol values
+func parseGatheringLines(s string, ncol int) ([][]float64, error) {
+	cols := make([][]float64, ncol)
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) != ncol {
+			return nil, fmt.Errorf("newport/xps: gathering line %d has %d values, expected %d: %q", i, len(fields), ncol, line)
+		}
+		for j, f := range fields {
+			v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+			if err != nil {
+				return nil, fmt.Errorf("newport/xps: gathering line %d: %w", i, err)
+			}
+			cols[j] = append(cols[j], v)
+		}
+	}
+	return cols, nil
+}
+
+// Gathering is the data gathered by the XPS
+type Gathering struct {
+	// Types are the gathered data types, e.g. Group1.Pos.CurrentPosition
+	Types []string `json:"types"`
+
+	// Data holds one slice of samples per type, in the order of Types
+	Data [][]float64 `json:"data"`
+}
+
+// GatheringStatus is the progress of gathering
+type GatheringStatus struct {
+	// Current is the number of samples gathered
+	Current int `json:"current"`
+
+	// Max is the number of samples the buffer can hold with the configured types
+	Max int `json:"max"`
+}
+
+// ConfigureGathering sets the data types to gather, e.g.
+// Group1.Pos.CurrentPosition and Group1.Pos.CurrentVelocity
+func (xps *XPS) ConfigureGathering(types []string) error {
+	if len(types) == 0 {
+		return generichttp.BadRequest(fmt.Errorf("newport/xps: at least one gathering type is required"))
+	}
+	resp, err := xps.openReadWriteClose(fmt.Sprintf("GatheringConfigurationSet(%s)", strings.Join(types, ",")))
+	if err != nil {
+		return err
+	}
+	return XPSErr(resp.errCode)
+}
+
+// GetGatheringConfig returns the data types being gathered
+func (xps *XPS) GetGatheringConfig() ([]string, error) {
+	resp, err := xps.openReadWriteClose("GatheringConfigurationGet(char *)")
+	if err != nil {
+		return nil, err
+	}
+	if resp.errCode != 0 {
+		return nil, XPSErr(resp.errCode)
+	}
+	ret := []string{}
+	for _, t := range strings.Split(resp.content, ";") {
+		if t = strings.TrimSpace(t); t != "" {
+			ret = append(ret, t)
+		}
+	}
+	return ret, nil
+}
+
+// StartGathering gathers points samples, one every divisor servo cycles
+func (xps *XPS) StartGathering(points, divisor int) error {
+	if points < 1 || divisor < 1 {
+		return generichttp.BadRequest(fmt.Errorf("newport/xps: gathering points (%d) and divisor (%d) must be at least 1", points, divisor))
+	}
+	resp, err := xps.openReadWriteClose(fmt.Sprintf("GatheringRun(%d,%d)", points, divisor))
+	if err != nil {
+		return err
+	}
+	return XPSErr(resp.errCode)
+}
+
+// StopGathering stops gathering, keeping the samples already taken
+func (xps *XPS) StopGathering() error {
+	resp, err := xps.openReadWriteClose("GatheringStop()")
+	if err != nil {
+		return err
+	}
+	return XPSErr(resp.errCode)
+}
+
+// GetGatheringStatus returns the number of samples gathered
+func (xps *XPS) GetGatheringStatus() (GatheringStatus, error) {
+	var st GatheringStatus
+	resp, err := xps.openReadWriteClose("GatheringCurrentNumberGet(int *,int *)")
+	if err != nil {
+		return st, err
+	}
+	if resp.errCode != 0 {
+		return st, XPSErr(resp.errCode)
+	}
+	_, err = fmt.Sscanf(resp.content, "%d,%d", &st.Current, &st.Max)
+	if err != nil {
+		return st, fmt.Errorf("newport/xps: could not parse gathering status %q: %w", resp.content, err)
+	}
+	return st, nil
+}
+
+// ReadGathering reads every sample gathered so far
+func (xps *XPS) ReadGathering() (Gathering, error) {
+	var g Gathering
+	types, err := xps.GetGatheringConfig()
+	if err != nil {
+		return g, err
+	}
+	st, err := xps.GetGatheringStatus()
+	if err != nil {
+		return g, err
+	}
+	g.Types = types
+	g.Data = make([][]float64, len(types))
+	for i := range g.Data {
+		g.Data[i] = make([]float64, 0, st.Current)
+	}
+	for start := 0; start < st.Current; start += GatheringChunkLines {
+		n := st.Current - start
+		if n > GatheringChunkLines {
+			n = GatheringChunkLines
+		}
+		resp, err := xps.queryLong(fmt.Sprintf("GatheringDataMultipleLinesGet(%d,%d,char *)", start, n))
+		if err != nil {
+			return g, err
+		}
+		if resp.errCode != 0 {
+			return g, XPSErr(resp.errCode)
+		}
+		cols, err := parseGatheringLines(resp.content, len(types))
+		if err != nil {
+			return g, err
+		}
+		for i := range cols {
+			g.Data[i] = append(g.Data[i], cols[i]...)
+		}
+	}
+	return g, nil
+}
+
+// Gatherer is a type which can gather data during motion
+type Gatherer interface {
+	// ConfigureGathering sets the data types to gather
+	ConfigureGathering([]string) error
+
+	// GetGatheringConfig returns the data types being gathered
+	GetGatheringConfig() ([]string, error)
+
+	// StartGathering gathers a number of samples at a divisor of the servo rate
+	StartGathering(int, int) error
+
+	// StopGathering stops gathering
+	StopGathering() error
+
+	// GetGatheringStatus returns the number of samples gathered
+	GetGatheringStatus() (GatheringStatus, error)
+
+	// ReadGathering reads the gathered samples
+	ReadGathering() (Gathering, error)
+}
+
+// respondJSON encodes v as the JSON response
+func respondJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// HTTPGathering adds the gathering routes to the table:
+//
+// GET and POST /gathering/config, {"types": [...]}
+// POST /gathering/start, {"points": N, "divisor": N}
+// POST /gathering/stop
+// GET /gathering/status, a GatheringStatus
+// GET /gathering/data, a Gathering
+func HTTPGathering(g Gatherer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/gathering/config"}] = func(w http.ResponseWriter, r *http.Request) {
+		types, err := g.GetGatheringConfig()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		respondJSON(w, struct {
+			Types []string `json:"types"`
+		}{types})
+	}
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/gathering/config"}] = func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Types []string `json:"types"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = g.ConfigureGathering(req.Types); err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/gathering/start"}] = func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			Points  int `json:"points"`
+			Divisor int `json:"divisor"`
+		}{Divisor: 1}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = g.StartGathering(req.Points, req.Divisor); err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/gathering/stop"}] = func(w http.ResponseWriter, r *http.Request) {
+		if err := g.StopGathering(); err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/gathering/status"}] = func(w http.ResponseWriter, r *http.Request) {
+		st, err := g.GetGatheringStatus()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		respondJSON(w, st)
+	}
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/gathering/data"}] = func(w http.ResponseWriter, r *http.Request) {
+		data, err := g.ReadGathering()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		respondJSON(w, data)
+	}
+}
@@ -0,0 +1,133 @@
+package newport
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// GroupStatusQueryer is a type which can report the state of an XPS group
+type GroupStatusQueryer interface {
+	// GetGroupStatus returns the numeric status and its description
+	GetGroupStatus(string) (int, string, error)
+}
+
+// GroupStatus is the JSON representation of an XPS group status
+type GroupStatus struct {
+	// Code is the numeric status of the group
+	Code int `json:"code"`
+
+	// Text is the description of the status from the XPS manual
+	Text string `json:"text"`
+
+	// State is the coarse state of the group, see XPSStatus.State
+	State string `json:"state"`
+}
+
+// GetGroupStatus returns an HTTP handler func that reports the status of a group
+func GetGroupStatus(g GroupStatusQueryer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		code, text, err := g.GetGroupStatus(group)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		st := GroupStatus{Code: code, Text: text, State: intToXPSStatus(code).State()}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(st)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+}
+
+// HTTPGroupStatus adds the group status route to the table
+func HTTPGroupStatus(iface GroupStatusQueryer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/group/{group}/status"}] = GetGroupStatus(iface)
+}
+
+// StageConfigReader is a type which can read the stage configuration of an axis
+type StageConfigReader interface {
+	// GetStageConfig returns the controller-stored configuration of an axis
+	GetStageConfig(int) (StageConfig, error)
+}
+
+// GetStageConfig returns an HTTP handler func that reports the stage configuration of an axis
+func GetStageConfig(s StageConfigReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis, err := strconv.Atoi(chi.URLParam(r, "axis"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfg, err := s.GetStageConfig(axis)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+}
+
+// HTTPStageConfig adds the stage configuration route to the table
+func HTTPStageConfig(iface StageConfigReader, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/config"}] = GetStageConfig(iface)
+}
+
+// HomeModer is a type whose axes can home by several modes
+type HomeModer interface {
+	// SetHomeMode sets the home search mode of an axis
+	SetHomeMode(string, string) error
+
+	// GetHomeMode returns the home search mode of an axis
+	GetHomeMode(string) (string, error)
+
+	// HomeAndWait homes an axis and waits for the search to finish
+	HomeAndWait(string) error
+}
+
+// HTTPHomeMode adds the GET and POST /axis/{axis}/home/mode, POST
+// /axis/{axis}/home/wait, and GET /home-modes routes to the table
+func HTTPHomeMode(h HomeModer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/home/mode"}] = func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		generichttp.GetString(func() (string, error) { return h.GetHomeMode(axis) })(w, r)
+	}
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/home/mode"}] = func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		generichttp.SetString(func(mode string) error { return h.SetHomeMode(axis, mode) })(w, r)
+	}
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/home/wait"}] = func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		if err := h.HomeAndWait(axis); err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/home-modes"}] = func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(ESPHomeModes))
+		for k := range ESPHomeModes {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(names)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
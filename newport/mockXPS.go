@@ -4,6 +4,7 @@ import (
 	"errors"
 	"math"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 )
@@ -88,6 +89,26 @@ func (c *MockController) Homed(axis string) (bool, error) {
 	return c.homed[axis], nil
 }
 
+// GetGroupStatus returns a status code consistent with the mock's state.
+// Groups which have never been homed are reported as not referenced
+func (c *MockController) GetGroupStatus(group string) (int, string, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.semAcq()
+	defer c.semRelease()
+	code := 12 // ready from motion
+	switch {
+	case c.moving[group]:
+		code = 44
+	case !c.homed[group]:
+		code = 42
+	case !c.enabled[group]:
+		code = 20
+	}
+	status := intToXPSStatus(code)
+	return status.Code, status.Text, nil
+}
+
 // func (c *MockController) GetInPosition(axis string) (bool, error) {
 // 	c.Lock()
 // 	defer c.Unlock()
@@ -275,6 +296,45 @@ func (c *MockController) Stop(axis string) error {
 	c.Unlock()
 	return nil
 }
+
+// Halt stops the group, as Stop
+func (c *MockController) Halt(axis string) error {
+	return c.Stop(axis)
+}
+
+// StopAll stops every moving group
+func (c *MockController) StopAll() error {
+	c.Lock()
+	defer c.Unlock()
+	for axis, moving := range c.moving {
+		if moving {
+			c.stop[axis] = true
+		}
+	}
+	return nil
+}
+
+// Axes returns every group which has been used
+func (c *MockController) Axes() ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	seen := make(map[string]bool)
+	for _, m := range []map[string]bool{c.enabled, c.homed} {
+		for k := range m {
+			seen[k] = true
+		}
+	}
+	for k := range c.pos {
+		seen[k] = true
+	}
+	ret := make([]string, 0, len(seen))
+	for k := range seen {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
 func (c *MockController) Raw(s string) (string, error) {
 	return "", NotImplemented
 }
@@ -102,6 +102,28 @@ func (s XPSStatus) IsHomed() bool {
 	return false
 }
 
+// State collapses the status code into one of the coarse states of the
+// XPS group state machine, NOTINIT, NOTREF, READY, DISABLED, HOMING, MOVING,
+// or OTHER for the auxiliary states (tuning, calibration, braking, ...)
+func (s XPSStatus) State() string {
+	c := s.Code
+	switch {
+	case c < 10, c == 50, c == 63, c == 66, c == 67, c == 71, c == 72:
+		return "NOTINIT"
+	case c == 42:
+		return "NOTREF"
+	case s.IsReady():
+		return "READY"
+	case (c >= 20 && c < 40) || (c >= 74 && c <= 76):
+		return "DISABLED"
+	case c == 43 || c == 64:
+		return "HOMING"
+	case c == 44 || c == 45 || c == 46 || c == 47 || c == 48 || c == 51:
+		return "MOVING"
+	}
+	return "OTHER"
+}
+
 var (
 	// XPSErrorCodes maps XPS error integers to strings
 	XPSErrorCodes = map[int]string{
@@ -388,6 +410,16 @@ func (xps *XPS) GetStatus(axis string) (XPSStatus, error) {
 	return intToXPSStatus(i), nil
 }
 
+// GetGroupStatus returns the numeric status of a group and its description
+// from the XPS status table
+func (xps *XPS) GetGroupStatus(group string) (int, string, error) {
+	status, err := xps.GetStatus(group)
+	if err != nil {
+		return 0, "", err
+	}
+	return status.Code, status.Text, nil
+}
+
 // GetEnabled gets if the axis is enabled
 func (xps *XPS) GetEnabled(axis string) (bool, error) {
 	// todo: look at GroupMotionStatusGet
@@ -511,7 +543,12 @@ func (xps *XPS) SetVelocity(axis string, vel float64) error {
 
 }
 
-// Stop aborts the motion of the axis
+// HaltAccelerationMultiplier is the factor by which Halt raises the
+// deceleration of the move profile
+var HaltAccelerationMultiplier = 5.0
+
+// Stop aborts the motion of the axis, which decelerates at the acceleration of
+// its SGamma profile
 func (xps *XPS) Stop(axis string) error {
     cmd := fmt.Sprintf("GroupMoveAbort(%s)", axis)
 	resp, err := xps.openReadWriteClose(cmd)
@@ -521,6 +558,99 @@ func (xps *XPS) Stop(axis string) error {
 	return XPSErr(resp.errCode)
 }
 
+// Halt aborts the motion of the axis with HaltAccelerationMultiplier times the
+// deceleration of Stop.  Unlike KillAll the group stays referenced
+func (xps *XPS) Halt(axis string) error {
+	cmd := fmt.Sprintf("GroupMoveAbortFast(%s,%g)", axis, HaltAccelerationMultiplier)
+	resp, err := xps.openReadWriteClose(cmd)
+	if err != nil {
+		return err
+	}
+	return XPSErr(resp.errCode)
+}
+
+// StopAll kills every group on the controller.  Killed groups must be
+// initialized and homed again before they can move
+func (xps *XPS) StopAll() error {
+	resp, err := xps.openReadWriteClose("KillAll()")
+	if err != nil {
+		return err
+	}
+	return XPSErr(resp.errCode)
+}
+
+// Reconnect closes the idle sockets of the XPS pool.  Connections in use are
+// left to finish
+func (xps *XPS) Reconnect() error {
+	xps.pool.Drain()
+	return nil
+}
+
+// Shutdown closes every socket of the XPS pool
+func (xps *XPS) Shutdown() error {
+	xps.pool.Close()
+	return nil
+}
+
+// Axes returns the groups configured on the controller.  Per the best practice
+// above, each group is treated as an axis
+func (xps *XPS) Axes() ([]string, error) {
+	resp, err := xps.openReadWriteClose("ObjectsListGet(char *)")
+	if err != nil {
+		return nil, err
+	}
+	if resp.errCode != 0 {
+		return nil, XPSErr(resp.errCode)
+	}
+	return groupsFromObjects(resp.content), nil
+}
+
+// MovingAxes returns the groups which are moving or homing
+func (xps *XPS) MovingAxes() ([]string, error) {
+	groups, err := xps.Axes()
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, g := range groups {
+		status, err := xps.GetStatus(g)
+		if err != nil {
+			return nil, err
+		}
+		if st := status.State(); st == "MOVING" || st == "HOMING" {
+			ret = append(ret, g)
+		}
+	}
+	return ret, nil
+}
+
+// groupsFromObjects extracts the group names from the reply to
+// ObjectsListGet, a semicolon separated list of groups and their positioners
+// as Group.Positioner
+func groupsFromObjects(objects string) []string {
+	ret := []string{}
+	for _, obj := range strings.Split(objects, ";") {
+		obj = strings.TrimSpace(obj)
+		if obj == "" || strings.Contains(obj, ".") {
+			continue
+		}
+		ret = append(ret, obj)
+	}
+	return ret
+}
+
+// Ping asks the XPS for its firmware version
+func (xps *XPS) Ping() error {
+	resp, err := xps.openReadWriteClose("FirmwareVersionGet(char *)")
+	if err != nil {
+		return err
+	}
+	if resp.errCode != 0 {
+		return XPSErr(resp.errCode)
+	}
+	return nil
+}
+
 // Raw implements ascii.Rawer
 func (xps *XPS) Raw(s string) (string, error) {
 	resp, err := xps.openReadWriteClose(s)
@@ -0,0 +1,85 @@
+package newport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+func TestGroupStatusTableDecoding(t *testing.T) {
+	cases := []struct {
+		code  int
+		text  string
+		state string
+	}{
+		{0, "Not initialized state", "NOTINIT"},
+		{11, "Ready state from homing", "READY"},
+		{20, "Disable state", "DISABLED"},
+		{42, "Not referenced state", "NOTREF"},
+		{43, "Homing state", "HOMING"},
+		{44, "moving state", "MOVING"},
+		{77, "Ready state from excitation signal generation", "READY"},
+	}
+	for _, c := range cases {
+		s := intToXPSStatus(c.code)
+		if s.Text != c.text {
+			t.Errorf("status %d: expected text %q, got %q", c.code, c.text, s.Text)
+		}
+		if s.State() != c.state {
+			t.Errorf("status %d: expected state %s, got %s", c.code, c.state, s.State())
+		}
+	}
+}
+
+func TestMockGroupStatusNotReferencedUntilHomed(t *testing.T) {
+	m := NewControllerMock("")
+	code, _, err := m.GetGroupStatus("Group1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 42 {
+		t.Errorf("expected fresh group to be NOTREF (42), got %d", code)
+	}
+	m.homed["Group1"] = true
+	m.enabled["Group1"] = true
+	code, _, _ = m.GetGroupStatus("Group1")
+	if !intToXPSStatus(code).IsReady() {
+		t.Errorf("expected homed and enabled group to be ready, got %d", code)
+	}
+}
+
+func TestGroupsFromObjects(t *testing.T) {
+	got := groupsFromObjects("Group1;Group1.Pos;Group2;Group2.Pos;")
+	if len(got) != 2 || got[0] != "Group1" || got[1] != "Group2" {
+		t.Errorf("expected [Group1 Group2], got %v", got)
+	}
+}
+
+func TestParseGatheringLines(t *testing.T) {
+	cols, err := parseGatheringLines("0.1;1e-3\n0.2;2e-3\r\n0.3;3e-3\n", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 2 || len(cols[0]) != 3 || cols[0][2] != 0.3 || cols[1][1] != 2e-3 {
+		t.Errorf("unexpected columns %v", cols)
+	}
+	if _, err = parseGatheringLines("0.1;1e-3\n0.2\n", 2); err == nil {
+		t.Error("expected an error for a short line")
+	}
+}
+
+func TestInvalidGatheringIsBadRequest(t *testing.T) {
+	xps := &XPS{}
+	for _, err := range []error{xps.ConfigureGathering(nil), xps.StartGathering(0, 1), xps.StartGathering(10, 0)} {
+		if err == nil {
+			t.Fatal("expected invalid gathering settings to be rejected")
+		}
+		w := httptest.NewRecorder()
+		generichttp.Error(w, err)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", err, w.Code)
+		}
+	}
+}
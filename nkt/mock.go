@@ -85,6 +85,14 @@ func (m *MockSuperK) GetPower() (float64, error) {
 	return float64(m.power / 10), nil
 }
 
+func (m *MockSuperK) SetPowerRamped(p float64) error {
+	return ramp(m.GetPower, m.SetPower, func() error { return nil }, clampPower(p, 100), DefaultRampStep, DefaultRampInterval)
+}
+
+func (m *MockSuperK) GetInterlock() (bool, error) {
+	return true, nil
+}
+
 func (m *MockSuperK) SetShortWave(nanometers float64) error {
 	m.Lock()
 	defer m.Unlock()
@@ -112,16 +120,11 @@ func (m *MockSuperK) GetLongWave() (float64, error) {
 }
 
 func (m *MockSuperK) SetCenterBandwidth(cbw laser.CenterBandwidth) error {
-	short, long := cbw.ToShortLong()
-	err := m.SetShortWave(short)
-	if err != nil {
-		return err
-	}
-	err = m.SetLongWave(long)
-	if err != nil {
-		return err
-	}
-	return nil
+	return m.SetBand(cbw.Center, cbw.Bandwidth)
+}
+
+func (m *MockSuperK) SetBand(center, width float64) error {
+	return setBand(m.GetLongWave, m.SetShortWave, m.SetLongWave, center, width)
 }
 
 func (m *MockSuperK) GetCenterBandwidth() (laser.CenterBandwidth, error) {
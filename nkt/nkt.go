@@ -7,7 +7,6 @@ package nkt
 import (
 	"encoding/json"
 	"errors"
-	"io"
 	"math"
 	"net/http"
 	"time"
@@ -84,6 +83,19 @@ type Module struct {
 	pool *comm.Pool // pointer so that pool is shared between modules
 }
 
+// Reconnect drains the pool of the module, which is shared with the other
+// modules of the laser
+func (m *Module) Reconnect() error {
+	m.pool.Drain()
+	return nil
+}
+
+// Shutdown closes the pool of the module, which is that of the whole laser
+func (m *Module) Shutdown() error {
+	m.pool.Close()
+	return nil
+}
+
 func (m *Module) getRegister(addrName string) (byte, error) {
 	var register byte
 	if value, ok := m.Info.Addresses[addrName]; ok {
@@ -245,10 +257,8 @@ func NewSuperK(addr string, connectSerial bool) *SuperK {
 	var maker comm.CreationFunc
 	// make the maker func
 	if connectSerial {
-		maker = func() (io.ReadWriteCloser, error) {
-			conf := makeSerConf(addr)
-			return serial.OpenPort(&conf)
-		}
+		conf := makeSerConf(addr)
+		maker = comm.SerialConnMaker(&conf)
 	} else {
 		maker = comm.BackingOffTCPConnMaker(addr, 3*time.Second)
 	}
@@ -269,6 +279,22 @@ func (sk *SuperK) StatusVaria() (map[string]bool, error) {
 	return sk.SuperKVaria.GetStatus()
 }
 
+// Ping reads the status register of the main module
+func (sk *SuperK) Ping() error {
+	_, err := sk.StatusMain()
+	return err
+}
+
+// Reconnect closes idle connections to the laser.  The modules share one pool
+func (sk *SuperK) Reconnect() error {
+	return sk.SuperKExtreme.Reconnect()
+}
+
+// Shutdown closes every connection to the laser.  The modules share one pool
+func (sk *SuperK) Shutdown() error {
+	return sk.SuperKExtreme.Shutdown()
+}
+
 func encodeStatus(fcn func() (map[string]bool, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		status, err := fcn()
@@ -293,11 +319,29 @@ type AugmentedLaserController interface {
 	StatusVaria() (map[string]bool, error)
 }
 
+// PowerRamper can change its power level gradually
+type PowerRamper interface {
+	// SetPowerRamped ramps the power level to a new value
+	SetPowerRamped(float64) error
+}
+
+// InterlockReader can report the state of its interlock
+type InterlockReader interface {
+	// GetInterlock returns true if the interlock permits emission
+	GetInterlock() (bool, error)
+}
+
 // NewHTTPWrapper creates a new HTTP wrapper and populates the route table
 func NewHTTPWrapper(sk AugmentedLaserController) laser.HTTPLaserController {
 	w := laser.NewHTTPLaserController(sk)
 	rt := w.RT()
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/main-module-status"}] = encodeStatus(sk.StatusMain)
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/varia-status"}] = encodeStatus(sk.StatusVaria)
+	if r, ok := sk.(PowerRamper); ok {
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/power/ramp"}] = generichttp.SetFloat(r.SetPowerRamped)
+	}
+	if il, ok := sk.(InterlockReader); ok {
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/interlock"}] = generichttp.GetBool(il.GetInterlock)
+	}
 	return w
 }
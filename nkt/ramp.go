@@ -0,0 +1,83 @@
+package nkt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRampStep is the largest change in power level, in percent, made
+	// at once by SetPowerRamped
+	DefaultRampStep = 5
+
+	// DefaultRampInterval is the time between steps of SetPowerRamped
+	DefaultRampInterval = 200 * time.Millisecond
+)
+
+// ErrInterlockOpen is returned when the interlock forbids emission
+var ErrInterlockOpen = errors.New("nkt: the interlock is open")
+
+// interlockBits are the bits of the main module status which mean the laser
+// cannot emit
+var interlockBits = []string{
+	"Interlock relays off",
+	"Interlock supply voltage low (possible short circuit)",
+	"Interlock loop open",
+}
+
+// interlockError returns ErrInterlockOpen, naming the bits which are set, if
+// status shows the interlock open
+func interlockError(status map[string]bool) error {
+	var open []string
+	for _, bit := range interlockBits {
+		if status[bit] {
+			open = append(open, strings.ToLower(bit))
+		}
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("%w: %s", ErrInterlockOpen, strings.Join(open, ", "))
+	}
+	return nil
+}
+
+// ramp moves the power level from its present value to target in steps no
+// larger than step, waiting interval between them.  check is called before
+// every step, and the ramp is abandoned at the level already reached if it
+// returns an error
+func ramp(get func() (float64, error), set func(float64) error, check func() error, target, step float64, interval time.Duration) error {
+	if step <= 0 {
+		step = DefaultRampStep
+	}
+	level, err := get()
+	if err != nil {
+		return err
+	}
+	for level != target {
+		if err = check(); err != nil {
+			return fmt.Errorf("nkt: power ramp stopped at %g%%: %w", level, err)
+		}
+		if math.Abs(target-level) <= step {
+			level = target
+		} else {
+			level += math.Copysign(step, target-level)
+		}
+		if err = set(level); err != nil {
+			return err
+		}
+		if level != target {
+			time.Sleep(interval)
+		}
+	}
+	return nil
+}
+
+// clampPower bounds a power level to [0, max]
+func clampPower(level, max float64) float64 {
+	if max <= 0 || max > 100 {
+		max = 100
+	}
+	return math.Max(0, math.Min(level, max))
+}
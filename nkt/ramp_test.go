@@ -0,0 +1,40 @@
+package nkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRampStopsWhenInterlockOpens(t *testing.T) {
+	level := 0.
+	var steps []float64
+	get := func() (float64, error) { return level, nil }
+	set := func(f float64) error {
+		level = f
+		steps = append(steps, f)
+		return nil
+	}
+	open := map[string]bool{"Interlock loop open": true}
+	check := func() error {
+		if len(steps) == 3 {
+			return interlockError(open)
+		}
+		return nil
+	}
+	err := ramp(get, set, check, 50, 10, 0)
+	if !errors.Is(err, ErrInterlockOpen) {
+		t.Fatalf("expected the ramp to stop for the interlock, got %v", err)
+	}
+	if level != 30 {
+		t.Errorf("expected the ramp to stop at 30%%, got %g", level)
+	}
+
+	steps = nil
+	level = 47
+	if err = ramp(get, set, func() error { return nil }, clampPower(80, 60), 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{57, 60}; len(steps) != 2 || steps[0] != want[0] || steps[1] != want[1] {
+		t.Errorf("expected steps %v, clamped to the maximum, got %v", want, steps)
+	}
+}
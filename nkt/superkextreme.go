@@ -1,6 +1,9 @@
 package nkt
 
 import (
+	"errors"
+	"time"
+
 	"github.com/nasa-jpl/golaborate/comm"
 )
 
@@ -82,20 +85,51 @@ var (
 // SuperKExtreme embeds Module and has some quick usage methods
 type SuperKExtreme struct {
 	Module
+
+	// MaxPower is the highest power level, in percent, SetPowerRamped will
+	// go to.  Zero is 100
+	MaxPower float64
+
+	// RampStep and RampInterval are the size of and time between the steps
+	// of SetPowerRamped.  Zero uses DefaultRampStep and DefaultRampInterval
+	RampStep     float64
+	RampInterval time.Duration
 }
 
 // NewSuperKExtreme create a new Module representing a SuperKExtreme's main module
 func NewSuperKExtreme(addr string, pool *comm.Pool) *SuperKExtreme {
-	return &SuperKExtreme{Module{
+	return &SuperKExtreme{Module: Module{
 		pool:    pool,
 		AddrDev: extremeDefaultAddr,
 		Info:    SuperKExtremeMainInfo}}
 }
 
-// SetEmission turns emission (laser output) on
+// checkInterlock returns ErrInterlockOpen if the interlock forbids emission
+func (sk *SuperKExtreme) checkInterlock() error {
+	status, err := sk.GetStatus()
+	if err != nil {
+		return err
+	}
+	return interlockError(status)
+}
+
+// GetInterlock returns true if the interlock is closed and the laser may emit
+func (sk *SuperKExtreme) GetInterlock() (bool, error) {
+	err := sk.checkInterlock()
+	if errors.Is(err, ErrInterlockOpen) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetEmission turns emission (laser output) on.  Emission is not turned on
+// if the interlock is open; the error says why
 func (sk *SuperKExtreme) SetEmission(on bool) error {
 	payload := []byte{0}
 	if on {
+		if err := sk.checkInterlock(); err != nil {
+			return err
+		}
 		payload[0] = 3
 	}
 	_, err := sk.SetValue("Emission", payload)
@@ -121,6 +155,17 @@ func (sk *SuperKExtreme) GetPower() (float64, error) {
 	return sk.GetFloat("Power Level")
 }
 
+// SetPowerRamped moves the power level to level (0-100) in steps, rather
+// than at once, to spare downstream optics.  The level is clamped to
+// MaxPower.  The ramp stops where it is if the interlock opens
+func (sk *SuperKExtreme) SetPowerRamped(level float64) error {
+	interval := sk.RampInterval
+	if interval <= 0 {
+		interval = DefaultRampInterval
+	}
+	return ramp(sk.GetPower, sk.SetPower, sk.checkInterlock, clampPower(level, sk.MaxPower), sk.RampStep, interval)
+}
+
 // SuperKBooster embeds Module and has an EmissionRuntime method
 type SuperKBooster struct {
 	Module
@@ -1,6 +1,7 @@
 package nkt
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/nasa-jpl/golaborate/comm"
@@ -61,7 +62,58 @@ func (cb CenterBandwidth) ToShortLong() (float64, float64) {
 	hb := cb.Bandwidth / 2
 	low := cb.Center - hb
 	high := cb.Center + hb
-	return high, low
+	return low, high
+}
+
+const (
+	// VariaMinWavelength and VariaMaxWavelength bound the filter edges of the
+	// Varia, in nm
+	VariaMinWavelength = 400
+	VariaMaxWavelength = 850
+
+	// VariaMinBandwidth and VariaMaxBandwidth bound the width of the band
+	// passed by the Varia, in nm
+	VariaMinBandwidth = 10
+	VariaMaxBandwidth = 100
+)
+
+// checkBand returns an error if the band from short to long nm cannot be set
+func checkBand(short, long float64) error {
+	if short >= long {
+		return fmt.Errorf("nkt: short wave edge %g nm must be below the long wave edge %g nm", short, long)
+	}
+	if short < VariaMinWavelength || long > VariaMaxWavelength {
+		return fmt.Errorf("nkt: band %g-%g nm is outside the range of the Varia, %d-%d nm",
+			short, long, VariaMinWavelength, VariaMaxWavelength)
+	}
+	if w := long - short; w < VariaMinBandwidth || w > VariaMaxBandwidth {
+		return fmt.Errorf("nkt: bandwidth %g nm must be from %d to %d nm", w, VariaMinBandwidth, VariaMaxBandwidth)
+	}
+	return nil
+}
+
+// setBand checks the band center +/- width/2 and sets its edges in the order
+// which keeps them from crossing on the way
+func setBand(getLong func() (float64, error), setShort, setLong func(float64) error, center, width float64) error {
+	short, long := CenterBandwidth{Center: center, Bandwidth: width}.ToShortLong()
+	if err := checkBand(short, long); err != nil {
+		return err
+	}
+	curLong, err := getLong()
+	if err != nil {
+		return err
+	}
+	if short >= curLong {
+		// moving up past the present band, raise the long edge first
+		if err = setLong(long); err != nil {
+			return err
+		}
+		return setShort(short)
+	}
+	if err = setShort(short); err != nil {
+		return err
+	}
+	return setLong(long)
 }
 
 // SuperKVaria embeds Module and has some quick usage methods
@@ -114,14 +166,11 @@ func (sk *SuperKVaria) GetCenterBandwidth() (laser.CenterBandwidth, error) {
 
 // SetCenterBandwidth sets the center wavelength and bandwidth of the laser
 func (sk *SuperKVaria) SetCenterBandwidth(cbw laser.CenterBandwidth) error {
-	short, long := cbw.ToShortLong()
-	err := sk.SetShortWave(short)
-	if err != nil {
-		return err
-	}
-	err = sk.SetLongWave(long)
-	if err != nil {
-		return err
-	}
-	return nil
+	return sk.SetBand(cbw.Center, cbw.Bandwidth)
+}
+
+// SetBand sets both filter edges for a band of width nm about center nm.  The
+// band must lie within the range of the Varia
+func (sk *SuperKVaria) SetBand(center, width float64) error {
+	return setBand(sk.GetLongWave, sk.SetShortWave, sk.SetLongWave, center, width)
 }
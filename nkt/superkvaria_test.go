@@ -0,0 +1,37 @@
+package nkt
+
+import "testing"
+
+func TestSetBandOrdersEdges(t *testing.T) {
+	short, long := 500., 550.
+	var order []string
+	getLong := func() (float64, error) { return long, nil }
+	setShort := func(f float64) error {
+		if f >= long {
+			t.Errorf("short edge %g set above the long edge %g", f, long)
+		}
+		short = f
+		order = append(order, "short")
+		return nil
+	}
+	setLong := func(f float64) error {
+		if f <= short {
+			t.Errorf("long edge %g set below the short edge %g", f, short)
+		}
+		long = f
+		order = append(order, "long")
+		return nil
+	}
+	// entirely above the present band
+	if err := setBand(getLong, setShort, setLong, 700, 50); err != nil {
+		t.Fatal(err)
+	}
+	if short != 675 || long != 725 || order[0] != "long" {
+		t.Errorf("expected 675-725 with the long edge set first, got %g-%g in order %v", short, long, order)
+	}
+	for _, c := range [][2]float64{{420, 60}, {600, 5}, {600, 200}} {
+		if err := setBand(getLong, setShort, setLong, c[0], c[1]); err == nil {
+			t.Errorf("expected center %g width %g to be rejected", c[0], c[1])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package pi
+
+import (
+	"strconv"
+	"time"
+)
+
+/* GCS2 axes may be addressed by the names the controller gives them, which
+are 1..N on some models and A..Z on others.  Callers may use either form; the
+controller's axes are learned from SAI? and the nth axis also answers to n, if
+no axis is named by a number, or the nth letter of the alphabet, if no axis is
+named by a letter.  Aliases set by the user take precedence.
+
+The aliases are learned on the first command which names an axis that is not
+an alias.  If they cannot be learned, commands go to the axis as named and
+learning is not tried again for AliasRetryInterval.
+*/
+
+// AliasRetryInterval is how long resolveAxis waits to try learning the axis
+// aliases again after failing to
+var AliasRetryInterval = time.Minute
+
+// LearnAxisAliases queries the axes of the controller and adds the aliases
+// 1..N or A..Z for them to c.Aliases.  Aliases already present are kept
+func (c *Controller) LearnAxisAliases() error {
+	axes, err := c.Axes()
+	c.aliasMu.Lock()
+	defer c.aliasMu.Unlock()
+	if err != nil || len(axes) == 0 {
+		c.aliasRetry = time.Now().Add(AliasRetryInterval)
+		return err
+	}
+	if c.Aliases == nil {
+		c.Aliases = map[string]string{}
+	}
+	add := func(alias, axis string) {
+		if _, exists := c.Aliases[alias]; !exists {
+			c.Aliases[alias] = axis
+		}
+	}
+	// the names the controller uses come first, so that an axis named "2"
+	// is not taken to mean the second axis
+	numbered, lettered := false, false
+	for _, axis := range axes {
+		add(axis, axis)
+		if _, err := strconv.Atoi(axis); err == nil {
+			numbered = true
+		}
+		if len(axis) == 1 && axis[0] >= 'A' && axis[0] <= 'Z' {
+			lettered = true
+		}
+	}
+	for i, axis := range axes {
+		if !numbered {
+			add(strconv.Itoa(i+1), axis)
+		}
+		if !lettered && i < 26 {
+			add(string(rune('A'+i)), axis)
+		}
+	}
+	c.aliasesLearned = true
+	return nil
+}
+
+// resolveAxis returns the name the controller uses for axis.  Names which
+// are not aliases are returned unchanged
+func (c *Controller) resolveAxis(axis string) string {
+	c.aliasMu.Lock()
+	name, ok := c.Aliases[axis]
+	learned := c.aliasesLearned
+	retry := c.aliasRetry
+	c.aliasMu.Unlock()
+	if ok {
+		return name
+	}
+	if learned || time.Now().Before(retry) || c.recordingMacro() != "" {
+		return axis
+	}
+	// an unreachable controller will fail the command itself
+	if c.LearnAxisAliases() != nil {
+		return axis
+	}
+	c.aliasMu.Lock()
+	defer c.aliasMu.Unlock()
+	if name, ok := c.Aliases[axis]; ok {
+		return name
+	}
+	return axis
+}
+
+// AxisAliases returns a map of every alias to the axis it names
+func (c *Controller) AxisAliases() (map[string]string, error) {
+	c.aliasMu.Lock()
+	learned := c.aliasesLearned
+	c.aliasMu.Unlock()
+	if !learned {
+		err := c.LearnAxisAliases()
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.aliasMu.Lock()
+	defer c.aliasMu.Unlock()
+	ret := make(map[string]string, len(c.Aliases))
+	for k, v := range c.Aliases {
+		ret[k] = v
+	}
+	return ret, nil
+}
@@ -2,15 +2,21 @@
 package pi
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/nasa-jpl/golaborate/generichttp"
 )
 
 /* GCS 2 primer
@@ -57,8 +63,14 @@ type ControllerNetwork struct {
 
 // NewNetwork creates a controller network with a shared pool
 func NewNetwork(addr string, serial bool) *ControllerNetwork {
+	return NewNetworkPooled(addr, serial, 1)
+}
+
+// NewNetworkPooled is NewNetwork with a pool of up to poolSize connections,
+// which the controllers of the network share
+func NewNetworkPooled(addr string, serial bool, poolSize int) *ControllerNetwork {
 	maker := comm.BackingOffTCPConnMaker(addr, 3*time.Second)
-	pool := comm.NewPool(1, 30*time.Second, maker)
+	pool := comm.NewPool(poolSize, 30*time.Second, maker)
 	return &ControllerNetwork{pool: pool, Controllers: map[int]PIController{}}
 }
 
@@ -98,8 +110,59 @@ type Controller struct {
 
 	// DV is the maximum allowed voltage delta between commands
 	DV *float64
+
+	// macroMu guards recording
+	macroMu sync.Mutex
+
+	// recording is the name of the macro being recorded, if any
+	recording string
+
+	// Aliases maps alternate names of axes to the names the controller uses,
+	// e.g. {"X": "1"}.  Positional aliases are added by LearnAxisAliases
+	Aliases map[string]string
+
+	// aliasMu guards Aliases, aliasesLearned and aliasRetry
+	aliasMu sync.Mutex
+
+	// aliasesLearned is true once the axes of the controller are known
+	aliasesLearned bool
+
+	// aliasRetry is when resolveAxis may next try to learn the aliases, after
+	// a failure
+	aliasRetry time.Time
+
+	// PCAddress is the address of the PC in the network, to which responses
+	// are addressed.  It is 0 unless the network uses custom addressing
+	PCAddress int
+
+	// Epsilon is the distance within which an axis is taken to already be at
+	// the target of MoveAbs, which then returns without moving.  Some firmware
+	// never signals on target for a move to the current position.  Zero
+	// disables the check
+	Epsilon float64
+
+	// RxTerm and TxTerm end the lines read from and written to the
+	// controller.  Both are DefaultTerminator unless the firmware differs
+	RxTerm, TxTerm string
+
+	// debug receives the raw traffic, see SetDebug
+	debug io.Writer
+
+	// Retries is the number of further attempts made at a query whose
+	// connection is dropped or reset, each on a fresh connection.  Timeouts
+	// and errors from the controller are not retried
+	Retries int
 }
 
+// DefaultEpsilon is the Epsilon of controllers made by NewController
+const DefaultEpsilon = 1e-6
+
+// DefaultTerminator is the line ending of GCS2
+const DefaultTerminator = "\n"
+
+// DefaultRetries is the Retries of controllers made by NewController
+const DefaultRetries = 2
+
 // NewController returns a new motion controller
 // addr is the location to send to, e.g. 192.168.100.2106.
 //
@@ -114,20 +177,41 @@ func NewController(pool *comm.Pool, index int, handshaking bool) *Controller {
 		pool:        pool,
 		Handshaking: handshaking,
 		Timeout:     30 * time.Second,
+		Epsilon:     DefaultEpsilon,
+		RxTerm:      DefaultTerminator,
+		TxTerm:      DefaultTerminator,
+		Retries:     DefaultRetries,
 	}
 }
 
 // write writes command(s) to the controller.  The controller index
 // is automatically prepended.  Commands with a ? in them will be rejected,
-// as they are queries.
+// as they are queries.  While a macro is recording, the controller stores
+// commands instead of executing them, so no error check is made
 func (c *Controller) write(msgs ...string) error {
+	return c.writeContext(context.Background(), msgs...)
+}
+
+// writeContext is write, giving up waiting for a connection when ctx is done
+func (c *Controller) writeContext(ctx context.Context, msgs ...string) error {
+	return c.send(ctx, c.Handshaking && c.recordingMacro() == "", msgs...)
+}
+
+// SetDebug logs every line sent to and received from the controller to w,
+// with its terminator.  nil turns logging off
+func (c *Controller) SetDebug(w io.Writer) {
+	c.debug = w
+}
+
+// send is writeContext, with handshaking given explicitly
+func (c *Controller) send(ctx context.Context, handshaking bool, msgs ...string) error {
 	for i := range msgs {
 		msg := msgs[i]
 		if strings.Contains(msg, "?") && !strings.Contains(msg, "WAC") {
 			return errors.New("pi/gcs2: command contains a query in write-only operation")
 		}
 	}
-	conn, err := c.pool.Get()
+	conn, err := c.pool.GetContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -137,7 +221,9 @@ func (c *Controller) write(msgs ...string) error {
 	if err != nil {
 		return err
 	}
-	wrap = comm.NewTerminator(wrap, '\n', '\n')
+	term := comm.NewLineTerminator(wrap, c.RxTerm, c.TxTerm)
+	term.Debug = c.debug
+	wrap = term
 
 	for i := range msgs {
 		msg := msgs[i]
@@ -147,7 +233,7 @@ func (c *Controller) write(msgs ...string) error {
 			return err
 		}
 	}
-	if c.Handshaking {
+	if handshaking {
 		msg := strconv.Itoa(c.index) + " ERR?"
 		_, err = io.WriteString(wrap, msg)
 		// error response will look like 0 1 nnnn which is six bytes, ten is enough
@@ -172,11 +258,64 @@ func (c *Controller) write(msgs ...string) error {
 // as they are queries.  The response is returned, after stripping the prefix
 // and suffix (~= "0 1" and \n)
 func (c *Controller) query(msg string) ([]byte, error) {
+	return c.queryContext(context.Background(), msg)
+}
+
+// queryContext is query, giving up waiting for a connection when ctx is done
+func (c *Controller) queryContext(ctx context.Context, msg string) ([]byte, error) {
 	// setup
 	if !strings.Contains(msg, "?") {
 		return nil, errors.New("query lacks a question mark")
 	}
-	conn, err := c.pool.Get()
+	if name := c.recordingMacro(); name != "" {
+		return nil, errRecording("query", name)
+	}
+	var resp []byte
+	err := c.retry(msg, func() error {
+		var err error
+		resp, err = c.queryOnce(ctx, msg)
+		return err
+	})
+	return resp, err
+}
+
+// transient returns true if err is a dropped connection, which a fresh one
+// may not suffer.  Timeouts and errors from the controller are not transient;
+// asking again would only wait as long again for the same answer
+func transient(err error) bool {
+	for _, e := range []error{io.EOF, io.ErrUnexpectedEOF, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.EPIPE, comm.ErrPortDisconnected} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry calls fn up to 1+c.Retries times, until it succeeds or fails with an
+// error which is not transient.  fn returns its connection to the pool with
+// its error, so each attempt is made on a fresh connection after a failure.
+// If every attempt fails, the errors are wrapped together
+func (c *Controller) retry(msg string, fn func() error) error {
+	var errs []string
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt == c.Retries || !transient(err) {
+			if attempt == 0 {
+				return err
+			}
+			return fmt.Errorf("pi/gcs2: %s failed %d times: %s: %w", msg, attempt+1, strings.Join(errs, "; "), err)
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil
+}
+
+// queryOnce is one attempt at query
+func (c *Controller) queryOnce(ctx context.Context, msg string) ([]byte, error) {
+	conn, err := c.pool.GetContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +325,9 @@ func (c *Controller) query(msg string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	wrap = comm.NewTerminator(wrap, '\n', '\n')
+	term := comm.NewLineTerminator(wrap, c.RxTerm, c.TxTerm)
+	term.Debug = c.debug
+	wrap = term
 
 	// prepend controller ID and send query
 	msg = strconv.Itoa(c.index) + " " + msg
@@ -200,17 +341,122 @@ func (c *Controller) query(msg string) ([]byte, error) {
 		return nil, err
 	}
 	pieces := bytes.SplitN(buf[:n], []byte{' '}, 3)
-	fromAddr, err := strconv.Atoi(string(pieces[1]))
+	if len(pieces) < 3 {
+		return nil, fmt.Errorf("pi/gcs2: response %q lacks the <to> <from> prefix", buf[:n])
+	}
+	err = c.checkRoute(pieces[0], pieces[1])
+	if err != nil {
+		return nil, err
+	}
+	return pieces[2], nil
+}
+
+// checkRoute verifies that the <to> and <from> prefix of a response shows it
+// was sent from this controller to the PC
+func (c *Controller) checkRoute(to, from []byte) error {
+	toAddr, err := strconv.Atoi(string(to))
 	if err != nil {
-		return nil, errors.New("pi/gcs2: could not parse controller ID from response")
+		return errors.New("pi/gcs2: could not parse PC address from response")
+	}
+	fromAddr, err := strconv.Atoi(string(from))
+	if err != nil {
+		return errors.New("pi/gcs2: could not parse controller ID from response")
 	}
 	if fromAddr != c.index {
-		return nil, errors.New("pi/gcs2: response received was not from the expected controller")
+		return fmt.Errorf("pi/gcs2: response received was from controller %d, not the expected controller %d", fromAddr, c.index)
+	}
+	if toAddr != c.PCAddress {
+		return fmt.Errorf("pi/gcs2: response received was addressed to %d, not the PC address %d", toAddr, c.PCAddress)
+	}
+	return nil
+}
+
+// queryLines is like query, but for replies which span several lines.  By
+// GCS2 convention, every line but the last ends with a space before the
+// terminator.  The controller prefix is stripped from each line
+func (c *Controller) queryLines(msg string) ([][]byte, error) {
+	if !strings.Contains(msg, "?") {
+		return nil, errors.New("query lacks a question mark")
+	}
+	if name := c.recordingMacro(); name != "" {
+		return nil, errRecording("query", name)
+	}
+	var lines [][]byte
+	err := c.retry(msg, func() error {
+		var err error
+		lines, err = c.queryLinesOnce(msg, c.Timeout)
+		return err
+	})
+	return lines, err
+}
+
+// bufferedConn reads through a bufio.Reader of the connection it writes to
+type bufferedConn struct {
+	*bufio.Reader
+	io.Writer
+}
+
+// queryLinesOnce is one attempt at queryLines, waiting up to timeout
+func (c *Controller) queryLinesOnce(msg string, timeout time.Duration) ([][]byte, error) {
+	conn, err := c.pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { c.pool.ReturnWithError(conn, err) }()
+	var wrap io.ReadWriter
+	wrap, err = comm.NewTimeout(conn, timeout)
+	if err != nil {
+		return nil, err
+	}
+	// one buffered reader for the whole reply, so that no lines are lost
+	// between reads.  The terminator reuses it, and logs each line to Debug
+	rxTerm := c.RxTerm
+	if rxTerm == "" {
+		rxTerm = "\n"
+	}
+	br := bufio.NewReader(wrap)
+	term := comm.NewLineTerminator(bufferedConn{br, wrap}, rxTerm, c.TxTerm)
+	term.Debug = c.debug
+
+	msg = strconv.Itoa(c.index) + " " + msg
+	_, err = io.WriteString(term, msg)
+	if err != nil {
+		return nil, err
+	}
+	prefix := []byte(strconv.Itoa(c.PCAddress) + " " + strconv.Itoa(c.index) + " ")
+	var lines [][]byte
+	buf := make([]byte, tcpFrameSize)
+	for {
+		var n int
+		n, err = term.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		line := append([]byte{}, buf[:n]...)
+		line = bytes.TrimRight(line, "\r\n")
+		more := bytes.HasSuffix(line, []byte{' '})
+		if len(lines) == 0 {
+			// only the first line carries the <to> <from> prefix
+			pieces := bytes.SplitN(bytes.TrimSpace(line), []byte{' '}, 3)
+			if len(pieces) < 3 {
+				err = fmt.Errorf("pi/gcs2: response %q lacks the <to> <from> prefix", line)
+				return nil, err
+			}
+			err = c.checkRoute(pieces[0], pieces[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		line = bytes.TrimPrefix(bytes.TrimSpace(line), prefix)
+		lines = append(lines, line)
+		if !more {
+			return lines, nil
+		}
 	}
-	return pieces[2], nil
 }
 
 func (c *Controller) readBool(cmd, axis string) (bool, error) {
+	axis = c.resolveAxis(axis)
 	str := strings.Join([]string{cmd, axis}, " ")
 	resp, err := c.query(str)
 	if err != nil {
@@ -221,6 +467,7 @@ func (c *Controller) readBool(cmd, axis string) (bool, error) {
 }
 
 func (c *Controller) readFloat(cmd, axis string) (float64, error) {
+	axis = c.resolveAxis(axis)
 	str := strings.Join([]string{cmd, axis}, " ")
 	resp, err := c.query(str)
 	if err != nil {
@@ -231,14 +478,81 @@ func (c *Controller) readFloat(cmd, axis string) (float64, error) {
 }
 
 // MoveAbs commands the controller to move an axis to an absolute position
+// and waits for it to arrive.  If the axis is not on target within c.Timeout,
+// an error including the final position is returned.  If the axis is already
+// within c.Epsilon of pos, no move is made.  The arrival cannot be awaited
+// while a macro is recording, so MoveAbs is then refused; use MoveRel or Raw
 func (c *Controller) MoveAbs(axis string, pos float64) error {
+	if name := c.recordingMacro(); name != "" {
+		return errRecording("move and wait", name)
+	}
+	axis = c.resolveAxis(axis)
+	if c.Epsilon > 0 {
+		cur, err := c.GetPos(axis)
+		if err != nil {
+			return err
+		}
+		if math.Abs(cur-pos) <= c.Epsilon {
+			return nil
+		}
+	}
 	// want to wait this long before reading position to wait for convergence
 	msg := fmt.Sprintf("MOV %s %.9f", axis, pos)
-	return c.write(msg)
+	err := c.write(msg)
+	if err != nil {
+		return err
+	}
+	return c.waitOnTarget(axis, pos)
+}
+
+// MoveAbsVerified moves an axis to an absolute position as MoveAbs, then
+// reads back the position and returns an error if it is more than tol from
+// pos.  The controller's own on-target window may be looser than tol
+func (c *Controller) MoveAbsVerified(axis string, pos, tol float64) error {
+	err := c.MoveAbs(axis, pos)
+	if err != nil {
+		return err
+	}
+	actual, err := c.GetPos(axis)
+	if err != nil {
+		return err
+	}
+	if math.Abs(actual-pos) > tol {
+		return fmt.Errorf("pi/gcs2: axis %s settled at %.9f after a move to %.9f, outside the tolerance of %g", axis, actual, pos, tol)
+	}
+	return nil
+}
+
+// ontPollInterval is the time between ONT? queries while waiting for a move
+const ontPollInterval = 10 * time.Millisecond
+
+// waitOnTarget polls ONT? until the axis is on target or c.Timeout elapses.
+// This is done instead of WAC on the controller, which would wait forever if
+// the axis stalled and leave the connection hung until the socket timeout
+func (c *Controller) waitOnTarget(axis string, target float64) error {
+	deadline := time.Now().Add(c.Timeout)
+	for {
+		ont, err := c.GetInPosition(axis)
+		if err != nil {
+			return err
+		}
+		if ont {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			pos, err := c.GetPos(axis)
+			if err != nil {
+				return fmt.Errorf("pi/gcs2: move of axis %s to %.9f did not complete within %v, and the final position could not be read: %w", axis, target, c.Timeout, err)
+			}
+			return fmt.Errorf("pi/gcs2: move of axis %s to %.9f did not complete within %v, final position %.9f", axis, target, c.Timeout, pos)
+		}
+		time.Sleep(ontPollInterval)
+	}
 }
 
 // MoveRel commands the controller to move an axis by a delta
 func (c *Controller) MoveRel(axis string, delta float64) error {
+	axis = c.resolveAxis(axis)
 	// want to wait this long before reading position to wait for convergence
 	msg := fmt.Sprintf("MVR %s %.9f", axis, delta)
 	return c.write(msg)
@@ -258,6 +572,169 @@ func (c *Controller) GetPos(axis string) (float64, error) {
 	return c.readFloat("POS?", axis)
 }
 
+// Axes returns the identifiers of the axes configured on the controller, from
+// SAI?
+func (c *Controller) Axes() ([]string, error) {
+	lines, err := c.queryLines("SAI?")
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, len(lines))
+	for i, line := range lines {
+		ret[i] = string(line)
+	}
+	return ret, nil
+}
+
+// readAll issues a query without an axis, which the controller answers for
+// every axis with one line of <axis>=<value> each
+func (c *Controller) readAll(cmd string) (map[string]string, error) {
+	lines, err := c.queryLines(cmd)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(lines))
+	for _, line := range lines {
+		pieces := bytes.SplitN(line, []byte{'='}, 2)
+		if len(pieces) != 2 {
+			return nil, fmt.Errorf("pi/gcs2: could not parse %s response line %q", cmd, line)
+		}
+		ret[string(pieces[0])] = string(pieces[1])
+	}
+	return ret, nil
+}
+
+// PositionsAll returns the position of every axis on the controller with a
+// single POS? query
+func (c *Controller) PositionsAll() (map[string]float64, error) {
+	strs, err := c.readAll("POS?")
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]float64, len(strs))
+	for k, v := range strs {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		ret[k] = f
+	}
+	return ret, nil
+}
+
+// AxisStatus is the state of one axis at a glance
+type AxisStatus struct {
+	// Position is the position of the axis
+	Position float64 `json:"position"`
+
+	// Servo is true if the axis is in closed loop
+	Servo bool `json:"servo"`
+
+	// Referenced is true if the axis has been homed
+	Referenced bool `json:"referenced"`
+
+	// OnTarget is true if the axis has settled at its target
+	OnTarget bool `json:"onTarget"`
+
+	// Error is the error code of the controller from ERR?, which is the
+	// same for every axis.  It is nil unless the error was asked for
+	Error *int `json:"error,omitempty"`
+}
+
+// Snapshot returns the status of every axis, using one query for each of
+// POS?, SVO?, FRF?, and ONT? across all axes.  If readErr is true, ERR? is
+// read last as well, which clears the error on the controller, so that
+// whoever else would have read it will not
+func (c *Controller) Snapshot(readErr bool) (map[string]AxisStatus, error) {
+	pos, err := c.PositionsAll()
+	if err != nil {
+		return nil, err
+	}
+	bools := make([]map[string]string, 3)
+	for i, cmd := range []string{"SVO?", "FRF?", "ONT?"} {
+		bools[i], err = c.readAll(cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var code *int
+	if readErr {
+		resp, err := c.query("ERR?")
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(resp)))
+		if err != nil {
+			return nil, fmt.Errorf("pi/gcs2: could not parse ERR? response %q", resp)
+		}
+		code = &n
+	}
+	ret := make(map[string]AxisStatus, len(pos))
+	for axis, p := range pos {
+		ret[axis] = AxisStatus{Position: p}
+	}
+	for _, m := range bools {
+		for axis := range m {
+			ret[axis] = ret[axis]
+		}
+	}
+	for axis, st := range ret {
+		st.Servo = bools[0][axis] == "1"
+		st.Referenced = bools[1][axis] == "1"
+		st.OnTarget = bools[2][axis] == "1"
+		st.Error = code
+		ret[axis] = st
+	}
+	return ret, nil
+}
+
+// Stop halts an axis smoothly with HLT, decelerating at the rate DEC? of the
+// axis.  GCS2 has no abrupt stop of a single axis; StopAll stops every axis
+// abruptly.  The controller flags the stop with error 10, which is not an
+// error here
+func (c *Controller) Stop(axis string) error {
+	axis = c.resolveAxis(axis)
+	err := c.write("HLT " + axis)
+	if err == GCS2Err(10) {
+		return nil
+	}
+	return err
+}
+
+// MovingAxes returns the axes which are not on target, with a single ONT?
+// query
+func (c *Controller) MovingAxes() ([]string, error) {
+	ont, err := c.readAll("ONT?")
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for axis, v := range ont {
+		if v != "1" {
+			ret = append(ret, axis)
+		}
+	}
+	return ret, nil
+}
+
+// StopAll halts every axis on the controller immediately with STP.  The
+// controller flags the stop with error 10, which is not an error here.  A
+// macro being recorded would store STP instead of running it, so the
+// recording is ended first, and the macro stored as far as it got
+func (c *Controller) StopAll() error {
+	c.macroMu.Lock()
+	endErr := c.endRecording()
+	c.macroMu.Unlock()
+	err := c.write("STP")
+	if err == GCS2Err(10) {
+		err = nil
+	}
+	if err == nil {
+		err = endErr
+	}
+	return err
+}
+
 // GetInPosition returns True if axis is in position
 func (c *Controller) GetInPosition(axis string) (bool, error) {
 	return c.readBool("ONT?", axis)
@@ -265,6 +742,7 @@ func (c *Controller) GetInPosition(axis string) (bool, error) {
 
 // SetVelocity returns the velocity of an axis
 func (c *Controller) SetVelocity(axis string, v float64) error {
+	axis = c.resolveAxis(axis)
 	return c.write(fmt.Sprintf("VEL %s %.9f", axis, v))
 }
 
@@ -275,11 +753,13 @@ func (c *Controller) GetVelocity(axis string) (float64, error) {
 
 // Enable causes the controller to enable motion on a given axis
 func (c *Controller) Enable(axis string) error {
+	axis = c.resolveAxis(axis)
 	return c.write(fmt.Sprintf("SVO %s 1", axis))
 }
 
 // Disable causes the controller to disable motion on a given axis
 func (c *Controller) Disable(axis string) error {
+	axis = c.resolveAxis(axis)
 	return c.write(fmt.Sprintf("SVO %s 0", axis))
 }
 
@@ -290,11 +770,13 @@ func (c *Controller) GetEnabled(axis string) (bool, error) {
 
 // Home causes the controller to move an axis to its home position
 func (c *Controller) Home(axis string) error {
+	axis = c.resolveAxis(axis)
 	return c.write(fmt.Sprintf("FRF %s", axis))
 }
 
 // SetVoltage sets the voltage on an axis
 func (c *Controller) SetVoltage(axis string, volts float64) error {
+	axis = c.resolveAxis(axis)
 	msg := fmt.Sprintf("SVA %s %.9f", axis, volts)
 	return c.write(msg)
 }
@@ -304,12 +786,110 @@ func (c *Controller) GetVoltage(axis string) (float64, error) {
 	return c.readFloat("SVA?", axis)
 }
 
+// Reconnect drains the pool, which every controller of the daisy chain shares
+func (c *Controller) Reconnect() error {
+	c.pool.Drain()
+	return nil
+}
+
+// Shutdown closes the pool, and so the daisy chain, for every controller on it
+func (c *Controller) Shutdown() error {
+	c.pool.Close()
+	return nil
+}
+
+// parseIDN parses a *IDN? response.  Most controllers reply with four comma
+// separated fields, e.g.
+// (c)2015 Physik Instrumente (PI) GmbH & Co. KG, E-709, 0115034125, 01.021
+// but the vendor field varies and may itself hold commas, so the model,
+// serial, and version are taken from the end.  ok is false if the response
+// has fewer than three fields, in which case the model is the whole response
+func parseIDN(resp string) (info generichttp.DeviceInfo, ok bool) {
+	info.Vendor = "Physik Instrumente"
+	resp = strings.TrimSpace(resp)
+	pieces := strings.Split(resp, ",")
+	for i := range pieces {
+		pieces[i] = strings.TrimSpace(pieces[i])
+	}
+	n := len(pieces)
+	if n < 3 {
+		info.Model = resp
+		return info, false
+	}
+	info.Model = pieces[n-3]
+	info.Serial = pieces[n-2]
+	info.Version = pieces[n-1]
+	if n == 3 && strings.Contains(strings.ToLower(info.Model), "physik") {
+		// vendor, model, serial with no version
+		info.Model, info.Serial, info.Version = pieces[1], pieces[2], ""
+	}
+	return info, true
+}
+
+// parseVER returns the firmware version from a VER? response, which has one
+// line per firmware component, e.g.
+// FW_ARM: V1.2.0.10
+// FW_FPGA: V1.0.0.2
+// The first FW line is used, or the whole response if there is none
+func parseVER(lines [][]byte) string {
+	for _, line := range lines {
+		l := strings.TrimSpace(string(line))
+		if strings.HasPrefix(l, "FW") {
+			if i := strings.Index(l, ":"); i >= 0 {
+				return strings.TrimSpace(l[i+1:])
+			}
+			return l
+		}
+	}
+	strs := make([]string, len(lines))
+	for i, line := range lines {
+		strs[i] = strings.TrimSpace(string(line))
+	}
+	return strings.Join(strs, "; ")
+}
+
+// VERTimeout is the longest Identify waits for an answer to VER?, which models
+// that do not know it never give
+var VERTimeout = time.Second
+
+// Identify queries *IDN? and parses the response as parseIDN.  If the
+// response is not understood or carries no version, the firmware version is
+// read with VER? instead, once and for at most VERTimeout.  Models which do
+// not know VER? keep what *IDN? gave; the error they raise is read back, so it
+// is not reported by the next command
+func (c *Controller) Identify() (generichttp.DeviceInfo, error) {
+	resp, err := c.query("*IDN?")
+	if err != nil {
+		return generichttp.DeviceInfo{}, err
+	}
+	info, ok := parseIDN(string(resp))
+	if !ok || info.Version == "" {
+		timeout := VERTimeout
+		if c.Timeout < timeout {
+			timeout = c.Timeout
+		}
+		lines, err := c.queryLinesOnce("VER?", timeout)
+		if err != nil {
+			c.query("ERR?")
+		} else if len(lines) > 0 {
+			info.Version = parseVER(lines)
+		}
+	}
+	return info, nil
+}
+
 // Raw implements generichttp/ascii.RawCommunicator
 func (c *Controller) Raw(s string) (string, error) {
+	return c.RawContext(context.Background(), s)
+}
+
+// RawContext is Raw, giving up waiting for a connection when ctx is done, as
+// when the HTTP request it serves has timed out
+func (c *Controller) RawContext(ctx context.Context, s string) (string, error) {
 	if strings.Contains(s, "?") {
-		resp, err := c.query(s)
+		resp, err := c.queryContext(ctx, s)
 		return string(resp), err
 	}
-	err := c.write(s)
+	err := c.writeContext(ctx, s)
 	return "", err
 }
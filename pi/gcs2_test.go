@@ -0,0 +1,440 @@
+package pi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+func TestMoveAbsStalledAxisTimesOut(t *testing.T) {
+	dev := newSimDevice()
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return simConn{dev: dev}, nil
+	})
+	c := NewController(pool, 1, true)
+	c.Timeout = 50 * time.Millisecond
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	dev.ctls[1].stalled["A"] = true
+
+	start := time.Now()
+	err := c.MoveAbs("A", 3)
+	if err == nil {
+		t.Fatal("expected an error moving a stalled axis")
+	}
+	if !strings.Contains(err.Error(), "did not complete") || !strings.Contains(err.Error(), "final position") {
+		t.Errorf("expected the error to describe the failed move, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the move to give up near the timeout, took %v", time.Since(start))
+	}
+}
+
+func TestMoveAbsVerifiedChecksTolerance(t *testing.T) {
+	dev := newSimDevice()
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return simConn{dev: dev}, nil
+	})
+	c := NewController(pool, 1, true)
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	dev.ctls[1].settle["A"] = 1e-3
+
+	if err := c.MoveAbsVerified("A", 2, 1e-2); err != nil {
+		t.Errorf("expected a 1e-3 error to be within a 1e-2 tolerance, got %v", err)
+	}
+	if err := c.MoveAbsVerified("A", 2, 1e-6); err == nil {
+		t.Error("expected a 1e-3 error to violate a 1e-6 tolerance")
+	}
+}
+
+func TestMoveAbsToCurrentPositionReturns(t *testing.T) {
+	dev := newSimDevice()
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return simConn{dev: dev}, nil
+	})
+	c := NewController(pool, 1, true)
+	c.Timeout = 50 * time.Millisecond
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	// a stalled axis never reports on target, like firmware which does not
+	// for a move to where the axis already is
+	dev.ctls[1].stalled["A"] = true
+	if err := c.MoveAbs("A", 0); err != nil {
+		t.Errorf("expected a move to the current position to return at once, got %v", err)
+	}
+	c.Epsilon = 0
+	if err := c.MoveAbs("A", 0); err == nil {
+		t.Error("expected the move to be made, and time out, with Epsilon disabled")
+	}
+}
+
+func TestMacroRecordAndRun(t *testing.T) {
+	c := NewSimController(1, true)
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartMacroRecording("scan"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartMacroRecording("other"); err == nil {
+		t.Error("expected an error starting a second recording")
+	}
+	if err := c.write("MOV A 4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPos("A"); err == nil {
+		t.Error("expected queries to be refused while recording")
+	}
+	err := c.MoveAbs("A", 7)
+	w := httptest.NewRecorder()
+	generichttp.Error(w, err)
+	if err == nil || w.Code != http.StatusConflict {
+		t.Errorf("expected MoveAbs to be refused with 409 while recording, got %d: %v", w.Code, err)
+	}
+	if err := c.EndMacroRecording(); err != nil {
+		t.Fatal(err)
+	}
+	pos, err := c.GetPos("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 0 {
+		t.Errorf("expected the recorded move not to run, axis is at %f", pos)
+	}
+	if err := c.RunMacro("scan"); err != nil {
+		t.Fatal(err)
+	}
+	pos, err = c.GetPos("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 4 {
+		t.Errorf("expected the macro to move the axis to 4, it is at %f", pos)
+	}
+}
+
+func TestWaveGenerator(t *testing.T) {
+	c := NewSimController(1, true)
+	if err := c.StartWave(1, 0); err == nil {
+		t.Error("expected an error starting a generator with no wave table")
+	}
+	if err := c.DefineWave(1, Waveform{Type: "square", Points: 100}); err == nil {
+		t.Error("expected an error defining an unknown waveform")
+	}
+	if err := c.DefineWave(1, Waveform{Type: WaveSine, Points: 1000, Amplitude: 10, Rate: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartWave(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	running, err := c.GetWaveRunning(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !running {
+		t.Error("expected the wave generator to be running")
+	}
+	if err := c.StopWave(1); err != nil {
+		t.Fatal(err)
+	}
+	running, err = c.GetWaveRunning(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running {
+		t.Error("expected the wave generator to be stopped")
+	}
+}
+
+func TestReadDataRecorder(t *testing.T) {
+	c := NewSimController(1, true)
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	for _, pos := range []float64{1, 2.5, -3} {
+		if err := c.MoveAbs("A", pos); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := c.ReadDataRecorder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []float64{1, 2.5, -3}
+	if len(data) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, data)
+	}
+	for i := range exp {
+		if data[i] != exp[i] {
+			t.Errorf("point %d: expected %f, got %f", i, exp[i], data[i])
+		}
+	}
+}
+
+func TestQueryChecksPCAddress(t *testing.T) {
+	c := NewSimController(1, true)
+	if _, err := c.GetPos("A"); err != nil {
+		t.Fatal(err)
+	}
+	// the simulator always addresses responses to 0
+	c.PCAddress = 3
+	_, err := c.GetPos("A")
+	if err == nil || !strings.Contains(err.Error(), "PC address 3") {
+		t.Errorf("expected an error naming the PC address, got %v", err)
+	}
+}
+
+func TestAxisAliases(t *testing.T) {
+	c := NewSimController(1, true)
+	for _, axis := range []string{"A", "B"} {
+		if err := c.Enable(axis); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.LearnAxisAliases(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveAbs("2", 7); err != nil {
+		t.Fatal(err)
+	}
+	pos, err := c.GetPos("B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 7 {
+		t.Errorf("expected a move of axis 2 to move axis B, B is at %f", pos)
+	}
+	aliases, err := c.AxisAliases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aliases["1"] != "A" || aliases["B"] != "B" {
+		t.Errorf("expected 1 to alias A and B itself, got %v", aliases)
+	}
+}
+
+func TestDebugLogsEveryLineOfMultiLineReplies(t *testing.T) {
+	c := NewSimController(1, false)
+	for _, axis := range []string{"A", "B", "C"} {
+		if err := c.Enable(axis); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var log bytes.Buffer
+	c.SetDebug(&log)
+	axes, err := c.Axes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(axes, ",") != "A,B,C" {
+		t.Fatalf("expected axes A,B,C, got %v", axes)
+	}
+	if n := strings.Count(log.String(), "rx "); n != 3 {
+		t.Errorf("expected a debug line for each of the 3 lines of the reply, got %d:\n%s", n, log.String())
+	}
+	if !strings.Contains(log.String(), "tx ") {
+		t.Errorf("expected the query to be logged, got\n%s", log.String())
+	}
+}
+
+func TestParseIDN(t *testing.T) {
+	cases := []struct {
+		resp                   string
+		model, serial, version string
+		ok                     bool
+	}{
+		{"(c)2015 Physik Instrumente (PI) GmbH & Co. KG, E-727, 0115020012, 01.200", "E-727", "0115020012", "01.200", true},
+		{"(c)2013 Physik Instrumente(PI) Karlsruhe, C-884.4DC, 0, 1.1.2", "C-884.4DC", "0", "1.1.2", true},
+		{"Physik Instrumente, E-509.C3A, 110038452", "E-509.C3A", "110038452", "", true},
+		{"E-509 Servo Controller", "E-509 Servo Controller", "", "", false},
+	}
+	for _, tc := range cases {
+		info, ok := parseIDN(tc.resp)
+		if ok != tc.ok || info.Model != tc.model || info.Serial != tc.serial || info.Version != tc.version {
+			t.Errorf("%q: expected %s, %s, %s (%v), got %+v (%v)", tc.resp, tc.model, tc.serial, tc.version, tc.ok, info, ok)
+		}
+	}
+}
+
+func TestCRLFTerminator(t *testing.T) {
+	dev := newSimDevice()
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return simConn{dev: dev}, nil
+	})
+	c := NewController(pool, 1, true)
+	c.TxTerm = "\r\n"
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveAbs("A", 2); err != nil {
+		t.Fatal(err)
+	}
+	pos, err := c.PositionsAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos["A"] != 2 {
+		t.Errorf("expected A at 2 with CRLF line endings, got %v", pos)
+	}
+}
+
+// flakyConn fails the first read made through any of its copies
+type flakyConn struct {
+	simConn
+	fails *int
+}
+
+func (f flakyConn) Read(b []byte) (int, error) {
+	if *f.fails > 0 {
+		*f.fails--
+		return 0, syscall.ECONNRESET
+	}
+	return f.simConn.Read(b)
+}
+
+// Close drops the replies not yet read, as closing a socket would
+func (f flakyConn) Close() error {
+	f.dev.Lock()
+	f.dev.out = nil
+	f.dev.Unlock()
+	return nil
+}
+
+func TestQueryRetriesOnFreshConnection(t *testing.T) {
+	dev := newSimDevice()
+	fails, made := 1, 0
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		made++
+		return flakyConn{simConn: simConn{dev: dev}, fails: &fails}, nil
+	})
+	c := NewController(pool, 1, false)
+	c.Aliases = map[string]string{}
+	c.aliasesLearned = true
+	if _, err := c.GetPos("A"); err != nil {
+		t.Fatalf("expected the query to succeed on retry, got %v", err)
+	}
+	if made != 2 {
+		t.Errorf("expected the failed connection to be replaced, %d were made", made)
+	}
+
+	fails = 10
+	_, err := c.GetPos("A")
+	if err == nil || !strings.Contains(err.Error(), "3 times") {
+		t.Errorf("expected an error after three attempts, got %v", err)
+	}
+}
+
+func TestAliasesAreNotRelearnedAfterAFailure(t *testing.T) {
+	dev := newSimDevice()
+	fails := 1
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return flakyConn{simConn: simConn{dev: dev}, fails: &fails}, nil
+	})
+	c := NewController(pool, 1, false)
+	c.Retries = 0
+	var log bytes.Buffer
+	c.SetDebug(&log)
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetPos("A"); err != nil && i > 0 {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(log.String(), "SAI?"); n != 1 {
+		t.Errorf("expected SAI? to be sent once after it failed, it was sent %d times", n)
+	}
+
+	c.aliasRetry = time.Time{} // as if AliasRetryInterval had passed
+	if _, err := c.GetPos("A"); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(log.String(), "SAI?"); n != 2 {
+		t.Errorf("expected SAI? to be sent again once the interval passed, it was sent %d times", n)
+	}
+}
+
+func TestIdentifyWithoutVERClearsTheError(t *testing.T) {
+	dev := newSimDevice()
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return simConn{dev: dev}, nil
+	})
+	c := NewController(pool, 1, true)
+	c.Timeout = 10 * time.Millisecond
+	var log bytes.Buffer
+	c.SetDebug(&log)
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	dev.ctls[1].idn = "Physik Instrumente, E-509.C3A, 110038452"
+	info, err := c.Identify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Model != "E-509.C3A" || info.Version != "" {
+		t.Errorf("expected the model from *IDN? and no version, got %+v", info)
+	}
+	if err = c.Enable("A"); err != nil {
+		t.Errorf("expected the error of VER? to be cleared, got %v", err)
+	}
+	if n := strings.Count(log.String(), "VER?"); n != 1 {
+		t.Errorf("expected VER? to be sent once, it was sent %d times", n)
+	}
+}
+
+func TestStopAllWhileRecordingStops(t *testing.T) {
+	dev := newSimDevice()
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		return simConn{dev: dev}, nil
+	})
+	c := NewController(pool, 1, true)
+	if err := c.StartMacroRecording("scan"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.write("MOV A 4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StopAll(); err != nil {
+		t.Fatal(err)
+	}
+	if name := c.recordingMacro(); name != "" {
+		t.Errorf("expected the recording to end, %s is still recording", name)
+	}
+	ctl := dev.ctls[1]
+	if ctl.recording != "" {
+		t.Errorf("expected the controller to stop recording, %s is still recording", ctl.recording)
+	}
+	if got := strings.Join(ctl.macros["scan"], ", "); got != "MOV A 4" {
+		t.Errorf("expected STP to be run, not stored, got macro %q", got)
+	}
+}
+
+func TestTimeoutsAreNotRetried(t *testing.T) {
+	dev := newSimDevice()
+	made := 0
+	pool := comm.NewPool(1, 0, func() (io.ReadWriteCloser, error) {
+		made++
+		return simConn{dev: dev}, nil
+	})
+	c := NewController(pool, 1, false)
+	c.Timeout = 10 * time.Millisecond
+	// the simulator never answers this, so the read times out
+	_, err := c.query("XYZ?")
+	if !errors.Is(err, comm.ErrTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if made != 1 {
+		t.Errorf("expected one attempt on one connection, %d were made", made)
+	}
+}
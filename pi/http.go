@@ -0,0 +1,196 @@
+package pi
+
+import (
+	"encoding/json"
+	"go/types"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server"
+)
+
+// MacroRecorder is a type which can record and run macros stored on a controller
+type MacroRecorder interface {
+	// StartMacroRecording begins recording a macro
+	StartMacroRecording(string) error
+
+	// EndMacroRecording ends the recording of a macro
+	EndMacroRecording() error
+
+	// RunMacro runs a stored macro
+	RunMacro(string) error
+}
+
+// EndMacroRecording returns an HTTP handler func that ends macro recording
+func EndMacroRecording(m MacroRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := m.EndMacroRecording()
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HTTPMacro adds the macro routes to the table.  POST /macro/record with
+// {"str": name} begins recording, after which commands sent to /raw are stored
+// in the macro.  POST /macro/end stores it, and POST /macro/run with
+// {"str": name} runs it
+func HTTPMacro(m MacroRecorder, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/macro/record"}] = generichttp.SetString(m.StartMacroRecording)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/macro/end"}] = EndMacroRecording(m)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/macro/run"}] = generichttp.SetString(m.RunMacro)
+}
+
+// WaveGenerator is a type which can play wave tables on its axes
+type WaveGenerator interface {
+	// DefineWave defines a wave table for a wave generator
+	DefineWave(int, Waveform) error
+
+	// StartWave starts a wave generator for a number of cycles
+	StartWave(int, int) error
+
+	// StopWave stops a wave generator
+	StopWave(int) error
+
+	// GetWaveRunning returns true if a wave generator is running
+	GetWaveRunning(int) (bool, error)
+}
+
+// waveGen wraps a handler which operates on the {gen} in the URL
+func waveGen(fcn func(int, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gen, err := strconv.Atoi(chi.URLParam(r, "gen"))
+		if err != nil {
+			http.Error(w, "pi/gcs2: wave generator must be an integer", http.StatusBadRequest)
+			return
+		}
+		fcn(gen, w, r)
+	}
+}
+
+// HTTPWave adds the wave generator routes to the table.
+//
+// POST /wave/{gen}/table with a Waveform defines the wave table of the generator.
+// POST /wave/{gen}/start with {"int": cycles} starts it, 0 for forever.
+// POST /wave/{gen}/stop stops it, and GET /wave/{gen}/running reports if it runs
+func HTTPWave(g WaveGenerator, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/wave/{gen}/table"}] = waveGen(func(gen int, w http.ResponseWriter, r *http.Request) {
+		wf := Waveform{}
+		err := json.NewDecoder(r.Body).Decode(&wf)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = g.DefineWave(gen, wf)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/wave/{gen}/start"}] = waveGen(func(gen int, w http.ResponseWriter, r *http.Request) {
+		i := generichttp.IntT{}
+		err := json.NewDecoder(r.Body).Decode(&i)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = g.StartWave(gen, i.Int)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/wave/{gen}/stop"}] = waveGen(func(gen int, w http.ResponseWriter, r *http.Request) {
+		err := g.StopWave(gen)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/wave/{gen}/running"}] = waveGen(func(gen int, w http.ResponseWriter, r *http.Request) {
+		b, err := g.GetWaveRunning(gen)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		hp := generichttp.HumanPayload{T: types.Bool, Bool: b}
+		hp.EncodeAndRespond(w, r)
+	})
+}
+
+// DataRecorder is a type which can read out a data recorder
+type DataRecorder interface {
+	// ReadDataRecorder reads every point in a record table
+	ReadDataRecorder(int) ([]float64, error)
+}
+
+// GetDataRecorder returns an HTTP handler func that responds with the
+// contents of the record table in the URL as a JSON array
+func GetDataRecorder(d DataRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tbl, err := strconv.Atoi(chi.URLParam(r, "table"))
+		if err != nil {
+			http.Error(w, "pi/gcs2: record table must be an integer", http.StatusBadRequest)
+			return
+		}
+		data, err := d.ReadDataRecorder(tbl)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPDataRecorder adds the GET /recorder/{table} route to the table
+func HTTPDataRecorder(d DataRecorder, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/recorder/{table}"}] = GetDataRecorder(d)
+}
+
+// Snapshotter is a type which can report the status of all of its axes at once
+type Snapshotter interface {
+	// Snapshot returns the status of every axis, with the error of the
+	// controller if readErr is true
+	Snapshot(readErr bool) (map[string]AxisStatus, error)
+}
+
+// GetSnapshot returns an HTTP handler func that responds with the status of
+// every axis as JSON.  The error of the controller is included only with
+// ?errors=true, since reading it clears it
+func GetSnapshot(s Snapshotter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readErr, err := server.QueryBool(r, "errors", false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		snap, err := s.Snapshot(readErr)
+		if err != nil {
+			generichttp.Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(snap)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPSnapshot adds the GET /snapshot route to the table
+func HTTPSnapshot(s Snapshotter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/snapshot"}] = GetSnapshot(s)
+}
@@ -0,0 +1,79 @@
+package pi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+/* GCS2 macros are sequences of commands stored on the controller.
+MAC BEG <name> begins recording, after which the controller stores the
+commands it receives instead of executing them, until MAC END.
+MAC START <name> runs a stored macro.
+*/
+
+// recordingMacro returns the name of the macro being recorded, or "" if none is
+func (c *Controller) recordingMacro() string {
+	c.macroMu.Lock()
+	defer c.macroMu.Unlock()
+	return c.recording
+}
+
+// errRecording returns the error for an operation, such as a query, which needs
+// an answer from the controller while macro name is recording and cannot get
+// one.  It is a conflict with the state of the controller
+func errRecording(op, name string) error {
+	return generichttp.Conflict(fmt.Errorf("pi/gcs2: cannot %s while macro %s is recording", op, name))
+}
+
+// StartMacroRecording begins recording a macro on the controller.  Until
+// EndMacroRecording is called, commands are stored in the macro instead of
+// executed, and queries are refused.  Only one macro may record at a time
+func (c *Controller) StartMacroRecording(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("pi/gcs2: invalid macro name %q", name)
+	}
+	c.macroMu.Lock()
+	defer c.macroMu.Unlock()
+	if c.recording != "" {
+		return fmt.Errorf("pi/gcs2: macro %s is already recording", c.recording)
+	}
+	// ERR? would be recorded, so there is no handshaking here
+	err := c.send(context.Background(), false, "MAC BEG "+name)
+	if err != nil {
+		return err
+	}
+	c.recording = name
+	return nil
+}
+
+// EndMacroRecording ends the recording of a macro, storing it on the controller
+func (c *Controller) EndMacroRecording() error {
+	c.macroMu.Lock()
+	defer c.macroMu.Unlock()
+	if c.recording == "" {
+		return errors.New("pi/gcs2: no macro is recording")
+	}
+	return c.endRecording()
+}
+
+// endRecording sends MAC END if a macro is recording.  c.macroMu must be held
+func (c *Controller) endRecording() error {
+	if c.recording == "" {
+		return nil
+	}
+	err := c.send(context.Background(), c.Handshaking, "MAC END")
+	c.recording = ""
+	return err
+}
+
+// RunMacro runs a macro stored on the controller
+func (c *Controller) RunMacro(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("pi/gcs2: invalid macro name %q", name)
+	}
+	return c.write("MAC START " + name)
+}
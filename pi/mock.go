@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 	"unicode"
 
 	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/nasa-jpl/golaborate/generichttp"
 )
 
 const (
@@ -79,6 +81,36 @@ func (c *MockController) GetPos(axis string) (float64, error) {
 	return c.pos[axis], nil
 }
 
+// Axes returns every axis which has been enabled or moved
+func (c *MockController) Axes() ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	seen := make(map[string]bool)
+	for k := range c.enabled {
+		seen[k] = true
+	}
+	for k := range c.pos {
+		seen[k] = true
+	}
+	ret := make([]string, 0, len(seen))
+	for k := range seen {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// PositionsAll returns the position of every axis which has been moved
+func (c *MockController) PositionsAll() (map[string]float64, error) {
+	c.Lock()
+	defer c.Unlock()
+	ret := make(map[string]float64, len(c.pos))
+	for k, v := range c.pos {
+		ret[k] = v
+	}
+	return ret, nil
+}
+
 func (c *MockController) GetVelocity(axis string) (float64, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -206,6 +238,11 @@ func (c *MockController) MoveRel(axis string, dPos float64) error {
 	return nil
 }
 
+// Identify returns a fixed identity for the mock
+func (c *MockController) Identify() (generichttp.DeviceInfo, error) {
+	return generichttp.DeviceInfo{Vendor: "Physik Instrumente", Model: "mock", Serial: "0", Version: "0"}, nil
+}
+
 func (c *MockController) Raw(s string) (string, error) {
 	// PI GCS2 format: (TLA = Three Letter Acronym)
 	// from<sp>to<sp>TLA<sp><?><sp>arg1<sp>arg2
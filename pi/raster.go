@@ -0,0 +1,213 @@
+package pi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+// GridAxis is the span of one axis of a raster scan
+type GridAxis struct {
+	// Start is the first position
+	Start float64 `json:"start"`
+
+	// Step is the distance between points, and may be negative
+	Step float64 `json:"step"`
+
+	// Count is the number of points
+	Count int `json:"count"`
+}
+
+// Position returns the position of the i-th point
+func (g GridAxis) Position(i int) float64 {
+	return g.Start + float64(i)*g.Step
+}
+
+// RasterSpec describes a raster scan.  X is the fast axis, stepped at every
+// point, and Y the slow axis, stepped once per row
+type RasterSpec struct {
+	X GridAxis `json:"x"`
+	Y GridAxis `json:"y"`
+
+	// Serpentine reverses every other row, so the X axis does not return to
+	// its start between rows.  Otherwise, each row is scanned in the same
+	// direction
+	Serpentine bool `json:"serpentine"`
+
+	// Dwell is the time to wait at each point, in seconds, after the axes
+	// are on target
+	Dwell float64 `json:"dwell"`
+}
+
+// RasterPoint is one point of a raster scan
+type RasterPoint struct {
+	// Index is the number of the point in the order it is visited
+	Index int `json:"index"`
+
+	// Row and Col locate the point in the grid
+	Row int `json:"row"`
+	Col int `json:"col"`
+
+	// X and Y are the positions of the axes
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Points returns the points of the scan in the order they are visited
+func (s RasterSpec) Points() []RasterPoint {
+	if s.X.Count <= 0 || s.Y.Count <= 0 {
+		return nil
+	}
+	pts := make([]RasterPoint, 0, s.X.Count*s.Y.Count)
+	for row := 0; row < s.Y.Count; row++ {
+		for i := 0; i < s.X.Count; i++ {
+			col := i
+			if s.Serpentine && row%2 == 1 {
+				col = s.X.Count - 1 - i
+			}
+			pts = append(pts, RasterPoint{
+				Index: len(pts),
+				Row:   row,
+				Col:   col,
+				X:     s.X.Position(col),
+				Y:     s.Y.Position(row)})
+		}
+	}
+	return pts
+}
+
+// Gantry is a pair of axes, possibly on different controllers, which move
+// together to scan a grid
+type Gantry struct {
+	// X and Y move the fast and slow axes
+	X, Y Mover
+
+	// XAxis and YAxis are the names of the axes on their controllers
+	XAxis, YAxis string
+
+	// XLimit and YLimit, if not nil, bound the positions the scan may visit
+	XLimit, YLimit *util.Limiter
+}
+
+// Check returns an error if spec is empty or visits a point outside the
+// limits of the gantry
+func (g *Gantry) Check(spec RasterSpec) error {
+	if spec.X.Count <= 0 || spec.Y.Count <= 0 {
+		return errors.New("pi/gcs2: a raster scan must have at least one point on each axis")
+	}
+	check := func(name string, lim *util.Limiter, ax GridAxis) error {
+		if lim == nil {
+			return nil
+		}
+		first, last := ax.Position(0), ax.Position(ax.Count-1)
+		if !lim.Contains(first) || !lim.Contains(last) {
+			return fmt.Errorf("pi/gcs2: raster scan of %s from %g to %g leaves the limits [%g, %g]", name, first, last, lim.Min, lim.Max)
+		}
+		return nil
+	}
+	if err := check("x", g.XLimit, spec.X); err != nil {
+		return err
+	}
+	return check("y", g.YLimit, spec.Y)
+}
+
+// RasterScan visits every point of spec, calling fn once both axes are on
+// target and the dwell has elapsed.  The Y axis is only moved at the start of
+// each row.  The scan stops at the first error from a move or from fn, or
+// when ctx is done
+func (g *Gantry) RasterScan(ctx context.Context, spec RasterSpec, fn func(RasterPoint) error) error {
+	if err := g.Check(spec); err != nil {
+		return err
+	}
+	dwell := time.Duration(spec.Dwell * float64(time.Second))
+	for _, pt := range spec.Points() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if pt.Index%spec.X.Count == 0 {
+			if err := g.Y.MoveAbs(g.YAxis, pt.Y); err != nil {
+				return err
+			}
+		}
+		if err := g.X.MoveAbs(g.XAxis, pt.X); err != nil {
+			return err
+		}
+		if dwell > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(dwell):
+			}
+		}
+		if fn != nil {
+			if err := fn(pt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ServeHTTP runs the raster scan given as a JSON RasterSpec in the body,
+// streaming progress as text/event-stream.  An "event: point" is sent with
+// the RasterPoint as each point is reached, followed by "event: done" or
+// "event: error" with {"error": ...}.  The scan is stopped if the client
+// disconnects
+func (g *Gantry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var spec RasterSpec
+	err := json.NewDecoder(r.Body).Decode(&spec)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = g.Check(spec); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported by this connection", http.StatusInternalServerError)
+		return
+	}
+	hdr := w.Header()
+	hdr.Set("Content-Type", "text/event-stream")
+	hdr.Set("Cache-Control", "no-cache")
+	hdr.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		if err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	err = g.RasterScan(r.Context(), spec, func(pt RasterPoint) error {
+		return send("point", pt)
+	})
+	if err != nil {
+		send("error", struct {
+			Error string `json:"error"`
+		}{err.Error()})
+		return
+	}
+	send("done", struct{}{})
+}
+
+// HTTPRasterScan adds the POST /raster route to the table
+func HTTPRasterScan(g *Gantry, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/raster"}] = g.ServeHTTP
+}
@@ -0,0 +1,63 @@
+package pi
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+/* The GCS2 data recorder samples values such as position and position error
+at the servo rate into record tables.  DRL? <table> reports how many points a
+table holds, and DRR? <start> <count> <table> reads them out, after a header
+of lines beginning with #:
+
+# TYPE = 1
+# SEPARATOR = 32
+# DIM = 1
+# SAMPLE_TIME = 0.000050
+# NDATA = 3
+# END_HEADER
+0.1
+0.2
+0.3
+*/
+
+// ReadDataRecorder reads every point recorded in a record table
+func (c *Controller) ReadDataRecorder(table int) ([]float64, error) {
+	key := strconv.Itoa(table)
+	resp, err := c.query("DRL? " + key)
+	if err != nil {
+		return nil, err
+	}
+	resp = stripAxis(key, resp)
+	n, err := strconv.Atoi(string(bytes.TrimSpace(resp)))
+	if err != nil {
+		return nil, fmt.Errorf("pi/gcs2: could not parse the length of record table %d: %w", table, err)
+	}
+	if n == 0 {
+		return []float64{}, nil
+	}
+	lines, err := c.queryLines(fmt.Sprintf("DRR? 1 %d %d", n, table))
+	if err != nil {
+		return nil, err
+	}
+	return parseRecord(lines)
+}
+
+// parseRecord parses the lines of a DRR? reply, skipping the header
+func parseRecord(lines [][]byte) ([]float64, error) {
+	ret := make([]float64, 0, len(lines))
+	for _, line := range lines {
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		// with several tables, a line holds one column per table
+		fields := bytes.Fields(line)
+		f, err := strconv.ParseFloat(string(fields[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("pi/gcs2: could not parse data recorder value %q: %w", line, err)
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
@@ -0,0 +1,447 @@
+package pi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/comm"
+)
+
+/* the simulator speaks GCS2 over an in-memory connection, so the real
+Controller code path (prefixes, handshaking, parsing) is exercised without
+hardware.  It is a dry run: moves complete instantly.
+
+Supported commands:
+MOV MVR STP POS? ONT? SVO? FRF? (with or without an axis) SAI? SVO SVA SVA? VEL VEL?
+FRF *IDN? ERR?
+MAC BEG, MAC END, MAC START WAV WSL WGC WTR WGO WGO? DRL? DRR?
+
+Record table 1 of the data recorder holds the position after each move.
+*/
+
+// simController holds the state of one simulated controller in the network
+type simController struct {
+	pos     map[string]float64
+	voltage map[string]float64
+	vel     map[string]float64
+	servo   map[string]bool
+	err     int
+
+	// referenced axes have been homed with FRF
+	referenced map[string]bool
+
+	// stalled axes never report on target, as if mechanically blocked
+	stalled map[string]bool
+
+	// settle is added to the target of each move, as a servo settling error
+	settle map[string]float64
+
+	// macros holds the commands of each stored macro
+	macros map[string][]string
+
+	// recording is the name of the macro being recorded, if any
+	recording string
+
+	// waveTables holds the wave tables which have been defined
+	waveTables map[string]bool
+
+	// waveSel maps wave generators to the tables connected to them
+	waveSel map[string]string
+
+	// waveRun holds the start mode of each wave generator
+	waveRun map[string]string
+
+	// record is record table 1 of the data recorder
+	record []float64
+
+	// idn, if not empty, is the reply to *IDN?, as of a model which does
+	// not give its firmware version there
+	idn string
+}
+
+func newSimController() *simController {
+	return &simController{
+		pos:     make(map[string]float64),
+		voltage: make(map[string]float64),
+		vel:     make(map[string]float64),
+		servo:   make(map[string]bool),
+		stalled: make(map[string]bool),
+		settle:  make(map[string]float64),
+		macros:  make(map[string][]string),
+
+		referenced: make(map[string]bool),
+		waveTables: make(map[string]bool),
+		waveSel:    make(map[string]string),
+		waveRun:    make(map[string]string),
+	}
+}
+
+// axes returns the sorted names of every axis the controller has seen
+func (c *simController) axes() []string {
+	seen := make(map[string]bool)
+	for k := range c.pos {
+		seen[k] = true
+	}
+	for k := range c.servo {
+		seen[k] = true
+	}
+	ret := make([]string, 0, len(seen))
+	for k := range seen {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// simDevice is a simulated daisy chain of GCS2 controllers.
+// Controllers are created on first use
+type simDevice struct {
+	sync.Mutex
+	ctls map[int]*simController
+	in   bytes.Buffer
+	out  [][]byte
+}
+
+func newSimDevice() *simDevice {
+	return &simDevice{ctls: make(map[int]*simController)}
+}
+
+// simConn is a connection to a simDevice.  It satisfies the deadline
+// interfaces so it can be used with comm.Timeout
+type simConn struct {
+	dev *simDevice
+}
+
+func (s simConn) Write(b []byte) (int, error) {
+	d := s.dev
+	d.Lock()
+	defer d.Unlock()
+	d.in.Write(b)
+	for {
+		line, err := d.in.ReadBytes('\n')
+		if err != nil {
+			// incomplete line, put it back for the next write
+			d.in.Write(line)
+			break
+		}
+		d.process(string(bytes.TrimRight(line, "\r\n")))
+	}
+	return len(b), nil
+}
+
+// Read returns one response line at a time
+func (s simConn) Read(b []byte) (int, error) {
+	d := s.dev
+	d.Lock()
+	defer d.Unlock()
+	if len(d.out) == 0 {
+		return 0, comm.ErrTimeout
+	}
+	line := d.out[0]
+	n := copy(b, line)
+	if n == len(line) {
+		d.out = d.out[1:]
+	} else {
+		d.out[0] = line[n:]
+	}
+	return n, nil
+}
+
+func (s simConn) Close() error                       { return nil }
+func (s simConn) SetReadDeadline(t time.Time) error  { return nil }
+func (s simConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// process handles one command line.  The device lock must be held
+func (d *simDevice) process(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	// a leading controller number addresses a controller in the network,
+	// and causes the reply to be prefixed with <to> <from>
+	index := 1
+	networked := false
+	if i, err := strconv.Atoi(fields[0]); err == nil {
+		index = i
+		networked = true
+		fields = fields[1:]
+		if len(fields) == 0 {
+			return
+		}
+	}
+	ctl, ok := d.ctls[index]
+	if !ok {
+		ctl = newSimController()
+		d.ctls[index] = ctl
+	}
+	reply := func(msg string) {
+		if networked {
+			msg = fmt.Sprintf("0 %d %s", index, msg)
+		}
+		d.out = append(d.out, []byte(msg+"\n"))
+	}
+	cmd, args := fields[0], fields[1:]
+	if ctl.recording != "" && !(cmd == "MAC" && len(args) == 1 && args[0] == "END") {
+		ctl.macros[ctl.recording] = append(ctl.macros[ctl.recording], strings.Join(fields, " "))
+		return
+	}
+	// fail sets the error code of the controller.  As on the real hardware,
+	// the first error is kept until it is read with ERR?
+	fail := func(code int) {
+		if ctl.err == 0 {
+			ctl.err = code
+		}
+	}
+	readAxis := func(m map[string]float64) {
+		if len(args) != 1 {
+			fail(1)
+			return
+		}
+		reply(fmt.Sprintf("%s=%.9f", args[0], m[args[0]]))
+	}
+	// readFlags replies <axis>=0|1 for the axis given, or for every axis,
+	// one per line, if none is
+	readFlags := func(flag func(string) bool) {
+		if len(args) > 1 {
+			fail(1)
+			return
+		}
+		axes := args
+		if len(axes) == 0 {
+			axes = ctl.axes()
+		}
+		for i, k := range axes {
+			msg := k + "=0"
+			if flag(k) {
+				msg = k + "=1"
+			}
+			if i < len(axes)-1 {
+				msg += " "
+			}
+			reply(msg)
+		}
+	}
+	writeAxis := func(m map[string]float64) (string, float64, bool) {
+		if len(args) != 2 {
+			fail(1)
+			return "", 0, false
+		}
+		f, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			fail(1)
+			return "", 0, false
+		}
+		return args[0], f, true
+	}
+	switch cmd {
+	case "*IDN?":
+		if ctl.idn != "" {
+			reply(ctl.idn)
+			return
+		}
+		reply(fmt.Sprintf("(c)%d Physik Instrumente (PI) GmbH & Co. KG, SIM-%d, %010d, 00.000", time.Now().Year(), index, index))
+	case "ERR?":
+		reply(strconv.Itoa(ctl.err))
+		ctl.err = 0
+	case "SAI?":
+		// axes are configured by first use
+		axes := ctl.axes()
+		for i, k := range axes {
+			if i < len(axes)-1 {
+				k += " "
+			}
+			reply(k)
+		}
+	case "POS?":
+		if len(args) == 0 {
+			// every axis, one per line, continued lines ending with a space
+			axes := make([]string, 0, len(ctl.pos))
+			for k := range ctl.pos {
+				axes = append(axes, k)
+			}
+			sort.Strings(axes)
+			for i, k := range axes {
+				msg := fmt.Sprintf("%s=%.9f", k, ctl.pos[k])
+				if i < len(axes)-1 {
+					msg += " "
+				}
+				reply(msg)
+			}
+			return
+		}
+		readAxis(ctl.pos)
+	case "SVA?":
+		readAxis(ctl.voltage)
+	case "VEL?":
+		readAxis(ctl.vel)
+	case "ONT?":
+		// moves are instant
+		readFlags(func(axis string) bool { return !ctl.stalled[axis] })
+	case "SVO?":
+		readFlags(func(axis string) bool { return ctl.servo[axis] })
+	case "FRF?":
+		readFlags(func(axis string) bool { return ctl.referenced[axis] })
+	case "SVO":
+		if len(args) != 2 || (args[1] != "0" && args[1] != "1") {
+			fail(1)
+			return
+		}
+		ctl.servo[args[0]] = args[1] == "1"
+	case "MOV", "MVR":
+		axis, f, ok := writeAxis(ctl.pos)
+		if !ok {
+			return
+		}
+		if !ctl.servo[axis] {
+			fail(5)
+			return
+		}
+		if cmd == "MVR" {
+			f += ctl.pos[axis]
+		}
+		ctl.pos[axis] = f + ctl.settle[axis]
+		ctl.record = append(ctl.record, ctl.pos[axis])
+	case "SVA":
+		axis, f, ok := writeAxis(ctl.voltage)
+		if !ok {
+			return
+		}
+		if ctl.servo[axis] {
+			fail(5) // open loop command in closed loop
+			return
+		}
+		ctl.voltage[axis] = f
+	case "VEL":
+		axis, f, ok := writeAxis(ctl.vel)
+		if !ok {
+			return
+		}
+		ctl.vel[axis] = f
+	case "STP":
+		fail(10) // stopped by command
+	case "MAC":
+		switch {
+		case len(args) == 2 && args[0] == "BEG":
+			ctl.recording = args[1]
+			ctl.macros[args[1]] = nil
+		case len(args) == 1 && args[0] == "END":
+			ctl.recording = ""
+		case len(args) == 2 && args[0] == "START":
+			cmds, ok := ctl.macros[args[1]]
+			if !ok {
+				fail(1)
+				return
+			}
+			for _, c := range cmds {
+				d.process(fmt.Sprintf("%d %s", index, c))
+			}
+		default:
+			fail(1)
+		}
+	case "WAV":
+		if len(args) < 3 {
+			fail(1)
+			return
+		}
+		ctl.waveTables[args[0]] = true
+	case "WSL":
+		if len(args) != 2 || !ctl.waveTables[args[1]] {
+			fail(1)
+			return
+		}
+		ctl.waveSel[args[0]] = args[1]
+	case "WGC", "WTR":
+		if len(args) < 2 {
+			fail(1)
+			return
+		}
+	case "WGO":
+		if len(args) != 2 {
+			fail(1)
+			return
+		}
+		if args[1] != "0" && ctl.waveSel[args[0]] == "" {
+			fail(1) // no wave table connected
+			return
+		}
+		ctl.waveRun[args[0]] = args[1]
+	case "WGO?":
+		if len(args) != 1 {
+			fail(1)
+			return
+		}
+		mode := ctl.waveRun[args[0]]
+		if mode == "" {
+			mode = "0"
+		}
+		reply(args[0] + "=" + mode)
+	case "DRL?":
+		if len(args) != 1 {
+			fail(1)
+			return
+		}
+		n := 0
+		if args[0] == "1" {
+			n = len(ctl.record)
+		}
+		reply(fmt.Sprintf("%s=%d", args[0], n))
+	case "DRR?":
+		if len(args) != 3 || args[2] != "1" {
+			fail(1)
+			return
+		}
+		start, err1 := strconv.Atoi(args[0])
+		count, err2 := strconv.Atoi(args[1])
+		if err1 != nil || err2 != nil || start < 1 || start-1+count > len(ctl.record) {
+			fail(1)
+			return
+		}
+		lines := []string{"# TYPE = 1", "# SEPARATOR = 32", "# DIM = 1",
+			"# SAMPLE_TIME = 0.000050", fmt.Sprintf("# NDATA = %d", count), "# END_HEADER"}
+		for _, v := range ctl.record[start-1 : start-1+count] {
+			lines = append(lines, fmt.Sprintf("%.9f", v))
+		}
+		for i, l := range lines {
+			if i < len(lines)-1 {
+				l += " "
+			}
+			reply(l)
+		}
+	case "FRF":
+		if len(args) != 1 {
+			fail(1)
+			return
+		}
+		ctl.pos[args[0]] = 0
+		ctl.referenced[args[0]] = true
+	default:
+		fail(2)
+	}
+}
+
+// NewSimNetwork returns a controller network backed by an in-memory GCS2
+// simulator instead of hardware.  Controllers added to it with Add(idx, hs, false)
+// use the real Controller code and talk to the simulator
+func NewSimNetwork() *ControllerNetwork {
+	dev := newSimDevice()
+	maker := func() (io.ReadWriteCloser, error) {
+		return simConn{dev: dev}, nil
+	}
+	pool := comm.NewPool(1, 0, maker)
+	return &ControllerNetwork{pool: pool, Controllers: map[int]PIController{}}
+}
+
+// NewSimController returns a Controller which talks to its own simulated
+// GCS2 device.  It tracks position, servo state, velocity, and voltage per axis
+func NewSimController(index int, handshaking bool) *Controller {
+	n := NewSimNetwork()
+	c := NewController(n.pool, index, handshaking)
+	n.Controllers[index] = c
+	return c
+}
@@ -0,0 +1,275 @@
+package pi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/generichttp/motion"
+	"github.com/nasa-jpl/golaborate/pi"
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+func TestSimControllerMoveAndReadBack(t *testing.T) {
+	c := pi.NewSimController(1, true)
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveAbs("A", 12.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveRel("A", -2.5); err != nil {
+		t.Fatal(err)
+	}
+	pos, err := c.GetPos("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 10 {
+		t.Errorf("expected position 10, got %f", pos)
+	}
+	enabled, err := c.GetEnabled("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		t.Error("expected servo to be on after Enable")
+	}
+}
+
+func TestSimControllerReportsErrorsWhenHandshaking(t *testing.T) {
+	c := pi.NewSimController(1, true)
+	err := c.MoveAbs("A", 1)
+	if err == nil || err != pi.GCS2Err(5) {
+		t.Errorf("expected error 5 moving with servo off, got %v", err)
+	}
+	// the error is cleared by the ERR? query
+	if err = c.Disable("A"); err != nil {
+		t.Errorf("expected no error after ERR? cleared the last, got %v", err)
+	}
+	if err = c.SetVoltage("A", 12); err != nil {
+		t.Fatal(err)
+	}
+	v, err := c.GetVoltage("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 12 {
+		t.Errorf("expected voltage 12, got %f", v)
+	}
+}
+
+func TestSimNetworkAddressesControllersIndependently(t *testing.T) {
+	n := pi.NewSimNetwork()
+	c1 := n.Add(1, true, false)
+	c2 := n.Add(2, true, false)
+	for _, c := range []pi.PIController{c1, c2} {
+		if err := c.Enable("1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c2.MoveAbs("1", 3); err != nil {
+		t.Fatal(err)
+	}
+	p1, _ := c1.GetPos("1")
+	p2, _ := c2.GetPos("1")
+	if p1 != 0 || p2 != 3 {
+		t.Errorf("expected positions 0 and 3, got %f and %f", p1, p2)
+	}
+}
+
+func TestSimControllerOverHTTP(t *testing.T) {
+	c := pi.NewSimController(1, true)
+	h := motion.NewHTTPMotionController(c)
+	r := chi.NewRouter()
+	h.RT().Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	post := func(path string, v interface{}) {
+		buf := &bytes.Buffer{}
+		json.NewEncoder(buf).Encode(v)
+		resp, err := http.Post(srv.URL+path, "application/json", buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s: status %d", path, resp.StatusCode)
+		}
+	}
+	post("/axis/A/enabled", generichttp.BoolT{Bool: true})
+	post("/axis/A/pos", generichttp.FloatT{F64: 4})
+
+	resp, err := http.Get(srv.URL + "/axis/A/pos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	f := generichttp.FloatT{}
+	if err = json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.F64 != 4 {
+		t.Errorf("expected position 4 over HTTP, got %f", f.F64)
+	}
+}
+
+func TestSimControllerPositionsAll(t *testing.T) {
+	c := pi.NewSimController(1, true)
+	for i, axis := range []string{"A", "B", "C"} {
+		if err := c.Enable(axis); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.MoveAbs(axis, float64(i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := motion.NewHTTPMotionController(c)
+	r := chi.NewRouter()
+	h.RT().Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/positions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	pos := map[string]float64{}
+	if err = json.NewDecoder(resp.Body).Decode(&pos); err != nil {
+		t.Fatal(err)
+	}
+	if len(pos) != 3 || pos["A"] != 1 || pos["B"] != 2 || pos["C"] != 3 {
+		t.Errorf("expected A=1 B=2 C=3, got %v", pos)
+	}
+}
+
+func TestSimControllerAxes(t *testing.T) {
+	c := pi.NewSimController(1, true)
+	for _, axis := range []string{"B", "A"} {
+		if err := c.Enable(axis); err != nil {
+			t.Fatal(err)
+		}
+	}
+	axes, err := c.Axes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(axes) != 2 || axes[0] != "A" || axes[1] != "B" {
+		t.Errorf("expected [A B], got %v", axes)
+	}
+}
+
+func TestSimControllerStopAllIsNotAnError(t *testing.T) {
+	c := pi.NewSimController(1, true)
+	for i := 0; i < 2; i++ {
+		if err := c.StopAll(); err != nil {
+			t.Errorf("expected STP to succeed on call %d, got %v", i+1, err)
+		}
+	}
+	if err := c.Enable("A"); err != nil {
+		t.Errorf("expected the stop error to have been cleared, got %v", err)
+	}
+}
+
+func TestRasterScanSerpentine(t *testing.T) {
+	x, y := pi.NewSimController(1, true), pi.NewSimController(2, true)
+	for _, c := range []*pi.Controller{x, y} {
+		if err := c.Enable("A"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := &pi.Gantry{X: x, Y: y, XAxis: "A", YAxis: "A"}
+	spec := pi.RasterSpec{
+		X:          pi.GridAxis{Start: 0, Step: 1, Count: 3},
+		Y:          pi.GridAxis{Start: 10, Step: -5, Count: 2},
+		Serpentine: true}
+	var got []pi.RasterPoint
+	err := g.RasterScan(context.Background(), spec, func(pt pi.RasterPoint) error {
+		xp, err := x.GetPos("A")
+		if err != nil {
+			return err
+		}
+		yp, err := y.GetPos("A")
+		if err != nil {
+			return err
+		}
+		if xp != pt.X || yp != pt.Y {
+			t.Errorf("point %d: expected the axes at (%g, %g), got (%g, %g)", pt.Index, pt.X, pt.Y, xp, yp)
+		}
+		got = append(got, pt)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantX := []float64{0, 1, 2, 2, 1, 0}
+	if len(got) != len(wantX) {
+		t.Fatalf("expected %d points, got %d", len(wantX), len(got))
+	}
+	for i, pt := range got {
+		if pt.X != wantX[i] {
+			t.Errorf("point %d: expected x %g, got %g", i, wantX[i], pt.X)
+		}
+	}
+	if got[5].Y != 5 {
+		t.Errorf("expected the second row at y 5, got %g", got[5].Y)
+	}
+
+	lim := &util.Limiter{Min: 0, Max: 1}
+	g.XLimit = lim
+	if err := g.RasterScan(context.Background(), spec, nil); err == nil {
+		t.Error("expected a scan leaving the limits to be rejected")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	c := pi.NewSimController(1, true)
+	if err := c.Enable("A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Home("A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveAbs("A", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Disable("B"); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := c.Snapshot(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := snap["A"]
+	if a.Position != 3 || !a.Servo || !a.Referenced || !a.OnTarget {
+		t.Errorf("expected A at 3, in servo, referenced, and on target, got %+v", a)
+	}
+	if b, ok := snap["B"]; !ok || b.Servo || b.Referenced {
+		t.Errorf("expected B out of servo and unreferenced, got %+v", b)
+	}
+	if a.Error != nil {
+		t.Errorf("expected no error code unless asked for, got %d", *a.Error)
+	}
+
+	// an unknown command, sent without handshaking, leaves an error for ERR?,
+	// which a snapshot must not take unless asked to
+	c.Handshaking = false
+	c.Raw("XYZ")
+	if _, err = c.Snapshot(false); err != nil {
+		t.Fatal(err)
+	}
+	snap, err = c.Snapshot(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := snap["A"].Error; e == nil || *e == 0 {
+		t.Errorf("expected the error to be left for ?errors=true, got %+v", snap["A"])
+	}
+}
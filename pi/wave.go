@@ -0,0 +1,102 @@
+package pi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* The GCS2 wave generator plays a wave table, point by point at the servo
+rate, on an axis.  The tables are defined with WAV, connected to a generator
+with WSL, and the generator is started and stopped with WGO.  Wave generator
+n drives axis n on most controllers.
+
+WAV <table> X SIN_P <length> <amplitude> <offset> <period> <start> <center>
+WAV <table> X LIN <length> <amplitude> <offset> <period> <start> <speed up/down>
+*/
+
+const (
+	// WaveSine is a single period of a sine, from offset to offset+amplitude and back
+	WaveSine = "sine"
+
+	// WaveLinear is a ramp from offset to offset+amplitude
+	WaveLinear = "linear"
+)
+
+// Waveform describes a wave table
+type Waveform struct {
+	// Type is WaveSine or WaveLinear
+	Type string `json:"type"`
+
+	// Points is the number of points in the table
+	Points int `json:"points"`
+
+	// Amplitude is the peak-to-valley extent of the wave, in axis units
+	Amplitude float64 `json:"amplitude"`
+
+	// Offset is the position the wave starts from, in axis units
+	Offset float64 `json:"offset"`
+
+	// Rate is the number of servo cycles each point is held for.  Zero leaves
+	// the controller's table rate unchanged
+	Rate int `json:"rate"`
+}
+
+// command returns the WAV command which defines w in table
+func (w Waveform) command(table int) (string, error) {
+	if w.Points < 2 {
+		return "", fmt.Errorf("pi/gcs2: a wave table needs at least two points, got %d", w.Points)
+	}
+	switch w.Type {
+	case WaveSine:
+		return fmt.Sprintf("WAV %d X SIN_P %d %.9f %.9f %d 0 %d",
+			table, w.Points, w.Amplitude, w.Offset, w.Points, w.Points/2), nil
+	case WaveLinear:
+		return fmt.Sprintf("WAV %d X LIN %d %.9f %.9f %d 0 0",
+			table, w.Points, w.Amplitude, w.Offset, w.Points), nil
+	default:
+		return "", fmt.Errorf("pi/gcs2: unknown waveform type %q, must be %s or %s", w.Type, WaveSine, WaveLinear)
+	}
+}
+
+// DefineWave defines wave table gen and connects it to wave generator gen
+func (c *Controller) DefineWave(gen int, w Waveform) error {
+	cmd, err := w.command(gen)
+	if err != nil {
+		return err
+	}
+	cmds := []string{cmd, fmt.Sprintf("WSL %d %d", gen, gen)}
+	if w.Rate > 0 {
+		cmds = append(cmds, fmt.Sprintf("WTR %d %d 0", gen, w.Rate))
+	}
+	return c.write(cmds...)
+}
+
+// StartWave starts wave generator gen, which plays its table cycles times.
+// Zero cycles plays it until StopWave is called
+func (c *Controller) StartWave(gen, cycles int) error {
+	if cycles < 0 {
+		return fmt.Errorf("pi/gcs2: number of wave cycles must be non-negative, got %d", cycles)
+	}
+	return c.write(fmt.Sprintf("WGC %d %d", gen, cycles), fmt.Sprintf("WGO %d 1", gen))
+}
+
+// StopWave stops wave generator gen
+func (c *Controller) StopWave(gen int) error {
+	return c.write(fmt.Sprintf("WGO %d 0", gen))
+}
+
+// GetWaveRunning returns true if wave generator gen is running
+func (c *Controller) GetWaveRunning(gen int) (bool, error) {
+	key := strconv.Itoa(gen)
+	resp, err := c.query("WGO? " + key)
+	if err != nil {
+		return false, err
+	}
+	resp = stripAxis(key, resp)
+	mode, err := strconv.Atoi(strings.TrimSpace(string(resp)))
+	if err != nil {
+		return false, err
+	}
+	return mode != 0, nil
+}
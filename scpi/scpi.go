@@ -27,6 +27,43 @@ type SCPI struct {
 	// where an error query is sent with every message
 	// to ensure the device accepted the input
 	Handshaking bool
+
+	// RxTerm and TxTerm end the lines read from and written to the device.
+	// Empty is a newline
+	RxTerm, TxTerm string
+
+	// debug receives the raw traffic, see SetDebug
+	debug io.Writer
+}
+
+// terminators returns the line endings of the device
+func (s *SCPI) terminators() (string, string) {
+	rx, tx := s.RxTerm, s.TxTerm
+	if rx == "" {
+		rx = "\n"
+	}
+	if tx == "" {
+		tx = "\n"
+	}
+	return rx, tx
+}
+
+// SetDebug logs every line sent to and received from the device to w, with
+// its terminator.  nil turns logging off
+func (s *SCPI) SetDebug(w io.Writer) {
+	s.debug = w
+}
+
+// Reconnect drains Pool
+func (s *SCPI) Reconnect() error {
+	s.Pool.Drain()
+	return nil
+}
+
+// Shutdown closes Pool
+func (s *SCPI) Shutdown() error {
+	s.Pool.Close()
+	return nil
 }
 
 // Write sends a command to the device.  if f.Handshaking == true,
@@ -39,7 +76,10 @@ func (s *SCPI) Write(cmds ...string) error {
 	}
 	defer func() { s.Pool.ReturnWithError(conn, err) }()
 	var wrap io.ReadWriter
-	wrap = comm.NewTerminator(conn, '\n', '\n')
+	rx, tx := s.terminators()
+	term := comm.NewLineTerminator(conn, rx, tx)
+	term.Debug = s.debug
+	wrap = term
 	wrap, err = comm.NewTimeout(wrap, timeout)
 	if err != nil {
 		return err
@@ -78,7 +118,10 @@ func (s *SCPI) WriteRead(cmds ...string) ([]byte, error) {
 	}
 	defer func() { s.Pool.ReturnWithError(conn, err) }()
 	var wrap io.ReadWriter
-	wrap = comm.NewTerminator(conn, '\n', '\n')
+	rx, tx := s.terminators()
+	term := comm.NewLineTerminator(conn, rx, tx)
+	term.Debug = s.debug
+	wrap = term
 	wrap, err = comm.NewTimeout(wrap, timeout)
 	if err != nil {
 		return resp, err
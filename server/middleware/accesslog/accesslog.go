@@ -0,0 +1,198 @@
+// Package accesslog provides a middleware which logs every request as one
+// line of JSON, for an audit trail of who commanded what.
+//
+// Each line holds the time, method, path, remote address, client ID (from the
+// X-Client-ID header), status, response size, and duration of the request.
+// The bodies of write requests are logged only for the routes named in
+// Log.Bodies, so that commands such as /factory-reset or /emission can be
+// recorded in full without also logging every image upload
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBody is the most bytes of a request body logged by default
+const DefaultMaxBody = 4096
+
+// Entry is one line of the access log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Query     string    `json:"query,omitempty"`
+	Remote    string    `json:"remote"`
+	Forwarded string    `json:"forwarded,omitempty"`
+	ClientID  string    `json:"clientId,omitempty"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+
+	// DurationMs is the time taken to respond, in milliseconds
+	DurationMs float64 `json:"durationMs"`
+
+	// Body is the request body, for the routes in Log.Bodies
+	Body string `json:"body,omitempty"`
+
+	// BodyTruncated is true if Body was cut off at Log.MaxBody
+	BodyTruncated bool `json:"bodyTruncated,omitempty"`
+}
+
+// Log is a middleware which writes an Entry for every request
+type Log struct {
+	// Out receives one line of JSON per request
+	Out io.Writer
+
+	// Bodies is a list of path fragments.  The bodies of requests other than
+	// GET, HEAD, and OPTIONS whose path contains any of them are logged
+	Bodies []string
+
+	// MaxBody is the most bytes of a body which are logged.  Zero is
+	// DefaultMaxBody
+	MaxBody int
+
+	mu sync.Mutex
+}
+
+// New returns a new Log which writes to out and logs the bodies of write
+// requests to paths containing any of bodies
+func New(out io.Writer, bodies ...string) *Log {
+	return &Log{Out: out, Bodies: bodies}
+}
+
+// logsBody returns true if the body of r should be logged
+func (l *Log) logsBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	for _, str := range l.Bodies {
+		if strings.Contains(r.URL.Path, str) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check wraps next with the log
+func (l *Log) Check(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		e := Entry{
+			Time:      start,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Query:     r.URL.RawQuery,
+			Remote:    r.RemoteAddr,
+			Forwarded: r.Header.Get("X-Forwarded-For"),
+			ClientID:  r.Header.Get("X-Client-ID"),
+		}
+		var body *capturedBody
+		if r.Body != nil && r.Body != http.NoBody && l.logsBody(r) {
+			max := l.MaxBody
+			if max <= 0 {
+				max = DefaultMaxBody
+			}
+			body = &capturedBody{ReadCloser: r.Body, max: max}
+			r.Body = body
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			e.Status = sw.status
+			if e.Status == 0 {
+				e.Status = http.StatusOK
+			}
+			e.Bytes = sw.n
+			e.DurationMs = float64(time.Since(start)) / float64(time.Millisecond)
+			if body != nil {
+				e.Body = string(body.buf)
+				e.BodyTruncated = body.truncated
+			}
+			l.write(e)
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// write writes e to Out as one line
+func (l *Log) write(e Entry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Out.Write(b)
+}
+
+// capturedBody keeps the first max bytes read from the body
+type capturedBody struct {
+	io.ReadCloser
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.max - len(b.buf); room > 0 {
+		if n > room {
+			b.buf = append(b.buf, p[:room]...)
+			b.truncated = true
+		} else {
+			b.buf = append(b.buf, p[:n]...)
+		}
+	} else if n > 0 {
+		b.truncated = true
+	}
+	return n, err
+}
+
+// statusWriter records the status and size of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+// Flush passes through to the underlying writer, for streamed responses
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for websocket upgrades, which are logged
+// as 101 Switching Protocols
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("accesslog: the underlying ResponseWriter does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
@@ -0,0 +1,58 @@
+package accesslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/server/middleware/accesslog"
+)
+
+// echo reads the body and responds 201 with it
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+})
+
+func TestAccessLog(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := accesslog.New(out, "/factory-reset")
+	l.MaxBody = 8
+	h := l.Check(echo)
+	cases := []struct {
+		name, method, path, body string
+		want                     string
+		truncated                bool
+	}{
+		{"opted in", http.MethodPost, "/laser/factory-reset", `{"bool":true}`, `{"bool":`, true},
+		{"not opted in", http.MethodPost, "/camera/defects", `{"a":1}`, "", false},
+		{"read", http.MethodGet, "/laser/factory-reset", "", "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out.Reset()
+			req := httptest.NewRequest(c.method, c.path+"?x=1", strings.NewReader(c.body))
+			req.Header.Set("X-Forwarded-For", "10.0.0.7")
+			req.Header.Set("X-Client-ID", "script-a")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			var e accesslog.Entry
+			if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+				t.Fatalf("expected one line of JSON, got %q: %v", out.String(), err)
+			}
+			if e.Method != c.method || e.Path != c.path || e.Query != "x=1" || e.Status != http.StatusCreated {
+				t.Errorf("expected %s %s?x=1 201, got %+v", c.method, c.path, e)
+			}
+			if e.Remote == "" || e.Forwarded != "10.0.0.7" || e.ClientID != "script-a" || e.Bytes != int64(len(c.body)) {
+				t.Errorf("expected the remote, forwarded, client ID, and size to be logged, got %+v", e)
+			}
+			if e.Body != c.want || e.BodyTruncated != c.truncated {
+				t.Errorf("expected body %q (truncated %v), got %q (%v)", c.want, c.truncated, e.Body, e.BodyTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package bodylimit provides a middleware which bounds the size of request
+// bodies.
+//
+// A request which declares a Content-Length over the limit is refused with
+// 413 Request Entity Too Large before the handler runs.  Other bodies are
+// cut off at the limit; the handler sees a read error, and whatever status
+// it responds with is replaced by 413, so clients get the same answer either
+// way
+package bodylimit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMax is the largest request body accepted by default, 1 MiB.  This is
+// far more than any JSON command, and ample for defect maps
+const DefaultMax = 1 << 20
+
+// Limit is a middleware which bounds the size of request bodies
+type Limit struct {
+	// Max is the largest body accepted, in bytes.  Zero or negative disables
+	// the limit
+	Max int64
+}
+
+// New returns a new Limit of max bytes, or DefaultMax if max is zero
+func New(max int64) *Limit {
+	if max == 0 {
+		max = DefaultMax
+	}
+	return &Limit{Max: max}
+}
+
+// Check wraps next with the limit
+func (l *Limit) Check(next http.Handler) http.Handler {
+	if l.Max <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > l.Max {
+			http.Error(w, l.message(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, l.Max), max: l.Max}
+		r.Body = body
+		next.ServeHTTP(&limitedWriter{ResponseWriter: w, body: body, msg: l.message()}, r)
+	})
+}
+
+func (l *Limit) message() string {
+	return fmt.Sprintf("request body is larger than the limit of %d bytes", l.Max)
+}
+
+// limitedBody notes when the body was cut off at the limit
+type limitedBody struct {
+	io.ReadCloser
+	max      int64
+	n        int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF && b.n >= b.max {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// limitedWriter replaces the response with 413 once the body was cut off
+type limitedWriter struct {
+	http.ResponseWriter
+	body        *limitedBody
+	msg         string
+	wroteHeader bool
+	rejected    bool
+}
+
+func (w *limitedWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.body.exceeded {
+		w.rejected = true
+		hdr := w.ResponseWriter.Header()
+		hdr.Del("Content-Length")
+		hdr.Del("Content-Encoding")
+		http.Error(w.ResponseWriter, w.msg, http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		// the handler's own error message is dropped
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes through to the underlying writer, for streamed responses
+func (w *limitedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,49 @@
+package bodylimit_test
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/server/middleware/bodylimit"
+)
+
+// decoder responds 400 if the body is not a JSON string, as most handlers do
+var decoder = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var s string
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestBodyLimit(t *testing.T) {
+	h := bodylimit.New(16).Check(decoder)
+	big := `"` + strings.Repeat("a", 64) + `"`
+	cases := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"small", httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`"abc"`)), http.StatusOK},
+		{"declared", httptest.NewRequest(http.MethodPost, "/", strings.NewReader(big)), http.StatusRequestEntityTooLarge},
+		{"chunked", func() *http.Request {
+			// hide the length, as for a chunked upload
+			r := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(io.MultiReader(strings.NewReader(big))))
+			r.ContentLength = -1
+			return r
+		}(), http.StatusRequestEntityTooLarge},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, c.req)
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d: %s", c.name, c.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package compress provides a middleware which gzips responses for clients
+// that accept it.
+//
+// Content which is already compressed (JPEG, PNG, gzip, zip) and streaming
+// content (server-sent events and multipart/x-mixed-replace, as used for
+// MJPEG) is passed through unchanged, as is any response which sets its own
+// Content-Encoding.  Everything else, notably FITS and JSON, is compressed
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultSkip is the list of content type prefixes which are not compressed
+var DefaultSkip = []string{
+	"image/jpeg",
+	"image/png",
+	"application/gzip",
+	"application/zip",
+	"text/event-stream",
+	"multipart/x-mixed-replace",
+}
+
+// Gzip is a middleware which compresses responses with gzip
+type Gzip struct {
+	// Level is the gzip compression level, gzip.DefaultCompression if zero
+	Level int
+
+	// Skip is the list of content type prefixes not to compress
+	Skip []string
+}
+
+// New returns a new Gzip at the default level which skips DefaultSkip
+func New() *Gzip {
+	return &Gzip{Level: gzip.DefaultCompression, Skip: DefaultSkip}
+}
+
+// Check compresses the response of next if the client accepts gzip
+func (g *Gzip) Check(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipWriter{ResponseWriter: w, g: g}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(strings.SplitN(enc, ";", 2)[0])
+		if enc == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter decides whether to compress when the header is written, based
+// on the content type the handler set
+type gzipWriter struct {
+	http.ResponseWriter
+	g *Gzip
+
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	hdr := w.Header()
+	if hdr.Get("Content-Encoding") != "" {
+		return
+	}
+	ctype := hdr.Get("Content-Type")
+	for _, s := range w.g.Skip {
+		if strings.HasPrefix(ctype, s) {
+			return
+		}
+	}
+	gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.g.Level)
+	if err != nil {
+		return
+	}
+	w.gz = gz
+	hdr.Set("Content-Encoding", "gzip")
+	hdr.Del("Content-Length")
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		// no body to compress
+		w.decided = true
+	}
+	w.decide()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.decide()
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, flushing the compressor first
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for websocket upgrades, which are never
+// compressed
+func (w *gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("compress: the underlying ResponseWriter does not support hijacking")
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
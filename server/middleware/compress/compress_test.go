@@ -0,0 +1,64 @@
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/server/middleware/compress"
+)
+
+func serve(ctype string, body []byte, acceptGzip bool) *httptest.ResponseRecorder {
+	h := compress.New().Check(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ctype)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptGzip {
+		req.Header.Set("Accept-Encoding", "deflate, gzip;q=1.0")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestFITSIsCompressed(t *testing.T) {
+	body := bytes.Repeat([]byte{0, 1, 2, 3}, 4096)
+	w := serve("image/fits", body, true)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("expected a gzip encoded response")
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Error("decompressed body does not match")
+	}
+}
+
+func TestSkippedAndUnacceptedAreUnchanged(t *testing.T) {
+	body := []byte("data: {}\n\n")
+	for _, c := range []struct {
+		ctype  string
+		accept bool
+	}{
+		{"text/event-stream", true},
+		{"image/jpeg", true},
+		{"multipart/x-mixed-replace; boundary=frame", true},
+		{"image/fits", false},
+	} {
+		w := serve(c.ctype, body, c.accept)
+		if w.Header().Get("Content-Encoding") != "" || !bytes.Equal(w.Body.Bytes(), body) {
+			t.Errorf("%s (accept gzip %v): expected the body to pass through unchanged", c.ctype, c.accept)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package cors provides a middleware which lets browser pages from other
+// origins use the server.
+//
+// Browsers send an Origin header with cross-origin requests, and a preflight
+// OPTIONS request before any which is not a simple GET or POST.  Requests from
+// an allowed origin are answered with the Access-Control-Allow-* headers the
+// browser needs; the preflight is answered by the middleware itself.  Requests
+// from other origins get no such headers, so the browser refuses them
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultMethods are the methods allowed by New
+var DefaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+
+// DefaultHeaders are the request headers allowed by New
+var DefaultHeaders = []string{"Content-Type", "Accept", "X-Lock-Token", "X-Client-ID"}
+
+// DefaultExpose are the response headers New lets pages read: those which
+// describe the body of /array, those which qualify the result of a move or
+// an acquisition, and Retry-After of a lock which timed out waiting
+var DefaultExpose = []string{
+	"X-Array-Width", "X-Array-Height", "X-Array-Bitdepth", "X-Array-Dtype",
+	"X-Motion-Clamped", "X-Bracket-Warnings", "X-Dark-Subtracted", "X-Defects-Masked",
+	"Retry-After"}
+
+// CORS is a middleware which answers cross-origin requests
+type CORS struct {
+	// Origins are the allowed origins, e.g. https://dash.lab:8080.  "*"
+	// allows any origin
+	Origins []string
+
+	// Methods and Headers are the methods and request headers allowed
+	Methods []string
+	Headers []string
+
+	// Expose are the response headers, beyond the basic ones, which pages
+	// may read
+	Expose []string
+
+	// MaxAge is how long browsers may cache a preflight, in seconds
+	MaxAge int
+}
+
+// New returns a new CORS which allows origins with the default methods and
+// headers, caching preflights for ten minutes
+func New(origins []string) *CORS {
+	return &CORS{Origins: origins, Methods: DefaultMethods, Headers: DefaultHeaders, Expose: DefaultExpose, MaxAge: 600}
+}
+
+// allowed returns true if origin may use the server
+func (c *CORS) allowed(origin string) bool {
+	for _, o := range c.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check wraps next with CORS handling
+func (c *CORS) Check(next http.Handler) http.Handler {
+	methods := strings.Join(c.Methods, ", ")
+	headers := strings.Join(c.Headers, ", ")
+	expose := strings.Join(c.Expose, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		hdr := w.Header()
+		hdr.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !c.allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		hdr.Set("Access-Control-Allow-Origin", origin)
+		if !preflight {
+			if expose != "" {
+				hdr.Set("Access-Control-Expose-Headers", expose)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		hdr.Set("Access-Control-Allow-Methods", methods)
+		hdr.Set("Access-Control-Allow-Headers", headers)
+		if c.MaxAge > 0 {
+			hdr.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
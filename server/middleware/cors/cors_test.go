@@ -0,0 +1,42 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/server/middleware/cors"
+)
+
+func TestCORS(t *testing.T) {
+	called := false
+	h := cors.New([]string{"http://dash.lab"}).Check(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// preflight from an allowed origin is answered without the handler
+	r := httptest.NewRequest(http.MethodOptions, "/stage/pos", nil)
+	r.Header.Set("Origin", "http://dash.lab")
+	r.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent || called {
+		t.Errorf("expected the preflight to be answered with 204, got %d (handler called: %v)", w.Code, called)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "http://dash.lab" {
+		t.Errorf("expected the origin to be allowed, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Error("expected the allowed methods in the preflight response")
+	}
+
+	// other origins get no CORS headers
+	r = httptest.NewRequest(http.MethodPost, "/stage/pos", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Origin for another origin, got %q", got)
+	}
+}
@@ -2,24 +2,53 @@
 package locker
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"go/types"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/nasa-jpl/golaborate/generichttp"
 )
 
+// TokenHeader is the HTTP header which carries a lock token
+const TokenHeader = "X-Lock-Token"
+
+// AdminHeader is the HTTP header which carries the admin token, see
+// Locker.AdminToken
+const AdminHeader = "X-Admin-Token"
+
+// ErrNotOwner is returned when an operation on a lock is attempted without
+// the token of the client which holds it
+var ErrNotOwner = errors.New("locker: the lock is held by another client")
+
+// ErrQueueTimeout is returned when a queued request waits longer than the
+// maximum time for a lock to free
+var ErrQueueTimeout = errors.New("locker: timed out waiting for the lock")
+
 // Inject adds a lock route to a generichttp.HTTPer which is used to manipulate the locker
 func Inject(other generichttp.HTTPer, l ManipulableLock) {
 	rt := other.RT()
 	if al, ok := (l).(*AxisLocker); ok {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/lock"}] = al.HTTPGet
 		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/lock"}] = al.HTTPSet
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/lock/status"}] = al.HTTPStatus
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/lock/force-release"}] = al.HTTPForceRelease
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/lock/transfer"}] = al.HTTPTransfer
 	} else {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/lock"}] = l.HTTPGet
 		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/lock"}] = l.HTTPSet
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/lock/status"}] = l.HTTPStatus
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/lock/force-release"}] = l.HTTPForceRelease
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/lock/transfer"}] = l.HTTPTransfer
 	}
 }
 
@@ -33,56 +62,359 @@ type ManipulableLock interface {
 
 	// Set changes the status of the lock
 	HTTPSet(http.ResponseWriter, *http.Request)
+
+	// HTTPStatus returns who holds the lock and since when
+	HTTPStatus(http.ResponseWriter, *http.Request)
+
+	// HTTPForceRelease unlocks the lock for its holder or an administrator
+	HTTPForceRelease(http.ResponseWriter, *http.Request)
+
+	// HTTPTransfer hands the lock to another client
+	HTTPTransfer(http.ResponseWriter, *http.Request)
+}
+
+// Status describes the state of a lock
+type Status struct {
+	// Locked is true if the lock is held
+	Locked bool `json:"locked"`
+
+	// Holder identifies the client which holds the lock
+	Holder string `json:"holder,omitempty"`
+
+	// Since is when the lock was acquired
+	Since *time.Time `json:"since,omitempty"`
+
+	// Expires is when the lock will expire, if it has a TTL
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// Queued is the number of requests waiting for the lock
+	Queued int `json:"queued"`
+}
+
+// Grant is the response to acquiring or being transferred a lock
+type Grant struct {
+	// Token must be sent in the X-Lock-Token header of every request to the
+	// locked resource, and to release the lock
+	Token string `json:"token"`
+}
+
+// ClientID identifies the client making a request, for the purpose of
+// reporting who holds a lock.  The X-Client-ID header is used if present,
+// otherwise the remote address
+func ClientID(r *http.Request) string {
+	if id := r.Header.Get("X-Client-ID"); id != "" {
+		return id
+	}
+	return r.RemoteAddr
+}
+
+// newToken returns a random token
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 // Locker is a type which behaves like a sync.Mutex without the blocking,
-// and holds a list of routes (Goji patterns) to not protext
+// and holds a list of routes (Goji patterns) to not protext.
+//
+// Acquiring the lock produces a token.  While locked, only requests which
+// carry the token in the X-Lock-Token header are let through.  Others are
+// rejected with 423, or if QueueWait is nonzero, wait their turn
 type Locker struct {
+	mu       sync.Mutex
 	isLocked bool
+	holder   string
+	token    string
+	since    time.Time
+
+	// waiters are the channels of queued requests, in arrival order.  Only
+	// the first is woken when the lock frees
+	waiters []chan struct{}
+
+	// QueueWait is the longest a request to a locked resource waits for the
+	// lock to free.  Zero means requests are rejected immediately
+	QueueWait time.Duration
+
+	// TTL is how long a lock is held before it expires on its own.  Zero
+	// means locks never expire.  Re-acquiring the lock with its token
+	// renews it
+	TTL time.Duration
+
+	// AdminToken lets requests which carry it in the X-Admin-Token header
+	// force the release of a lock held by anyone.  Empty means only the
+	// holder may force a release
+	AdminToken string
 
 	// DoNotProtect is a list of paths not to apply the lock to
 	DoNotProtect []string
 }
 
-// New returns a new Locker with DoNotProtect prepopulated with "lock"
+// New returns a new Locker with DoNotProtect prepopulated with "lock" and
+// "emergency-stop", so that a held lock never prevents an emergency stop
 func New() *Locker {
-	return &Locker{DoNotProtect: []string{"lock"}}
+	return &Locker{DoNotProtect: []string{"lock", "emergency-stop"}}
 }
 
 // Lock the locker
 func (l *Locker) Lock() {
-	l.isLocked = true
+	l.LockBy("")
 }
 
-// Unlock the locker
+// LockBy locks the locker on behalf of holder, taking it from any present
+// holder, and returns the new token
+func (l *Locker) LockBy(holder string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lock(holder, newToken())
+	return l.token
+}
+
+// Acquire locks the locker on behalf of holder.  If the locker is already
+// locked, token must be the present token, and the lock is renewed.  The
+// token to use from now on is returned
+func (l *Locker) Acquire(holder, token string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	if l.isLocked {
+		if !l.holds(token) {
+			return "", ErrNotOwner
+		}
+		l.lock(holder, l.token)
+		return l.token, nil
+	}
+	l.lock(holder, newToken())
+	return l.token, nil
+}
+
+// Release unlocks the locker.  token must be the present token
+func (l *Locker) Release(token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	if l.isLocked && !l.holds(token) {
+		return ErrNotOwner
+	}
+	l.unlock()
+	return nil
+}
+
+// ForceRelease unlocks the locker if token is the present token, or admin is
+// AdminToken
+func (l *Locker) ForceRelease(token, admin string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	isAdmin := l.AdminToken != "" && subtle.ConstantTimeCompare([]byte(admin), []byte(l.AdminToken)) == 1
+	if l.isLocked && !l.holds(token) && !isAdmin {
+		return ErrNotOwner
+	}
+	l.unlock()
+	return nil
+}
+
+// Transfer hands the lock to holder.  token must be the present token.  The
+// old token is invalidated and the new one returned, to be passed on to the
+// new holder.  The TTL, if any, restarts
+func (l *Locker) Transfer(token, holder string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	if !l.isLocked || !l.holds(token) {
+		return "", ErrNotOwner
+	}
+	l.lock(holder, newToken())
+	return l.token, nil
+}
+
+// Allows returns true if the locker is unlocked, or token is the present token
+func (l *Locker) Allows(token string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	return !l.isLocked || l.holds(token)
+}
+
+// Unlock the locker, regardless of who holds it
 func (l *Locker) Unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unlock()
+}
+
+// holds returns true if token is the present token, comparing in constant
+// time.  mu must be held
+func (l *Locker) holds(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(l.token)) == 1
+}
+
+// lock locks without taking mu
+func (l *Locker) lock(holder, token string) {
+	l.isLocked = true
+	l.holder = holder
+	l.token = token
+	l.since = time.Now()
+}
+
+// unlock unlocks without taking mu, and wakes the first queued request
+func (l *Locker) unlock() {
 	l.isLocked = false
+	l.holder = ""
+	l.token = ""
+	l.since = time.Time{}
+	l.wakeHead()
+}
+
+// wakeHead wakes the request at the head of the queue, if the lock is free.
+// mu must be held
+func (l *Locker) wakeHead() {
+	if l.isLocked || len(l.waiters) == 0 {
+		return
+	}
+	select {
+	case l.waiters[0] <- struct{}{}:
+	default:
+		// already woken
+	}
+}
+
+// dequeue removes ch from the queue.  mu must be held
+func (l *Locker) dequeue(ch chan struct{}) {
+	for i, c := range l.waiters {
+		if c == ch {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Wait blocks until the locker allows token, ctx is done, or QueueWait
+// passes, in which case ErrQueueTimeout is returned.  Requests are let
+// through one at a time in the order they began to wait; each wakes the next
+// as it leaves the queue
+func (l *Locker) Wait(ctx context.Context, token string) error {
+	deadline := time.NewTimer(l.QueueWait)
+	defer deadline.Stop()
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.waiters = append(l.waiters, ch)
+	defer func() {
+		l.mu.Lock()
+		l.dequeue(ch)
+		l.wakeHead()
+		l.mu.Unlock()
+	}()
+	for {
+		l.expire()
+		if token != "" && l.holds(token) || !l.isLocked && l.waiters[0] == ch {
+			l.mu.Unlock()
+			return nil
+		}
+		// a lock with a TTL expires without anyone unlocking it, so check
+		// back when it is due to
+		expiry := time.NewTimer(time.Hour)
+		if l.isLocked && l.TTL > 0 {
+			expiry.Reset(time.Until(l.since.Add(l.TTL)) + time.Millisecond)
+		}
+		l.mu.Unlock()
+		var err error
+		select {
+		case <-ch:
+		case <-expiry.C:
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-deadline.C:
+			err = ErrQueueTimeout
+		}
+		expiry.Stop()
+		if err != nil {
+			return err
+		}
+		l.mu.Lock()
+	}
+}
+
+// expire releases the lock if its TTL has passed.  mu must be held
+func (l *Locker) expire() {
+	if l.isLocked && l.TTL > 0 && time.Since(l.since) > l.TTL {
+		l.unlock()
+	}
 }
 
 // Locked returns true if the locker is locked
 func (l *Locker) Locked() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
 	return l.isLocked
 }
 
-// Check is an HTTP middleware that returns http.StatusLocked if Locked() is true, otherwise passes down the line
+// Status returns the state of the lock
+func (l *Locker) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	s := Status{Locked: l.isLocked, Queued: len(l.waiters)}
+	if l.isLocked {
+		since := l.since
+		s.Holder = l.holder
+		s.Since = &since
+		if l.TTL > 0 {
+			exp := since.Add(l.TTL)
+			s.Expires = &exp
+		}
+	}
+	return s
+}
+
+// protects returns true if the lock applies to the path
+func (l *Locker) protects(path string) bool {
+	for _, str := range l.DoNotProtect {
+		if strings.Contains(path, str) {
+			return false
+		}
+	}
+	return true
+}
+
+// admit returns true if the request may proceed.  If not, a response has
+// been written: 423 if the locker does not queue, 503 with Retry-After if the
+// queue wait timed out
+func (l *Locker) admit(w http.ResponseWriter, r *http.Request) bool {
+	if !l.protects(r.URL.Path) {
+		return true
+	}
+	token := r.Header.Get(TokenHeader)
+	if l.Allows(token) {
+		return true
+	}
+	if l.QueueWait <= 0 {
+		w.WriteHeader(http.StatusLocked)
+		w.Write([]byte("Access denied\n"))
+		return false
+	}
+	err := l.Wait(r.Context(), token)
+	switch err {
+	case nil:
+		return true
+	case ErrQueueTimeout:
+		w.Header().Set("Retry-After", strconv.Itoa(int(l.QueueWait.Seconds()+1)))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		// the client went away, no one to respond to
+	}
+	return false
+}
+
+// Check is an HTTP middleware that returns http.StatusLocked if the locker
+// is locked and the request does not carry the token, otherwise passes down
+// the line.  If QueueWait is nonzero, the request waits for the lock instead
 func (l *Locker) Check(next http.Handler) http.Handler {
 	// return a handlerfunc wrapping a handler, middleware/generator pattern
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if l.Locked() {
-			// check if the path is protected
-			protected := true
-			url := r.URL.Path
-			for _, str := range l.DoNotProtect {
-				if strings.Contains(url, str) {
-					protected = false
-				}
-			}
-			// if it is, bounce the request - locked
-			if protected {
-				w.WriteHeader(http.StatusLocked)
-				w.Write([]byte("Access denied\n"))
-				return
-			}
+		if !l.admit(w, r) {
+			return
 		}
 		next.ServeHTTP(w, r)
 	})
@@ -90,26 +422,95 @@ func (l *Locker) Check(next http.Handler) http.Handler {
 
 // HTTPSet calls Lock or Unlock based on json:bool on the request body
 func (l *Locker) HTTPSet(w http.ResponseWriter, r *http.Request) {
+	httpSet(l, w, r)
+}
+
+// HTTPStatus returns Status() over HTTP as JSON
+func (l *Locker) HTTPStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, l.Status())
+}
+
+// HTTPForceRelease unlocks the locker for a request carrying its token, or
+// AdminToken in the X-Admin-Token header, and fails with 403 otherwise.  It is
+// meant for administrators to recover from a client which crashed while
+// holding the lock
+func (l *Locker) HTTPForceRelease(w http.ResponseWriter, r *http.Request) {
+	httpForceRelease(l, w, r)
+}
+
+// HTTPTransfer transfers the lock to the holder named in the body,
+// {"holder": "..."}, and responds with the new token
+func (l *Locker) HTTPTransfer(w http.ResponseWriter, r *http.Request) {
+	httpTransfer(l, w, r)
+}
+
+// HTTPGet returns Locked() over HTTP as JSON
+func (l *Locker) HTTPGet(w http.ResponseWriter, r *http.Request) {
+	b := l.Locked()
+	hp := generichttp.HumanPayload{T: types.Bool, Bool: b}
+	hp.EncodeAndRespond(w, r)
+	return
+}
+
+// httpSet acquires the lock and responds with a Grant on {"bool": true}, or
+// releases it on {"bool": false}.  Either requires the token if the lock is
+// held, and fails with 423 otherwise
+func httpSet(l *Locker, w http.ResponseWriter, r *http.Request) {
 	b := generichttp.BoolT{}
 	err := json.NewDecoder(r.Body).Decode(&b)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if b.Bool {
-		l.Lock()
-	} else {
-		l.Unlock()
+	token := r.Header.Get(TokenHeader)
+	if !b.Bool {
+		if err = l.Release(token); err != nil {
+			http.Error(w, err.Error(), http.StatusLocked)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	token, err = l.Acquire(ClientID(r), token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	}
+	writeJSON(w, Grant{Token: token})
+}
+
+func httpForceRelease(l *Locker, w http.ResponseWriter, r *http.Request) {
+	err := l.ForceRelease(r.Header.Get(TokenHeader), r.Header.Get(AdminHeader))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-// HTTPGet returns Locked() over HTTP as JSON
-func (l *Locker) HTTPGet(w http.ResponseWriter, r *http.Request) {
-	b := l.Locked()
-	hp := generichttp.HumanPayload{T: types.Bool, Bool: b}
-	hp.EncodeAndRespond(w, r)
-	return
+func httpTransfer(l *Locker, w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Holder string `json:"holder"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token, err := l.Transfer(r.Header.Get(TokenHeader), req.Holder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	}
+	writeJSON(w, Grant{Token: token})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
 // NewAL returns a new axis locker
@@ -119,8 +520,40 @@ func NewAL() *AxisLocker {
 
 // AxisLocker is a Locker, but for multi-axis devices, enabling granular locks (per-axis)
 type AxisLocker struct {
+	mu sync.Mutex
+
 	// locked maps if the axes are locked (true) or not (false)
 	locked map[string]*Locker
+
+	// TTL is the TTL given to each axis' Locker
+	TTL time.Duration
+
+	// QueueWait is the QueueWait given to each axis' Locker
+	QueueWait time.Duration
+
+	// AdminToken is the AdminToken given to each axis' Locker
+	AdminToken string
+}
+
+// get returns the locker for an axis, creating it if needed
+func (al *AxisLocker) get(axis string) *Locker {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	locked, ok := al.locked[axis]
+	if !ok {
+		locked = New()
+		locked.TTL = al.TTL
+		locked.QueueWait = al.QueueWait
+		locked.AdminToken = al.AdminToken
+		al.locked[axis] = locked
+	}
+	return locked
+}
+
+// Axis returns the Locker of one axis, for routes which move an axis that is
+// not in their URL
+func (al *AxisLocker) Axis(axis string) *Locker {
+	return al.get(axis)
 }
 
 // Check is an HTTP middleware that implements the locker
@@ -143,25 +576,8 @@ func (al *AxisLocker) Check(next http.Handler) http.Handler {
 			}
 		}()
 		axis := chi.URLParam(r, "axis")
-		locked, ok := al.locked[axis]
-		if !ok {
-			al.locked[axis] = New()
-			locked = al.locked[axis]
-		}
-		if locked.isLocked {
-			// check if the path is protected
-			protected := true
-			url := r.URL.Path
-			for _, str := range locked.DoNotProtect {
-				if strings.Contains(url, str) {
-					protected = false
-				}
-			}
-			// if it is, bounce the request - locked
-			if protected {
-				w.WriteHeader(http.StatusLocked)
-				return
-			}
+		if !al.get(axis).admit(w, r) {
+			return
 		}
 		next.ServeHTTP(w, r)
 	})
@@ -169,36 +585,29 @@ func (al *AxisLocker) Check(next http.Handler) http.Handler {
 
 // HTTPSet calls Lock or Unlock based on json:bool on the request body
 func (al *AxisLocker) HTTPSet(w http.ResponseWriter, r *http.Request) {
-	b := generichttp.BoolT{}
-	err := json.NewDecoder(r.Body).Decode(&b)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	axis := chi.URLParam(r, "axis")
-	locked, ok := al.locked[axis]
-	if !ok {
-		al.locked[axis] = New()
-		locked = al.locked[axis]
-	}
-	if b.Bool {
-		locked.Lock()
-	} else {
-		locked.Unlock()
-	}
-	w.WriteHeader(http.StatusOK)
+	httpSet(al.get(chi.URLParam(r, "axis")), w, r)
 }
 
 // HTTPGet returns Locked() over HTTP as JSON
 func (al *AxisLocker) HTTPGet(w http.ResponseWriter, r *http.Request) {
-	axis := chi.URLParam(r, "axis")
-	locked, ok := al.locked[axis]
-	if !ok {
-		al.locked[axis] = New()
-		locked = al.locked[axis]
-	}
+	locked := al.get(chi.URLParam(r, "axis"))
 	b := locked.Locked()
 	hp := generichttp.HumanPayload{T: types.Bool, Bool: b}
 	hp.EncodeAndRespond(w, r)
 	return
 }
+
+// HTTPStatus returns the Status() of an axis over HTTP as JSON
+func (al *AxisLocker) HTTPStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, al.get(chi.URLParam(r, "axis")).Status())
+}
+
+// HTTPForceRelease unlocks an axis for its holder or an administrator
+func (al *AxisLocker) HTTPForceRelease(w http.ResponseWriter, r *http.Request) {
+	httpForceRelease(al.get(chi.URLParam(r, "axis")), w, r)
+}
+
+// HTTPTransfer transfers the lock on an axis to another holder
+func (al *AxisLocker) HTTPTransfer(w http.ResponseWriter, r *http.Request) {
+	httpTransfer(al.get(chi.URLParam(r, "axis")), w, r)
+}
@@ -0,0 +1,255 @@
+package locker_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server/middleware/locker"
+)
+
+// node is a generichttp.HTTPer with a single protected route
+type node struct{ rt generichttp.RouteTable }
+
+func (n node) RT() generichttp.RouteTable { return n.rt }
+
+func newNode(l locker.ManipulableLock) *httptest.Server {
+	n := node{rt: generichttp.RouteTable{
+		{Method: http.MethodGet, Path: "/thing"}: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}}}
+	locker.Inject(n, l)
+	r := chi.NewRouter()
+	r.Use(l.Check)
+	n.RT().Bind(r)
+	return httptest.NewServer(r)
+}
+
+func do(t *testing.T, method, url, body string, hdr map[string]string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	if body != "" {
+		req, err = http.NewRequest(method, url, strings.NewReader(body))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestStatusAndForceRelease(t *testing.T) {
+	l := locker.New()
+	l.AdminToken = "sesame"
+	srv := newNode(l)
+	defer srv.Close()
+
+	resp := do(t, http.MethodPost, srv.URL+"/lock", `{"bool":true}`, map[string]string{"X-Client-ID": "script-a"})
+	resp.Body.Close()
+	resp = do(t, http.MethodGet, srv.URL+"/thing", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusLocked {
+		t.Fatalf("expected 423 while locked, got %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/lock/status", "", nil)
+	var s locker.Status
+	err := json.NewDecoder(resp.Body).Decode(&s)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Locked || s.Holder != "script-a" || s.Since == nil {
+		t.Errorf("expected the lock to be held by script-a with a time, got %+v", s)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/lock/force-release", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !l.Locked() {
+		t.Errorf("expected force-release without a token to be refused, got %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, srv.URL+"/lock/force-release", "", map[string]string{locker.AdminHeader: "wrong"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !l.Locked() {
+		t.Errorf("expected force-release with the wrong admin token to be refused, got %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/lock/force-release", "", map[string]string{locker.AdminHeader: "sesame"})
+	resp.Body.Close()
+	if l.Locked() {
+		t.Error("expected force-release with the admin token to unlock")
+	}
+}
+
+func TestTTLExpires(t *testing.T) {
+	l := locker.New()
+	l.TTL = 10 * time.Millisecond
+	l.LockBy("someone")
+	if !l.Locked() {
+		t.Fatal("expected the lock to be held before the TTL")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if l.Locked() {
+		t.Error("expected the lock to expire after the TTL")
+	}
+}
+
+func TestTokenOwnershipAndTransfer(t *testing.T) {
+	srv := newNode(locker.New())
+	defer srv.Close()
+
+	resp := do(t, http.MethodPost, srv.URL+"/lock", `{"bool":true}`, nil)
+	var g locker.Grant
+	err := json.NewDecoder(resp.Body).Decode(&g)
+	resp.Body.Close()
+	if err != nil || g.Token == "" {
+		t.Fatalf("expected a token acquiring the lock, got %+v (%v)", g, err)
+	}
+
+	// another client can neither unlock nor take it
+	resp = do(t, http.MethodPost, srv.URL+"/lock", `{"bool":false}`, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusLocked {
+		t.Errorf("expected 423 unlocking without the token, got %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, srv.URL+"/lock", `{"bool":true}`, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusLocked {
+		t.Errorf("expected 423 acquiring a held lock, got %d", resp.StatusCode)
+	}
+
+	// the holder can operate
+	resp = do(t, http.MethodGet, srv.URL+"/thing", "", map[string]string{locker.TokenHeader: g.Token})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 with the token, got %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/lock/transfer", `{"holder":"script-b"}`, map[string]string{locker.TokenHeader: g.Token})
+	var g2 locker.Grant
+	err = json.NewDecoder(resp.Body).Decode(&g2)
+	resp.Body.Close()
+	if err != nil || g2.Token == "" || g2.Token == g.Token {
+		t.Fatalf("expected a new token from the transfer, got %+v (%v)", g2, err)
+	}
+	resp = do(t, http.MethodGet, srv.URL+"/thing", "", map[string]string{locker.TokenHeader: g.Token})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusLocked {
+		t.Errorf("expected the old token to be rejected after transfer, got %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, srv.URL+"/lock", `{"bool":false}`, map[string]string{locker.TokenHeader: g2.Token})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the new holder to be able to release, got %d", resp.StatusCode)
+	}
+}
+
+func TestQueuedRequestsWaitForRelease(t *testing.T) {
+	l := locker.New()
+	l.QueueWait = time.Second
+	srv := newNode(l)
+	defer srv.Close()
+	token := l.LockBy("holder")
+
+	done := make(chan int)
+	go func() {
+		resp, err := http.Get(srv.URL + "/thing")
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := l.Release(token); err != nil {
+		t.Fatal(err)
+	}
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected the queued request to proceed after release, got %d", code)
+	}
+}
+
+func TestQueueWakesNextWhenHeadGivesUp(t *testing.T) {
+	l := locker.New()
+	l.QueueWait = time.Second
+	token := l.LockBy("holder")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	head := make(chan error, 1)
+	go func() { head <- l.Wait(ctx, "") }()
+	for l.Status().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	rest := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { rest <- l.Wait(context.Background(), "") }()
+	}
+	for l.Status().Queued != 3 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-head; err != context.Canceled {
+		t.Errorf("expected the cancelled head to give up, got %v", err)
+	}
+	if err := l.Release(token); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-rest; err != nil {
+			t.Errorf("expected queued request %d to proceed, got %v", i, err)
+		}
+	}
+	if q := l.Status().Queued; q != 0 {
+		t.Errorf("expected an empty queue, %d remain", q)
+	}
+}
+
+func TestQueueTimeoutIs503WithRetryAfter(t *testing.T) {
+	l := locker.New()
+	l.QueueWait = 20 * time.Millisecond
+	srv := newNode(l)
+	defer srv.Close()
+	l.LockBy("holder")
+	resp := do(t, http.MethodGet, srv.URL+"/thing", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after the queue wait, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+}
+
+func TestEmergencyStopBypassesLock(t *testing.T) {
+	l := locker.New()
+	stopped := false
+	n := node{rt: generichttp.RouteTable{
+		{Method: http.MethodPost, Path: "/emergency-stop"}: func(w http.ResponseWriter, r *http.Request) {
+			stopped = true
+		}}}
+	locker.Inject(n, l)
+	r := chi.NewRouter()
+	r.Use(l.Check)
+	n.RT().Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	l.LockBy("someone")
+	resp := do(t, http.MethodPost, srv.URL+"/emergency-stop", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !stopped {
+		t.Errorf("expected the emergency stop to go through a held lock, got %d", resp.StatusCode)
+	}
+}
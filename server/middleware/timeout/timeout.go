@@ -0,0 +1,158 @@
+// Package timeout provides a middleware which bounds how long a request may
+// take.
+//
+// The request context carries the deadline, so handlers and the device
+// operations beneath them which observe the context can stop early.  If the
+// deadline passes before the handler has written anything, the client
+// receives 503 Service Unavailable and whatever the handler writes afterwards
+// is discarded.  A handler which has begun its response is left to finish it,
+// as the status has already been sent.  Responses are written through as the
+// handler produces them, not buffered.
+//
+// Streaming responses, such as server-sent events and websockets, cannot be bounded this
+// way and are passed through untouched; see Timeout.Exclude
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout is a middleware which applies a deadline to requests
+type Timeout struct {
+	// Duration is the longest a request may take.  Zero or negative disables
+	// the timeout
+	Duration time.Duration
+
+	// Exclude is a list of path fragments; requests whose path contains any
+	// of them are not subject to the timeout
+	Exclude []string
+}
+
+// New returns a new Timeout of d, which excludes the streaming /events and
+// /raster routes
+func New(d time.Duration) *Timeout {
+	return &Timeout{Duration: d, Exclude: []string{"/events", "/raster"}}
+}
+
+// excluded returns true if r should not be subject to the timeout
+func (t *Timeout) excluded(r *http.Request) bool {
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return true
+	}
+	for _, str := range t.Exclude {
+		if strings.Contains(r.URL.Path, str) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check wraps next with the timeout
+func (t *Timeout) Check(next http.Handler) http.Handler {
+	if t.Duration <= 0 {
+		return next
+	}
+	msg := "request did not complete within " + t.Duration.String()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t.excluded(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), t.Duration)
+		defer cancel()
+		tw := &timeoutWriter{w: w, h: http.Header{}}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case <-done:
+			return
+		case p := <-panicked:
+			panic(p)
+		case <-ctx.Done():
+		}
+		tw.mu.Lock()
+		if !tw.wrote {
+			tw.timedOut = true
+			tw.mu.Unlock()
+			http.Error(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+		tw.mu.Unlock()
+		// the response has begun and w must not be abandoned
+		select {
+		case <-done:
+		case p := <-panicked:
+			panic(p)
+		}
+	})
+}
+
+// timeoutWriter passes writes through to w until the request times out,
+// after which they are discarded.  The handler sets headers on a map of its
+// own, copied to w when the response begins, so they do not race with the
+// 503 written at the deadline
+type timeoutWriter struct {
+	w http.ResponseWriter
+	h http.Header
+
+	mu       sync.Mutex
+	wrote    bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+// begin writes the header with code if it has not been.  mu must be held
+func (tw *timeoutWriter) begin(code int) {
+	if tw.wrote {
+		return
+	}
+	tw.wrote = true
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.timedOut {
+		tw.begin(code)
+	}
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.begin(http.StatusOK)
+	return tw.w.Write(p)
+}
+
+// Flush satisfies http.Flusher if the underlying writer does
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if f, ok := tw.w.(http.Flusher); ok && !tw.timedOut {
+		tw.begin(http.StatusOK)
+		f.Flush()
+	}
+}
@@ -0,0 +1,61 @@
+package timeout_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/server/middleware/timeout"
+)
+
+func TestTimeoutCancelsContext(t *testing.T) {
+	cancelled := make(chan bool, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+	})
+	h := timeout.New(20 * time.Millisecond).Check(slow)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after the timeout, got %d", w.Code)
+	}
+	if !<-cancelled {
+		t.Error("expected the request context to be cancelled")
+	}
+}
+
+func TestTimeoutExcludesStreams(t *testing.T) {
+	h := timeout.New(10 * time.Millisecond).Check(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/camera/events", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the event stream to be exempt, got %d", w.Code)
+	}
+}
+
+func TestTimeoutDoesNotBufferOrCutOffStartedResponses(t *testing.T) {
+	w := httptest.NewRecorder()
+	h := timeout.New(20 * time.Millisecond).Check(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Thing", "1")
+		rw.Write([]byte("a"))
+		// the first chunk reaches the client before the handler returns
+		if w.Body.String() != "a" {
+			t.Errorf("expected the first chunk to be written through, got %q", w.Body.String())
+		}
+		<-r.Context().Done()
+		rw.Write([]byte("b"))
+	}))
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ab" || w.Header().Get("X-Thing") != "1" {
+		t.Errorf("expected a started response to finish, got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+}
@@ -0,0 +1,91 @@
+// Package server contains helpers shared by the HTTP servers built on this
+// module.  Middleware lives in the subpackages of server/middleware
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QueryError is returned when a query parameter is present but malformed
+type QueryError struct {
+	// Name is the name of the parameter
+	Name string
+
+	// Value is the value as given
+	Value string
+
+	// Want describes the expected type, e.g. "an integer"
+	Want string
+}
+
+func (e QueryError) Error() string {
+	return fmt.Sprintf("query parameter %s=%q is not %s", e.Name, e.Value, e.Want)
+}
+
+// query returns the value of the parameter and whether it was given
+func query(r *http.Request, name string) (string, bool) {
+	s := r.URL.Query().Get(name)
+	return s, s != ""
+}
+
+// QueryInt returns the query parameter name as an int, or def if it is absent
+func QueryInt(r *http.Request, name string, def int) (int, error) {
+	s, ok := query(r, name)
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return def, QueryError{Name: name, Value: s, Want: "an integer"}
+	}
+	return i, nil
+}
+
+// QueryFloat returns the query parameter name as a float64, or def if it is
+// absent
+func QueryFloat(r *http.Request, name string, def float64) (float64, error) {
+	s, ok := query(r, name)
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return def, QueryError{Name: name, Value: s, Want: "a number"}
+	}
+	return f, nil
+}
+
+// QueryBool returns the query parameter name as a bool, or def if it is
+// absent.  Any value accepted by strconv.ParseBool may be used
+func QueryBool(r *http.Request, name string, def bool) (bool, error) {
+	s, ok := query(r, name)
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return def, QueryError{Name: name, Value: s, Want: "a boolean"}
+	}
+	return b, nil
+}
+
+// QueryDuration returns the query parameter name as a duration, or def if it
+// is absent.  The value is a Go duration such as "1.5s", or a bare number of
+// seconds
+func QueryDuration(r *http.Request, name string, def time.Duration) (time.Duration, error) {
+	s, ok := query(r, name)
+	if !ok {
+		return def, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def, QueryError{Name: name, Value: s, Want: "a duration"}
+	}
+	return d, nil
+}
@@ -0,0 +1,49 @@
+package server_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/server"
+)
+
+func TestQueryHelpers(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?i=3&f=2.5&b=true&d=1.5s&n=2&bad=x", nil)
+
+	if i, err := server.QueryInt(r, "i", 7); err != nil || i != 3 {
+		t.Errorf("QueryInt valid: got %d, %v", i, err)
+	}
+	if i, err := server.QueryInt(r, "missing", 7); err != nil || i != 7 {
+		t.Errorf("QueryInt missing: got %d, %v", i, err)
+	}
+	if f, err := server.QueryFloat(r, "f", 0); err != nil || f != 2.5 {
+		t.Errorf("QueryFloat valid: got %f, %v", f, err)
+	}
+	if b, err := server.QueryBool(r, "b", false); err != nil || !b {
+		t.Errorf("QueryBool valid: got %v, %v", b, err)
+	}
+	if d, err := server.QueryDuration(r, "d", 0); err != nil || d != 1500*time.Millisecond {
+		t.Errorf("QueryDuration valid: got %v, %v", d, err)
+	}
+	if d, err := server.QueryDuration(r, "n", 0); err != nil || d != 2*time.Second {
+		t.Errorf("QueryDuration bare seconds: got %v, %v", d, err)
+	}
+	if d, err := server.QueryDuration(r, "missing", time.Minute); err != nil || d != time.Minute {
+		t.Errorf("QueryDuration missing: got %v, %v", d, err)
+	}
+
+	malformed := []func() error{
+		func() error { _, err := server.QueryInt(r, "bad", 0); return err },
+		func() error { _, err := server.QueryFloat(r, "bad", 0); return err },
+		func() error { _, err := server.QueryBool(r, "bad", false); return err },
+		func() error { _, err := server.QueryDuration(r, "bad", 0); return err },
+	}
+	for i, f := range malformed {
+		var qe server.QueryError
+		if err := f(); !errors.As(err, &qe) || qe.Name != "bad" || qe.Value != "x" {
+			t.Errorf("case %d: expected a QueryError naming the parameter, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package websocket is a minimal server side implementation of RFC 6455,
+// enough to push messages to browsers.
+//
+// Only what the servers in this module need is supported: the server sends
+// unfragmented text frames, and frames from the client are read only to
+// answer pings and to notice when the client goes away
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptGUID is appended to the client's key to form the accept header
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// maxControl is the longest payload a control frame may carry
+const maxControl = 125
+
+// WriteTimeout bounds how long a write to the client may take
+var WriteTimeout = 5 * time.Second
+
+// ErrClosed is returned by writes after the connection has closed
+var ErrClosed = errors.New("websocket: connection closed")
+
+// IsUpgrade returns true if r asks to become a websocket
+func IsUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		headerContains(r.Header, "Connection", "upgrade")
+}
+
+func headerContains(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// accept returns the Sec-WebSocket-Accept value for key
+func accept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Conn is a websocket connection to one client
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	wmu  sync.Mutex
+	once sync.Once
+	done chan struct{}
+}
+
+// Upgrade turns the request into a websocket connection.  If it fails, an
+// error response has already been written to w
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) {
+		http.Error(w, "websocket: this route must be opened as a websocket", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket: only version 13 is supported", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "websocket: missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket: the connection cannot be taken over", http.StatusInternalServerError)
+		return nil, errors.New("websocket: ResponseWriter does not support hijacking")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept(key) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	if _, err = conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	c := &Conn{conn: conn, br: brw.Reader, done: make(chan struct{})}
+	go c.readLoop()
+	return c, nil
+}
+
+// Done is closed when the connection closes, from either end
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// readLoop discards data frames, answers pings, and closes the connection
+// when the client sends a close frame or the connection fails
+func (c *Conn) readLoop() {
+	defer c.shutdown()
+	for {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return
+		case opPing:
+			c.writeFrame(opPong, payload)
+		}
+	}
+}
+
+// readFrame reads one frame from the client, which must be masked
+func (c *Conn) readFrame() (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	op := hdr[0] & 0x0F
+	if hdr[1]&0x80 == 0 {
+		return 0, nil, errors.New("websocket: client frame is not masked")
+	}
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	if op >= opClose {
+		if n > maxControl {
+			return 0, nil, errors.New("websocket: control frame too long")
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+		return op, payload, nil
+	}
+	// data from the client is not used
+	_, err := io.CopyN(ioutil.Discard, c.br, int64(n))
+	return op, nil, err
+}
+
+// writeFrame writes one unmasked, unfragmented frame
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	var hdr []byte
+	n := len(payload)
+	switch {
+	case n < 126:
+		hdr = []byte{0x80 | op, byte(n)}
+	case n <= 0xFFFF:
+		hdr = []byte{0x80 | op, 126, 0, 0}
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr = make([]byte, 10)
+		hdr[0], hdr[1] = 0x80|op, 127
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	if _, err := c.conn.Write(append(hdr, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteText sends b to the client as a text message
+func (c *Conn) WriteText(b []byte) error {
+	return c.writeFrame(opText, b)
+}
+
+// WriteJSON sends v to the client as a JSON text message
+func (c *Conn) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteText(b)
+}
+
+// Close sends a close frame and closes the connection
+func (c *Conn) Close() error {
+	c.writeFrame(opClose, nil)
+	c.shutdown()
+	return nil
+}
+
+func (c *Conn) shutdown() {
+	c.once.Do(func() {
+		c.wmu.Lock()
+		close(c.done)
+		c.wmu.Unlock()
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// the example from RFC 6455 section 1.3
+	if got := accept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("wrong accept key %q", got)
+	}
+}
+
+func TestSendAndClientClose(t *testing.T) {
+	closed := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.WriteText([]byte("hello"))
+		select {
+		case <-conn.Done():
+			closed <- true
+		case <-time.After(time.Second):
+			closed <- false
+		}
+	}))
+	defer srv.Close()
+
+	c, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	req := "GET / HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	c.Write([]byte(req))
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	frame := make([]byte, 7)
+	if _, err = io.ReadFull(br, frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame[0] != 0x81 || frame[1] != 5 || string(frame[2:]) != "hello" {
+		t.Errorf("expected a text frame of hello, got % x", frame)
+	}
+	// a masked close frame with an empty payload
+	c.Write([]byte{0x88, 0x80, 1, 2, 3, 4})
+	if !<-closed {
+		t.Error("expected the connection to close when the client sent close")
+	}
+}
@@ -1,11 +1,14 @@
 package thorlabs
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/nasa-jpl/golaborate/generichttp/laser"
 	"github.com/nasa-jpl/golaborate/usbtmc"
 )
 
@@ -33,17 +36,23 @@ func (e LDCError) Error() string {
 	return fmt.Sprintf("%d - UNKNOWN ERROR CODE", e.code)
 }
 
+// bus is the USBTMC connection to the device
+type bus interface {
+	Write([]byte) error
+	Read() (usbtmc.BulkInResponse, error)
+}
+
 // ITC4000 represents an ITC4000 laser diode and TEC controller
 type ITC4000 struct {
 	sync.Mutex
 
-	dev usbtmc.USBDevice
+	dev bus
 }
 
 // NewITC4000 creates a new ITC4000 instance absorbing the first one seen on the USB[us]
 func NewITC4000() (*ITC4000, error) {
 	d, err := usbtmc.NewUSBDevice(TLVID, LDC4001PID)
-	return &ITC4000{dev: d}, err
+	return &ITC4000{dev: &d}, err
 }
 
 var (
@@ -119,6 +128,11 @@ var (
 func (ldc *ITC4000) writeReadBus(cmd string) (string, error) {
 	ldc.Lock()
 	defer ldc.Unlock()
+	return ldc.query(cmd)
+}
+
+// query is writeReadBus for callers which already hold the lock
+func (ldc *ITC4000) query(cmd string) (string, error) {
 	err := ldc.dev.Write(append([]byte(cmd), '\n'))
 	if err != nil {
 		return "", err
@@ -206,6 +220,180 @@ func (ldc *ITC4000) GetCurrent() (float64, error) {
 	return f * 1e3, err
 }
 
+// ErrNoPhotodiode is returned when a photodiode reading is asked for and the
+// ITC4000 has none to give, most often because no photodiode is connected
+var ErrNoPhotodiode = errors.New("thorlabs/itc4000: no photodiode reading is available, is a photodiode connected?")
+
+// scpiNaN is the value SCPI instruments return for a measurement they cannot
+// make
+const scpiNaN = 9.91e37
+
+// popError reads the oldest error from the error queue, nil if there is none
+func (ldc *ITC4000) popError() error {
+	ldc.Lock()
+	defer ldc.Unlock()
+	return ldc.readError()
+}
+
+// readError is popError for callers which already hold the lock
+func (ldc *ITC4000) readError() error {
+	resp, err := ldc.query("SYSTEM:ERROR?")
+	if err != nil {
+		return err
+	}
+	// -241,"Hardware missing"
+	code, err := strconv.Atoi(strings.SplitN(resp, ",", 2)[0])
+	if err != nil {
+		return fmt.Errorf("thorlabs/itc4000: unable to parse error %q: %w", resp, err)
+	}
+	if code == 0 {
+		return nil
+	}
+	return LDCError{code: code}
+}
+
+// measurePhotodiode sends a photodiode measurement query and parses the reply,
+// returning ErrNoPhotodiode if the device could not make the measurement.  The
+// bus is held until the error queue is read, so that the error read is the
+// one the measurement caused
+func (ldc *ITC4000) measurePhotodiode(cmd string) (float64, error) {
+	ldc.Lock()
+	defer ldc.Unlock()
+	resp, err := ldc.query(cmd)
+	if err != nil {
+		return 0, err
+	}
+	f, parseErr := strconv.ParseFloat(resp, 64)
+	if err = ldc.readError(); err != nil {
+		var lerr LDCError
+		if errors.As(err, &lerr) && (lerr.code == -241 || lerr.code == -230) {
+			return 0, ErrNoPhotodiode
+		}
+		return 0, err
+	}
+	if parseErr != nil {
+		return 0, parseErr
+	}
+	if f >= scpiNaN {
+		return 0, ErrNoPhotodiode
+	}
+	return f, nil
+}
+
+// GetOpticalPower gets the optical power measured by the photodiode, in
+// watts.  The photodiode responsivity must be configured on the device
+func (ldc *ITC4000) GetOpticalPower() (float64, error) {
+	return ldc.measurePhotodiode("MEASURE:POWER2?")
+}
+
+// GetMonitorCurrent gets the current of the monitor photodiode in mA
+func (ldc *ITC4000) GetMonitorCurrent() (float64, error) {
+	f, err := ldc.measurePhotodiode("MEASURE:CURRENT2?")
+	return f * 1e3, err
+}
+
+// ITC4000ModulationModes are the modulation modes of the ITC4000 and the
+// sources of SOURCE:AM they select.  Internal and external modulation may be
+// combined
+var ITC4000ModulationModes = map[string]string{
+	"off":               "",
+	"internal":          "INT",
+	"external":          "EXT",
+	"internal+external": "INT,EXT",
+}
+
+// modulationModeNames returns the names of the modulation modes, sorted
+func modulationModeNames() []string {
+	names := make([]string, 0, len(ITC4000ModulationModes))
+	for k := range ITC4000ModulationModes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeChecked sends a command and returns the error it caused, if any.  The
+// bus is held until the error queue is read, so that another command's error
+// is not taken for this one's
+func (ldc *ITC4000) writeChecked(cmd string) error {
+	ldc.Lock()
+	defer ldc.Unlock()
+	err := ldc.writeOnlyBus(cmd)
+	if err != nil {
+		return err
+	}
+	return ldc.readError()
+}
+
+// SetModulationMode sets the modulation mode, one of the keys of
+// ITC4000ModulationModes.  "off" disables modulation
+func (ldc *ITC4000) SetModulationMode(mode string) error {
+	src, ok := ITC4000ModulationModes[strings.ToLower(mode)]
+	if !ok {
+		return fmt.Errorf("thorlabs/itc4000: modulation mode %q is not one of %s", mode, strings.Join(modulationModeNames(), ", "))
+	}
+	if src == "" {
+		return ldc.writeChecked("SOURCE:AM:STATE OFF")
+	}
+	if err := ldc.writeChecked("SOURCE:AM:SOURCE " + src); err != nil {
+		return err
+	}
+	return ldc.writeChecked("SOURCE:AM:STATE ON")
+}
+
+// SetModulationFrequency sets the frequency of the internal modulation in Hz
+func (ldc *ITC4000) SetModulationFrequency(f float64) error {
+	if f <= 0 {
+		return fmt.Errorf("thorlabs/itc4000: modulation frequency %g Hz must be positive", f)
+	}
+	return ldc.writeChecked(fmt.Sprintf("SOURCE:AM:INTERNAL:FREQUENCY %.9g", f))
+}
+
+// SetModulationDepth sets the depth of the internal modulation in percent
+func (ldc *ITC4000) SetModulationDepth(d float64) error {
+	if d < 0 || d > 100 {
+		return fmt.Errorf("thorlabs/itc4000: modulation depth %g%% must be between 0 and 100", d)
+	}
+	return ldc.writeChecked(fmt.Sprintf("SOURCE:AM:INTERNAL:DEPTH %.9g", d))
+}
+
+// GetModulation gets the modulation mode, frequency, and depth
+func (ldc *ITC4000) GetModulation() (laser.Modulation, error) {
+	var m laser.Modulation
+	state, err := ldc.writeReadBus("SOURCE:AM:STATE?")
+	if err != nil {
+		return m, err
+	}
+	m.Mode = "off"
+	if state == "1" {
+		src, err := ldc.writeReadBus("SOURCE:AM:SOURCE?")
+		if err != nil {
+			return m, err
+		}
+		// the device abbreviates, "INT,EXT" or "EXT"
+		src = strings.ToUpper(strings.ReplaceAll(src, " ", ""))
+		m.Mode = src
+		for k, v := range ITC4000ModulationModes {
+			if v != "" && v == src {
+				m.Mode = k
+			}
+		}
+	}
+	resp, err := ldc.writeReadBus("SOURCE:AM:INTERNAL:FREQUENCY?")
+	if err != nil {
+		return m, err
+	}
+	if m.Frequency, err = strconv.ParseFloat(resp, 64); err != nil {
+		return m, err
+	}
+	resp, err = ldc.writeReadBus("SOURCE:AM:INTERNAL:DEPTH?")
+	if err != nil {
+		return m, err
+	}
+	m.Depth, err = strconv.ParseFloat(resp, 64)
+	return m, err
+}
+
 // Raw sends a command and retrieves the reply if there is a question mark in the command, else returns "", err
 func (ldc *ITC4000) Raw(cmd string) (string, error) {
 	if !strings.Contains(cmd, "?") {
@@ -0,0 +1,139 @@
+package thorlabs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/usbtmc"
+)
+
+// fakeBus is an ITC4000 which queues error -113 for the command BAD and
+// answers every measurement with 0.5.  onWrite, if not nil, is called once,
+// with the first command written.  unlocked is set if the bus is used without
+// holding the lock of ldc
+type fakeBus struct {
+	ldc *ITC4000
+
+	mu       sync.Mutex
+	cmds     []string
+	errs     []int
+	reply    string
+	onWrite  func(cmd string)
+	unlocked bool
+}
+
+func newFakeBus() (*fakeBus, *ITC4000) {
+	f := &fakeBus{}
+	f.ldc = &ITC4000{dev: f}
+	return f, f.ldc
+}
+
+// checkLocked notes if the bus is in use without the lock.  f.mu must be held
+func (f *fakeBus) checkLocked() {
+	if f.ldc.TryLock() {
+		f.ldc.Unlock()
+		f.unlocked = true
+	}
+}
+
+func (f *fakeBus) Write(b []byte) error {
+	cmd := strings.TrimSpace(string(b))
+	f.mu.Lock()
+	f.checkLocked()
+	f.cmds = append(f.cmds, cmd)
+	switch {
+	case cmd == "SYSTEM:ERROR?":
+		code := 0
+		if len(f.errs) > 0 {
+			code, f.errs = f.errs[0], f.errs[1:]
+		}
+		f.reply = fmt.Sprintf("%d,\"error\"", code)
+	case cmd == "BAD":
+		f.errs = append(f.errs, -113)
+	case strings.HasPrefix(cmd, "MEASURE:"):
+		f.reply = "0.5"
+	}
+	hook := f.onWrite
+	f.onWrite = nil
+	f.mu.Unlock()
+	if hook != nil {
+		hook(cmd)
+	}
+	return nil
+}
+
+func (f *fakeBus) Read() (usbtmc.BulkInResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checkLocked()
+	return usbtmc.BulkInResponse{Data: []byte(f.reply + "\n")}, nil
+}
+
+// check fails t if the bus was used without the lock, or the commands sent
+// were not want, joined by commas
+func (f *fakeBus) check(t *testing.T, want string) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unlocked {
+		t.Error("the bus was used without holding the lock")
+	}
+	if got := strings.Join(f.cmds, ", "); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+// interrupt starts fn while the first command written to f is in flight, and
+// returns the error of fn when it is done
+func interrupt(f *fakeBus, fn func() error) <-chan error {
+	done := make(chan error, 1)
+	f.onWrite = func(string) {
+		go func() { done <- fn() }()
+		time.Sleep(20 * time.Millisecond)
+	}
+	return done
+}
+
+func TestMeasurePhotodiodeReadsItsOwnError(t *testing.T) {
+	f, ldc := newFakeBus()
+	done := interrupt(f, func() error {
+		ldc.Lock()
+		defer ldc.Unlock()
+		return ldc.dev.Write([]byte("BAD\n"))
+	})
+	p, err := ldc.GetOpticalPower()
+	if err != nil || p != 0.5 {
+		t.Errorf("expected 0.5 W and no error, got %g, %v", p, err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	f.check(t, "MEASURE:POWER2?, SYSTEM:ERROR?, BAD")
+	var lerr LDCError
+	if err = ldc.popError(); !errors.As(err, &lerr) || lerr.code != -113 {
+		t.Errorf("expected the error of BAD to be left for its sender, got %v", err)
+	}
+}
+
+func TestWriteCheckedReadsItsOwnError(t *testing.T) {
+	f, ldc := newFakeBus()
+	var p float64
+	done := interrupt(f, func() error {
+		var err error
+		p, err = ldc.GetOpticalPower()
+		return err
+	})
+	err := ldc.writeChecked("BAD")
+	var lerr LDCError
+	if !errors.As(err, &lerr) || lerr.code != -113 {
+		t.Errorf("expected error -113 from BAD, got %v", err)
+	}
+	if err = <-done; err != nil || p != 0.5 {
+		t.Errorf("expected the measurement to succeed, got %g, %v", p, err)
+	}
+	f.check(t, "BAD, SYSTEM:ERROR?, MEASURE:POWER2?, SYSTEM:ERROR?")
+}
@@ -82,6 +82,67 @@ func ArangeByte(startEnd byte, endStep ...byte) []byte {
 	return s
 }
 
+/*Arange replicates np.arange for float64 slices
+
+if one argument is given, it is the end value and start = 0, step = 1
+
+if two arguments are given, they are start, end and step is 1.
+
+if three arguments are given, they are start, end, step
+
+The end is excluded.  Each value is computed as start + i*step rather than
+by accumulation, so error does not build up over long ranges, and an end
+which is within floating point error of a multiple of step is not included.
+A step of zero, one with the wrong sign, or no arguments produce an empty slice.
+*/
+func Arange(args ...float64) []float64 {
+	var start, end, step float64
+	switch len(args) {
+	case 0:
+		return []float64{}
+	case 1:
+		start, end, step = 0, args[0], 1
+	case 2:
+		start, end, step = args[0], args[1], 1
+	default:
+		start, end, step = args[0], args[1], args[2]
+	}
+	if step == 0 {
+		return []float64{}
+	}
+	// the tolerance keeps e.g. Arange(0, 0.3, 0.1) from including 0.3,
+	// as 0.3/0.1 = 2.9999999999999996
+	n := int(math.Ceil((end-start)/step - 1e-10))
+	if n <= 0 {
+		return []float64{}
+	}
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = start + float64(i)*step
+	}
+	return s
+}
+
+// Linspace replicates np.linspace, returning n evenly spaced values from
+// start to stop, inclusive.  The last value is exactly stop.  n == 1 returns
+// []float64{start} and n <= 0 an empty slice
+func Linspace(start, stop float64, n int) []float64 {
+	if n <= 0 {
+		return []float64{}
+	}
+	s := make([]float64, n)
+	s[0] = start
+	if n == 1 {
+		return s
+	}
+	step := (stop - start) / float64(n-1)
+	for i := 1; i < n-1; i++ {
+		s[i] = start + float64(i)*step
+	}
+	s[n-1] = stop
+	return s
+}
+
 // UniqueString reduces a slice of strings to the unique values
 func UniqueString(slice []string) []string {
 	var out []string
@@ -137,6 +198,11 @@ func (l *Limiter) Clamp(input float64) float64 {
 	return Clamp(input, l.Min, l.Max)
 }
 
+// Contains returns true if min <= input <= max
+func (l *Limiter) Contains(input float64) bool {
+	return input >= l.Min && input <= l.Max
+}
+
 // Check verifies if min < input < max, returns true if this is the case
 func (l *Limiter) Check(input float64) bool {
 	if input < l.Min {
@@ -8,28 +8,63 @@ import (
 	"github.com/nasa-jpl/golaborate/util"
 )
 
-func ExampleArangeByte_EndOnly() {
+func ExampleArangeByte_endOnly() {
 	fmt.Println(util.ArangeByte(10))
 	// Output: [0 1 2 3 4 5 6 7 8 9]
 }
 
-func ExampleArangeByte_StartEnd() {
+func ExampleArangeByte_startEnd() {
 	fmt.Println(util.ArangeByte(5, 15))
 	// Output: [5 6 7 8 9 10 11 12 13 14]
 }
 
-func ExampleArangeByte_StartEndStep() {
+func ExampleArangeByte_startEndStep() {
 	fmt.Println(util.ArangeByte(10, 22, 2))
 	// Output: [10 12 14 16 18 20]
 }
 
-func ExampleSetBit_MSB() {
+func ExampleArange_endOnly() {
+	fmt.Println(util.Arange(5))
+	// Output: [0 1 2 3 4]
+}
+
+func ExampleArange_startEnd() {
+	fmt.Println(util.Arange(1.5, 5))
+	// Output: [1.5 2.5 3.5 4.5]
+}
+
+func ExampleArange_startEndStep() {
+	fmt.Println(util.Arange(0, 2, 0.75))
+	// Output: [0 0.75 1.5]
+}
+
+func ExampleArange_descending() {
+	fmt.Println(util.Arange(10, 0, -2.5))
+	// Output: [10 7.5 5 2.5]
+}
+
+func ExampleLinspace() {
+	fmt.Println(util.Linspace(400, 700, 4))
+	// Output: [400 500 600 700]
+}
+
+func ExampleLinspace_one() {
+	fmt.Println(util.Linspace(3, 7, 1))
+	// Output: [3]
+}
+
+func ExampleLinspace_none() {
+	fmt.Println(util.Linspace(3, 7, 0))
+	// Output: []
+}
+
+func ExampleSetBit_msb() {
 	out := util.SetBit(0, 7, true)
 	fmt.Printf("%08b\n", out)
 	// Output: 10000000
 }
 
-func ExampleSetBit_LSB() {
+func ExampleSetBit_lsb() {
 	out := util.SetBit(255, 0, false)
 	fmt.Printf("%08b\n", out)
 	// Output: 11111110
@@ -50,6 +85,21 @@ func TestArangeByteForward(t *testing.T) {
 	}
 }
 
+func TestArangeEndpointFloatingError(t *testing.T) {
+	// 0.3/0.1 is not exactly 3, which must not add a fourth element
+	out := util.Arange(0, 0.3, 0.1)
+	if len(out) != 3 {
+		t.Errorf("expected 3 elements, got %v", out)
+	}
+}
+
+func TestLinspaceHitsStopExactly(t *testing.T) {
+	out := util.Linspace(0, 0.3, 7)
+	if len(out) != 7 || out[6] != 0.3 {
+		t.Errorf("expected 7 elements ending in exactly 0.3, got %v", out)
+	}
+}
+
 func TestUniqueString(t *testing.T) {
 	inp := []string{"a", "b", "c", "a"}
 	expected := []string{"a", "b", "c"}
@@ -102,3 +152,24 @@ func TestSecsToDuration(t *testing.T) {
 		t.Errorf("expected SecsToDuration to round trip, output %v != expected %v", out, dur)
 	}
 }
+
+func TestLimiterContains(t *testing.T) {
+	l := util.Limiter{Min: -1, Max: 1}
+	for _, tc := range []struct {
+		in   float64
+		want bool
+	}{{-2, false}, {-1, true}, {0, true}, {1, true}, {1.0001, false}} {
+		if got := l.Contains(tc.in); got != tc.want {
+			t.Errorf("Contains(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestLimiterClamp(t *testing.T) {
+	l := util.Limiter{Min: -1, Max: 1}
+	for _, tc := range []struct{ in, want float64 }{{-2, -1}, {0.5, 0.5}, {3, 1}} {
+		if got := l.Clamp(tc.in); got != tc.want {
+			t.Errorf("Clamp(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}